@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 
 	"gopkg.in/validator.v2"
 )
 
+type OverlayNetwork struct {
+	Network            string `json:"network"`
+	SubnetPrefixLength int    `json:"subnet_prefix_length"`
+}
+
 type Config struct {
 	UnderlayIP                string `json:"underlay_ip" validate:"nonzero"`
 	VxlanInterfaceName        string `json:"vxlan_interface_name"`
@@ -29,6 +35,16 @@ type Config struct {
 	MetronPort                int    `json:"metron_port" validate:"min=1"`
 	LogPrefix                 string `json:"log_prefix" validate:"nonzero"`
 	SingleIPOnly              bool   `json:"single_ip_only"`
+	WatchLeases               bool   `json:"watch_leases"`
+	RepairDrift               bool   `json:"repair_drift"`
+	CellID                    string `json:"cell_id"`
+	AZ                        string `json:"az"`
+
+	// Labels are stored with the lease, along with CellID and AZ, to tell
+	// which instance holds it.
+	Labels map[string]string `json:"labels"`
+
+	AdditionalOverlayNetworks []OverlayNetwork `json:"additional_overlay_networks"`
 }
 
 func LoadConfig(filePath string) (Config, error) {
@@ -46,5 +62,31 @@ func LoadConfig(filePath string) (Config, error) {
 	if err := validator.Validate(cfg); err != nil {
 		return cfg, fmt.Errorf("invalid config: %s", err)
 	}
+	for _, network := range cfg.AdditionalOverlayNetworks {
+		if _, _, err := net.ParseCIDR(network.Network); err != nil {
+			return cfg, fmt.Errorf("invalid config: AdditionalOverlayNetworks: %q is not a cidr", network.Network)
+		}
+		if network.SubnetPrefixLength == 0 {
+			return cfg, fmt.Errorf("invalid config: AdditionalOverlayNetworks: missing subnet prefix length for %s", network.Network)
+		}
+	}
 	return cfg, nil
 }
+
+// OverlayNetworks returns the overlay network followed by the additional
+// overlay networks.
+func (c Config) OverlayNetworks() []OverlayNetwork {
+	networks := []OverlayNetwork{{Network: c.OverlayNetwork, SubnetPrefixLength: c.SubnetPrefixLength}}
+	return append(networks, c.AdditionalOverlayNetworks...)
+}
+
+// OverlayNetworkFor returns the overlay network holding the ip.
+func (c Config) OverlayNetworkFor(ip net.IP) (OverlayNetwork, bool) {
+	for _, network := range c.OverlayNetworks() {
+		_, ipNet, err := net.ParseCIDR(network.Network)
+		if err == nil && ipNet.Contains(ip) {
+			return network, true
+		}
+	}
+	return OverlayNetwork{}, false
+}
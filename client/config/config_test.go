@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 
 	"code.cloudfoundry.org/silk/client/config"
@@ -92,6 +93,58 @@ var _ = Describe("Config.LoadConfig", func() {
 		})
 	})
 
+	Context("when watch leases is specified", func() {
+		It("sets WatchLeases", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["watch_leases"] = true
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.WatchLeases).To(BeTrue())
+		})
+	})
+
+	Context("when repair drift is specified", func() {
+		It("sets RepairDrift", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["repair_drift"] = true
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.RepairDrift).To(BeTrue())
+		})
+	})
+
+	Context("when lease metadata is specified", func() {
+		It("sets CellID, AZ and Labels", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["cell_id"] = "diego-cell/0"
+			cfg["az"] = "z1"
+			cfg["labels"] = map[string]string{"rack": "r3"}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.CellID).To(Equal("diego-cell/0"))
+			Expect(loadedConfig.AZ).To(Equal("z1"))
+			Expect(loadedConfig.Labels).To(Equal(map[string]string{"rack": "r3"}))
+		})
+	})
+
 	Context("when vxlan_interface_name is specified", func() {
 		It("sets VxlanInterfaceName", func() {
 			cfg := cloneMap(requiredFields)
@@ -107,4 +160,72 @@ var _ = Describe("Config.LoadConfig", func() {
 			Expect(loadedConfig.VxlanInterfaceName).To(Equal("something"))
 		})
 	})
+
+	Context("when additional overlay networks are specified", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["additional_overlay_networks"] = []map[string]interface{}{
+				{"network": "10.254.0.0/16", "subnet_prefix_length": 26},
+			}
+		})
+
+		It("returns them after the overlay network", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.OverlayNetworks()).To(Equal([]config.OverlayNetwork{
+				{Network: "10.255.0.0/16", SubnetPrefixLength: 24},
+				{Network: "10.254.0.0/16", SubnetPrefixLength: 26},
+			}))
+
+			network, ok := loadedConfig.OverlayNetworkFor(net.ParseIP("10.254.3.64"))
+			Expect(ok).To(BeTrue())
+			Expect(network).To(Equal(config.OverlayNetwork{Network: "10.254.0.0/16", SubnetPrefixLength: 26}))
+
+			_, ok = loadedConfig.OverlayNetworkFor(net.ParseIP("10.253.3.64"))
+			Expect(ok).To(BeFalse())
+		})
+
+		Context("when an additional overlay network is not a cidr", func() {
+			BeforeEach(func() {
+				cfg["additional_overlay_networks"] = []map[string]interface{}{
+					{"network": "banana", "subnet_prefix_length": 26},
+				}
+			})
+
+			It("returns an error", func() {
+				file, err := ioutil.TempFile(os.TempDir(), "config-")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+				_, err = config.LoadConfig(file.Name())
+				Expect(err).To(MatchError(`invalid config: AdditionalOverlayNetworks: "banana" is not a cidr`))
+			})
+		})
+
+		Context("when an additional overlay network has no subnet prefix length", func() {
+			BeforeEach(func() {
+				cfg["additional_overlay_networks"] = []map[string]interface{}{
+					{"network": "10.254.0.0/16"},
+				}
+			})
+
+			It("returns an error", func() {
+				file, err := ioutil.TempFile(os.TempDir(), "config-")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+				_, err = config.LoadConfig(file.Name())
+				Expect(err).To(MatchError("invalid config: AdditionalOverlayNetworks: missing subnet prefix length for 10.254.0.0/16"))
+			})
+		})
+	})
 })
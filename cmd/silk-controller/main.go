@@ -18,9 +18,12 @@ import (
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagerflags"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/admin"
 	"code.cloudfoundry.org/silk/controller/config"
 	"code.cloudfoundry.org/silk/controller/database"
 	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/leader"
 	"code.cloudfoundry.org/silk/controller/leaser"
 	"code.cloudfoundry.org/silk/controller/server_metrics"
 	"github.com/cloudfoundry/dropsonde"
@@ -69,30 +72,63 @@ func mainWithError() error {
 		return fmt.Errorf("mutual tls config: %s", err)
 	}
 
-	connectionPool, err := db.NewConnectionPool(
-		conf.Database,
-		conf.MaxOpenConnections,
-		conf.MaxIdleConnections,
-		time.Duration(conf.MaxConnectionsLifetimeSeconds)*time.Second,
-		logPrefix,
-		jobPrefix,
-		logger,
-	)
+	var dbConnection database.Db
+	var connectionPool *db.ConnWrapper
+	if conf.Database.Type == database.SQLite {
+		dbConnection, err = database.NewSQLiteConnection(conf.Database.DatabaseName)
+	} else {
+		connectionPool, err = db.NewConnectionPool(
+			conf.Database,
+			conf.MaxOpenConnections,
+			conf.MaxIdleConnections,
+			time.Duration(conf.MaxConnectionsLifetimeSeconds)*time.Second,
+			logPrefix,
+			jobPrefix,
+			logger,
+		)
+		dbConnection = connectionPool
+	}
 	if err != nil {
 		return fmt.Errorf("connecting to database: %s", err)
 	}
 
-	databaseHandler := database.NewDatabaseHandler(&database.MigrateAdapter{}, connectionPool)
-	cidrPool := leaser.NewCIDRPool(conf.Network, conf.SubnetPrefixLength)
+	databaseHandler := database.NewDatabaseHandler(&database.MigrateAdapter{}, dbConnection)
+	if flag.Arg(0) == "migrate" {
+		return runMigrateCommand(databaseHandler, flag.Args()[1:], os.Stdout)
+	}
+
+	allocationStrategy, err := leaser.NewAllocationStrategy(conf.AllocationStrategy)
+	if err != nil {
+		return fmt.Errorf("allocation strategy: %s", err)
+	}
+	cidrPool := leaser.NewCIDRPoolForNetworks(conf.Networks(), conf.ExcludedRanges...)
+	cidrPool.SetAllocationStrategy(allocationStrategy)
 	leaseController := &leaser.LeaseController{
 		DatabaseHandler:            databaseHandler,
-		HardwareAddressGenerator:   &leaser.HardwareAddressGenerator{},
+		HardwareAddressGenerator:   &leaser.HardwareAddressGenerator{IPv6Network: conf.IPv6Network, IPv6SubnetPrefixLength: conf.IPv6SubnetPrefixLength},
 		LeaseValidator:             &leaser.LeaseValidator{},
 		AcquireSubnetLeaseAttempts: 10,
 		CIDRPool:                   cidrPool,
 		LeaseExpirationSeconds:     conf.LeaseExpirationSeconds,
+		ReclaimQuarantineSeconds:   conf.ReclaimQuarantineSeconds,
+		AllocationStrategy:         allocationStrategy,
 		Logger:                     logger,
 	}
+	if len(conf.AZSubnetRanges) > 0 {
+		leaseController.AZPools = leaser.NewAZPools(cidrPool, conf.AZSubnetRanges, conf.AZRangeFallback)
+	}
+	if conf.IPv6Network != "" {
+		ipv6CIDRPool := leaser.NewCIDRPool(conf.IPv6Network, conf.IPv6SubnetPrefixLength, conf.ExcludedRanges...)
+		ipv6CIDRPool.SetAllocationStrategy(allocationStrategy)
+		leaseController.IPv6CIDRPool = ipv6CIDRPool
+		if len(conf.AZSubnetRanges) > 0 {
+			leaseController.IPv6AZPools = leaser.NewAZPools(ipv6CIDRPool, conf.AZSubnetRanges, conf.AZRangeFallback)
+		}
+	}
+	leaseController.StaticReservations, err = leaser.NewStaticReservations(conf.StaticReservations, leaseController.CIDRPool, leaseController.IPv6CIDRPool)
+	if err != nil {
+		return fmt.Errorf("static reservations: %s", err)
+	}
 	migrator := &database.Migrator{
 		DatabaseMigrator:              databaseHandler,
 		MaxMigrationAttempts:          5,
@@ -103,6 +139,24 @@ func mainWithError() error {
 		return fmt.Errorf("migrating database: %s", err)
 	}
 
+	var leaseCache *leaser.LeaseCache
+	if conf.LeaseCacheRefreshSeconds > 0 {
+		leaseCache = &leaser.LeaseCache{
+			DatabaseHandler: databaseHandler,
+			RefreshInterval: time.Duration(conf.LeaseCacheRefreshSeconds) * time.Second,
+			Logger:          logger.Session("lease-cache"),
+		}
+		leaseController.LeaseCache = leaseCache
+	}
+
+	excludedLeases, err := leaseController.LeasesInExcludedRanges()
+	if err != nil {
+		return fmt.Errorf("checking excluded ranges: %s", err)
+	}
+	for _, lease := range excludedLeases {
+		logger.Error("lease-in-excluded-range", nil, lager.Data{"lease": lease})
+	}
+
 	metricsSender := &metrics.MetricsSender{
 		Logger: logger.Session("time-metric-emitter"),
 	}
@@ -111,10 +165,41 @@ func mainWithError() error {
 		MetricsSender: metricsSender,
 	}
 
+	revisionWatcher := &leaser.RevisionWatcher{
+		RevisionRepository: databaseHandler,
+		PollInterval:       time.Second,
+		Logger:             logger.Session("revision-watcher"),
+	}
+	if leaseCache != nil {
+		revisionWatcher.LeaseCache = leaseCache
+	}
+
 	leasesIndex := &handlers.LeasesIndex{
 		Marshaler:       marshal.MarshalFunc(json.Marshal),
 		LeaseRepository: leaseController,
+		RevisionWatcher: revisionWatcher,
+		ErrorResponse:   errorResponse,
+		SnapshotCount:   16,
+	}
+
+	leasesWatch := &handlers.LeasesWatch{
+		Marshaler:       marshal.MarshalFunc(json.Marshal),
+		RevisionWatcher: revisionWatcher,
+		LeaseRepository: leaseController,
 		ErrorResponse:   errorResponse,
+		MaxTimeout:      30 * time.Second,
+	}
+
+	leasesHistory := &handlers.LeasesHistory{
+		Marshaler:              marshal.MarshalFunc(json.Marshal),
+		LeaseHistoryRepository: leaseController,
+		ErrorResponse:          errorResponse,
+	}
+
+	leasesLookup := &handlers.LeasesLookup{
+		Marshaler:             marshal.MarshalFunc(json.Marshal),
+		LeaseLookupRepository: leaseController,
+		ErrorResponse:         errorResponse,
 	}
 
 	leasesAcquire := &handlers.LeasesAcquire{
@@ -155,12 +240,18 @@ func mainWithError() error {
 	router, err := rata.NewRouter(
 		rata.Routes{
 			{Name: "leases-index", Method: "GET", Path: "/leases"},
+			{Name: "leases-history", Method: "GET", Path: "/leases/history"},
+			{Name: "leases-lookup", Method: "GET", Path: "/leases/lookup"},
+			{Name: "leases-watch", Method: "GET", Path: "/leases/watch"},
 			{Name: "leases-acquire", Method: "PUT", Path: "/leases/acquire"},
 			{Name: "leases-release", Method: "PUT", Path: "/leases/release"},
 			{Name: "leases-renew", Method: "PUT", Path: "/leases/renew"},
 		},
 		rata.Handlers{
 			"leases-index":   metricsWrap("LeasesIndex", logWrap(leasesIndex)),
+			"leases-history": metricsWrap("LeasesHistory", logWrap(leasesHistory)),
+			"leases-lookup":  metricsWrap("LeasesLookup", logWrap(leasesLookup)),
+			"leases-watch":   metricsWrap("LeasesWatch", logWrap(leasesWatch)),
 			"leases-acquire": metricsWrap("LeasesAcquire", logWrap(leasesAcquire)),
 			"leases-release": metricsWrap("LeasesRelease", logWrap(leasesRelease)),
 			"leases-renew":   metricsWrap("LeasesRenew", logWrap(leasesRenew)),
@@ -170,6 +261,54 @@ func mainWithError() error {
 		return fmt.Errorf("creating router: %s", err)
 	}
 
+	adminLeasesIndex := &handlers.AdminLeasesIndex{
+		Marshaler:             marshal.MarshalFunc(json.Marshal),
+		LeaseRecordRepository: leaseController,
+		ErrorResponse:         errorResponse,
+	}
+
+	adminLeasesRelease := &handlers.AdminLeasesRelease{
+		LeaseReleaser: leaseController,
+		ErrorResponse: errorResponse,
+	}
+
+	reservationsIndex := &handlers.ReservationsIndex{
+		Marshaler:             marshal.MarshalFunc(json.Marshal),
+		ReservationRepository: leaseController,
+		ErrorResponse:         errorResponse,
+	}
+
+	reservationsCreate := &handlers.ReservationsCreate{
+		Unmarshaler:    marshal.UnmarshalFunc(json.Unmarshal),
+		SubnetReserver: leaseController,
+		ErrorResponse:  errorResponse,
+	}
+
+	reservationsDelete := &handlers.ReservationsDelete{
+		SubnetUnreserver: leaseController,
+		ErrorResponse:    errorResponse,
+	}
+
+	adminRouter, err := rata.NewRouter(
+		rata.Routes{
+			{Name: "admin-leases-index", Method: "GET", Path: "/leases"},
+			{Name: "admin-leases-release", Method: "DELETE", Path: "/leases"},
+			{Name: "reservations-index", Method: "GET", Path: "/reservations"},
+			{Name: "reservations-create", Method: "PUT", Path: "/reservations"},
+			{Name: "reservations-delete", Method: "DELETE", Path: "/reservations"},
+		},
+		rata.Handlers{
+			"admin-leases-index":   metricsWrap("AdminLeasesIndex", logWrap(adminLeasesIndex)),
+			"admin-leases-release": metricsWrap("AdminLeasesRelease", logWrap(adminLeasesRelease)),
+			"reservations-index":   metricsWrap("ReservationsIndex", logWrap(reservationsIndex)),
+			"reservations-create":  metricsWrap("ReservationsCreate", logWrap(reservationsCreate)),
+			"reservations-delete":  metricsWrap("ReservationsDelete", logWrap(reservationsDelete)),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("creating admin router: %s", err)
+	}
+
 	health := &handlers.Health{
 		DatabaseChecker: databaseHandler,
 		ErrorResponse:   errorResponse,
@@ -198,19 +337,85 @@ func mainWithError() error {
 	healthServer := http_server.New(healthServerAddress, healthRouter)
 
 	// Metrics sources
+	var leaseLister activeLeaseLister = databaseHandler
+	if leaseCache != nil {
+		leaseLister = leaseCache
+	}
+	freeLeasesSource := server_metrics.NewFreeLeasesSource(leaseLister, cidrPool)
+	if leaseController.IPv6CIDRPool != nil {
+		freeLeasesSource = server_metrics.NewFreeLeasesSource(leaseLister, cidrPool, leaseController.IPv6CIDRPool)
+	}
 	metricSources := []metrics.MetricSource{
 		metrics.NewUptimeSource(),
-		server_metrics.NewTotalLeasesSource(databaseHandler),
-		server_metrics.NewFreeLeasesSource(databaseHandler, cidrPool),
-		server_metrics.NewStaleLeasesSource(databaseHandler, conf.StalenessThresholdSeconds),
+		server_metrics.NewTotalLeasesSource(leaseLister),
+		freeLeasesSource,
+		server_metrics.NewStaleLeasesSource(leaseLister, conf.StalenessThresholdSeconds),
+		server_metrics.NewExcludedLeasesSource(leaseController),
+		server_metrics.NewQuarantinedLeasesSource(leaseController),
+	}
+	metricSources = append(metricSources, azFreeLeasesSources(leaseLister, leaseController.AZPools, leaseController.IPv6AZPools)...)
+	if connectionPool != nil {
+		metricSources = append(metricSources, metrics.NewDBMonitorSource(connectionPool, connectionPool.Monitor)...)
 	}
-	metricSources = append(metricSources, metrics.NewDBMonitorSource(connectionPool, connectionPool.Monitor)...)
 	metricsEmitter := metrics.NewMetricsEmitter(logger, time.Duration(conf.MetricsEmitSeconds)*time.Second, metricSources...)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("getting hostname: %s", err)
+	}
+	leaderRunner := &leader.Runner{
+		Locker:        databaseHandler,
+		LockName:      "silk-controller-leader",
+		Owner:         fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		TTL:           15 * time.Second,
+		RenewInterval: 5 * time.Second,
+		Logger:        logger.Session("leader"),
+	}
+	expiryNotifier := &leaser.ExpiryNotifier{
+		LeaseRepository: leaseController,
+		RevisionBumper:  databaseHandler,
+	}
+	leaderRunner.Jobs = append(leaderRunner.Jobs, leader.Job{
+		Name:     "notify-lease-expiry",
+		Interval: 5 * time.Second,
+		Run:      expiryNotifier.Notify,
+	})
+	if conf.ReaperIntervalSeconds > 0 {
+		reaper := &leaser.Reaper{
+			LeaseReaper:        leaseController,
+			GracePeriodSeconds: conf.ReaperGracePeriodSeconds,
+			DryRun:             conf.ReaperDryRun,
+			MetricSender:       metricsSender,
+		}
+		leaderRunner.Jobs = append(leaderRunner.Jobs, leader.Job{
+			Name:     "reap-expired-leases",
+			Interval: time.Duration(conf.ReaperIntervalSeconds) * time.Second,
+			Run:      reaper.Reap,
+		})
+	}
 	members := grouper.Members{
+		{"revision-watcher", revisionWatcher},
 		{"http_server", httpServer},
 		{"health-server", healthServer},
 		{"debug-server", debugserver.Runner(debugServerAddress, reconfigurableSink)},
 		{"metrics-emitter", metricsEmitter},
+		{"leader", leaderRunner},
+	}
+	if leaseCache != nil {
+		members = append(grouper.Members{{"lease-cache", leaseCache}}, members...)
+	}
+
+	if conf.AdminListenPort != 0 {
+		adminTLSConfig, err := admin.NewServerTLSConfig(conf.AdminServerCertFile, conf.AdminServerKeyFile, conf.AdminCACertFile, conf.AdminAllowedCommonNames)
+		if err != nil {
+			return fmt.Errorf("admin mutual tls config: %s", err)
+		}
+		adminListenHost := conf.AdminListenHost
+		if adminListenHost == "" {
+			adminListenHost = conf.ListenHost
+		}
+		adminServerAddress := fmt.Sprintf("%s:%d", adminListenHost, conf.AdminListenPort)
+		members = append(members, grouper.Member{"admin-server", http_server.NewTLSServer(adminServerAddress, adminRouter, adminTLSConfig)})
 	}
 
 	group := grouper.NewOrdered(os.Interrupt, members)
@@ -226,6 +431,36 @@ func mainWithError() error {
 	return nil
 }
 
+type activeLeaseLister interface {
+	All() ([]controller.Lease, error)
+	AllActive(int) ([]controller.Lease, error)
+}
+
+// azFreeLeasesSources counts the free blocks of each availability zone in
+// both overlays.
+func azFreeLeasesSources(lister activeLeaseLister, azPools, ipv6AZPools *leaser.AZPools) []metrics.MetricSource {
+	if azPools == nil {
+		return nil
+	}
+
+	var sources []metrics.MetricSource
+	for az, zone := range azPools.Zones {
+		if ipv6AZPools == nil || ipv6AZPools.Zones[az] == nil {
+			sources = append(sources, server_metrics.NewAZFreeLeasesSource(lister, az, zone))
+			continue
+		}
+		sources = append(sources, server_metrics.NewAZFreeLeasesSource(lister, az, zone, ipv6AZPools.Zones[az]))
+	}
+	if ipv6AZPools != nil {
+		for az, zone := range ipv6AZPools.Zones {
+			if azPools.Zones[az] == nil {
+				sources = append(sources, server_metrics.NewAZFreeLeasesSource(lister, az, zone))
+			}
+		}
+	}
+	return sources
+}
+
 func getLagerConfig() lagerflags.LagerConfig {
 	lagerConfig := lagerflags.DefaultLagerConfig()
 	lagerConfig.TimeFormat = lagerflags.FormatRFC3339
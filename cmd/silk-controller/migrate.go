@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller/database"
+)
+
+const migrateUsage = "usage: silk-controller -config <path> migrate up|down [steps]|status"
+
+type schemaMigrator interface {
+	Migrate() (int, error)
+	MigrateDown(steps int) (int, error)
+	MigrationStatus() ([]database.MigrationStatus, error)
+}
+
+func runMigrateCommand(migrator schemaMigrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Migrate()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New("down steps must be a positive number")
+			}
+		}
+		n, err := migrator.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted %d migrations\n", n)
+	case "status":
+		statuses, err := migrator.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if !status.Known {
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status.Id, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/lib/datastore"
+)
+
+type leaseLookup interface {
+	LookupLease(ip string) (*controller.LeaseLookupResponse, error)
+}
+
+type containerStore interface {
+	ReadAll(filePath string) (map[string]datastore.Container, error)
+}
+
+// runLookupCommand prints the lease holding the ip and, when the lease
+// belongs to this cell, the container the ip is assigned to.
+func runLookupCommand(lookup leaseLookup, store containerStore, cfg config.Config, ip string, out io.Writer) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid ip: %s", ip)
+	}
+
+	response, err := lookup.LookupLease(ip)
+	if err != nil {
+		return fmt.Errorf("lookup lease: %s", err)
+	}
+	if response == nil {
+		fmt.Fprintf(out, "no lease holds %s\n", ip)
+		return nil
+	}
+
+	state := "expired"
+	if response.Active {
+		state = "active"
+	} else if response.Quarantined {
+		state = "quarantined"
+	}
+	lease := response.Lease
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "overlay subnet:\t%s\n", lease.OverlaySubnet)
+	fmt.Fprintf(w, "underlay ip:\t%s\n", lease.UnderlayIP)
+	fmt.Fprintf(w, "hardware addr:\t%s\n", lease.OverlayHardwareAddr)
+	fmt.Fprintf(w, "state:\t%s\n", state)
+	fmt.Fprintf(w, "last renewed at:\t%s\n", time.Unix(response.LastRenewedAt, 0).UTC().Format(time.RFC3339))
+	if lease.CellID != "" {
+		fmt.Fprintf(w, "cell id:\t%s\n", lease.CellID)
+	}
+	if lease.AZ != "" {
+		fmt.Fprintf(w, "az:\t%s\n", lease.AZ)
+	}
+
+	if lease.UnderlayIP == cfg.UnderlayIP {
+		containers, err := store.ReadAll(cfg.Datastore)
+		if err != nil {
+			return fmt.Errorf("read datastore: %s", err)
+		}
+		handle := "none"
+		for _, container := range containers {
+			if net.ParseIP(container.IP).Equal(net.ParseIP(ip)) {
+				handle = container.Handle
+				break
+			}
+		}
+		fmt.Fprintf(w, "container handle:\t%s\n", handle)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/lib/datastore"
+	"code.cloudfoundry.org/silk/lib/serial"
+)
+
+const usage = "usage: silk-ctl -config <path> lookup <ip>"
+
+func main() {
+	if err := mainWithError(); err != nil {
+		log.Fatalf("silk-ctl error: %s", err)
+	}
+}
+
+func mainWithError() error {
+	configFilePath := flag.String("config", "", "path to the silk-daemon config file")
+	flag.Parse()
+
+	if flag.NArg() != 2 || flag.Arg(0) != "lookup" {
+		return errors.New(usage)
+	}
+
+	cfg, err := config.LoadConfig(*configFilePath)
+	if err != nil {
+		return fmt.Errorf("load config file: %s", err)
+	}
+
+	tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+	if err != nil {
+		return fmt.Errorf("create tls config: %s", err)
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	client := controller.NewClient(lager.NewLogger("silk-ctl"), httpClient, cfg.ConnectivityServerURL)
+
+	store := &datastore.Store{
+		Serializer: &serial.Serial{},
+		LockerNew:  filelock.NewLocker,
+	}
+
+	return runLookupCommand(client, store, cfg, flag.Arg(1), os.Stdout)
+}
@@ -50,6 +50,7 @@ func main() {
 
 func mainWithError() error {
 	configFilePath := flag.String("config", "", "path to config file")
+	dryRun := flag.Bool("dry-run", false, "log the changes converging on the current leases would make to the VTEP and exit")
 	flag.Parse()
 
 	cfg, err := config.LoadConfig(*configFilePath)
@@ -93,15 +94,24 @@ func mainWithError() error {
 	}
 
 	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
+	client.LeaseMetadata = leaseMetadata(cfg)
 
 	store := &datastore.Store{
 		Serializer: &serial.Serial{},
 		LockerNew:  filelock.NewLocker,
 	}
 
-	_, overlayNetwork, err := net.ParseCIDR(cfg.OverlayNetwork)
-	if err != nil {
-		return fmt.Errorf("parse overlay network CIDR: %s", err) //TODO add test coverage
+	var overlayNetworks []*net.IPNet
+	for _, network := range cfg.OverlayNetworks() {
+		_, overlayNetwork, err := net.ParseCIDR(network.Network)
+		if err != nil {
+			return fmt.Errorf("parse overlay network CIDR: %s", err) //TODO add test coverage
+		}
+		overlayNetworks = append(overlayNetworks, overlayNetwork)
+	}
+
+	if *dryRun {
+		return logPlan(logger, cfg, client, vtepFactory, overlayNetworks, metricSender)
 	}
 
 	lease, err := discoverLocalLease(cfg, vtepFactory)
@@ -116,7 +126,7 @@ func mainWithError() error {
 			return fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
 		}
 
-		if !overlayNetwork.Contains(localSubnet.IP) {
+		if _, ok := cfg.OverlayNetworkFor(localSubnet.IP); !ok {
 			logger.Error("network-contains-lease", fmt.Errorf("discovered lease is not in overlay network"), lager.Data{
 				"lease":   lease,
 				"network": cfg.OverlayNetwork,
@@ -137,6 +147,31 @@ func mainWithError() error {
 			}
 		}
 
+		vtepUnderlayIP, err := vtepFactory.GetVTEPUnderlayIP(cfg.VTEPName)
+		if err != nil {
+			return fmt.Errorf("get vtep underlay ip: %s", err) // not tested
+		}
+
+		if !vtepUnderlayIP.Equal(net.ParseIP(cfg.UnderlayIP)) {
+			logger.Error("vtep-underlay-ip", fmt.Errorf("discovered vtep has another underlay ip"), lager.Data{
+				"vtep_underlay_ip": vtepUnderlayIP,
+				"underlay_ip":      cfg.UnderlayIP,
+			})
+
+			metadata, err := store.ReadAll(cfg.Datastore)
+			if err != nil {
+				return fmt.Errorf("read datastore: %s", err)
+			}
+
+			if len(metadata) != 0 {
+				return fmt.Errorf("discovered vtep has another underlay ip and has containers: %d", len(metadata))
+			}
+			lease, err = deleteAndAcquire(cfg, logger, client, vtepConfigCreator, vtepFactory)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = client.RenewSubnetLease(lease)
 		if err != nil {
 			logger.Error("renew-lease", err, lager.Data{"lease": lease})
@@ -169,35 +204,27 @@ func mainWithError() error {
 		return fmt.Errorf("create health check server: %s", err) // not tested
 	}
 
-	_, localSubnet, err := net.ParseCIDR(lease.OverlaySubnet)
+	converger, err := buildConverger(logger, cfg, lease, overlayNetworks, metricSender)
 	if err != nil {
-		return fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
+		return err
 	}
 
-	vxlanIface, err := net.InterfaceByName(cfg.VTEPName)
-	if err != nil || vxlanIface == nil {
-		return fmt.Errorf("find local VTEP: %s", err) //TODO add test coverage
+	vxlanPlanner := &planner.VXLANPlanner{
+		Logger:           logger,
+		ControllerClient: client,
+		Lease:            lease,
+		Converger:        converger,
+		ErrorDetector: planner.NewGracefulDetector(
+			time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
+		),
+		MetricSender:        metricSender,
+		WatchTimeoutSeconds: cfg.ClientTimeoutSeconds / 2,
 	}
 
 	vxlanPoller := &poller.Poller{
-		Logger:       logger,
-		PollInterval: time.Duration(cfg.PollInterval) * time.Second,
-		SingleCycleFunc: (&planner.VXLANPlanner{
-			Logger:           logger,
-			ControllerClient: client,
-			Lease:            lease,
-			Converger: &vtep.Converger{
-				OverlayNetwork: overlayNetwork,
-				LocalSubnet:    localSubnet,
-				LocalVTEP:      *vxlanIface,
-				NetlinkAdapter: &adapter.NetlinkAdapter{},
-				Logger:         logger,
-			},
-			ErrorDetector: planner.NewGracefulDetector(
-				time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
-			),
-			MetricSender: metricSender,
-		}).DoCycle,
+		Logger:          logger,
+		PollInterval:    time.Duration(cfg.PollInterval) * time.Second,
+		SingleCycleFunc: vxlanPlanner.DoCycle,
 	}
 
 	uptimeSource := metrics.NewUptimeSource()
@@ -205,9 +232,27 @@ func mainWithError() error {
 	members := grouper.Members{
 		{"server", healthCheckServer},
 		{"vxlan-poller", vxlanPoller},
-		{"debug-server", debugserver.Runner(debugServerAddress, reconfigurableSink)},
+		{"debug-server", buildDebugServer(logger, debugServerAddress, reconfigurableSink, vxlanPlanner)},
 		{"metrics-emitter", metricsEmitter},
 	}
+	if cfg.WatchLeases {
+		members = append(members, grouper.Member{"vxlan-watcher", &poller.Poller{
+			Logger:          logger,
+			PollInterval:    time.Second,
+			SingleCycleFunc: vxlanPlanner.WatchCycle,
+		}})
+	}
+	if cfg.RepairDrift {
+		members = append(members, grouper.Member{"drift-watcher", &vtep.DriftWatcher{
+			Logger:              logger.Session("drift-watcher"),
+			Subscriber:          &adapter.NetlinkAdapter{},
+			Repairer:            vxlanPlanner,
+			LocalVTEP:           converger.LocalVTEP,
+			OverlayNetworks:     overlayNetworks,
+			SettleTime:          100 * time.Millisecond,
+			ResubscribeInterval: time.Second,
+		}})
+	}
 	group := grouper.NewOrdered(os.Interrupt, members)
 	monitor := ifrit.Invoke(sigmon.New(group))
 
@@ -215,6 +260,67 @@ func mainWithError() error {
 	return err
 }
 
+func buildConverger(logger lager.Logger, cfg config.Config, lease controller.Lease, overlayNetworks []*net.IPNet, metricSender *metrics.MetricsSender) (*vtep.Converger, error) {
+	_, localSubnet, err := net.ParseCIDR(lease.OverlaySubnet)
+	if err != nil {
+		return nil, fmt.Errorf("parse local subnet CIDR: %s", err) //TODO add test coverage
+	}
+
+	vxlanIface, err := net.InterfaceByName(cfg.VTEPName)
+	if err != nil || vxlanIface == nil {
+		return nil, fmt.Errorf("find local VTEP: %s", err) //TODO add test coverage
+	}
+
+	return &vtep.Converger{
+		OverlayNetworks: overlayNetworks,
+		LocalSubnet:     localSubnet,
+		LocalVTEP:       *vxlanIface,
+		UnderlayIP:      net.ParseIP(cfg.UnderlayIP),
+		NetlinkAdapter:  &adapter.NetlinkAdapter{},
+		MetricSender:    metricSender,
+		Logger:          logger,
+	}, nil
+}
+
+// logPlan plans against the lease of the existing VTEP without acquiring,
+// renewing or creating anything.
+func logPlan(logger lager.Logger, cfg config.Config, client *controller.Client, vtepFactory *vtep.Factory, overlayNetworks []*net.IPNet, metricSender *metrics.MetricsSender) error {
+	lease, err := discoverLocalLease(cfg, vtepFactory)
+	if err != nil {
+		return fmt.Errorf("dry run: %s", err)
+	}
+
+	converger, err := buildConverger(logger, cfg, lease, overlayNetworks, metricSender)
+	if err != nil {
+		return err
+	}
+
+	vxlanPlanner := &planner.VXLANPlanner{
+		Logger:           logger,
+		ControllerClient: client,
+		Lease:            lease,
+		Converger:        converger,
+		MetricSender:     metricSender,
+	}
+	plan, err := vxlanPlanner.Plan()
+	if err != nil {
+		return fmt.Errorf("dry run: %s", err)
+	}
+
+	logger.Info("plan", lager.Data{"lease": lease, "plan": plan})
+	return nil
+}
+
+func buildDebugServer(logger lager.Logger, address string, sink *lager.ReconfigurableSink, vxlanPlanner *planner.VXLANPlanner) ifrit.Runner {
+	mux := http.NewServeMux()
+	mux.Handle("/plan", &planner.PlanHandler{
+		Logger:     logger,
+		PlanSource: vxlanPlanner,
+	})
+	mux.Handle("/", debugserver.Handler(sink))
+	return http_server.New(address, mux)
+}
+
 func acquireLease(logger lager.Logger, client *controller.Client, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory, cfg config.Config) (controller.Lease, error) {
 	var lease controller.Lease
 	if cfg.SingleIPOnly {
@@ -273,14 +379,27 @@ func discoverLocalLease(clientConfig config.Config, vtepFactory *vtep.Factory) (
 }
 
 func leaseFromVTEPState(clientConfig config.Config, overlayHwAddr net.HardwareAddr, overlayIP net.IP) controller.Lease {
+	subnetPrefixLength := clientConfig.SubnetPrefixLength
+	if network, ok := clientConfig.OverlayNetworkFor(overlayIP); ok {
+		subnetPrefixLength = network.SubnetPrefixLength
+	}
 	overlaySubnet := &net.IPNet{
 		IP:   overlayIP,
-		Mask: net.CIDRMask(clientConfig.SubnetPrefixLength, 32),
+		Mask: net.CIDRMask(subnetPrefixLength, 32),
 	}
 	return controller.Lease{
 		UnderlayIP:          clientConfig.UnderlayIP,
 		OverlaySubnet:       overlaySubnet.String(),
 		OverlayHardwareAddr: overlayHwAddr.String(),
+		LeaseMetadata:       leaseMetadata(clientConfig),
+	}
+}
+
+func leaseMetadata(clientConfig config.Config) controller.LeaseMetadata {
+	return controller.LeaseMetadata{
+		CellID: clientConfig.CellID,
+		AZ:     clientConfig.AZ,
+		Labels: clientConfig.Labels,
 	}
 }
 
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+)
+
+// NewServerTLSConfig returns a mutual TLS config for the admin listener which
+// only accepts client certificates whose common name is allowed. The CA is
+// usually shared with the daemons, so a valid chain alone is not enough.
+func NewServerTLSConfig(certFile, keyFile, caCertFile string, allowedCommonNames []string) (*tls.Config, error) {
+	tlsConfig, err := mutualtls.NewServerTLSConfig(certFile, keyFile, caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.VerifyPeerCertificate = VerifyCommonName(allowedCommonNames)
+	return tlsConfig, nil
+}
+
+func VerifyCommonName(allowedCommonNames []string) func([][]byte, [][]*x509.Certificate) error {
+	allowed := map[string]bool{}
+	for _, commonName := range allowedCommonNames {
+		allowed[commonName] = true
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+			return errors.New("no verified client certificate")
+		}
+		commonName := verifiedChains[0][0].Subject.CommonName
+		if !allowed[commonName] {
+			return fmt.Errorf("client certificate common name %q is not allowed", commonName)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package admin_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+
+	"code.cloudfoundry.org/silk/controller/admin"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyCommonName", func() {
+	var verify func([][]byte, [][]*x509.Certificate) error
+
+	chainFor := func(commonName string) [][]*x509.Certificate {
+		return [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: commonName}},
+			{Subject: pkix.Name{CommonName: "some-ca"}},
+		}}
+	}
+
+	BeforeEach(func() {
+		verify = admin.VerifyCommonName([]string{"operator", "silk-admin"})
+	})
+
+	It("accepts client certificates with an allowed common name", func() {
+		Expect(verify(nil, chainFor("operator"))).To(Succeed())
+		Expect(verify(nil, chainFor("silk-admin"))).To(Succeed())
+	})
+
+	It("rejects client certificates with any other common name", func() {
+		err := verify(nil, chainFor("silk-daemon"))
+		Expect(err).To(MatchError(`client certificate common name "silk-daemon" is not allowed`))
+	})
+
+	It("only checks the leaf certificate", func() {
+		err := verify(nil, [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: "silk-daemon"}},
+			{Subject: pkix.Name{CommonName: "operator"}},
+		}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when there is no verified chain", func() {
+		It("returns an error", func() {
+			Expect(verify(nil, nil)).To(MatchError("no verified client certificate"))
+		})
+	})
+
+	Context("when the allowlist is empty", func() {
+		It("rejects every client", func() {
+			verify = admin.VerifyCommonName(nil)
+			Expect(verify(nil, chainFor("operator"))).To(HaveOccurred())
+		})
+	})
+})
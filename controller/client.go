@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"code.cloudfoundry.org/cf-networking-helpers/json_client"
 	"code.cloudfoundry.org/lager"
@@ -16,12 +18,138 @@ func (n NonRetriableError) Error() string {
 
 type Client struct {
 	JsonClient json_client.JsonClient
+
+	// LeaseMetadata is stored with the leases acquired by the client.
+	LeaseMetadata LeaseMetadata
+}
+
+// LeaseMetadata describes the cell holding a lease. It is informational and
+// does not take part in matching a renewed lease against the stored one.
+type LeaseMetadata struct {
+	CellID string            `json:"cell_id,omitempty"`
+	AZ     string            `json:"az,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func (m LeaseMetadata) Equal(other LeaseMetadata) bool {
+	if m.CellID != other.CellID || m.AZ != other.AZ || len(m.Labels) != len(other.Labels) {
+		return false
+	}
+	for key, value := range m.Labels {
+		if otherValue, ok := other.Labels[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
 }
 
 type Lease struct {
 	UnderlayIP          string `json:"underlay_ip"`
 	OverlaySubnet       string `json:"overlay_subnet"`
 	OverlayHardwareAddr string `json:"overlay_hardware_addr"`
+	LeaseMetadata
+}
+
+// Key identifies the lease by its addresses and metadata, for use as a map
+// key since the labels make Lease incomparable.
+func (l Lease) Key() string {
+	bytes, _ := json.Marshal(l)
+	return string(bytes)
+}
+
+// SameAddresses reports whether both leases assign the same overlay subnet
+// and hardware address to the same underlay ip, regardless of metadata.
+func (l Lease) SameAddresses(other Lease) bool {
+	return l.UnderlayIP == other.UnderlayIP &&
+		l.OverlaySubnet == other.OverlaySubnet &&
+		l.OverlayHardwareAddr == other.OverlayHardwareAddr
+}
+
+const (
+	LeaseEventAcquire       = "acquire"
+	LeaseEventRenewMismatch = "renew-mismatch"
+	LeaseEventRelease       = "release"
+	LeaseEventReclaim       = "reclaim"
+	LeaseEventQuarantine    = "quarantine"
+)
+
+type LeaseEvent struct {
+	EventType           string `json:"event_type"`
+	UnderlayIP          string `json:"underlay_ip"`
+	OverlaySubnet       string `json:"overlay_subnet"`
+	OverlayHardwareAddr string `json:"overlay_hardware_addr"`
+	CreatedAt           int64  `json:"created_at"`
+}
+
+type LeaseRecord struct {
+	UnderlayIP          string `json:"underlay_ip"`
+	OverlaySubnet       string `json:"overlay_subnet"`
+	OverlayHardwareAddr string `json:"overlay_hardware_addr"`
+	LastRenewedAt       int64  `json:"last_renewed_at"`
+	Expired             bool   `json:"expired"`
+	Quarantined         bool   `json:"quarantined"`
+	QuarantinedAt       int64  `json:"quarantined_at,omitempty"`
+	LeaseMetadata
+}
+
+// LeasesResponse holds either the full set of leases at a revision or, when
+// Delta is set, the leases added and removed since the requested revision.
+type LeasesResponse struct {
+	Revision   string  `json:"revision"`
+	Delta      bool    `json:"delta"`
+	Leases     []Lease `json:"leases"`
+	Added      []Lease `json:"added"`
+	Removed    []Lease `json:"removed"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// LeaseQuery selects leases by their addresses and metadata. The matching
+// leases are ordered by overlay subnet, and when Limit is set at most Limit
+// of them are returned, starting after the overlay subnet After.
+type LeaseQuery struct {
+	UnderlayIP     string
+	OverlaySubnet  string
+	ContainsIP     string
+	CellID         string
+	AZ             string
+	Labels         map[string]string
+	IncludeExpired bool
+	Limit          int
+	After          string
+}
+
+// Apply returns the leases at the response revision given the leases at the
+// revision that was requested.
+func (r LeasesResponse) Apply(leases []Lease) []Lease {
+	if !r.Delta {
+		return r.Leases
+	}
+
+	removed := map[string]bool{}
+	for _, lease := range r.Removed {
+		removed[lease.Key()] = true
+	}
+	var applied []Lease
+	for _, lease := range leases {
+		if !removed[lease.Key()] {
+			applied = append(applied, lease)
+		}
+	}
+	return append(applied, r.Added...)
+}
+
+// LeaseLookupResponse holds the lease whose overlay subnet contains the
+// looked up ip.
+type LeaseLookupResponse struct {
+	Lease         Lease `json:"lease"`
+	Active        bool  `json:"active"`
+	Quarantined   bool  `json:"quarantined"`
+	LastRenewedAt int64 `json:"last_renewed_at"`
+}
+
+type WatchLeasesResponse struct {
+	Revision int64   `json:"revision"`
+	Leases   []Lease `json:"leases"`
 }
 
 type ReleaseLeaseRequest struct {
@@ -31,6 +159,8 @@ type ReleaseLeaseRequest struct {
 type AcquireLeaseRequest struct {
 	UnderlayIP      string `json:"underlay_ip"`
 	SingleOverlayIP bool   `json:"single_overlay_ip"`
+	IPv6Overlay     bool   `json:"ipv6_overlay"`
+	LeaseMetadata
 }
 
 func NewClient(logger lager.Logger, httpClient json_client.HttpClient, baseURL string) *Client {
@@ -50,19 +180,73 @@ func (c *Client) GetActiveLeases() ([]Lease, error) {
 	return response.Leases, nil
 }
 
+// GetLeasesSince returns the changes to the routable leases since the given
+// revision, or all of them when the revision is empty or no longer known to
+// the controller.
+func (c *Client) GetLeasesSince(revision string) (LeasesResponse, error) {
+	route := "/leases"
+	if revision != "" {
+		route = fmt.Sprintf("/leases?since=%s", url.QueryEscape(revision))
+	}
+
+	var response LeasesResponse
+	err := c.JsonClient.Do("GET", route, nil, &response, "")
+	if err != nil {
+		httpResponseErr, ok := err.(*json_client.HttpResponseCodeError)
+		if ok && httpResponseErr.StatusCode == http.StatusNotModified {
+			return LeasesResponse{Revision: revision, Delta: true}, nil
+		}
+		return LeasesResponse{}, err
+	}
+	return response, nil
+}
+
+// LookupLease returns the lease holding the overlay ip, or nil if no lease
+// holds it.
+func (c *Client) LookupLease(ip string) (*LeaseLookupResponse, error) {
+	var response LeaseLookupResponse
+	err := c.JsonClient.Do("GET", fmt.Sprintf("/leases/lookup?ip=%s", url.QueryEscape(ip)), nil, &response, "")
+	if err != nil {
+		httpResponseErr, ok := err.(*json_client.HttpResponseCodeError)
+		if ok && httpResponseErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WatchLeases blocks until the lease revision differs from the given
+// revision or the controller gives up after timeoutSeconds.
+func (c *Client) WatchLeases(revision int64, timeoutSeconds int) (WatchLeasesResponse, error) {
+	var response WatchLeasesResponse
+	route := fmt.Sprintf("/leases/watch?revision=%d&timeout=%d", revision, timeoutSeconds)
+	err := c.JsonClient.Do("GET", route, nil, &response, "")
+	if err != nil {
+		return WatchLeasesResponse{}, err
+	}
+	return response, nil
+}
+
 func (c *Client) AcquireSubnetLease(underlayIP string) (Lease, error) {
-	return c.acquireLease(underlayIP, false)
+	return c.acquireLease(underlayIP, false, false)
 }
 
 func (c *Client) AcquireSingleOverlayIPLease(underlayIP string) (Lease, error) {
-	return c.acquireLease(underlayIP, true)
+	return c.acquireLease(underlayIP, true, false)
+}
+
+func (c *Client) AcquireIPv6SubnetLease(underlayIP string) (Lease, error) {
+	return c.acquireLease(underlayIP, false, true)
 }
 
-func (c *Client) acquireLease(underlayIP string, singleOverlayIP bool) (Lease, error) {
+func (c *Client) acquireLease(underlayIP string, singleOverlayIP, ipv6Overlay bool) (Lease, error) {
 	var response Lease
 	request := AcquireLeaseRequest{
 		UnderlayIP:      underlayIP,
 		SingleOverlayIP: singleOverlayIP,
+		IPv6Overlay:     ipv6Overlay,
+		LeaseMetadata:   c.LeaseMetadata,
 	}
 	err := c.JsonClient.Do("PUT", "/leases/acquire", request, &response, "")
 	if err != nil {
@@ -80,6 +80,235 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("GetLeasesSince", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`
+				{
+					"revision": "new-revision",
+					"delta": true,
+					"added": [
+						{ "underlay_ip": "10.0.3.1", "overlay_subnet": "10.255.90.0/24" }
+					],
+					"removed": [
+						{ "underlay_ip": "10.0.5.9", "overlay_subnet": "10.253.30.0/24" }
+					]
+				}`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+
+		It("gets the changes since the revision", func() {
+			response, err := client.GetLeasesSince("old-revision")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/leases?since=old-revision"))
+			Expect(reqData).To(BeNil())
+			Expect(token).To(BeEmpty())
+
+			Expect(response).To(Equal(controller.LeasesResponse{
+				Revision: "new-revision",
+				Delta:    true,
+				Added:    []controller.Lease{{UnderlayIP: "10.0.3.1", OverlaySubnet: "10.255.90.0/24"}},
+				Removed:  []controller.Lease{{UnderlayIP: "10.0.5.9", OverlaySubnet: "10.253.30.0/24"}},
+			}))
+		})
+
+		Context("when no revision is given", func() {
+			It("gets all the leases", func() {
+				_, err := client.GetLeasesSince("")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, route, _, _, _ := jsonClient.DoArgsForCall(0)
+				Expect(route).To(Equal("/leases"))
+			})
+		})
+
+		Context("when the leases have not been modified", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(&json_client.HttpResponseCodeError{
+					StatusCode: http.StatusNotModified,
+				})
+			})
+			It("returns an empty delta at the same revision", func() {
+				response, err := client.GetLeasesSince("old-revision")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(response).To(Equal(controller.LeasesResponse{Revision: "old-revision", Delta: true}))
+			})
+		})
+
+		Context("when the json client fails", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(errors.New("banana"))
+			})
+			It("returns the error", func() {
+				_, err := client.GetLeasesSince("old-revision")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("LookupLease", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`
+				{
+					"lease": { "underlay_ip": "10.0.3.1", "overlay_subnet": "10.255.90.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:5a:00" },
+					"active": true,
+					"last_renewed_at": 1500000000
+				}`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+
+		It("looks up the lease holding the ip", func() {
+			response, err := client.LookupLease("10.255.90.12")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, _ := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/leases/lookup?ip=10.255.90.12"))
+			Expect(reqData).To(BeNil())
+
+			Expect(response).To(Equal(&controller.LeaseLookupResponse{
+				Lease: controller.Lease{
+					UnderlayIP:          "10.0.3.1",
+					OverlaySubnet:       "10.255.90.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:5a:00",
+				},
+				Active:        true,
+				LastRenewedAt: 1500000000,
+			}))
+		})
+
+		Context("when no lease holds the ip", func() {
+			It("returns nil", func() {
+				jsonClient.DoReturns(&json_client.HttpResponseCodeError{StatusCode: http.StatusNotFound})
+				jsonClient.DoStub = nil
+
+				response, err := client.LookupLease("10.255.90.12")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(response).To(BeNil())
+			})
+		})
+
+		Context("when the json client fails", func() {
+			It("returns the error", func() {
+				jsonClient.DoReturns(errors.New("banana"))
+				jsonClient.DoStub = nil
+
+				_, err := client.LookupLease("10.255.90.12")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("LeasesResponse", func() {
+		var leases []controller.Lease
+
+		BeforeEach(func() {
+			leases = []controller.Lease{
+				{UnderlayIP: "10.0.3.1", OverlaySubnet: "10.255.90.0/24"},
+				{UnderlayIP: "10.0.5.9", OverlaySubnet: "10.253.30.0/24"},
+			}
+		})
+
+		It("applies the delta to the leases", func() {
+			response := controller.LeasesResponse{
+				Delta:   true,
+				Added:   []controller.Lease{{UnderlayIP: "10.0.0.8", OverlaySubnet: "10.255.255.55/32"}},
+				Removed: []controller.Lease{{UnderlayIP: "10.0.3.1", OverlaySubnet: "10.255.90.0/24"}},
+			}
+			Expect(response.Apply(leases)).To(Equal([]controller.Lease{
+				{UnderlayIP: "10.0.5.9", OverlaySubnet: "10.253.30.0/24"},
+				{UnderlayIP: "10.0.0.8", OverlaySubnet: "10.255.255.55/32"},
+			}))
+		})
+
+		It("replaces the leases when the response is not a delta", func() {
+			response := controller.LeasesResponse{
+				Leases: []controller.Lease{{UnderlayIP: "10.0.0.8", OverlaySubnet: "10.255.255.55/32"}},
+			}
+			Expect(response.Apply(leases)).To(Equal(response.Leases))
+		})
+
+		It("removes leases whose metadata changed", func() {
+			leases[0].LeaseMetadata = controller.LeaseMetadata{Labels: map[string]string{"rack": "r1"}}
+			updated := leases[0]
+			updated.LeaseMetadata = controller.LeaseMetadata{Labels: map[string]string{"rack": "r2"}}
+			response := controller.LeasesResponse{
+				Delta:   true,
+				Added:   []controller.Lease{updated},
+				Removed: []controller.Lease{leases[0]},
+			}
+			Expect(response.Apply(leases)).To(Equal([]controller.Lease{leases[1], updated}))
+		})
+	})
+
+	Describe("LeaseMetadata", func() {
+		It("is equal to metadata with the same cell id, az and labels", func() {
+			metadata := controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1", Labels: map[string]string{"rack": "r1"}}
+			Expect(metadata.Equal(controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1", Labels: map[string]string{"rack": "r1"}})).To(BeTrue())
+			Expect(metadata.Equal(controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1", Labels: map[string]string{"rack": "r2"}})).To(BeFalse())
+			Expect(metadata.Equal(controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1"})).To(BeFalse())
+			Expect(controller.LeaseMetadata{Labels: map[string]string{}}.Equal(controller.LeaseMetadata{})).To(BeTrue())
+		})
+	})
+
+	Describe("WatchLeases", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`
+				{
+					"revision": 8,
+					"leases": [
+						{ "underlay_ip": "10.0.3.1", "overlay_subnet": "10.255.90.0/24" }
+					]
+				}`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+
+		It("long-polls the controller for the next revision", func() {
+			response, err := client.WatchLeases(7, 3)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/leases/watch?revision=7&timeout=3"))
+			Expect(reqData).To(BeNil())
+			Expect(token).To(BeEmpty())
+
+			Expect(response).To(Equal(controller.WatchLeasesResponse{
+				Revision: 8,
+				Leases: []controller.Lease{
+					{
+						UnderlayIP:    "10.0.3.1",
+						OverlaySubnet: "10.255.90.0/24",
+					},
+				},
+			}))
+		})
+
+		Context("when the json client fails", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(errors.New("banana"))
+			})
+			It("returns the error", func() {
+				_, err := client.WatchLeases(7, 3)
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
 	Describe("AcquireSubnetLease", func() {
 		Context("when acquring a single overlay IP", func() {
 			BeforeEach(func() {
@@ -146,6 +375,53 @@ var _ = Describe("Client", func() {
 
 		})
 
+		Context("when acquiring an ipv6 overlay lease", func() {
+			BeforeEach(func() {
+				jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+					respBytes := []byte(`
+				{
+					"underlay_ip": "10.0.3.1",
+					"overlay_subnet": "fd00:10:255:5a::/64"
+				}`)
+					json.Unmarshal(respBytes, respData)
+					return nil
+				}
+			})
+
+			It("requests an ipv6 overlay subnet", func() {
+				lease, err := client.AcquireIPv6SubnetLease("10.0.3.1")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(jsonClient.DoCallCount()).To(Equal(1))
+				method, route, reqData, _, _ := jsonClient.DoArgsForCall(0)
+				Expect(method).To(Equal("PUT"))
+				Expect(route).To(Equal("/leases/acquire"))
+				Expect(reqData).To(Equal(controller.AcquireLeaseRequest{UnderlayIP: "10.0.3.1", IPv6Overlay: true}))
+
+				Expect(lease).To(Equal(controller.Lease{
+					UnderlayIP:    "10.0.3.1",
+					OverlaySubnet: "fd00:10:255:5a::/64",
+				}))
+			})
+		})
+
+		Context("when the client has lease metadata", func() {
+			BeforeEach(func() {
+				client.LeaseMetadata = controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1"}
+			})
+
+			It("sends it with the request", func() {
+				_, err := client.AcquireSubnetLease("10.0.3.1")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, reqData, _, _ := jsonClient.DoArgsForCall(0)
+				Expect(reqData).To(Equal(controller.AcquireLeaseRequest{
+					UnderlayIP:    "10.0.3.1",
+					LeaseMetadata: controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1"},
+				}))
+			})
+		})
+
 		Context("when the json client fails", func() {
 			BeforeEach(func() {
 				jsonClient.DoReturns(errors.New("carrot"))
@@ -2,33 +2,74 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"strings"
 
 	"code.cloudfoundry.org/cf-networking-helpers/db"
 	"gopkg.in/validator.v2"
 )
 
+const sqliteDatabaseType = "sqlite3"
+
+type StaticReservation struct {
+	Underlay      string `json:"underlay"`
+	OverlaySubnet string `json:"overlay_subnet"`
+}
+
+type OverlayNetwork struct {
+	Network            string `json:"network"`
+	SubnetPrefixLength int    `json:"subnet_prefix_length"`
+}
+
+// AZSubnetRange reserves a range of an overlay network for the cells of an
+// availability zone.
+type AZSubnetRange struct {
+	AZ      string `json:"az"`
+	Network string `json:"network"`
+}
+
 type Config struct {
-	DebugServerPort               int       `json:"debug_server_port" validate:"min=1"`
-	ListenHost                    string    `json:"listen_host" validate:"nonzero"`
-	ListenPort                    int       `json:"listen_port" validate:"nonzero"`
-	CACertFile                    string    `json:"ca_cert_file" validate:"nonzero"`
-	ServerCertFile                string    `json:"server_cert_file" validate:"nonzero"`
-	ServerKeyFile                 string    `json:"server_key_file" validate:"nonzero"`
-	Network                       string    `json:"network" validate:"nonzero"`
-	SubnetPrefixLength            int       `json:"subnet_prefix_length" validate:"nonzero"`
-	Database                      db.Config `json:"database" validate:"nonzero"`
-	LeaseExpirationSeconds        int       `json:"lease_expiration_seconds" validate:"min=1"`
-	MetronPort                    int       `json:"metron_port" validate:"min=1"`
-	HealthCheckPort               int       `json:"health_check_port" validate:"min=1"`
-	MetricsEmitSeconds            int       `json:"metrics_emit_seconds" validate:"min=1"`
-	StalenessThresholdSeconds     int       `json:"staleness_threshold_seconds" validate:"min=1"`
-	LogPrefix                     string    `json:"log_prefix" validate:"nonzero"`
-	MaxIdleConnections            int       `json:"max_idle_connections" validate:"min=0"`
-	MaxOpenConnections            int       `json:"max_open_connections" validate:"min=0"`
-	MaxConnectionsLifetimeSeconds int       `json:"connections_max_lifetime_seconds" validate:"min=0"`
+	DebugServerPort               int                 `json:"debug_server_port" validate:"min=1"`
+	ListenHost                    string              `json:"listen_host" validate:"nonzero"`
+	ListenPort                    int                 `json:"listen_port" validate:"nonzero"`
+	CACertFile                    string              `json:"ca_cert_file" validate:"nonzero"`
+	ServerCertFile                string              `json:"server_cert_file" validate:"nonzero"`
+	ServerKeyFile                 string              `json:"server_key_file" validate:"nonzero"`
+	Network                       string              `json:"network" validate:"nonzero"`
+	SubnetPrefixLength            int                 `json:"subnet_prefix_length" validate:"nonzero"`
+	IPv6Network                   string              `json:"ipv6_network"`
+	IPv6SubnetPrefixLength        int                 `json:"ipv6_subnet_prefix_length" validate:"min=0,max=128"`
+	Database                      db.Config           `json:"database" validate:"nonzero"`
+	LeaseExpirationSeconds        int                 `json:"lease_expiration_seconds" validate:"min=1"`
+	MetronPort                    int                 `json:"metron_port" validate:"min=1"`
+	HealthCheckPort               int                 `json:"health_check_port" validate:"min=1"`
+	MetricsEmitSeconds            int                 `json:"metrics_emit_seconds" validate:"min=1"`
+	StalenessThresholdSeconds     int                 `json:"staleness_threshold_seconds" validate:"min=1"`
+	LogPrefix                     string              `json:"log_prefix" validate:"nonzero"`
+	MaxIdleConnections            int                 `json:"max_idle_connections" validate:"min=0"`
+	MaxOpenConnections            int                 `json:"max_open_connections" validate:"min=0"`
+	MaxConnectionsLifetimeSeconds int                 `json:"connections_max_lifetime_seconds" validate:"min=0"`
+	AdminListenHost               string              `json:"admin_listen_host"`
+	AdminListenPort               int                 `json:"admin_listen_port" validate:"min=0"`
+	AdminCACertFile               string              `json:"admin_ca_cert_file"`
+	AdminServerCertFile           string              `json:"admin_server_cert_file"`
+	AdminServerKeyFile            string              `json:"admin_server_key_file"`
+	AdminAllowedCommonNames       []string            `json:"admin_allowed_common_names"`
+	StaticReservations            []StaticReservation `json:"static_reservations"`
+	ExcludedRanges                []string            `json:"excluded_ranges"`
+	AdditionalNetworks            []OverlayNetwork    `json:"additional_networks"`
+	AllocationStrategy            string              `json:"allocation_strategy"`
+	LeaseCacheRefreshSeconds      int                 `json:"lease_cache_refresh_seconds" validate:"min=0"`
+	ReaperIntervalSeconds         int                 `json:"reaper_interval_seconds" validate:"min=0"`
+	ReaperGracePeriodSeconds      int                 `json:"reaper_grace_period_seconds" validate:"min=0"`
+	ReaperDryRun                  bool                `json:"reaper_dry_run"`
+	AZSubnetRanges                []AZSubnetRange     `json:"az_subnet_ranges"`
+	AZRangeFallback               bool                `json:"az_range_fallback"`
+	ReclaimQuarantineSeconds      int                 `json:"reclaim_quarantine_seconds" validate:"min=0"`
 }
 
 func (c *Config) WriteToFile(configFilePath string) error {
@@ -53,8 +94,226 @@ func ReadFromFile(configFilePath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal config: %s", err)
 	}
-	if err := validator.Validate(conf); err != nil {
+	if err := conf.validateFields(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateIPv6Network(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateAdminListener(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateAllocationStrategy(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateAdditionalNetworks(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateExcludedRanges(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateStaticReservations(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if err := conf.validateAZSubnetRanges(); err != nil {
 		return nil, fmt.Errorf("invalid config: %s", err)
 	}
 	return &conf, nil
 }
+
+// validateFields ignores the database server fields for sqlite, which only
+// needs the path of the database file in database_name.
+func (c *Config) validateFields() error {
+	err := validator.Validate(*c)
+	if c.Database.Type != sqliteDatabaseType {
+		return err
+	}
+	if c.Database.DatabaseName == "" {
+		return errors.New("database_name is required for sqlite3")
+	}
+	errs, ok := err.(validator.ErrorMap)
+	if !ok {
+		return err
+	}
+	for field := range errs {
+		if strings.HasPrefix(field, "Database.") {
+			delete(errs, field)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c *Config) validateIPv6Network() error {
+	if c.IPv6Network == "" {
+		return nil
+	}
+	ip, network, err := net.ParseCIDR(c.IPv6Network)
+	if err != nil || ip.To4() != nil {
+		return fmt.Errorf("IPv6Network: not an ipv6 cidr")
+	}
+	networkPrefixLength, _ := network.Mask.Size()
+	if c.IPv6SubnetPrefixLength <= networkPrefixLength {
+		return fmt.Errorf("IPv6SubnetPrefixLength: must be longer than the IPv6Network prefix")
+	}
+	// the hardware address of a vtep holds 32 bits of its subnet
+	if c.IPv6SubnetPrefixLength-networkPrefixLength > 32 {
+		return fmt.Errorf("IPv6SubnetPrefixLength: must be at most 32 bits longer than the IPv6Network prefix")
+	}
+	return nil
+}
+
+func (c *Config) validateAdminListener() error {
+	if c.AdminListenPort == 0 {
+		return nil
+	}
+	switch {
+	case c.AdminCACertFile == "":
+		return fmt.Errorf("AdminCACertFile: required when AdminListenPort is set")
+	case c.AdminServerCertFile == "":
+		return fmt.Errorf("AdminServerCertFile: required when AdminListenPort is set")
+	case c.AdminServerKeyFile == "":
+		return fmt.Errorf("AdminServerKeyFile: required when AdminListenPort is set")
+	case len(c.AdminAllowedCommonNames) == 0:
+		return fmt.Errorf("AdminAllowedCommonNames: required when AdminListenPort is set")
+	}
+	return nil
+}
+
+func (c *Config) validateStaticReservations() error {
+	overlaySubnets := map[string]bool{}
+	underlays := map[string]bool{}
+	for _, reservation := range c.StaticReservations {
+		ip, overlayNetwork, err := net.ParseCIDR(reservation.OverlaySubnet)
+		if err != nil || !ip.Equal(overlayNetwork.IP) {
+			return fmt.Errorf("StaticReservations: invalid overlay subnet %q", reservation.OverlaySubnet)
+		}
+		if net.ParseIP(reservation.Underlay) == nil {
+			if _, _, err := net.ParseCIDR(reservation.Underlay); err != nil {
+				return fmt.Errorf("StaticReservations: invalid underlay %q", reservation.Underlay)
+			}
+		}
+		if excluded := c.excludedRangeOverlapping(overlayNetwork); excluded != "" {
+			return fmt.Errorf("StaticReservations: overlay subnet %s overlaps excluded range %s", reservation.OverlaySubnet, excluded)
+		}
+		if overlaySubnets[reservation.OverlaySubnet] {
+			return fmt.Errorf("StaticReservations: overlay subnet %s is reserved more than once", reservation.OverlaySubnet)
+		}
+		overlaySubnets[reservation.OverlaySubnet] = true
+
+		// an underlay may hold one ipv4 and one ipv6 lease
+		underlayKey := fmt.Sprintf("%s-%t", reservation.Underlay, ip.To4() == nil)
+		if underlays[underlayKey] {
+			return fmt.Errorf("StaticReservations: underlay %s is reserved more than one subnet", reservation.Underlay)
+		}
+		underlays[underlayKey] = true
+	}
+	return nil
+}
+
+// validateAZSubnetRanges requires every range to hold at least one subnet of
+// the overlay network it is part of.
+func (c *Config) validateAZSubnetRanges() error {
+	networks := c.Networks()
+	if c.IPv6Network != "" {
+		networks = append(networks, OverlayNetwork{Network: c.IPv6Network, SubnetPrefixLength: c.IPv6SubnetPrefixLength})
+	}
+
+	var seen []*net.IPNet
+	for _, azRange := range c.AZSubnetRanges {
+		if azRange.AZ == "" {
+			return fmt.Errorf("AZSubnetRanges: az is required for %s", azRange.Network)
+		}
+		ip, rangeNet, err := net.ParseCIDR(azRange.Network)
+		if err != nil || !ip.Equal(rangeNet.IP) {
+			return fmt.Errorf("AZSubnetRanges: invalid network %q", azRange.Network)
+		}
+		rangePrefixLength, rangeBits := rangeNet.Mask.Size()
+
+		inOverlay := false
+		for _, network := range networks {
+			_, overlayNet, err := net.ParseCIDR(network.Network)
+			if err != nil {
+				continue
+			}
+			overlayPrefixLength, overlayBits := overlayNet.Mask.Size()
+			if overlayBits == rangeBits && overlayPrefixLength <= rangePrefixLength && overlayNet.Contains(rangeNet.IP) {
+				if rangePrefixLength > network.SubnetPrefixLength {
+					return fmt.Errorf("AZSubnetRanges: %s is smaller than a subnet of %s", azRange.Network, network.Network)
+				}
+				inOverlay = true
+			}
+		}
+		if !inOverlay {
+			return fmt.Errorf("AZSubnetRanges: %s is not in the overlay network", azRange.Network)
+		}
+
+		for _, other := range seen {
+			if other.Contains(rangeNet.IP) || rangeNet.Contains(other.IP) {
+				return fmt.Errorf("AZSubnetRanges: %s overlaps %s", azRange.Network, other)
+			}
+		}
+		seen = append(seen, rangeNet)
+	}
+	return nil
+}
+
+// Networks returns the ipv4 overlay networks in allocation order.
+func (c *Config) Networks() []OverlayNetwork {
+	networks := []OverlayNetwork{{Network: c.Network, SubnetPrefixLength: c.SubnetPrefixLength}}
+	return append(networks, c.AdditionalNetworks...)
+}
+
+func (c *Config) validateAllocationStrategy() error {
+	switch c.AllocationStrategy {
+	case "", "random", "sequential", "least-recently-used":
+		return nil
+	default:
+		return fmt.Errorf("AllocationStrategy: must be one of random, sequential or least-recently-used")
+	}
+}
+
+func (c *Config) validateAdditionalNetworks() error {
+	var seen []*net.IPNet
+	if _, primary, err := net.ParseCIDR(c.Network); err == nil {
+		seen = append(seen, primary)
+	}
+	for _, network := range c.AdditionalNetworks {
+		ip, ipNet, err := net.ParseCIDR(network.Network)
+		if err != nil || ip.To4() == nil {
+			return fmt.Errorf("AdditionalNetworks: %q is not an ipv4 cidr", network.Network)
+		}
+		networkPrefixLength, _ := ipNet.Mask.Size()
+		if network.SubnetPrefixLength <= networkPrefixLength || network.SubnetPrefixLength > 32 {
+			return fmt.Errorf("AdditionalNetworks: subnet prefix length for %s must be longer than the network prefix", network.Network)
+		}
+		for _, other := range seen {
+			if other.Contains(ipNet.IP) || ipNet.Contains(other.IP) {
+				return fmt.Errorf("AdditionalNetworks: %s overlaps %s", network.Network, other)
+			}
+		}
+		seen = append(seen, ipNet)
+	}
+	return nil
+}
+
+func (c *Config) validateExcludedRanges() error {
+	for _, excludedRange := range c.ExcludedRanges {
+		if _, _, err := net.ParseCIDR(excludedRange); err != nil {
+			return fmt.Errorf("ExcludedRanges: invalid cidr %q", excludedRange)
+		}
+	}
+	return nil
+}
+
+func (c *Config) excludedRangeOverlapping(network *net.IPNet) string {
+	for _, excludedRange := range c.ExcludedRanges {
+		_, excluded, _ := net.ParseCIDR(excludedRange)
+		if excluded.Contains(network.IP) || network.Contains(excluded.IP) {
+			return excludedRange
+		}
+	}
+	return ""
+}
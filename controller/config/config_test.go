@@ -66,6 +66,336 @@ var _ = Describe("Config.ReadFromFile", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("does not require the database server fields for sqlite", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["database"] = db.Config{
+			Type:         "sqlite3",
+			DatabaseName: "/var/vcap/store/silk-controller/silk.db",
+		}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Database.DatabaseName).To(Equal("/var/vcap/store/silk-controller/silk.db"))
+	})
+
+	Context("when the sqlite database has no path", func() {
+		It("returns an error", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["database"] = db.Config{Type: "sqlite3"}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError("invalid config: database_name is required for sqlite3"))
+		})
+	})
+
+	It("does not error on a valid config with an ipv6 network", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["ipv6_network"] = "fd00:10:255::/48"
+		cfg["ipv6_subnet_prefix_length"] = 64
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.IPv6Network).To(Equal("fd00:10:255::/48"))
+		Expect(conf.IPv6SubnetPrefixLength).To(Equal(64))
+	})
+
+	It("errors when the ipv6 network holds more than 2^32 subnets", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["ipv6_network"] = "fd00:10::/31"
+		cfg["ipv6_subnet_prefix_length"] = 64
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		_, err = config.ReadFromFile(file.Name())
+		Expect(err).To(MatchError("invalid config: IPv6SubnetPrefixLength: must be at most 32 bits longer than the IPv6Network prefix"))
+	})
+
+	It("does not error on a valid config with an admin listener", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["admin_listen_port"] = 4104
+		cfg["admin_ca_cert_file"] = "/some/admin/ca/file"
+		cfg["admin_server_cert_file"] = "/some/admin/cert/file"
+		cfg["admin_server_key_file"] = "/some/admin/key/file"
+		cfg["admin_allowed_common_names"] = []string{"operator"}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.AdminListenPort).To(Equal(4104))
+		Expect(conf.AdminAllowedCommonNames).To(Equal([]string{"operator"}))
+	})
+
+	DescribeTable("when the admin listener is missing a member",
+		func(missingFlag, errorString string) {
+			cfg := cloneMap(requiredFields)
+			cfg["admin_listen_port"] = 4104
+			cfg["admin_ca_cert_file"] = "/some/admin/ca/file"
+			cfg["admin_server_cert_file"] = "/some/admin/cert/file"
+			cfg["admin_server_key_file"] = "/some/admin/key/file"
+			cfg["admin_allowed_common_names"] = []string{"operator"}
+
+			delete(cfg, missingFlag)
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorString)))
+		},
+
+		Entry("missing admin_ca_cert_file", "admin_ca_cert_file", "AdminCACertFile: required when AdminListenPort is set"),
+		Entry("missing admin_server_cert_file", "admin_server_cert_file", "AdminServerCertFile: required when AdminListenPort is set"),
+		Entry("missing admin_server_key_file", "admin_server_key_file", "AdminServerKeyFile: required when AdminListenPort is set"),
+		Entry("missing admin_allowed_common_names", "admin_allowed_common_names", "AdminAllowedCommonNames: required when AdminListenPort is set"),
+	)
+
+	It("does not error on a valid config with static reservations", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["static_reservations"] = []map[string]string{
+			{"underlay": "10.244.5.6", "overlay_subnet": "10.255.30.0/24"},
+			{"underlay": "10.244.6.0/28", "overlay_subnet": "10.255.31.0/24"},
+			{"underlay": "10.244.5.6", "overlay_subnet": "fd00:10:255:30::/64"},
+		}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.StaticReservations).To(Equal([]config.StaticReservation{
+			{Underlay: "10.244.5.6", OverlaySubnet: "10.255.30.0/24"},
+			{Underlay: "10.244.6.0/28", OverlaySubnet: "10.255.31.0/24"},
+			{Underlay: "10.244.5.6", OverlaySubnet: "fd00:10:255:30::/64"},
+		}))
+	})
+
+	DescribeTable("when a static reservation is invalid",
+		func(reservations []map[string]string, errorString string) {
+			cfg := cloneMap(requiredFields)
+			cfg["static_reservations"] = reservations
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorString)))
+		},
+
+		Entry("invalid overlay subnet", []map[string]string{
+			{"underlay": "10.244.5.6", "overlay_subnet": "banana"},
+		}, `StaticReservations: invalid overlay subnet "banana"`),
+		Entry("overlay subnet with host bits", []map[string]string{
+			{"underlay": "10.244.5.6", "overlay_subnet": "10.255.30.1/24"},
+		}, `StaticReservations: invalid overlay subnet "10.255.30.1/24"`),
+		Entry("invalid underlay", []map[string]string{
+			{"underlay": "banana", "overlay_subnet": "10.255.30.0/24"},
+		}, `StaticReservations: invalid underlay "banana"`),
+		Entry("overlay subnet reserved twice", []map[string]string{
+			{"underlay": "10.244.5.6", "overlay_subnet": "10.255.30.0/24"},
+			{"underlay": "10.244.5.7", "overlay_subnet": "10.255.30.0/24"},
+		}, "StaticReservations: overlay subnet 10.255.30.0/24 is reserved more than once"),
+		Entry("underlay reserved twice", []map[string]string{
+			{"underlay": "10.244.5.6", "overlay_subnet": "10.255.30.0/24"},
+			{"underlay": "10.244.5.6", "overlay_subnet": "10.255.31.0/24"},
+		}, "StaticReservations: underlay 10.244.5.6 is reserved more than one subnet"),
+	)
+
+	It("does not error on a valid config with an allocation strategy", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["allocation_strategy"] = "least-recently-used"
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.AllocationStrategy).To(Equal("least-recently-used"))
+	})
+
+	It("does not error on a valid config with additional networks", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["additional_networks"] = []map[string]interface{}{
+			{"network": "10.254.0.0/16", "subnet_prefix_length": 24},
+			{"network": "10.250.0.0/15", "subnet_prefix_length": 26},
+		}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.Networks()).To(Equal([]config.OverlayNetwork{
+			{Network: "10.255.0.0/16", SubnetPrefixLength: 24},
+			{Network: "10.254.0.0/16", SubnetPrefixLength: 24},
+			{Network: "10.250.0.0/15", SubnetPrefixLength: 26},
+		}))
+	})
+
+	DescribeTable("when an additional network is invalid",
+		func(networks []map[string]interface{}, errorString string) {
+			cfg := cloneMap(requiredFields)
+			cfg["additional_networks"] = networks
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorString)))
+		},
+
+		Entry("invalid network", []map[string]interface{}{
+			{"network": "banana", "subnet_prefix_length": 24},
+		}, `AdditionalNetworks: "banana" is not an ipv4 cidr`),
+		Entry("ipv6 network", []map[string]interface{}{
+			{"network": "fd00:10:254::/48", "subnet_prefix_length": 64},
+		}, `AdditionalNetworks: "fd00:10:254::/48" is not an ipv4 cidr`),
+		Entry("subnet prefix length too short", []map[string]interface{}{
+			{"network": "10.254.0.0/16", "subnet_prefix_length": 16},
+		}, "AdditionalNetworks: subnet prefix length for 10.254.0.0/16 must be longer than the network prefix"),
+		Entry("overlapping the network", []map[string]interface{}{
+			{"network": "10.255.128.0/17", "subnet_prefix_length": 24},
+		}, "AdditionalNetworks: 10.255.128.0/17 overlaps 10.255.0.0/16"),
+		Entry("overlapping another additional network", []map[string]interface{}{
+			{"network": "10.254.0.0/16", "subnet_prefix_length": 24},
+			{"network": "10.0.0.0/8", "subnet_prefix_length": 24},
+		}, "AdditionalNetworks: 10.0.0.0/8 overlaps 10.255.0.0/16"),
+	)
+
+	It("does not error on a valid config with az subnet ranges", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["az_subnet_ranges"] = []map[string]interface{}{
+			{"az": "z1", "network": "10.255.0.0/18"},
+			{"az": "z2", "network": "10.255.64.0/18"},
+		}
+		cfg["az_range_fallback"] = true
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.AZSubnetRanges).To(Equal([]config.AZSubnetRange{
+			{AZ: "z1", Network: "10.255.0.0/18"},
+			{AZ: "z2", Network: "10.255.64.0/18"},
+		}))
+		Expect(conf.AZRangeFallback).To(BeTrue())
+	})
+
+	DescribeTable("when an az subnet range is invalid",
+		func(ranges []map[string]interface{}, errorString string) {
+			cfg := cloneMap(requiredFields)
+			cfg["az_subnet_ranges"] = ranges
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorString)))
+		},
+
+		Entry("missing az", []map[string]interface{}{
+			{"network": "10.255.0.0/18"},
+		}, "AZSubnetRanges: az is required for 10.255.0.0/18"),
+		Entry("invalid network", []map[string]interface{}{
+			{"az": "z1", "network": "banana"},
+		}, `AZSubnetRanges: invalid network "banana"`),
+		Entry("outside of the overlay", []map[string]interface{}{
+			{"az": "z1", "network": "10.254.0.0/18"},
+		}, "AZSubnetRanges: 10.254.0.0/18 is not in the overlay network"),
+		Entry("smaller than a subnet", []map[string]interface{}{
+			{"az": "z1", "network": "10.255.0.0/25"},
+		}, "AZSubnetRanges: 10.255.0.0/25 is smaller than a subnet of 10.255.0.0/16"),
+		Entry("overlapping another range", []map[string]interface{}{
+			{"az": "z1", "network": "10.255.0.0/18"},
+			{"az": "z2", "network": "10.255.32.0/19"},
+		}, "AZSubnetRanges: 10.255.32.0/19 overlaps 10.255.0.0/18"),
+	)
+
+	It("does not error on a valid config with excluded ranges", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["excluded_ranges"] = []string{"10.255.16.0/20", "fd00:10:255:30::/64"}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.ExcludedRanges).To(Equal([]string{"10.255.16.0/20", "fd00:10:255:30::/64"}))
+	})
+
+	Context("when an excluded range is not a cidr", func() {
+		It("returns an error", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["excluded_ranges"] = []string{"10.255.16.0/20", "banana"}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError(`invalid config: ExcludedRanges: invalid cidr "banana"`))
+		})
+	})
+
+	Context("when a static reservation overlaps an excluded range", func() {
+		It("returns an error", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["excluded_ranges"] = []string{"10.255.16.0/20"}
+			cfg["static_reservations"] = []map[string]string{
+				{"underlay": "10.244.5.6", "overlay_subnet": "10.255.17.0/24"},
+			}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.ReadFromFile(file.Name())
+			Expect(err).To(MatchError("invalid config: StaticReservations: overlay subnet 10.255.17.0/24 overlaps excluded range 10.255.16.0/20"))
+		})
+	})
+
 	DescribeTable("when config file is missing a member",
 		func(missingFlag, errorString string) {
 			cfg := cloneMap(requiredFields)
@@ -115,5 +445,14 @@ var _ = Describe("Config.ReadFromFile", func() {
 		Entry("invalid max_open_connections", "max_open_connections", -2, "MaxOpenConnections: less than min"),
 		Entry("invalid max_idle_connections", "max_idle_connections", -2, "MaxIdleConnections: less than min"),
 		Entry("invalid connections_max_lifetime_seconds", "connections_max_lifetime_seconds", -2, "MaxConnectionsLifetimeSeconds: less than min"),
+		Entry("invalid admin_listen_port", "admin_listen_port", -1, "AdminListenPort: less than min"),
+		Entry("invalid ipv6_subnet_prefix_length", "ipv6_subnet_prefix_length", 129, "IPv6SubnetPrefixLength: greater than max"),
+		Entry("ipv4 ipv6_network", "ipv6_network", "10.255.0.0/16", "IPv6Network: not an ipv6 cidr"),
+		Entry("invalid lease_cache_refresh_seconds", "lease_cache_refresh_seconds", -1, "LeaseCacheRefreshSeconds: less than min"),
+		Entry("invalid reaper_interval_seconds", "reaper_interval_seconds", -1, "ReaperIntervalSeconds: less than min"),
+		Entry("invalid reaper_grace_period_seconds", "reaper_grace_period_seconds", -1, "ReaperGracePeriodSeconds: less than min"),
+		Entry("invalid reclaim_quarantine_seconds", "reclaim_quarantine_seconds", -1, "ReclaimQuarantineSeconds: less than min"),
+		Entry("unknown allocation_strategy", "allocation_strategy", "banana", "AllocationStrategy: must be one of random, sequential or least-recently-used"),
+		Entry("ipv6_network without ipv6_subnet_prefix_length", "ipv6_network", "fd00:10:255::/48", "IPv6SubnetPrefixLength: must be longer than the IPv6Network prefix"),
 	)
 })
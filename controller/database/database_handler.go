@@ -2,8 +2,13 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/silk/controller"
 	"github.com/jmoiron/sqlx"
@@ -12,11 +17,32 @@ import (
 
 const postgresTimeNow = "EXTRACT(EPOCH FROM now())::numeric::integer"
 const mysqlTimeNow = "UNIX_TIMESTAMP()"
+const sqliteTimeNow = "CAST(strftime('%s', 'now') AS INTEGER)"
 const MySQL = "mysql"
 const Postgres = "postgres"
+const SQLite = "sqlite3"
+
+const maxLabelsLength = 2048
+
+const singleIPSubnetCondition = "((overlay_ip_version = 4 AND overlay_subnet LIKE '%/32') OR (overlay_ip_version = 6 AND overlay_subnet LIKE '%/128'))"
 
 var RecordNotAffectedError = errors.New("record not affected")
 
+type SchemaTooNewError struct {
+	UnknownMigrations []string
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("database schema is newer than this controller, unknown migrations: %s", strings.Join(e.UnknownMigrations, ", "))
+}
+
+type MigrationStatus struct {
+	Id        string
+	Known     bool
+	Applied   bool
+	AppliedAt time.Time
+}
+
 //go:generate counterfeiter -o fakes/db.go --fake-name Db . Db
 type Db interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
@@ -27,9 +53,17 @@ type Db interface {
 	RawConnection() *sqlx.DB
 }
 
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Rebind(query string) string
+	DriverName() string
+}
+
 //go:generate counterfeiter -o fakes/migrateAdapter.go --fake-name MigrateAdapter . migrateAdapter
 type migrateAdapter interface {
 	Exec(db Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection) (int, error)
+	ExecMax(db Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection, max int) (int, error)
+	GetMigrationRecords(db Db, dialect string) ([]*migrate.MigrationRecord, error)
 }
 
 type DatabaseHandler struct {
@@ -42,13 +76,7 @@ func NewDatabaseHandler(migrator migrateAdapter, db Db) *DatabaseHandler {
 	return &DatabaseHandler{
 		migrator: migrator,
 		migrations: &migrate.MemoryMigrationSource{
-			Migrations: []*migrate.Migration{
-				{
-					Id:   "1",
-					Up:   []string{createSubnetTable(db.DriverName())},
-					Down: []string{"DROP TABLE subnets"},
-				},
-			},
+			Migrations: migrationsForDriver(db.DriverName()),
 		},
 		db: db,
 	}
@@ -60,7 +88,7 @@ func (d *DatabaseHandler) CheckDatabase() error {
 }
 
 func (d *DatabaseHandler) All() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets")
+	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, cell_id, az, labels FROM subnets")
 	if err != nil {
 		return nil, fmt.Errorf("selecting all subnets: %s", err)
 	}
@@ -73,103 +101,238 @@ func (d *DatabaseHandler) All() ([]controller.Lease, error) {
 	return leases, nil
 }
 
-func (d *DatabaseHandler) AllSingleIPSubnets() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet LIKE '%/32'")
+func (d *DatabaseHandler) AllActive(duration int) ([]controller.Lease, error) {
+	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
-		return nil, fmt.Errorf("selecting all single ip subnets: %s", err)
+		return nil, err
+	}
+	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, cell_id, az, labels FROM subnets WHERE last_renewed_at + %d > %s", duration, timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("selecting all active subnets: %s", err)
 	}
 	defer rows.Close() // untested
 	leases, err := rowsToLeases(rows)
 	if err != nil {
-		return nil, fmt.Errorf("selecting all single ip subnets: %s", err)
+		return nil, fmt.Errorf("selecting all active subnets: %s", err)
 	}
 
 	return leases, nil
 }
 
-func (d *DatabaseHandler) AllBlockSubnets() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet NOT LIKE '%/32'")
+func (d *DatabaseHandler) AllLeaseRecords(expirationTime int) ([]controller.LeaseRecord, error) {
+	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
-		return nil, fmt.Errorf("selecting all block subnets: %s", err)
+		return nil, err
+	}
+	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at, CASE WHEN last_renewed_at + %d <= %s THEN 1 ELSE 0 END, cell_id, az, labels, quarantined_at FROM subnets", expirationTime, timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("selecting all lease records: %s", err)
 	}
 	defer rows.Close() // untested
-	leases, err := rowsToLeases(rows)
+
+	records := []controller.LeaseRecord{}
+	for rows.Next() {
+		record, err := rowToLeaseRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("selecting all lease records: %s", err)
+		}
+		records = append(records, *record)
+	}
+	err = rows.Err()
 	if err != nil {
-		return nil, fmt.Errorf("selecting all block subnets: %s", err)
+		return nil, fmt.Errorf("selecting all lease records: getting next row: %s", err) // untested
 	}
 
-	return leases, nil
+	return records, nil
 }
 
-func (d *DatabaseHandler) AllActive(duration int) ([]controller.Lease, error) {
+// LeaseRecordContainingIP returns the lease whose overlay subnet holds the ip,
+// expired or not, or nil if there is none.
+func (d *DatabaseHandler) LeaseRecordContainingIP(ip string, expirationTime int) (*controller.LeaseRecord, error) {
 	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
 		return nil, err
 	}
-	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE last_renewed_at + %d > %s", duration, timestamp))
+	subnets, err := subnetsContaining(ip)
 	if err != nil {
-		return nil, fmt.Errorf("selecting all active subnets: %s", err)
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(subnets))
+	for _, subnet := range subnets {
+		args = append(args, subnet)
+	}
+
+	row := d.db.QueryRow(d.db.Rebind(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at, CASE WHEN last_renewed_at + %d <= %s THEN 1 ELSE 0 END, cell_id, az, labels, quarantined_at FROM subnets WHERE overlay_subnet IN (?%s)", expirationTime, timestamp, strings.Repeat(", ?", len(subnets)-1))), args...)
+	record, err := rowToLeaseRecord(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("selecting lease record containing ip: %s", err)
+	}
+	return record, nil
+}
+
+// QueryLeases returns the leases matching the query and, when there are more
+// than query.Limit of them, the overlay subnet to continue after.
+func (d *DatabaseHandler) QueryLeases(query controller.LeaseQuery, expirationTime int) ([]controller.Lease, string, error) {
+	var conditions []string
+	var args []interface{}
+	if !query.IncludeExpired {
+		timestamp, err := timestampForDriver(d.db.DriverName())
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, fmt.Sprintf("last_renewed_at + %d > %s", expirationTime, timestamp))
+	}
+	if query.UnderlayIP != "" {
+		conditions = append(conditions, "underlay_ip = ?")
+		args = append(args, query.UnderlayIP)
+	}
+	if query.OverlaySubnet != "" {
+		conditions = append(conditions, "overlay_subnet = ?")
+		args = append(args, query.OverlaySubnet)
+	}
+	if query.ContainsIP != "" {
+		subnets, err := subnetsContaining(query.ContainsIP)
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, fmt.Sprintf("overlay_subnet IN (?%s)", strings.Repeat(", ?", len(subnets)-1)))
+		for _, subnet := range subnets {
+			args = append(args, subnet)
+		}
+	}
+	if query.CellID != "" {
+		conditions = append(conditions, "cell_id = ?")
+		args = append(args, query.CellID)
+	}
+	if query.AZ != "" {
+		conditions = append(conditions, "az = ?")
+		args = append(args, query.AZ)
+	}
+	if len(query.Labels) > 0 {
+		condition, err := substringConditionForDriver(d.db.DriverName(), "labels")
+		if err != nil {
+			return nil, "", err
+		}
+		for _, label := range encodedLabelPairs(query.Labels) {
+			conditions = append(conditions, condition)
+			args = append(args, label)
+		}
+	}
+	if query.After != "" {
+		conditions = append(conditions, "overlay_subnet > ?")
+		args = append(args, query.After)
+	}
+
+	statement := "SELECT underlay_ip, overlay_subnet, overlay_hwaddr, cell_id, az, labels FROM subnets"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY overlay_subnet"
+	if query.Limit > 0 {
+		// one more to find out whether there is a next page
+		statement += fmt.Sprintf(" LIMIT %d", query.Limit+1)
+	}
+
+	rows, err := d.db.Query(d.db.Rebind(statement), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying leases: %s", err)
 	}
 	defer rows.Close() // untested
 	leases, err := rowsToLeases(rows)
 	if err != nil {
-		return nil, fmt.Errorf("selecting all active subnets: %s", err)
+		return nil, "", fmt.Errorf("querying leases: %s", err)
 	}
 
-	return leases, nil
+	var next string
+	if query.Limit > 0 && len(leases) > query.Limit {
+		leases = leases[:query.Limit]
+		next = leases[query.Limit-1].OverlaySubnet
+	}
+	return leases, next, nil
 }
 
-func (d *DatabaseHandler) OldestExpiredBlockSubnet(expirationTime int) (*controller.Lease, error) {
-	timestamp, err := timestampForDriver(d.db.DriverName())
-	if err != nil {
-		return nil, err
+func (d *DatabaseHandler) Migrate() (int, error) {
+	if err := d.CheckSchemaVersion(); err != nil {
+		return 0, err
 	}
 
-	var underlayIP, overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet NOT LIKE '%%/32' AND last_renewed_at + %d <= %s ORDER BY last_renewed_at ASC LIMIT 1", expirationTime, timestamp))
-	err = result.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+	migrations := d.migrations
+	numMigrations, err := d.migrator.Exec(d.db, d.db.DriverName(), *migrations, migrate.Up)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("scan result: %s", err)
+		return 0, fmt.Errorf("migrating: %s", err)
 	}
-	return &controller.Lease{
-		UnderlayIP:          underlayIP,
-		OverlaySubnet:       overlaySubnet,
-		OverlayHardwareAddr: overlayHWAddr,
-	}, nil
+	return numMigrations, nil
 }
 
-func (d *DatabaseHandler) OldestExpiredSingleIP(expirationTime int) (*controller.Lease, error) {
-	timestamp, err := timestampForDriver(d.db.DriverName())
+// MigrateDown reverts the last steps applied migrations.
+func (d *DatabaseHandler) MigrateDown(steps int) (int, error) {
+	if err := d.CheckSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	migrations := d.migrations
+	numMigrations, err := d.migrator.ExecMax(d.db, d.db.DriverName(), *migrations, migrate.Down, steps)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("migrating down: %s", err)
 	}
+	return numMigrations, nil
+}
 
-	var underlayIP, overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet LIKE '%%/32' AND last_renewed_at + %d <= %s ORDER BY last_renewed_at ASC LIMIT 1", expirationTime, timestamp))
-	err = result.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+// MigrationStatus lists the migrations this controller knows in order,
+// followed by the applied ones it does not.
+func (d *DatabaseHandler) MigrationStatus() ([]MigrationStatus, error) {
+	records, err := d.migrator.GetMigrationRecords(d.db, d.db.DriverName())
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return nil, fmt.Errorf("getting migration records: %s", err)
+	}
+	applied := map[string]time.Time{}
+	for _, record := range records {
+		applied[record.Id] = record.AppliedAt
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range d.migrations.Migrations {
+		appliedAt, ok := applied[migration.Id]
+		statuses = append(statuses, MigrationStatus{
+			Id:        migration.Id,
+			Known:     true,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+		delete(applied, migration.Id)
+	}
+	for _, record := range records {
+		if _, ok := applied[record.Id]; ok {
+			statuses = append(statuses, MigrationStatus{
+				Id:        record.Id,
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+			})
 		}
-		return nil, fmt.Errorf("scan result: %s", err)
 	}
-	return &controller.Lease{
-		UnderlayIP:          underlayIP,
-		OverlaySubnet:       overlaySubnet,
-		OverlayHardwareAddr: overlayHWAddr,
-	}, nil
+	return statuses, nil
 }
 
-func (d *DatabaseHandler) Migrate() (int, error) {
-	migrations := d.migrations
-	numMigrations, err := d.migrator.Exec(d.db, d.db.DriverName(), *migrations, migrate.Up)
+// CheckSchemaVersion refuses a schema with migrations applied by a newer
+// controller, which this one might corrupt.
+func (d *DatabaseHandler) CheckSchemaVersion() error {
+	statuses, err := d.MigrationStatus()
 	if err != nil {
-		return 0, fmt.Errorf("migrating: %s", err)
+		return err
 	}
-	return numMigrations, nil
+	unknown := []string{}
+	for _, status := range statuses {
+		if !status.Known {
+			unknown = append(unknown, status.Id)
+		}
+	}
+	if len(unknown) > 0 {
+		return &SchemaTooNewError{UnknownMigrations: unknown}
+	}
+	return nil
 }
 
 func (d *DatabaseHandler) AddEntry(lease controller.Lease) error {
@@ -178,13 +341,33 @@ func (d *DatabaseHandler) AddEntry(lease controller.Lease) error {
 		return err
 	}
 
-	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, %s)", timestamp)), lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr)
+	labels, err := encodeLabels(lease.Labels)
+	if err != nil {
+		return fmt.Errorf("adding entry: %s", err)
+	}
+
+	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, %s, ?, ?, ?)", timestamp)), lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr, overlayIPVersionForSubnet(lease.OverlaySubnet), lease.CellID, lease.AZ, labels)
 	if err != nil {
 		return fmt.Errorf("adding entry: %s", err)
 	}
 	return nil
 }
 
+// UpdateLeaseMetadata replaces the metadata of the lease for the overlay
+// subnet.
+func (d *DatabaseHandler) UpdateLeaseMetadata(lease controller.Lease) error {
+	labels, err := encodeLabels(lease.Labels)
+	if err != nil {
+		return fmt.Errorf("updating lease metadata: %s", err)
+	}
+
+	_, err = d.db.Exec(d.db.Rebind("UPDATE subnets SET cell_id = ?, az = ?, labels = ? WHERE overlay_subnet = ?"), lease.CellID, lease.AZ, labels, lease.OverlaySubnet)
+	if err != nil {
+		return fmt.Errorf("updating lease metadata: %s", err)
+	}
+	return nil
+}
+
 func (d *DatabaseHandler) DeleteEntry(underlayIP string) error {
 	deleteRows, err := d.db.Exec(d.db.Rebind("DELETE FROM subnets WHERE underlay_ip = ?"), underlayIP)
 
@@ -204,39 +387,142 @@ func (d *DatabaseHandler) DeleteEntry(underlayIP string) error {
 	return nil
 }
 
-func (d *DatabaseHandler) LeaseForUnderlayIP(underlayIP string) (*controller.Lease, error) {
-	var overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(d.db.Rebind("SELECT overlay_subnet, overlay_hwaddr FROM subnets WHERE underlay_ip = ?"), underlayIP)
-	err := result.Scan(&overlaySubnet, &overlayHWAddr)
+func (d *DatabaseHandler) DeleteEntryForOverlaySubnet(overlaySubnet string) error {
+	deleteRows, err := d.db.Exec(d.db.Rebind("DELETE FROM subnets WHERE overlay_subnet = ?"), overlaySubnet)
+
+	if err != nil {
+		return fmt.Errorf("deleting entry: %s", err)
+	}
+
+	rowsAffected, err := deleteRows.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+// DeleteExpiredEntry deletes the lease for the overlay subnet unless it was
+// renewed within the last expirationTime seconds.
+func (d *DatabaseHandler) DeleteExpiredEntry(overlaySubnet string, expirationTime int) error {
+	timestamp, err := timestampForDriver(d.db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	deleteRows, err := d.db.Exec(d.db.Rebind(fmt.Sprintf("DELETE FROM subnets WHERE overlay_subnet = ? AND last_renewed_at + %d <= %s", expirationTime, timestamp)), overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("deleting entry: %s", err)
+	}
+
+	rowsAffected, err := deleteRows.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+// QuarantineExpiredEntry starts the quarantine of the lease for the overlay
+// subnet unless it was renewed within the last expirationTime seconds or is
+// quarantined already.
+func (d *DatabaseHandler) QuarantineExpiredEntry(overlaySubnet string, expirationTime int) error {
+	timestamp, err := timestampForDriver(d.db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	result, err := d.db.Exec(d.db.Rebind(fmt.Sprintf("UPDATE subnets SET quarantined_at = %s WHERE overlay_subnet = ? AND last_renewed_at + %d <= %s AND quarantined_at = 0", timestamp, expirationTime, timestamp)), overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("quarantining entry: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+// DeleteReclaimableEntry deletes the lease for the overlay subnet like
+// DeleteExpiredEntry, but only once it has been quarantined for
+// quarantineSeconds.
+func (d *DatabaseHandler) DeleteReclaimableEntry(overlaySubnet string, expirationTime, quarantineSeconds int) error {
+	timestamp, err := timestampForDriver(d.db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	deleteRows, err := d.db.Exec(d.db.Rebind(fmt.Sprintf("DELETE FROM subnets WHERE overlay_subnet = ? AND last_renewed_at + %d <= %s AND quarantined_at > 0 AND quarantined_at + %d <= %s", expirationTime, timestamp, quarantineSeconds, timestamp)), overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("deleting entry: %s", err)
+	}
+
+	rowsAffected, err := deleteRows.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+func (d *DatabaseHandler) LeaseForUnderlayIP(underlayIP string, ipv6 bool) (*controller.Lease, error) {
+	result := d.db.QueryRow(d.db.Rebind("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, cell_id, az, labels FROM subnets WHERE underlay_ip = ? AND overlay_ip_version = ?"), underlayIP, overlayIPVersion(ipv6))
+	lease, err := rowToLease(result)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err // test me
 	}
-	return &controller.Lease{
-		UnderlayIP:          underlayIP,
-		OverlaySubnet:       overlaySubnet,
-		OverlayHardwareAddr: overlayHWAddr,
-	}, nil
+	return lease, nil
 }
 
-func (d *DatabaseHandler) RenewLeaseForUnderlayIP(underlayIP string) error {
+func (d *DatabaseHandler) LeaseForOverlaySubnet(overlaySubnet string) (*controller.Lease, error) {
+	result := d.db.QueryRow(d.db.Rebind("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, cell_id, az, labels FROM subnets WHERE overlay_subnet = ?"), overlaySubnet)
+	lease, err := rowToLease(result)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (d *DatabaseHandler) RenewLeaseForUnderlayIP(underlayIP string, ipv6 bool) error {
 	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
 		return err
 	}
 
-	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("UPDATE subnets SET last_renewed_at = %s WHERE underlay_ip = ?", timestamp)), underlayIP)
+	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("UPDATE subnets SET last_renewed_at = %s, quarantined_at = 0 WHERE underlay_ip = ? AND overlay_ip_version = ?", timestamp)), underlayIP, overlayIPVersion(ipv6))
 	if err != nil {
 		return fmt.Errorf("renewing lease: %s", err)
 	}
 	return nil
 }
 
-func (d *DatabaseHandler) LastRenewedAtForUnderlayIP(underlayIP string) (int64, error) {
+func (d *DatabaseHandler) LastRenewedAtForUnderlayIP(underlayIP string, ipv6 bool) (int64, error) {
 	var lastRenewedAt int64
-	result := d.db.QueryRow(d.db.Rebind("SELECT last_renewed_at FROM subnets WHERE underlay_ip = ?"), underlayIP)
+	result := d.db.QueryRow(d.db.Rebind("SELECT last_renewed_at FROM subnets WHERE underlay_ip = ? AND overlay_ip_version = ?"), underlayIP, overlayIPVersion(ipv6))
 	err := result.Scan(&lastRenewedAt)
 	if err != nil {
 		return 0, err
@@ -254,19 +540,212 @@ func (d *DatabaseHandler) SubnetForUnderlayIP(underlayIP string) (string, error)
 	return subnet, nil
 }
 
+func (d *DatabaseHandler) AddLeaseEvent(eventType string, lease controller.Lease) error {
+	return addLeaseEvent(d.db, eventType, lease)
+}
+
+func (d *DatabaseHandler) LeaseEventsForOverlaySubnet(overlaySubnet string) ([]controller.LeaseEvent, error) {
+	rows, err := d.db.Query(d.db.Rebind("SELECT event_type, underlay_ip, overlay_subnet, overlay_hwaddr, created_at FROM lease_events WHERE overlay_subnet = ? ORDER BY created_at, id"), overlaySubnet)
+	if err != nil {
+		return nil, fmt.Errorf("selecting lease events: %s", err)
+	}
+	defer rows.Close() // untested
+
+	events := []controller.LeaseEvent{}
+	for rows.Next() {
+		var event controller.LeaseEvent
+		err := rows.Scan(&event.EventType, &event.UnderlayIP, &event.OverlaySubnet, &event.OverlayHardwareAddr, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("selecting lease events: parsing result: %s", err)
+		}
+		events = append(events, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("selecting lease events: getting next row: %s", err) // untested
+	}
+
+	return events, nil
+}
+
+// ReleasedSubnets returns the subnets that have been released or reclaimed,
+// least recently released first.
+func (d *DatabaseHandler) ReleasedSubnets() ([]string, error) {
+	rows, err := d.db.Query(d.db.Rebind("SELECT overlay_subnet FROM lease_events WHERE event_type IN (?, ?) GROUP BY overlay_subnet ORDER BY MAX(created_at), MAX(id)"), controller.LeaseEventRelease, controller.LeaseEventReclaim)
+	if err != nil {
+		return nil, fmt.Errorf("selecting released subnets: %s", err)
+	}
+	defer rows.Close() // untested
+
+	subnets := []string{}
+	for rows.Next() {
+		var overlaySubnet string
+		err := rows.Scan(&overlaySubnet)
+		if err != nil {
+			return nil, fmt.Errorf("selecting released subnets: parsing result: %s", err)
+		}
+		subnets = append(subnets, overlaySubnet)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("selecting released subnets: getting next row: %s", err) // untested
+	}
+
+	return subnets, nil
+}
+
+func (d *DatabaseHandler) AddReservation(overlaySubnet string) error {
+	timestamp, err := timestampForDriver(d.db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = d.db.QueryRow(d.db.Rebind("SELECT COUNT(*) FROM reserved_subnets WHERE overlay_subnet = ?"), overlaySubnet).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("selecting reservation: %s", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO reserved_subnets (overlay_subnet, created_at) VALUES (?, %s)", timestamp)), overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("adding reservation: %s", err)
+	}
+	return nil
+}
+
+func (d *DatabaseHandler) DeleteReservation(overlaySubnet string) error {
+	deleteRows, err := d.db.Exec(d.db.Rebind("DELETE FROM reserved_subnets WHERE overlay_subnet = ?"), overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("deleting reservation: %s", err)
+	}
+
+	rowsAffected, err := deleteRows.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+func (d *DatabaseHandler) AllReservations() ([]string, error) {
+	rows, err := d.db.Query("SELECT overlay_subnet FROM reserved_subnets ORDER BY overlay_subnet")
+	if err != nil {
+		return nil, fmt.Errorf("selecting all reservations: %s", err)
+	}
+	defer rows.Close() // untested
+
+	reservations := []string{}
+	for rows.Next() {
+		var overlaySubnet string
+		err := rows.Scan(&overlaySubnet)
+		if err != nil {
+			return nil, fmt.Errorf("selecting all reservations: parsing result: %s", err)
+		}
+		reservations = append(reservations, overlaySubnet)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("selecting all reservations: getting next row: %s", err) // untested
+	}
+
+	return reservations, nil
+}
+
+// Revision increases whenever the set of leases changes.
+func (d *DatabaseHandler) Revision() (int64, error) {
+	var revision int64
+	err := d.db.QueryRow("SELECT revision FROM lease_revisions WHERE id = 1").Scan(&revision)
+	if err != nil {
+		return 0, fmt.Errorf("selecting revision: %s", err)
+	}
+	return revision, nil
+}
+
+func (d *DatabaseHandler) BumpRevision() error {
+	return bumpRevision(d.db)
+}
+
+func bumpRevision(db execer) error {
+	_, err := db.Exec("UPDATE lease_revisions SET revision = revision + 1 WHERE id = 1")
+	if err != nil {
+		return fmt.Errorf("bumping revision: %s", err)
+	}
+	return nil
+}
+
+// AcquireLock takes the named lock for ttlSeconds if it is free or expired,
+// and extends it if the owner already holds it. It reports whether the owner
+// holds the lock afterwards.
+func (d *DatabaseHandler) AcquireLock(name, owner string, ttlSeconds int) (bool, error) {
+	timestamp, err := timestampForDriver(d.db.DriverName())
+	if err != nil {
+		return false, err
+	}
+
+	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("UPDATE locks SET owner = ?, expires_at = %s + ? WHERE name = ? AND (owner = ? OR expires_at <= %s)", timestamp, timestamp)), owner, ttlSeconds, name, owner)
+	if err != nil {
+		return false, fmt.Errorf("updating lock: %s", err)
+	}
+
+	// mysql does not count rows updated to the values they already had, so
+	// read the holder back instead of relying on the rows affected
+	holder, err := d.lockHolder(name)
+	if err == sql.ErrNoRows {
+		_, insertErr := d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO locks (name, owner, expires_at) VALUES (?, ?, %s + ?)", timestamp)), name, owner, ttlSeconds)
+		holder, err = d.lockHolder(name)
+		if err == sql.ErrNoRows && insertErr != nil {
+			return false, fmt.Errorf("inserting lock: %s", insertErr)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("selecting lock: %s", err)
+	}
+
+	return holder == owner, nil
+}
+
+func (d *DatabaseHandler) ReleaseLock(name, owner string) error {
+	_, err := d.db.Exec(d.db.Rebind("DELETE FROM locks WHERE name = ? AND owner = ?"), name, owner)
+	if err != nil {
+		return fmt.Errorf("deleting lock: %s", err)
+	}
+	return nil
+}
+
+func (d *DatabaseHandler) lockHolder(name string) (string, error) {
+	var owner string
+	err := d.db.QueryRow(d.db.Rebind("SELECT owner FROM locks WHERE name = ?"), name).Scan(&owner)
+	return owner, err
+}
+
+func addLeaseEvent(db execer, eventType string, lease controller.Lease) error {
+	timestamp, err := timestampForDriver(db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(db.Rebind(fmt.Sprintf("INSERT INTO lease_events (event_type, underlay_ip, overlay_subnet, overlay_hwaddr, created_at) VALUES (?, ?, ?, ?, %s)", timestamp)), eventType, lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr)
+	if err != nil {
+		return fmt.Errorf("adding lease event: %s", err)
+	}
+	return nil
+}
+
 func rowsToLeases(rows *sql.Rows) ([]controller.Lease, error) {
 	leases := []controller.Lease{}
 	for rows.Next() {
-		var underlayIP, overlaySubnet, overlayHWAddr string
-		err := rows.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+		lease, err := rowToLease(rows)
 		if err != nil {
 			return nil, fmt.Errorf("parsing result: %s", err)
 		}
-		leases = append(leases, controller.Lease{
-			UnderlayIP:          underlayIP,
-			OverlaySubnet:       overlaySubnet,
-			OverlayHardwareAddr: overlayHWAddr,
-		})
+		leases = append(leases, *lease)
 	}
 	err := rows.Err()
 	if err != nil {
@@ -276,28 +755,131 @@ func rowsToLeases(rows *sql.Rows) ([]controller.Lease, error) {
 	return leases, nil
 }
 
-func createSubnetTable(dbType string) string {
-	baseCreateTable := "CREATE TABLE IF NOT EXISTS subnets (" +
-		"%s" +
-		", underlay_ip varchar(15) NOT NULL" +
-		", overlay_subnet varchar(18) NOT NULL" +
-		", overlay_hwaddr varchar(17) NOT NULL" +
-		", last_renewed_at bigint NOT NULL" +
-		", UNIQUE (underlay_ip)" +
-		", UNIQUE (overlay_subnet)" +
-		", UNIQUE (overlay_hwaddr)" +
-		");"
-	mysqlId := "id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id)"
-	psqlId := "id SERIAL PRIMARY KEY"
-
-	switch dbType {
-	case Postgres:
-		return fmt.Sprintf(baseCreateTable, psqlId)
-	case MySQL:
-		return fmt.Sprintf(baseCreateTable, mysqlId)
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowToLease scans the columns underlay_ip, overlay_subnet, overlay_hwaddr,
+// cell_id, az and labels.
+func rowToLease(row scanner) (*controller.Lease, error) {
+	var lease controller.Lease
+	var labels string
+	err := row.Scan(&lease.UnderlayIP, &lease.OverlaySubnet, &lease.OverlayHardwareAddr, &lease.CellID, &lease.AZ, &labels)
+	if err != nil {
+		return nil, err
+	}
+	lease.Labels, err = decodeLabels(labels)
+	if err != nil {
+		return nil, fmt.Errorf("parsing labels: %s", err)
+	}
+	return &lease, nil
+}
+
+// rowToLeaseRecord scans the columns underlay_ip, overlay_subnet,
+// overlay_hwaddr, last_renewed_at, the expired flag, cell_id, az, labels and
+// quarantined_at.
+func rowToLeaseRecord(row scanner) (*controller.LeaseRecord, error) {
+	var record controller.LeaseRecord
+	var expired int
+	var labels string
+	err := row.Scan(&record.UnderlayIP, &record.OverlaySubnet, &record.OverlayHardwareAddr, &record.LastRenewedAt, &expired, &record.CellID, &record.AZ, &labels, &record.QuarantinedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing result: %s", err)
+	}
+	record.Expired = expired == 1
+	record.Quarantined = record.QuarantinedAt > 0
+	record.Labels, err = decodeLabels(labels)
+	if err != nil {
+		return nil, fmt.Errorf("parsing labels: %s", err)
+	}
+	return &record, nil
+}
+
+// labels are stored as a json object, or the empty string when there are
+// none.
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	bytes, err := json.Marshal(labels)
+	if err != nil {
+		return "", err // not possible
+	}
+	if len(bytes) > maxLabelsLength {
+		return "", fmt.Errorf("labels exceed %d bytes", maxLabelsLength)
+	}
+	return string(bytes), nil
+}
+
+func decodeLabels(encoded string) (map[string]string, error) {
+	if encoded == "" {
+		return nil, nil
 	}
+	var labels map[string]string
+	err := json.Unmarshal([]byte(encoded), &labels)
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// encodedLabelPairs returns each label the way it appears in the encoded
+// labels, where the keys are unique and the quotes within keys and values are
+// escaped, so a label matches exactly when its encoding is a substring.
+func encodedLabelPairs(labels map[string]string) []string {
+	var pairs []string
+	for key, value := range labels {
+		encodedKey, _ := json.Marshal(key)
+		encodedValue, _ := json.Marshal(value)
+		pairs = append(pairs, string(encodedKey)+":"+string(encodedValue))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// subnetsContaining returns every subnet holding the ip, one per prefix
+// length, in the form the overlay subnets are stored in.
+func subnetsContaining(ip string) ([]string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid ip: %s", ip)
+	}
+	if ip4 := parsed.To4(); ip4 != nil {
+		parsed = ip4
+	}
+
+	bits := 8 * len(parsed)
+	subnets := make([]string, 0, bits+1)
+	for ones := 0; ones <= bits; ones++ {
+		mask := net.CIDRMask(ones, bits)
+		subnets = append(subnets, (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String())
+	}
+	return subnets, nil
+}
+
+func substringConditionForDriver(driverName, column string) (string, error) {
+	switch driverName {
+	case MySQL, Postgres:
+		return fmt.Sprintf("POSITION(? IN %s) > 0", column), nil
+	case SQLite:
+		return fmt.Sprintf("INSTR(%s, ?) > 0", column), nil
+	default:
+		return "", fmt.Errorf("database type %s is not supported", driverName)
+	}
+}
+
+func overlayIPVersion(ipv6 bool) int {
+	if ipv6 {
+		return 6
+	}
+	return 4
+}
 
-	return ""
+func overlayIPVersionForSubnet(overlaySubnet string) int {
+	return overlayIPVersion(strings.Contains(overlaySubnet, ":"))
 }
 
 func timestampForDriver(driverName string) (string, error) {
@@ -306,6 +888,8 @@ func timestampForDriver(driverName string) (string, error) {
 		return mysqlTimeNow, nil
 	case Postgres:
 		return postgresTimeNow, nil
+	case SQLite:
+		return sqliteTimeNow, nil
 	default:
 		return "", fmt.Errorf("database type %s is not supported", driverName)
 	}
@@ -32,6 +32,7 @@ var _ = Describe("DatabaseHandler", func() {
 		lease2             controller.Lease
 		singleIPLease      controller.Lease
 		singleIPLease2     controller.Lease
+		ipv6Lease          controller.Lease
 	)
 	BeforeEach(func() {
 		mockDb = &fakes.Db{}
@@ -77,6 +78,11 @@ var _ = Describe("DatabaseHandler", func() {
 			OverlaySubnet:       "10.255.0.19/32",
 			OverlayHardwareAddr: "ee:ee:0a:ff:11:12",
 		}
+		ipv6Lease = controller.Lease{
+			UnderlayIP:          "10.244.11.22",
+			OverlaySubnet:       "fd00:10:255:11::/64",
+			OverlayHardwareAddr: "ee:e6:1d:2c:3b:4a",
+		}
 	})
 
 	AfterEach(func() {
@@ -110,6 +116,76 @@ var _ = Describe("DatabaseHandler", func() {
 							Up:   []string{"CREATE TABLE IF NOT EXISTS subnets (id SERIAL PRIMARY KEY, underlay_ip varchar(15) NOT NULL, overlay_subnet varchar(18) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, last_renewed_at bigint NOT NULL, UNIQUE (underlay_ip), UNIQUE (overlay_subnet), UNIQUE (overlay_hwaddr));"},
 							Down: []string{"DROP TABLE subnets"},
 						},
+						{
+							Id: "2",
+							Up: []string{
+								"ALTER TABLE subnets ALTER COLUMN underlay_ip TYPE varchar(45);",
+								"ALTER TABLE subnets ALTER COLUMN overlay_subnet TYPE varchar(49);",
+								"ALTER TABLE subnets ADD COLUMN overlay_ip_version smallint NOT NULL DEFAULT 4;",
+								"ALTER TABLE subnets DROP CONSTRAINT subnets_underlay_ip_key;",
+								"ALTER TABLE subnets ADD UNIQUE (underlay_ip, overlay_ip_version);",
+							},
+							Down: []string{
+								"DELETE FROM subnets WHERE overlay_ip_version = 6 OR underlay_ip LIKE '%:%';",
+								"ALTER TABLE subnets DROP CONSTRAINT subnets_underlay_ip_overlay_ip_version_key;",
+								"ALTER TABLE subnets ADD UNIQUE (underlay_ip);",
+								"ALTER TABLE subnets DROP COLUMN overlay_ip_version;",
+								"ALTER TABLE subnets ALTER COLUMN overlay_subnet TYPE varchar(18);",
+								"ALTER TABLE subnets ALTER COLUMN underlay_ip TYPE varchar(15);",
+							},
+						},
+						{
+							Id: "3",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS subnet_allocation_locks (name varchar(16) NOT NULL, PRIMARY KEY (name));",
+								"INSERT INTO subnet_allocation_locks (name) VALUES ('block-4'), ('single-4'), ('block-6'), ('single-6');",
+							},
+							Down: []string{"DROP TABLE subnet_allocation_locks"},
+						},
+						{
+							Id: "4",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS lease_events (id SERIAL PRIMARY KEY, event_type varchar(16) NOT NULL, underlay_ip varchar(45) NOT NULL, overlay_subnet varchar(49) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, created_at bigint NOT NULL);",
+								"CREATE INDEX lease_events_overlay_subnet ON lease_events (overlay_subnet);",
+							},
+							Down: []string{"DROP TABLE lease_events"},
+						},
+						{
+							Id:   "5",
+							Up:   []string{"CREATE TABLE IF NOT EXISTS reserved_subnets (overlay_subnet varchar(49) NOT NULL, created_at bigint NOT NULL, PRIMARY KEY (overlay_subnet));"},
+							Down: []string{"DROP TABLE reserved_subnets"},
+						},
+						{
+							Id: "6",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS lease_revisions (id int NOT NULL, revision bigint NOT NULL, PRIMARY KEY (id));",
+								"INSERT INTO lease_revisions (id, revision) VALUES (1, 0);",
+							},
+							Down: []string{"DROP TABLE lease_revisions"},
+						},
+						{
+							Id:   "7",
+							Up:   []string{"CREATE TABLE IF NOT EXISTS locks (name varchar(64) NOT NULL, owner varchar(255) NOT NULL, expires_at bigint NOT NULL, PRIMARY KEY (name));"},
+							Down: []string{"DROP TABLE locks"},
+						},
+						{
+							Id: "8",
+							Up: []string{
+								"ALTER TABLE subnets ADD COLUMN cell_id varchar(255) NOT NULL DEFAULT '';",
+								"ALTER TABLE subnets ADD COLUMN az varchar(255) NOT NULL DEFAULT '';",
+								"ALTER TABLE subnets ADD COLUMN labels varchar(2048) NOT NULL DEFAULT '';",
+							},
+							Down: []string{
+								"ALTER TABLE subnets DROP COLUMN labels;",
+								"ALTER TABLE subnets DROP COLUMN az;",
+								"ALTER TABLE subnets DROP COLUMN cell_id;",
+							},
+						},
+						{
+							Id:   "9",
+							Up:   []string{"ALTER TABLE subnets ADD COLUMN quarantined_at bigint NOT NULL DEFAULT 0;"},
+							Down: []string{"ALTER TABLE subnets DROP COLUMN quarantined_at;"},
+						},
 					},
 				}))
 			} else {
@@ -120,6 +196,76 @@ var _ = Describe("DatabaseHandler", func() {
 							Up:   []string{"CREATE TABLE IF NOT EXISTS subnets (id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id), underlay_ip varchar(15) NOT NULL, overlay_subnet varchar(18) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, last_renewed_at bigint NOT NULL, UNIQUE (underlay_ip), UNIQUE (overlay_subnet), UNIQUE (overlay_hwaddr));"},
 							Down: []string{"DROP TABLE subnets"},
 						},
+						{
+							Id: "2",
+							Up: []string{
+								"ALTER TABLE subnets MODIFY underlay_ip varchar(45) NOT NULL;",
+								"ALTER TABLE subnets MODIFY overlay_subnet varchar(49) NOT NULL;",
+								"ALTER TABLE subnets ADD COLUMN overlay_ip_version smallint NOT NULL DEFAULT 4;",
+								"ALTER TABLE subnets DROP INDEX underlay_ip;",
+								"ALTER TABLE subnets ADD UNIQUE (underlay_ip, overlay_ip_version);",
+							},
+							Down: []string{
+								"DELETE FROM subnets WHERE overlay_ip_version = 6 OR underlay_ip LIKE '%:%';",
+								"ALTER TABLE subnets DROP INDEX underlay_ip;",
+								"ALTER TABLE subnets ADD UNIQUE (underlay_ip);",
+								"ALTER TABLE subnets DROP COLUMN overlay_ip_version;",
+								"ALTER TABLE subnets MODIFY overlay_subnet varchar(18) NOT NULL;",
+								"ALTER TABLE subnets MODIFY underlay_ip varchar(15) NOT NULL;",
+							},
+						},
+						{
+							Id: "3",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS subnet_allocation_locks (name varchar(16) NOT NULL, PRIMARY KEY (name));",
+								"INSERT INTO subnet_allocation_locks (name) VALUES ('block-4'), ('single-4'), ('block-6'), ('single-6');",
+							},
+							Down: []string{"DROP TABLE subnet_allocation_locks"},
+						},
+						{
+							Id: "4",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS lease_events (id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id), event_type varchar(16) NOT NULL, underlay_ip varchar(45) NOT NULL, overlay_subnet varchar(49) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, created_at bigint NOT NULL);",
+								"CREATE INDEX lease_events_overlay_subnet ON lease_events (overlay_subnet);",
+							},
+							Down: []string{"DROP TABLE lease_events"},
+						},
+						{
+							Id:   "5",
+							Up:   []string{"CREATE TABLE IF NOT EXISTS reserved_subnets (overlay_subnet varchar(49) NOT NULL, created_at bigint NOT NULL, PRIMARY KEY (overlay_subnet));"},
+							Down: []string{"DROP TABLE reserved_subnets"},
+						},
+						{
+							Id: "6",
+							Up: []string{
+								"CREATE TABLE IF NOT EXISTS lease_revisions (id int NOT NULL, revision bigint NOT NULL, PRIMARY KEY (id));",
+								"INSERT INTO lease_revisions (id, revision) VALUES (1, 0);",
+							},
+							Down: []string{"DROP TABLE lease_revisions"},
+						},
+						{
+							Id:   "7",
+							Up:   []string{"CREATE TABLE IF NOT EXISTS locks (name varchar(64) NOT NULL, owner varchar(255) NOT NULL, expires_at bigint NOT NULL, PRIMARY KEY (name));"},
+							Down: []string{"DROP TABLE locks"},
+						},
+						{
+							Id: "8",
+							Up: []string{
+								"ALTER TABLE subnets ADD COLUMN cell_id varchar(255) NOT NULL DEFAULT '';",
+								"ALTER TABLE subnets ADD COLUMN az varchar(255) NOT NULL DEFAULT '';",
+								"ALTER TABLE subnets ADD COLUMN labels varchar(2048) NOT NULL DEFAULT '';",
+							},
+							Down: []string{
+								"ALTER TABLE subnets DROP COLUMN labels;",
+								"ALTER TABLE subnets DROP COLUMN az;",
+								"ALTER TABLE subnets DROP COLUMN cell_id;",
+							},
+						},
+						{
+							Id:   "9",
+							Up:   []string{"ALTER TABLE subnets ADD COLUMN quarantined_at bigint NOT NULL DEFAULT 0;"},
+							Down: []string{"ALTER TABLE subnets DROP COLUMN quarantined_at;"},
+						},
 					},
 				}))
 			}
@@ -135,6 +281,107 @@ var _ = Describe("DatabaseHandler", func() {
 				Expect(err).To(MatchError("migrating: guava"))
 			})
 		})
+		Context("when the schema has migrations unknown to the controller", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockMigrateAdapter.GetMigrationRecordsReturns([]*migrate.MigrationRecord{{Id: "1"}, {Id: "99"}}, nil)
+			})
+			It("refuses to migrate", func() {
+				_, err := databaseHandler.Migrate()
+				Expect(err).To(MatchError("database schema is newer than this controller, unknown migrations: 99"))
+				Expect(err).To(BeAssignableToTypeOf(&database.SchemaTooNewError{}))
+				Expect(mockMigrateAdapter.ExecCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("MigrateDown", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reverts the given number of migrations", func() {
+			n, err := databaseHandler.MigrateDown(2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(2))
+
+			statuses, err := databaseHandler.MigrationStatus()
+			Expect(err).NotTo(HaveOccurred())
+			last := statuses[len(statuses)-2:]
+			Expect(last[0].Applied).To(BeFalse())
+			Expect(last[1].Applied).To(BeFalse())
+
+			n, err = databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(2))
+		})
+
+		It("deletes the leases that do not fit the schema without ipv6 support", func() {
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(ipv6Lease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(controller.Lease{
+				UnderlayIP:          "fd00:10:244:11::22",
+				OverlaySubnet:       lease2.OverlaySubnet,
+				OverlayHardwareAddr: lease2.OverlayHardwareAddr,
+			})).To(Succeed())
+
+			statuses, err := databaseHandler.MigrationStatus()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = databaseHandler.MigrateDown(len(statuses) - 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			var underlayIPs []string
+			Expect(realDb.RawConnection().Select(&underlayIPs, "SELECT underlay_ip FROM subnets")).To(Succeed())
+			Expect(underlayIPs).To(ConsistOf(lease.UnderlayIP))
+		})
+
+		Context("when the migrator fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockMigrateAdapter.ExecMaxReturns(0, errors.New("guava"))
+			})
+			It("returns the error", func() {
+				_, err := databaseHandler.MigrateDown(1)
+				Expect(err).To(MatchError("migrating down: guava"))
+
+				_, _, _, dir, max := mockMigrateAdapter.ExecMaxArgsForCall(0)
+				Expect(dir).To(Equal(migrate.Down))
+				Expect(max).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("MigrationStatus", func() {
+		var appliedAt time.Time
+
+		BeforeEach(func() {
+			appliedAt = time.Unix(1500000000, 0)
+			databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+			mockMigrateAdapter.GetMigrationRecordsReturns([]*migrate.MigrationRecord{
+				{Id: "1", AppliedAt: appliedAt},
+				{Id: "99", AppliedAt: appliedAt},
+			}, nil)
+		})
+
+		It("lists the known migrations followed by the unknown applied ones", func() {
+			statuses, err := databaseHandler.MigrationStatus()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statuses[0]).To(Equal(database.MigrationStatus{Id: "1", Known: true, Applied: true, AppliedAt: appliedAt}))
+			Expect(statuses[1]).To(Equal(database.MigrationStatus{Id: "2", Known: true}))
+			Expect(statuses[len(statuses)-1]).To(Equal(database.MigrationStatus{Id: "99", Applied: true, AppliedAt: appliedAt}))
+		})
+
+		Context("when getting the migration records fails", func() {
+			BeforeEach(func() {
+				mockMigrateAdapter.GetMigrationRecordsReturns(nil, errors.New("guava"))
+			})
+			It("returns the error", func() {
+				_, err := databaseHandler.MigrationStatus()
+				Expect(err).To(MatchError("getting migration records: guava"))
+			})
+		})
 	})
 
 	Describe("AddEntry", func() {
@@ -156,7 +403,7 @@ var _ = Describe("DatabaseHandler", func() {
 		Context("when the database type is postgres", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES ($1, $2, $3, EXTRACT(EPOCH FROM now())::numeric::integer)")
+				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES ($1, $2, $3, $4, EXTRACT(EPOCH FROM now())::numeric::integer, $5, $6, $7)")
 				mockDb.DriverNameReturns("postgres")
 			})
 			It("adds an entry to the DB", func() {
@@ -165,9 +412,9 @@ var _ = Describe("DatabaseHandler", func() {
 
 				Expect(mockDb.ExecCallCount()).To(Equal(1))
 				query, args := mockDb.ExecArgsForCall(0)
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, EXTRACT(EPOCH FROM now())::numeric::integer)"))
-				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES ($1, $2, $3, EXTRACT(EPOCH FROM now())::numeric::integer)"))
-				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00"}))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, EXTRACT(EPOCH FROM now())::numeric::integer, ?, ?, ?)"))
+				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES ($1, $2, $3, $4, EXTRACT(EPOCH FROM now())::numeric::integer, $5, $6, $7)"))
+				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00", 4, "", "", ""}))
 			})
 		})
 
@@ -175,7 +422,7 @@ var _ = Describe("DatabaseHandler", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
 				mockDb.DriverNameReturns("mysql")
-				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())")
+				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP(), ?, ?, ?)")
 			})
 			It("adds an entry to the DB", func() {
 				err := databaseHandler.AddEntry(lease)
@@ -183,9 +430,9 @@ var _ = Describe("DatabaseHandler", func() {
 
 				Expect(mockDb.ExecCallCount()).To(Equal(1))
 				query, args := mockDb.ExecArgsForCall(0)
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())"))
-				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())"))
-				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00"}))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP(), ?, ?, ?)"))
+				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP(), ?, ?, ?)"))
+				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00", 4, "", "", ""}))
 			})
 		})
 
@@ -195,7 +442,7 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4")
+				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4", false)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
@@ -281,6 +528,172 @@ var _ = Describe("DatabaseHandler", func() {
 		})
 	})
 
+	Describe("DeleteEntryForOverlaySubnet", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(ipv6Lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("deletes only the entry for the overlay subnet", func() {
+			err := databaseHandler.DeleteEntryForOverlaySubnet(ipv6Lease.OverlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(ConsistOf(lease))
+		})
+
+		Context("when the database exec returns an error", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("carrot"))
+				mockDb.RebindReturns("DELETE FROM subnets WHERE overlay_subnet = $1")
+			})
+			It("returns a sensible error", func() {
+				err := databaseHandler.DeleteEntryForOverlaySubnet("10.255.17.0/24")
+				Expect(err).To(MatchError("deleting entry: carrot"))
+
+				query, args := mockDb.ExecArgsForCall(0)
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("DELETE FROM subnets WHERE overlay_subnet = ?"))
+				Expect(query).To(Equal("DELETE FROM subnets WHERE overlay_subnet = $1"))
+				Expect(args).To(Equal([]interface{}{"10.255.17.0/24"}))
+			})
+		})
+
+		Context("when no entry exists", func() {
+			It("returns a RecordNotAffectedError", func() {
+				err := databaseHandler.DeleteEntryForOverlaySubnet("10.255.99.0/24")
+				Expect(err).To(Equal(database.RecordNotAffectedError))
+			})
+		})
+	})
+
+	Describe("DeleteExpiredEntry", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("deletes the entry once it has expired", func() {
+			err := databaseHandler.DeleteExpiredEntry(lease.OverlaySubnet, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(BeEmpty())
+		})
+
+		Context("when the entry has been renewed recently", func() {
+			It("returns a RecordNotAffectedError and keeps the entry", func() {
+				err := databaseHandler.DeleteExpiredEntry(lease.OverlaySubnet, 60)
+				Expect(err).To(Equal(database.RecordNotAffectedError))
+
+				leases, err := databaseHandler.All()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leases).To(ConsistOf(lease))
+			})
+		})
+
+		Context("when the database type is not supported", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.DriverNameReturns("foo")
+			})
+			It("returns an error", func() {
+				err := databaseHandler.DeleteExpiredEntry(lease.OverlaySubnet, 0)
+				Expect(err).To(MatchError("database type foo is not supported"))
+			})
+		})
+
+		Context("when the database exec returns an error", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("carrot"))
+			})
+			It("returns a sensible error", func() {
+				err := databaseHandler.DeleteExpiredEntry(lease.OverlaySubnet, 0)
+				Expect(err).To(MatchError("deleting entry: carrot"))
+			})
+		})
+	})
+
+	Describe("QuarantineExpiredEntry", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("quarantines the entry once it has expired", func() {
+			err := databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			records, err := databaseHandler.AllLeaseRecords(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0].Quarantined).To(BeTrue())
+		})
+
+		Context("when the entry has been renewed recently", func() {
+			It("returns a RecordNotAffectedError", func() {
+				err := databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, 60)
+				Expect(err).To(Equal(database.RecordNotAffectedError))
+			})
+		})
+
+		Context("when the database exec returns an error", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("carrot"))
+			})
+			It("returns a sensible error", func() {
+				err := databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, 0)
+				Expect(err).To(MatchError("quarantining entry: carrot"))
+			})
+		})
+	})
+
+	Describe("DeleteReclaimableEntry", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("keeps an expired entry that is not quarantined", func() {
+			err := databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, 0, 0)
+			Expect(err).To(Equal(database.RecordNotAffectedError))
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(ConsistOf(lease))
+		})
+
+		It("deletes a quarantined entry once its quarantine is over", func() {
+			Expect(databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, 0)).To(Succeed())
+
+			err := databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, 0, 60)
+			Expect(err).To(Equal(database.RecordNotAffectedError))
+
+			err = databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, 0, 0)
+			Expect(err).NotTo(HaveOccurred())
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(BeEmpty())
+		})
+	})
+
 	Describe("LeaseForUnderlayIP", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
@@ -290,18 +703,34 @@ var _ = Describe("DatabaseHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 		It("returns the subnet for the given underlay IP", func() {
-			found, err := databaseHandler.LeaseForUnderlayIP("10.244.11.22")
+			found, err := databaseHandler.LeaseForUnderlayIP("10.244.11.22", false)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(*found).To(Equal(lease))
 		})
 
 		Context("when there is no entry for the underlay ip", func() {
 			It("returns nil", func() {
-				entry, err := databaseHandler.LeaseForUnderlayIP("10.244.11.23")
+				entry, err := databaseHandler.LeaseForUnderlayIP("10.244.11.23", false)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(entry).To(BeNil())
 			})
 		})
+
+		Context("when the underlay ip also has an ipv6 overlay lease", func() {
+			BeforeEach(func() {
+				err := databaseHandler.AddEntry(ipv6Lease)
+				Expect(err).NotTo(HaveOccurred())
+			})
+			It("returns the lease for the requested ip version", func() {
+				found, err := databaseHandler.LeaseForUnderlayIP("10.244.11.22", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*found).To(Equal(lease))
+
+				found, err = databaseHandler.LeaseForUnderlayIP("10.244.11.22", true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*found).To(Equal(ipv6Lease))
+			})
+		})
 	})
 
 	Describe("RenewLeaseForUnderlayIP", func() {
@@ -312,17 +741,17 @@ var _ = Describe("DatabaseHandler", func() {
 		Context("when the database is postgres", func() {
 			BeforeEach(func() {
 				mockDb.DriverNameReturns("postgres")
-				mockDb.RebindReturns("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer WHERE underlay_ip = $1")
+				mockDb.RebindReturns("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer, quarantined_at = 0 WHERE underlay_ip = $1 AND overlay_ip_version = $2")
 			})
 			It("updates the last renewed at time", func() {
-				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4")
+				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4", false)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(mockDb.ExecCallCount()).To(Equal(1))
 				query, args := mockDb.ExecArgsForCall(0)
 
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer WHERE underlay_ip = ?"))
-				Expect(query).To(Equal("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer WHERE underlay_ip = $1"))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer, quarantined_at = 0 WHERE underlay_ip = ? AND overlay_ip_version = ?"))
+				Expect(query).To(Equal("UPDATE subnets SET last_renewed_at = EXTRACT(EPOCH FROM now())::numeric::integer, quarantined_at = 0 WHERE underlay_ip = $1 AND overlay_ip_version = $2"))
 				Expect(args).To(ContainElement("1.2.3.4"))
 			})
 		})
@@ -330,15 +759,15 @@ var _ = Describe("DatabaseHandler", func() {
 		Context("when the database is mysql", func() {
 			BeforeEach(func() {
 				mockDb.DriverNameReturns("mysql")
-				mockDb.RebindReturns("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP() WHERE underlay_ip = ?")
+				mockDb.RebindReturns("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP(), quarantined_at = 0 WHERE underlay_ip = ? AND overlay_ip_version = ?")
 			})
 			It("updates the last renewed at time", func() {
-				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4")
+				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4", false)
 				Expect(err).NotTo(HaveOccurred())
 
 				query, args := mockDb.ExecArgsForCall(0)
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP() WHERE underlay_ip = ?"))
-				Expect(query).To(Equal("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP() WHERE underlay_ip = ?"))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP(), quarantined_at = 0 WHERE underlay_ip = ? AND overlay_ip_version = ?"))
+				Expect(query).To(Equal("UPDATE subnets SET last_renewed_at = UNIX_TIMESTAMP(), quarantined_at = 0 WHERE underlay_ip = ? AND overlay_ip_version = ?"))
 				Expect(args).To(ContainElement("1.2.3.4"))
 			})
 		})
@@ -348,7 +777,7 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4")
+				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4", false)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
@@ -358,7 +787,7 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.ExecReturns(nil, errors.New("apple"))
 			})
 			It("returns a sensible error", func() {
-				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4")
+				err := databaseHandler.RenewLeaseForUnderlayIP("1.2.3.4", false)
 				Expect(err).To(MatchError("renewing lease: apple"))
 			})
 		})
@@ -373,114 +802,166 @@ var _ = Describe("DatabaseHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 		It("selects the last_renewed_at time for the lease", func() {
-			lastRenewedAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22")
+			lastRenewedAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22", false)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(lastRenewedAt).To(BeNumerically(">", 0))
 		})
 		It("gets updated when the lease is renewed", func() {
-			createdAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22")
+			createdAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22", false)
 			Expect(err).NotTo(HaveOccurred())
 			time.Sleep(1 * time.Second)
-			err = databaseHandler.RenewLeaseForUnderlayIP("10.244.11.22")
+			err = databaseHandler.RenewLeaseForUnderlayIP("10.244.11.22", false)
 			Expect(err).NotTo(HaveOccurred())
-			updatedAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22")
+			updatedAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22", false)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(updatedAt).To(BeNumerically(">", createdAt))
 		})
 
 		Context("when there is no entry for the underlay ip", func() {
 			It("returns an error", func() {
-				_, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.23")
+				_, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.23", false)
 				Expect(err).To(MatchError("sql: no rows in result set"))
 			})
 		})
 	})
 
-	Describe("All", func() {
+	Describe("AllLeaseRecords", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease)
-			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease2)
-			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease)
-			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease2)
-			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(lease2)).To(Succeed())
 		})
 
-		It("returns all the saved subnets", func() {
-			leases, err := databaseHandler.All()
+		It("returns every lease with its renewal time", func() {
+			records, err := databaseHandler.AllLeaseRecords(1000)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
 
-			Expect(len(leases)).To(Equal(4))
-			Expect(leases).To(ConsistOf([]controller.Lease{
-				lease,
-				lease2,
-				singleIPLease,
-				singleIPLease2,
+			lastRenewedAt, err := databaseHandler.LastRenewedAtForUnderlayIP(lease.UnderlayIP, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(ContainElement(controller.LeaseRecord{
+				UnderlayIP:          lease.UnderlayIP,
+				OverlaySubnet:       lease.OverlaySubnet,
+				OverlayHardwareAddr: lease.OverlayHardwareAddr,
+				LastRenewedAt:       lastRenewedAt,
+				Expired:             false,
 			}))
 		})
 
-		Context("when the query fails", func() {
+		It("flags the expired leases", func() {
+			records, err := databaseHandler.AllLeaseRecords(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Expired).To(BeTrue())
+			Expect(records[1].Expired).To(BeTrue())
+		})
+
+		Context("when the database type is not supported", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.QueryReturns(nil, errors.New("strawberry"))
+				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.All()
-				Expect(err).To(MatchError("selecting all subnets: strawberry"))
+				_, err := databaseHandler.AllLeaseRecords(1000)
+				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when the parsing the result fails", func() {
-			var rows *sql.Rows
+		Context("when the query fails", func() {
 			BeforeEach(func() {
-				var err error
-				rows, err = realDb.Query("SELECT 1")
-				Expect(err).NotTo(HaveOccurred())
-
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.QueryReturns(rows, nil)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
-
-			AfterEach(func() {
-				Expect(rows.Close()).To(Succeed())
-			})
-
 			It("returns an error", func() {
-				_, err := databaseHandler.All()
-				Expect(err.Error()).To(ContainSubstring("selecting all subnets: parsing result"))
+				_, err := databaseHandler.AllLeaseRecords(1000)
+				Expect(err).To(MatchError("selecting all lease records: strawberry"))
 			})
 		})
 	})
 
-	Describe("AllBlockSubnets", func() {
+	Describe("LeaseForOverlaySubnet", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease)
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		})
+
+		It("returns the lease holding the overlay subnet", func() {
+			found, err := databaseHandler.LeaseForOverlaySubnet(lease.OverlaySubnet)
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease2)
+			Expect(found).To(Equal(&lease))
+		})
+
+		Context("when there is no lease for the overlay subnet", func() {
+			It("returns nil and does not error", func() {
+				found, err := databaseHandler.LeaseForOverlaySubnet("10.255.99.0/24")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeNil())
+			})
+		})
+	})
+
+	Describe("Reservations", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease)
+		})
+
+		It("adds, lists and deletes reservations", func() {
+			Expect(databaseHandler.AddReservation("10.255.31.0/24")).To(Succeed())
+			Expect(databaseHandler.AddReservation("10.255.30.0/24")).To(Succeed())
+
+			reservations, err := databaseHandler.AllReservations()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease2)
+			Expect(reservations).To(Equal([]string{"10.255.30.0/24", "10.255.31.0/24"}))
+
+			Expect(databaseHandler.DeleteReservation("10.255.30.0/24")).To(Succeed())
+
+			reservations, err = databaseHandler.AllReservations()
 			Expect(err).NotTo(HaveOccurred())
+			Expect(reservations).To(Equal([]string{"10.255.31.0/24"}))
 		})
 
-		It("returns all the saved subnets", func() {
-			leases, err := databaseHandler.AllBlockSubnets()
+		It("treats adding an existing reservation as a no-op", func() {
+			Expect(databaseHandler.AddReservation("10.255.30.0/24")).To(Succeed())
+			Expect(databaseHandler.AddReservation("10.255.30.0/24")).To(Succeed())
+
+			reservations, err := databaseHandler.AllReservations()
 			Expect(err).NotTo(HaveOccurred())
+			Expect(reservations).To(HaveLen(1))
+		})
 
-			Expect(len(leases)).To(Equal(2))
-			Expect(leases).To(ConsistOf([]controller.Lease{
-				lease,
-				lease2,
-			}))
+		Context("when deleting a subnet that is not reserved", func() {
+			It("returns a RecordNotAffectedError", func() {
+				err := databaseHandler.DeleteReservation("10.255.30.0/24")
+				Expect(err).To(Equal(database.RecordNotAffectedError))
+			})
+		})
+
+		Context("when the database type is not supported", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.DriverNameReturns("foo")
+			})
+			It("returns an error", func() {
+				err := databaseHandler.AddReservation("10.255.30.0/24")
+				Expect(err).To(MatchError("database type foo is not supported"))
+			})
+		})
+
+		Context("when the delete fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("carrot"))
+			})
+			It("returns an error", func() {
+				err := databaseHandler.DeleteReservation("10.255.30.0/24")
+				Expect(err).To(MatchError("deleting reservation: carrot"))
+			})
 		})
 
 		Context("when the query fails", func() {
@@ -489,137 +970,273 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.AllBlockSubnets()
-				Expect(err).To(MatchError("selecting all block subnets: strawberry"))
+				_, err := databaseHandler.AllReservations()
+				Expect(err).To(MatchError("selecting all reservations: strawberry"))
 			})
 		})
+	})
 
-		Context("when the parsing the result fails", func() {
-			var rows *sql.Rows
-			BeforeEach(func() {
-				var err error
-				rows, err = realDb.Query("SELECT 1")
+	Describe("LeaseEventsForOverlaySubnet", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AddLeaseEvent("acquire", lease)).To(Succeed())
+			Expect(databaseHandler.AddLeaseEvent("acquire", lease2)).To(Succeed())
+			Expect(databaseHandler.AddLeaseEvent("release", lease)).To(Succeed())
+		})
+
+		It("returns the events recorded for the overlay subnet in order", func() {
+			events, err := databaseHandler.LeaseEventsForOverlaySubnet(lease.OverlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(2))
+
+			Expect(events[0].EventType).To(Equal("acquire"))
+			Expect(events[0].UnderlayIP).To(Equal(lease.UnderlayIP))
+			Expect(events[0].OverlaySubnet).To(Equal(lease.OverlaySubnet))
+			Expect(events[0].OverlayHardwareAddr).To(Equal(lease.OverlayHardwareAddr))
+			Expect(events[0].CreatedAt).To(BeNumerically(">", 0))
+			Expect(events[1].EventType).To(Equal("release"))
+			Expect(events[1].CreatedAt).To(BeNumerically(">=", events[0].CreatedAt))
+		})
+
+		It("keeps the history after the lease is deleted", func() {
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+			Expect(databaseHandler.DeleteEntry(lease.UnderlayIP)).To(Succeed())
+
+			events, err := databaseHandler.LeaseEventsForOverlaySubnet(lease.OverlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(2))
+		})
+
+		Context("when there are no events for the overlay subnet", func() {
+			It("returns an empty list", func() {
+				events, err := databaseHandler.LeaseEventsForOverlaySubnet("10.255.99.0/24")
 				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(BeEmpty())
+			})
+		})
 
+		Context("when the query fails", func() {
+			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.QueryReturns(rows, nil)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
-
-			AfterEach(func() {
-				Expect(rows.Close()).To(Succeed())
+			It("returns an error", func() {
+				_, err := databaseHandler.LeaseEventsForOverlaySubnet(lease.OverlaySubnet)
+				Expect(err).To(MatchError("selecting lease events: strawberry"))
 			})
+		})
+	})
+
+	Describe("Revision", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("starts at zero and increases with every bump", func() {
+			revision, err := databaseHandler.Revision()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal(int64(0)))
+
+			Expect(databaseHandler.BumpRevision()).To(Succeed())
+			Expect(databaseHandler.BumpRevision()).To(Succeed())
+
+			revision, err = databaseHandler.Revision()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal(int64(2)))
+		})
 
+		Context("when bumping the revision fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("apple"))
+			})
 			It("returns an error", func() {
-				_, err := databaseHandler.AllBlockSubnets()
-				Expect(err.Error()).To(ContainSubstring("selecting all block subnets: parsing result"))
+				err := databaseHandler.BumpRevision()
+				Expect(err).To(MatchError("bumping revision: apple"))
 			})
 		})
 	})
 
-	Describe("AllSingleIPSubnets", func() {
+	Describe("AcquireLock", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease)
+		})
+
+		It("gives the lock to a single owner until it is released", func() {
+			acquired, err := databaseHandler.AcquireLock("leader", "controller-1", 60)
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease)
+			Expect(acquired).To(BeTrue())
+
+			acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 60)
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease2)
+			Expect(acquired).To(BeFalse())
+
+			By("extending the lock for the owner")
+			acquired, err = databaseHandler.AcquireLock("leader", "controller-1", 60)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+
+			By("ignoring releases by other owners")
+			Expect(databaseHandler.ReleaseLock("leader", "controller-2")).To(Succeed())
+			acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 60)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeFalse())
+
+			Expect(databaseHandler.ReleaseLock("leader", "controller-1")).To(Succeed())
+			acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 60)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
 		})
 
-		It("returns all singleIP subnets", func() {
-			leases, err := databaseHandler.AllSingleIPSubnets()
+		It("gives an expired lock to another owner", func() {
+			acquired, err := databaseHandler.AcquireLock("leader", "controller-1", 0)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
 
-			Expect(leases).To(HaveLen(2))
-			Expect(leases).To(ConsistOf([]controller.Lease{
-				singleIPLease,
-				singleIPLease2,
-			}))
+			acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 60)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
 		})
 
-		Context("when the query fails", func() {
+		Context("when the database type is not supported", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.QueryReturns(nil, errors.New("strawberry"))
+				mockDb.DriverNameReturns("foo")
 			})
-
 			It("returns an error", func() {
-				_, err := databaseHandler.AllSingleIPSubnets()
-				Expect(err).To(MatchError("selecting all single ip subnets: strawberry"))
+				_, err := databaseHandler.AcquireLock("leader", "controller-1", 60)
+				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when the parsing the result fails", func() {
-			var rows *sql.Rows
+		Context("when updating the lock fails", func() {
 			BeforeEach(func() {
-				var err error
-				rows, err = realDb.Query("SELECT 1")
-				Expect(err).NotTo(HaveOccurred())
-
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.QueryReturns(rows, nil)
+				mockDb.ExecReturns(nil, errors.New("apple"))
 			})
-
-			AfterEach(func() {
-				Expect(rows.Close()).To(Succeed())
+			It("returns an error", func() {
+				_, err := databaseHandler.AcquireLock("leader", "controller-1", 60)
+				Expect(err).To(MatchError("updating lock: apple"))
 			})
+		})
+	})
 
+	Describe("ReleaseLock", func() {
+		Context("when deleting the lock fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("apple"))
+			})
 			It("returns an error", func() {
-				_, err := databaseHandler.AllSingleIPSubnets()
-				Expect(err.Error()).To(ContainSubstring("selecting all single ip subnets: parsing result"))
+				err := databaseHandler.ReleaseLock("leader", "controller-1")
+				Expect(err).To(MatchError("deleting lock: apple"))
 			})
 		})
 	})
 
-	Describe("AllActive", func() {
+	Describe("ReleasedSubnets", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease)
-			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease2)
-			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AddLeaseEvent("acquire", lease)).To(Succeed())
+			Expect(databaseHandler.AddLeaseEvent("acquire", lease2)).To(Succeed())
+			Expect(databaseHandler.AddLeaseEvent("release", lease)).To(Succeed())
+			Expect(databaseHandler.AddLeaseEvent("reclaim", lease2)).To(Succeed())
 		})
 
-		It("returns the leases which have been renewed within the expiration time", func() {
-			leases, err := databaseHandler.AllActive(1000)
+		It("returns the released and reclaimed subnets, least recently released first", func() {
+			subnets, err := databaseHandler.ReleasedSubnets()
 			Expect(err).NotTo(HaveOccurred())
+			Expect(subnets).To(Equal([]string{lease.OverlaySubnet, lease2.OverlaySubnet}))
+		})
 
-			Expect(leases).To(HaveLen(2))
-			Expect(leases).To(ConsistOf([]controller.Lease{
-				lease,
-				lease2,
-			}))
+		It("orders a subnet by its most recent release", func() {
+			Expect(databaseHandler.AddLeaseEvent("release", lease)).To(Succeed())
 
-			leases, err = databaseHandler.AllActive(0)
+			subnets, err := databaseHandler.ReleasedSubnets()
 			Expect(err).NotTo(HaveOccurred())
+			Expect(subnets).To(Equal([]string{lease2.OverlaySubnet, lease.OverlaySubnet}))
+		})
 
-			Expect(leases).To(HaveLen(0))
+		Context("when the query fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
+			})
+			It("returns an error", func() {
+				_, err := databaseHandler.ReleasedSubnets()
+				Expect(err).To(MatchError("selecting released subnets: strawberry"))
+			})
 		})
+	})
 
-		Context("when the db driver name is not supported", func() {
+	Describe("AddLeaseEvent", func() {
+		Context("when the database type is not supported", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
 				mockDb.DriverNameReturns("foo")
 			})
-			It("should return an error", func() {
-				_, err := databaseHandler.AllActive(1000)
+			It("returns an error", func() {
+				err := databaseHandler.AddLeaseEvent("acquire", lease)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
+		Context("when the database exec returns an error", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("apple"))
+			})
+			It("returns an error", func() {
+				err := databaseHandler.AddLeaseEvent("acquire", lease)
+				Expect(err).To(MatchError("adding lease event: apple"))
+			})
+		})
+	})
+
+	Describe("All", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease2)
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(singleIPLease)
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(singleIPLease2)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns all the saved subnets", func() {
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(len(leases)).To(Equal(4))
+			Expect(leases).To(ConsistOf([]controller.Lease{
+				lease,
+				lease2,
+				singleIPLease,
+				singleIPLease2,
+			}))
+		})
+
 		Context("when the query fails", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
 				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.AllActive(100)
-				Expect(err).To(MatchError("selecting all active subnets: strawberry"))
+				_, err := databaseHandler.All()
+				Expect(err).To(MatchError("selecting all subnets: strawberry"))
 			})
 		})
 
@@ -639,54 +1256,128 @@ var _ = Describe("DatabaseHandler", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := databaseHandler.AllActive(100)
-				Expect(err.Error()).To(ContainSubstring("selecting all active subnets: parsing result"))
+				_, err := databaseHandler.All()
+				Expect(err.Error()).To(ContainSubstring("selecting all subnets: parsing result"))
 			})
 		})
 	})
 
-	Describe("CheckDatabase", func() {
+	Describe("LeaseRecordContainingIP", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(singleIPLease)).To(Succeed())
 		})
 
-		It("checks the database", func() {
-			err := databaseHandler.CheckDatabase()
+		It("returns the lease whose subnet holds the ip", func() {
+			record, err := databaseHandler.LeaseRecordContainingIP("10.255.17.200", 1000)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(record.UnderlayIP).To(Equal(lease.UnderlayIP))
+			Expect(record.OverlaySubnet).To(Equal(lease.OverlaySubnet))
+			Expect(record.Expired).To(BeFalse())
+
+			record, err = databaseHandler.LeaseRecordContainingIP("10.255.0.12", 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.OverlaySubnet).To(Equal(singleIPLease.OverlaySubnet))
+			Expect(record.Expired).To(BeTrue())
 		})
 
-		Context("when the connection to the database is closed", func() {
-			BeforeEach(func() {
-				if realDb != nil {
-					Expect(realDb.Close()).To(Succeed())
-				}
+		Context("when no lease holds the ip", func() {
+			It("returns nil", func() {
+				record, err := databaseHandler.LeaseRecordContainingIP("10.255.18.1", 1000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(record).To(BeNil())
 			})
+		})
 
+		Context("when the ip is invalid", func() {
 			It("returns an error", func() {
-				err := databaseHandler.CheckDatabase()
-				Expect(err).To(HaveOccurred())
+				_, err := databaseHandler.LeaseRecordContainingIP("banana", 1000)
+				Expect(err).To(MatchError("invalid ip: banana"))
+			})
+		})
+
+		Context("when the database type is not supported", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.DriverNameReturns("foo")
+			})
+			It("returns an error", func() {
+				_, err := databaseHandler.LeaseRecordContainingIP("10.255.17.200", 1000)
+				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 	})
 
-	Describe("OldestExpiredBlockSubnet", func() {
+	Describe("QueryLeases", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease)
+
+			lease.LeaseMetadata = controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1", Labels: map[string]string{"rack": "r1", "pool": "blue"}}
+			lease2.LeaseMetadata = controller.LeaseMetadata{CellID: "diego-cell/1", AZ: "z2", Labels: map[string]string{"rack": "r2", "pool": "blue"}}
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(lease2)).To(Succeed())
+			Expect(databaseHandler.AddEntry(singleIPLease)).To(Succeed())
+			Expect(databaseHandler.AddEntry(ipv6Lease)).To(Succeed())
+		})
+
+		It("returns the active leases matching the query in overlay subnet order", func() {
+			leases, next, err := databaseHandler.QueryLeases(controller.LeaseQuery{Labels: map[string]string{"pool": "blue"}}, 1000)
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(lease)
+			Expect(leases).To(Equal([]controller.Lease{lease, lease2}))
+			Expect(next).To(BeEmpty())
+
+			leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{AZ: "z2", CellID: "diego-cell/1"}, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{lease2}))
+
+			leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{UnderlayIP: ipv6Lease.UnderlayIP, OverlaySubnet: ipv6Lease.OverlaySubnet}, 1000)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{ipv6Lease}))
 		})
 
-		It("gets the oldest lease that is expired", func() {
-			expiredLease, err := databaseHandler.OldestExpiredBlockSubnet(0)
+		It("finds the lease containing an ip", func() {
+			leases, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{ContainsIP: "10.255.93.17"}, 1000)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{lease2}))
 
-			Expect(expiredLease).To(Equal(&lease))
+			leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{ContainsIP: "fd00:10:255:11::a"}, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{ipv6Lease}))
+		})
+
+		It("includes the expired leases only when asked to", func() {
+			leases, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{}, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(BeEmpty())
+
+			leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{IncludeExpired: true}, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(HaveLen(4))
+		})
+
+		It("pages through the leases", func() {
+			leases, next, err := databaseHandler.QueryLeases(controller.LeaseQuery{Limit: 3}, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(HaveLen(3))
+			Expect(next).To(Equal(leases[2].OverlaySubnet))
+
+			rest, next, err := databaseHandler.QueryLeases(controller.LeaseQuery{Limit: 3, After: next}, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rest).To(HaveLen(1))
+			Expect(next).To(BeEmpty())
+			Expect(append(leases, rest...)).To(ConsistOf(lease, lease2, singleIPLease, ipv6Lease))
+		})
+
+		Context("when the contains ip is invalid", func() {
+			It("returns an error", func() {
+				_, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{ContainsIP: "banana"}, 1000)
+				Expect(err).To(MatchError("invalid ip: banana"))
+			})
 		})
 
 		Context("when the database type is not supported", func() {
@@ -695,92 +1386,116 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredBlockSubnet(23)
+				_, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{}, 1000)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when no lease is expired", func() {
-			BeforeEach(func() {
-				databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
-				_, err := databaseHandler.Migrate()
-				Expect(err).NotTo(HaveOccurred())
-			})
-			It("returns nil and does not error", func() {
-				lease, err := databaseHandler.OldestExpiredBlockSubnet(23)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(lease).To(BeNil())
-			})
-		})
-
-		Context("when parsing the result fails", func() {
-			var result *sql.Row
+		Context("when the query fails", func() {
 			BeforeEach(func() {
-				result = realDb.QueryRow("SELECT 1")
-
-				mockDb.QueryRowReturns(result)
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredBlockSubnet(23)
-				Expect(err).To(MatchError(ContainSubstring("scan result:")))
+				_, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{}, 1000)
+				Expect(err).To(MatchError("querying leases: strawberry"))
 			})
 		})
 	})
 
-	Describe("OldestExipredSingleIP", func() {
+	Describe("AllActive", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
 			Expect(err).NotTo(HaveOccurred())
 			err = databaseHandler.AddEntry(lease)
 			Expect(err).NotTo(HaveOccurred())
-			err = databaseHandler.AddEntry(singleIPLease)
+			err = databaseHandler.AddEntry(lease2)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("gets the oldest lease that is expired", func() {
-			expiredLease, err := databaseHandler.OldestExpiredSingleIP(0)
+		It("returns the leases which have been renewed within the expiration time", func() {
+			leases, err := databaseHandler.AllActive(1000)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(expiredLease).To(Equal(&singleIPLease))
+			Expect(leases).To(HaveLen(2))
+			Expect(leases).To(ConsistOf([]controller.Lease{
+				lease,
+				lease2,
+			}))
+
+			leases, err = databaseHandler.AllActive(0)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(leases).To(HaveLen(0))
 		})
 
-		Context("when the database type is not supported", func() {
+		Context("when the db driver name is not supported", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
 				mockDb.DriverNameReturns("foo")
 			})
-			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredSingleIP(23)
+			It("should return an error", func() {
+				_, err := databaseHandler.AllActive(1000)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when no lease is expired", func() {
+		Context("when the query fails", func() {
 			BeforeEach(func() {
-				databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
-				_, err := databaseHandler.Migrate()
-				Expect(err).NotTo(HaveOccurred())
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
-			It("returns nil and does not error", func() {
-				lease, err := databaseHandler.OldestExpiredSingleIP(23)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(lease).To(BeNil())
+			It("returns an error", func() {
+				_, err := databaseHandler.AllActive(100)
+				Expect(err).To(MatchError("selecting all active subnets: strawberry"))
 			})
 		})
 
-		Context("when parsing the result fails", func() {
-			var result *sql.Row
+		Context("when the parsing the result fails", func() {
+			var rows *sql.Rows
 			BeforeEach(func() {
-				result = realDb.QueryRow("SELECT 1")
+				var err error
+				rows, err = realDb.Query("SELECT 1")
+				Expect(err).NotTo(HaveOccurred())
 
-				mockDb.QueryRowReturns(result)
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(rows, nil)
+			})
+
+			AfterEach(func() {
+				Expect(rows.Close()).To(Succeed())
 			})
+
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredSingleIP(23)
-				Expect(err).To(MatchError(ContainSubstring("scan result:")))
+				_, err := databaseHandler.AllActive(100)
+				Expect(err.Error()).To(ContainSubstring("selecting all active subnets: parsing result"))
+			})
+		})
+	})
+
+	Describe("CheckDatabase", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("checks the database", func() {
+			err := databaseHandler.CheckDatabase()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the connection to the database is closed", func() {
+			BeforeEach(func() {
+				if realDb != nil {
+					Expect(realDb.Close()).To(Succeed())
+				}
+			})
+
+			It("returns an error", func() {
+				err := databaseHandler.CheckDatabase()
+				Expect(err).To(HaveOccurred())
 			})
 		})
 	})
@@ -0,0 +1,706 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
+)
+
+type LeaseTransaction struct {
+	TakenSubnetsStub        func() ([]string, error)
+	takenSubnetsMutex       sync.RWMutex
+	takenSubnetsArgsForCall []struct{}
+	takenSubnetsReturns     struct {
+		result1 []string
+		result2 error
+	}
+	takenSubnetsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	OldestExpiredStub        func(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	oldestExpiredMutex       sync.RWMutex
+	oldestExpiredArgsForCall []struct {
+		expirationTime int
+		reclaimable    func(overlaySubnet string) bool
+	}
+	oldestExpiredReturns struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	oldestExpiredReturnsOnCall map[int]struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	OldestReclaimableStub        func(expirationTime int, quarantineSeconds int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	oldestReclaimableMutex       sync.RWMutex
+	oldestReclaimableArgsForCall []struct {
+		expirationTime    int
+		quarantineSeconds int
+		reclaimable       func(overlaySubnet string) bool
+	}
+	oldestReclaimableReturns struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	oldestReclaimableReturnsOnCall map[int]struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	QuarantineOldestExpiredStub        func(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	quarantineOldestExpiredMutex       sync.RWMutex
+	quarantineOldestExpiredArgsForCall []struct {
+		expirationTime int
+		reclaimable    func(overlaySubnet string) bool
+	}
+	quarantineOldestExpiredReturns struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	quarantineOldestExpiredReturnsOnCall map[int]struct {
+		result1 *controller.Lease
+		result2 error
+	}
+	LeaseRecordForOverlaySubnetStub        func(overlaySubnet string, expirationTime int) (*controller.LeaseRecord, error)
+	leaseRecordForOverlaySubnetMutex       sync.RWMutex
+	leaseRecordForOverlaySubnetArgsForCall []struct {
+		overlaySubnet  string
+		expirationTime int
+	}
+	leaseRecordForOverlaySubnetReturns struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	leaseRecordForOverlaySubnetReturnsOnCall map[int]struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	AddEntryStub        func(controller.Lease) error
+	addEntryMutex       sync.RWMutex
+	addEntryArgsForCall []struct {
+		arg1 controller.Lease
+	}
+	addEntryReturns struct {
+		result1 error
+	}
+	addEntryReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReassignEntryStub        func(controller.Lease) error
+	reassignEntryMutex       sync.RWMutex
+	reassignEntryArgsForCall []struct {
+		arg1 controller.Lease
+	}
+	reassignEntryReturns struct {
+		result1 error
+	}
+	reassignEntryReturnsOnCall map[int]struct {
+		result1 error
+	}
+	AddLeaseEventStub        func(eventType string, lease controller.Lease) error
+	addLeaseEventMutex       sync.RWMutex
+	addLeaseEventArgsForCall []struct {
+		eventType string
+		lease     controller.Lease
+	}
+	addLeaseEventReturns struct {
+		result1 error
+	}
+	addLeaseEventReturnsOnCall map[int]struct {
+		result1 error
+	}
+	BumpRevisionStub        func() error
+	bumpRevisionMutex       sync.RWMutex
+	bumpRevisionArgsForCall []struct{}
+	bumpRevisionReturns     struct {
+		result1 error
+	}
+	bumpRevisionReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CommitStub        func() error
+	commitMutex       sync.RWMutex
+	commitArgsForCall []struct{}
+	commitReturns     struct {
+		result1 error
+	}
+	commitReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RollbackStub        func() error
+	rollbackMutex       sync.RWMutex
+	rollbackArgsForCall []struct{}
+	rollbackReturns     struct {
+		result1 error
+	}
+	rollbackReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseTransaction) TakenSubnets() ([]string, error) {
+	fake.takenSubnetsMutex.Lock()
+	ret, specificReturn := fake.takenSubnetsReturnsOnCall[len(fake.takenSubnetsArgsForCall)]
+	fake.takenSubnetsArgsForCall = append(fake.takenSubnetsArgsForCall, struct{}{})
+	fake.recordInvocation("TakenSubnets", []interface{}{})
+	fake.takenSubnetsMutex.Unlock()
+	if fake.TakenSubnetsStub != nil {
+		return fake.TakenSubnetsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.takenSubnetsReturns.result1, fake.takenSubnetsReturns.result2
+}
+
+func (fake *LeaseTransaction) TakenSubnetsCallCount() int {
+	fake.takenSubnetsMutex.RLock()
+	defer fake.takenSubnetsMutex.RUnlock()
+	return len(fake.takenSubnetsArgsForCall)
+}
+
+func (fake *LeaseTransaction) TakenSubnetsReturns(result1 []string, result2 error) {
+	fake.TakenSubnetsStub = nil
+	fake.takenSubnetsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) TakenSubnetsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.TakenSubnetsStub = nil
+	if fake.takenSubnetsReturnsOnCall == nil {
+		fake.takenSubnetsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.takenSubnetsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) OldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	fake.oldestExpiredMutex.Lock()
+	ret, specificReturn := fake.oldestExpiredReturnsOnCall[len(fake.oldestExpiredArgsForCall)]
+	fake.oldestExpiredArgsForCall = append(fake.oldestExpiredArgsForCall, struct {
+		expirationTime int
+		reclaimable    func(overlaySubnet string) bool
+	}{expirationTime, reclaimable})
+	fake.recordInvocation("OldestExpired", []interface{}{expirationTime, reclaimable})
+	fake.oldestExpiredMutex.Unlock()
+	if fake.OldestExpiredStub != nil {
+		return fake.OldestExpiredStub(expirationTime, reclaimable)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.oldestExpiredReturns.result1, fake.oldestExpiredReturns.result2
+}
+
+func (fake *LeaseTransaction) OldestExpiredCallCount() int {
+	fake.oldestExpiredMutex.RLock()
+	defer fake.oldestExpiredMutex.RUnlock()
+	return len(fake.oldestExpiredArgsForCall)
+}
+
+func (fake *LeaseTransaction) OldestExpiredArgsForCall(i int) (int, func(overlaySubnet string) bool) {
+	fake.oldestExpiredMutex.RLock()
+	defer fake.oldestExpiredMutex.RUnlock()
+	return fake.oldestExpiredArgsForCall[i].expirationTime, fake.oldestExpiredArgsForCall[i].reclaimable
+}
+
+func (fake *LeaseTransaction) OldestExpiredReturns(result1 *controller.Lease, result2 error) {
+	fake.OldestExpiredStub = nil
+	fake.oldestExpiredReturns = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) OldestExpiredReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
+	fake.OldestExpiredStub = nil
+	if fake.oldestExpiredReturnsOnCall == nil {
+		fake.oldestExpiredReturnsOnCall = make(map[int]struct {
+			result1 *controller.Lease
+			result2 error
+		})
+	}
+	fake.oldestExpiredReturnsOnCall[i] = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) OldestReclaimable(expirationTime int, quarantineSeconds int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	fake.oldestReclaimableMutex.Lock()
+	ret, specificReturn := fake.oldestReclaimableReturnsOnCall[len(fake.oldestReclaimableArgsForCall)]
+	fake.oldestReclaimableArgsForCall = append(fake.oldestReclaimableArgsForCall, struct {
+		expirationTime    int
+		quarantineSeconds int
+		reclaimable       func(overlaySubnet string) bool
+	}{expirationTime, quarantineSeconds, reclaimable})
+	fake.recordInvocation("OldestReclaimable", []interface{}{expirationTime, quarantineSeconds, reclaimable})
+	fake.oldestReclaimableMutex.Unlock()
+	if fake.OldestReclaimableStub != nil {
+		return fake.OldestReclaimableStub(expirationTime, quarantineSeconds, reclaimable)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.oldestReclaimableReturns.result1, fake.oldestReclaimableReturns.result2
+}
+
+func (fake *LeaseTransaction) OldestReclaimableCallCount() int {
+	fake.oldestReclaimableMutex.RLock()
+	defer fake.oldestReclaimableMutex.RUnlock()
+	return len(fake.oldestReclaimableArgsForCall)
+}
+
+func (fake *LeaseTransaction) OldestReclaimableArgsForCall(i int) (int, int, func(overlaySubnet string) bool) {
+	fake.oldestReclaimableMutex.RLock()
+	defer fake.oldestReclaimableMutex.RUnlock()
+	return fake.oldestReclaimableArgsForCall[i].expirationTime, fake.oldestReclaimableArgsForCall[i].quarantineSeconds, fake.oldestReclaimableArgsForCall[i].reclaimable
+}
+
+func (fake *LeaseTransaction) OldestReclaimableReturns(result1 *controller.Lease, result2 error) {
+	fake.OldestReclaimableStub = nil
+	fake.oldestReclaimableReturns = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) OldestReclaimableReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
+	fake.OldestReclaimableStub = nil
+	if fake.oldestReclaimableReturnsOnCall == nil {
+		fake.oldestReclaimableReturnsOnCall = make(map[int]struct {
+			result1 *controller.Lease
+			result2 error
+		})
+	}
+	fake.oldestReclaimableReturnsOnCall[i] = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) QuarantineOldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	fake.quarantineOldestExpiredMutex.Lock()
+	ret, specificReturn := fake.quarantineOldestExpiredReturnsOnCall[len(fake.quarantineOldestExpiredArgsForCall)]
+	fake.quarantineOldestExpiredArgsForCall = append(fake.quarantineOldestExpiredArgsForCall, struct {
+		expirationTime int
+		reclaimable    func(overlaySubnet string) bool
+	}{expirationTime, reclaimable})
+	fake.recordInvocation("QuarantineOldestExpired", []interface{}{expirationTime, reclaimable})
+	fake.quarantineOldestExpiredMutex.Unlock()
+	if fake.QuarantineOldestExpiredStub != nil {
+		return fake.QuarantineOldestExpiredStub(expirationTime, reclaimable)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.quarantineOldestExpiredReturns.result1, fake.quarantineOldestExpiredReturns.result2
+}
+
+func (fake *LeaseTransaction) QuarantineOldestExpiredCallCount() int {
+	fake.quarantineOldestExpiredMutex.RLock()
+	defer fake.quarantineOldestExpiredMutex.RUnlock()
+	return len(fake.quarantineOldestExpiredArgsForCall)
+}
+
+func (fake *LeaseTransaction) QuarantineOldestExpiredArgsForCall(i int) (int, func(overlaySubnet string) bool) {
+	fake.quarantineOldestExpiredMutex.RLock()
+	defer fake.quarantineOldestExpiredMutex.RUnlock()
+	return fake.quarantineOldestExpiredArgsForCall[i].expirationTime, fake.quarantineOldestExpiredArgsForCall[i].reclaimable
+}
+
+func (fake *LeaseTransaction) QuarantineOldestExpiredReturns(result1 *controller.Lease, result2 error) {
+	fake.QuarantineOldestExpiredStub = nil
+	fake.quarantineOldestExpiredReturns = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) QuarantineOldestExpiredReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
+	fake.QuarantineOldestExpiredStub = nil
+	if fake.quarantineOldestExpiredReturnsOnCall == nil {
+		fake.quarantineOldestExpiredReturnsOnCall = make(map[int]struct {
+			result1 *controller.Lease
+			result2 error
+		})
+	}
+	fake.quarantineOldestExpiredReturnsOnCall[i] = struct {
+		result1 *controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) LeaseRecordForOverlaySubnet(overlaySubnet string, expirationTime int) (*controller.LeaseRecord, error) {
+	fake.leaseRecordForOverlaySubnetMutex.Lock()
+	ret, specificReturn := fake.leaseRecordForOverlaySubnetReturnsOnCall[len(fake.leaseRecordForOverlaySubnetArgsForCall)]
+	fake.leaseRecordForOverlaySubnetArgsForCall = append(fake.leaseRecordForOverlaySubnetArgsForCall, struct {
+		overlaySubnet  string
+		expirationTime int
+	}{overlaySubnet, expirationTime})
+	fake.recordInvocation("LeaseRecordForOverlaySubnet", []interface{}{overlaySubnet, expirationTime})
+	fake.leaseRecordForOverlaySubnetMutex.Unlock()
+	if fake.LeaseRecordForOverlaySubnetStub != nil {
+		return fake.LeaseRecordForOverlaySubnetStub(overlaySubnet, expirationTime)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.leaseRecordForOverlaySubnetReturns.result1, fake.leaseRecordForOverlaySubnetReturns.result2
+}
+
+func (fake *LeaseTransaction) LeaseRecordForOverlaySubnetCallCount() int {
+	fake.leaseRecordForOverlaySubnetMutex.RLock()
+	defer fake.leaseRecordForOverlaySubnetMutex.RUnlock()
+	return len(fake.leaseRecordForOverlaySubnetArgsForCall)
+}
+
+func (fake *LeaseTransaction) LeaseRecordForOverlaySubnetArgsForCall(i int) (string, int) {
+	fake.leaseRecordForOverlaySubnetMutex.RLock()
+	defer fake.leaseRecordForOverlaySubnetMutex.RUnlock()
+	return fake.leaseRecordForOverlaySubnetArgsForCall[i].overlaySubnet, fake.leaseRecordForOverlaySubnetArgsForCall[i].expirationTime
+}
+
+func (fake *LeaseTransaction) LeaseRecordForOverlaySubnetReturns(result1 *controller.LeaseRecord, result2 error) {
+	fake.LeaseRecordForOverlaySubnetStub = nil
+	fake.leaseRecordForOverlaySubnetReturns = struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) LeaseRecordForOverlaySubnetReturnsOnCall(i int, result1 *controller.LeaseRecord, result2 error) {
+	fake.LeaseRecordForOverlaySubnetStub = nil
+	if fake.leaseRecordForOverlaySubnetReturnsOnCall == nil {
+		fake.leaseRecordForOverlaySubnetReturnsOnCall = make(map[int]struct {
+			result1 *controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.leaseRecordForOverlaySubnetReturnsOnCall[i] = struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseTransaction) AddEntry(arg1 controller.Lease) error {
+	fake.addEntryMutex.Lock()
+	ret, specificReturn := fake.addEntryReturnsOnCall[len(fake.addEntryArgsForCall)]
+	fake.addEntryArgsForCall = append(fake.addEntryArgsForCall, struct {
+		arg1 controller.Lease
+	}{arg1})
+	fake.recordInvocation("AddEntry", []interface{}{arg1})
+	fake.addEntryMutex.Unlock()
+	if fake.AddEntryStub != nil {
+		return fake.AddEntryStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.addEntryReturns.result1
+}
+
+func (fake *LeaseTransaction) AddEntryCallCount() int {
+	fake.addEntryMutex.RLock()
+	defer fake.addEntryMutex.RUnlock()
+	return len(fake.addEntryArgsForCall)
+}
+
+func (fake *LeaseTransaction) AddEntryArgsForCall(i int) controller.Lease {
+	fake.addEntryMutex.RLock()
+	defer fake.addEntryMutex.RUnlock()
+	return fake.addEntryArgsForCall[i].arg1
+}
+
+func (fake *LeaseTransaction) AddEntryReturns(result1 error) {
+	fake.AddEntryStub = nil
+	fake.addEntryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) AddEntryReturnsOnCall(i int, result1 error) {
+	fake.AddEntryStub = nil
+	if fake.addEntryReturnsOnCall == nil {
+		fake.addEntryReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addEntryReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) ReassignEntry(arg1 controller.Lease) error {
+	fake.reassignEntryMutex.Lock()
+	ret, specificReturn := fake.reassignEntryReturnsOnCall[len(fake.reassignEntryArgsForCall)]
+	fake.reassignEntryArgsForCall = append(fake.reassignEntryArgsForCall, struct {
+		arg1 controller.Lease
+	}{arg1})
+	fake.recordInvocation("ReassignEntry", []interface{}{arg1})
+	fake.reassignEntryMutex.Unlock()
+	if fake.ReassignEntryStub != nil {
+		return fake.ReassignEntryStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.reassignEntryReturns.result1
+}
+
+func (fake *LeaseTransaction) ReassignEntryCallCount() int {
+	fake.reassignEntryMutex.RLock()
+	defer fake.reassignEntryMutex.RUnlock()
+	return len(fake.reassignEntryArgsForCall)
+}
+
+func (fake *LeaseTransaction) ReassignEntryArgsForCall(i int) controller.Lease {
+	fake.reassignEntryMutex.RLock()
+	defer fake.reassignEntryMutex.RUnlock()
+	return fake.reassignEntryArgsForCall[i].arg1
+}
+
+func (fake *LeaseTransaction) ReassignEntryReturns(result1 error) {
+	fake.ReassignEntryStub = nil
+	fake.reassignEntryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) ReassignEntryReturnsOnCall(i int, result1 error) {
+	fake.ReassignEntryStub = nil
+	if fake.reassignEntryReturnsOnCall == nil {
+		fake.reassignEntryReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.reassignEntryReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) AddLeaseEvent(eventType string, lease controller.Lease) error {
+	fake.addLeaseEventMutex.Lock()
+	ret, specificReturn := fake.addLeaseEventReturnsOnCall[len(fake.addLeaseEventArgsForCall)]
+	fake.addLeaseEventArgsForCall = append(fake.addLeaseEventArgsForCall, struct {
+		eventType string
+		lease     controller.Lease
+	}{eventType, lease})
+	fake.recordInvocation("AddLeaseEvent", []interface{}{eventType, lease})
+	fake.addLeaseEventMutex.Unlock()
+	if fake.AddLeaseEventStub != nil {
+		return fake.AddLeaseEventStub(eventType, lease)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.addLeaseEventReturns.result1
+}
+
+func (fake *LeaseTransaction) AddLeaseEventCallCount() int {
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	return len(fake.addLeaseEventArgsForCall)
+}
+
+func (fake *LeaseTransaction) AddLeaseEventArgsForCall(i int) (string, controller.Lease) {
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	return fake.addLeaseEventArgsForCall[i].eventType, fake.addLeaseEventArgsForCall[i].lease
+}
+
+func (fake *LeaseTransaction) AddLeaseEventReturns(result1 error) {
+	fake.AddLeaseEventStub = nil
+	fake.addLeaseEventReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) AddLeaseEventReturnsOnCall(i int, result1 error) {
+	fake.AddLeaseEventStub = nil
+	if fake.addLeaseEventReturnsOnCall == nil {
+		fake.addLeaseEventReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addLeaseEventReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) BumpRevision() error {
+	fake.bumpRevisionMutex.Lock()
+	ret, specificReturn := fake.bumpRevisionReturnsOnCall[len(fake.bumpRevisionArgsForCall)]
+	fake.bumpRevisionArgsForCall = append(fake.bumpRevisionArgsForCall, struct{}{})
+	fake.recordInvocation("BumpRevision", []interface{}{})
+	fake.bumpRevisionMutex.Unlock()
+	if fake.BumpRevisionStub != nil {
+		return fake.BumpRevisionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.bumpRevisionReturns.result1
+}
+
+func (fake *LeaseTransaction) BumpRevisionCallCount() int {
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
+	return len(fake.bumpRevisionArgsForCall)
+}
+
+func (fake *LeaseTransaction) BumpRevisionReturns(result1 error) {
+	fake.BumpRevisionStub = nil
+	fake.bumpRevisionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) BumpRevisionReturnsOnCall(i int, result1 error) {
+	fake.BumpRevisionStub = nil
+	if fake.bumpRevisionReturnsOnCall == nil {
+		fake.bumpRevisionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.bumpRevisionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) Commit() error {
+	fake.commitMutex.Lock()
+	ret, specificReturn := fake.commitReturnsOnCall[len(fake.commitArgsForCall)]
+	fake.commitArgsForCall = append(fake.commitArgsForCall, struct{}{})
+	fake.recordInvocation("Commit", []interface{}{})
+	fake.commitMutex.Unlock()
+	if fake.CommitStub != nil {
+		return fake.CommitStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.commitReturns.result1
+}
+
+func (fake *LeaseTransaction) CommitCallCount() int {
+	fake.commitMutex.RLock()
+	defer fake.commitMutex.RUnlock()
+	return len(fake.commitArgsForCall)
+}
+
+func (fake *LeaseTransaction) CommitReturns(result1 error) {
+	fake.CommitStub = nil
+	fake.commitReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) CommitReturnsOnCall(i int, result1 error) {
+	fake.CommitStub = nil
+	if fake.commitReturnsOnCall == nil {
+		fake.commitReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.commitReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) Rollback() error {
+	fake.rollbackMutex.Lock()
+	ret, specificReturn := fake.rollbackReturnsOnCall[len(fake.rollbackArgsForCall)]
+	fake.rollbackArgsForCall = append(fake.rollbackArgsForCall, struct{}{})
+	fake.recordInvocation("Rollback", []interface{}{})
+	fake.rollbackMutex.Unlock()
+	if fake.RollbackStub != nil {
+		return fake.RollbackStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.rollbackReturns.result1
+}
+
+func (fake *LeaseTransaction) RollbackCallCount() int {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	return len(fake.rollbackArgsForCall)
+}
+
+func (fake *LeaseTransaction) RollbackReturns(result1 error) {
+	fake.RollbackStub = nil
+	fake.rollbackReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) RollbackReturnsOnCall(i int, result1 error) {
+	fake.RollbackStub = nil
+	if fake.rollbackReturnsOnCall == nil {
+		fake.rollbackReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.rollbackReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LeaseTransaction) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.takenSubnetsMutex.RLock()
+	defer fake.takenSubnetsMutex.RUnlock()
+	fake.oldestExpiredMutex.RLock()
+	defer fake.oldestExpiredMutex.RUnlock()
+	fake.oldestReclaimableMutex.RLock()
+	defer fake.oldestReclaimableMutex.RUnlock()
+	fake.quarantineOldestExpiredMutex.RLock()
+	defer fake.quarantineOldestExpiredMutex.RUnlock()
+	fake.leaseRecordForOverlaySubnetMutex.RLock()
+	defer fake.leaseRecordForOverlaySubnetMutex.RUnlock()
+	fake.addEntryMutex.RLock()
+	defer fake.addEntryMutex.RUnlock()
+	fake.reassignEntryMutex.RLock()
+	defer fake.reassignEntryMutex.RUnlock()
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
+	fake.commitMutex.RLock()
+	defer fake.commitMutex.RUnlock()
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseTransaction) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ database.LeaseTransaction = new(LeaseTransaction)
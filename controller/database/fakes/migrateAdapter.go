@@ -5,7 +5,7 @@ import (
 	"sync"
 
 	"code.cloudfoundry.org/silk/controller/database"
-	"github.com/rubenv/sql-migrate"
+	migrate "github.com/rubenv/sql-migrate"
 )
 
 type MigrateAdapter struct {
@@ -25,6 +25,37 @@ type MigrateAdapter struct {
 		result1 int
 		result2 error
 	}
+	ExecMaxStub        func(db database.Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection, max int) (int, error)
+	execMaxMutex       sync.RWMutex
+	execMaxArgsForCall []struct {
+		db      database.Db
+		dialect string
+		m       migrate.MigrationSource
+		dir     migrate.MigrationDirection
+		max     int
+	}
+	execMaxReturns struct {
+		result1 int
+		result2 error
+	}
+	execMaxReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	GetMigrationRecordsStub        func(db database.Db, dialect string) ([]*migrate.MigrationRecord, error)
+	getMigrationRecordsMutex       sync.RWMutex
+	getMigrationRecordsArgsForCall []struct {
+		db      database.Db
+		dialect string
+	}
+	getMigrationRecordsReturns struct {
+		result1 []*migrate.MigrationRecord
+		result2 error
+	}
+	getMigrationRecordsReturnsOnCall map[int]struct {
+		result1 []*migrate.MigrationRecord
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -83,11 +114,122 @@ func (fake *MigrateAdapter) ExecReturnsOnCall(i int, result1 int, result2 error)
 	}{result1, result2}
 }
 
+func (fake *MigrateAdapter) ExecMax(db database.Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection, max int) (int, error) {
+	fake.execMaxMutex.Lock()
+	ret, specificReturn := fake.execMaxReturnsOnCall[len(fake.execMaxArgsForCall)]
+	fake.execMaxArgsForCall = append(fake.execMaxArgsForCall, struct {
+		db      database.Db
+		dialect string
+		m       migrate.MigrationSource
+		dir     migrate.MigrationDirection
+		max     int
+	}{db, dialect, m, dir, max})
+	fake.recordInvocation("ExecMax", []interface{}{db, dialect, m, dir, max})
+	fake.execMaxMutex.Unlock()
+	if fake.ExecMaxStub != nil {
+		return fake.ExecMaxStub(db, dialect, m, dir, max)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.execMaxReturns.result1, fake.execMaxReturns.result2
+}
+
+func (fake *MigrateAdapter) ExecMaxCallCount() int {
+	fake.execMaxMutex.RLock()
+	defer fake.execMaxMutex.RUnlock()
+	return len(fake.execMaxArgsForCall)
+}
+
+func (fake *MigrateAdapter) ExecMaxArgsForCall(i int) (database.Db, string, migrate.MigrationSource, migrate.MigrationDirection, int) {
+	fake.execMaxMutex.RLock()
+	defer fake.execMaxMutex.RUnlock()
+	return fake.execMaxArgsForCall[i].db, fake.execMaxArgsForCall[i].dialect, fake.execMaxArgsForCall[i].m, fake.execMaxArgsForCall[i].dir, fake.execMaxArgsForCall[i].max
+}
+
+func (fake *MigrateAdapter) ExecMaxReturns(result1 int, result2 error) {
+	fake.ExecMaxStub = nil
+	fake.execMaxReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MigrateAdapter) ExecMaxReturnsOnCall(i int, result1 int, result2 error) {
+	fake.ExecMaxStub = nil
+	if fake.execMaxReturnsOnCall == nil {
+		fake.execMaxReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.execMaxReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MigrateAdapter) GetMigrationRecords(db database.Db, dialect string) ([]*migrate.MigrationRecord, error) {
+	fake.getMigrationRecordsMutex.Lock()
+	ret, specificReturn := fake.getMigrationRecordsReturnsOnCall[len(fake.getMigrationRecordsArgsForCall)]
+	fake.getMigrationRecordsArgsForCall = append(fake.getMigrationRecordsArgsForCall, struct {
+		db      database.Db
+		dialect string
+	}{db, dialect})
+	fake.recordInvocation("GetMigrationRecords", []interface{}{db, dialect})
+	fake.getMigrationRecordsMutex.Unlock()
+	if fake.GetMigrationRecordsStub != nil {
+		return fake.GetMigrationRecordsStub(db, dialect)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getMigrationRecordsReturns.result1, fake.getMigrationRecordsReturns.result2
+}
+
+func (fake *MigrateAdapter) GetMigrationRecordsCallCount() int {
+	fake.getMigrationRecordsMutex.RLock()
+	defer fake.getMigrationRecordsMutex.RUnlock()
+	return len(fake.getMigrationRecordsArgsForCall)
+}
+
+func (fake *MigrateAdapter) GetMigrationRecordsArgsForCall(i int) (database.Db, string) {
+	fake.getMigrationRecordsMutex.RLock()
+	defer fake.getMigrationRecordsMutex.RUnlock()
+	return fake.getMigrationRecordsArgsForCall[i].db, fake.getMigrationRecordsArgsForCall[i].dialect
+}
+
+func (fake *MigrateAdapter) GetMigrationRecordsReturns(result1 []*migrate.MigrationRecord, result2 error) {
+	fake.GetMigrationRecordsStub = nil
+	fake.getMigrationRecordsReturns = struct {
+		result1 []*migrate.MigrationRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MigrateAdapter) GetMigrationRecordsReturnsOnCall(i int, result1 []*migrate.MigrationRecord, result2 error) {
+	fake.GetMigrationRecordsStub = nil
+	if fake.getMigrationRecordsReturnsOnCall == nil {
+		fake.getMigrationRecordsReturnsOnCall = make(map[int]struct {
+			result1 []*migrate.MigrationRecord
+			result2 error
+		})
+	}
+	fake.getMigrationRecordsReturnsOnCall[i] = struct {
+		result1 []*migrate.MigrationRecord
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *MigrateAdapter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.execMutex.RLock()
 	defer fake.execMutex.RUnlock()
+	fake.execMaxMutex.RLock()
+	defer fake.execMaxMutex.RUnlock()
+	fake.getMigrationRecordsMutex.RLock()
+	defer fake.getMigrationRecordsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,292 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"code.cloudfoundry.org/silk/controller"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:generate counterfeiter -o fakes/lease_transaction.go --fake-name LeaseTransaction . LeaseTransaction
+type LeaseTransaction interface {
+	TakenSubnets() ([]string, error)
+	OldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	OldestReclaimable(expirationTime, quarantineSeconds int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	QuarantineOldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error)
+	LeaseRecordForOverlaySubnet(overlaySubnet string, expirationTime int) (*controller.LeaseRecord, error)
+	AddEntry(controller.Lease) error
+	ReassignEntry(controller.Lease) error
+	AddLeaseEvent(eventType string, lease controller.Lease) error
+	BumpRevision() error
+	Commit() error
+	Rollback() error
+}
+
+// leaseTransaction serializes acquisitions from one pool by holding the
+// pool's row in subnet_allocation_locks until it commits, so concurrent
+// controllers never pick the same free subnet.
+type leaseTransaction struct {
+	tx              *sqlx.Tx
+	singleOverlayIP bool
+	ipVersion       int
+}
+
+func (d *DatabaseHandler) BeginLeaseTransaction(singleOverlayIP, ipv6 bool) (LeaseTransaction, error) {
+	if _, err := timestampForDriver(d.db.DriverName()); err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.RawConnection().Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %s", err)
+	}
+
+	t := &leaseTransaction{
+		tx:              tx,
+		singleOverlayIP: singleOverlayIP,
+		ipVersion:       overlayIPVersion(ipv6),
+	}
+
+	var name string
+	err = tx.QueryRow(tx.Rebind("SELECT name FROM subnet_allocation_locks WHERE name = ?"+forUpdate(tx.DriverName())), t.lockName()).Scan(&name)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("locking subnet allocation: %s", err)
+	}
+
+	return t, nil
+}
+
+// TakenSubnets also returns the reserved subnets of every pool; the CIDRPool
+// ignores the ones outside of it.
+func (t *leaseTransaction) TakenSubnets() ([]string, error) {
+	rows, err := t.tx.Query(t.tx.Rebind(fmt.Sprintf("SELECT overlay_subnet FROM subnets WHERE %s AND overlay_ip_version = ? UNION SELECT overlay_subnet FROM reserved_subnets", t.poolCondition())), t.ipVersion)
+	if err != nil {
+		return nil, fmt.Errorf("selecting taken subnets: %s", err)
+	}
+	defer rows.Close() // untested
+
+	var taken []string
+	for rows.Next() {
+		var overlaySubnet string
+		if err := rows.Scan(&overlaySubnet); err != nil {
+			return nil, fmt.Errorf("selecting taken subnets: parsing result: %s", err)
+		}
+		taken = append(taken, overlaySubnet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("selecting taken subnets: getting next row: %s", err) // untested
+	}
+	return taken, nil
+}
+
+// OldestExpired locks and returns the least recently renewed expired lease
+// of the pool. Reserved subnets, the subnets reclaimable rejects and leases
+// renewed by a concurrent transaction are skipped; a nil reclaimable accepts
+// every subnet.
+func (t *leaseTransaction) OldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	return t.oldestExpired(expirationTime, "", reclaimable)
+}
+
+// OldestReclaimable is OldestExpired restricted to the leases that have been
+// quarantined for at least quarantineSeconds.
+func (t *leaseTransaction) OldestReclaimable(expirationTime, quarantineSeconds int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	return t.oldestExpired(expirationTime, fmt.Sprintf(" AND quarantined_at > 0 AND quarantined_at + %d <= %s", quarantineSeconds, timestamp), reclaimable)
+}
+
+// QuarantineOldestExpired marks the oldest expired lease that is not
+// quarantined yet and returns it, or nil if there is none. Renewing the lease
+// lifts the quarantine.
+func (t *leaseTransaction) QuarantineOldestExpired(expirationTime int, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return nil, err
+	}
+
+	lease, err := t.oldestExpired(expirationTime, " AND quarantined_at = 0", reclaimable)
+	if err != nil || lease == nil {
+		return lease, err
+	}
+
+	_, err = t.tx.Exec(t.tx.Rebind(fmt.Sprintf("UPDATE subnets SET quarantined_at = %s WHERE overlay_subnet = ?", timestamp)), lease.OverlaySubnet)
+	if err != nil {
+		return nil, fmt.Errorf("quarantining lease: %s", err)
+	}
+	return lease, nil
+}
+
+// oldestExpired first lists the expired subnets of the pool, oldest first,
+// without locking them, so the pool can reject subnets without binding them
+// to the query. It then locks the first accepted one that is still expired,
+// waiting for a concurrent renewal of it to finish. Renewals never hold the
+// pool's lock, so the wait always ends.
+func (t *leaseTransaction) oldestExpired(expirationTime int, condition string, reclaimable func(overlaySubnet string) bool) (*controller.Lease, error) {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return nil, err
+	}
+	expired := fmt.Sprintf("last_renewed_at + %d <= %s%s", expirationTime, timestamp, condition)
+
+	candidates, err := t.expiredSubnets(expired, reclaimable)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		var underlayIP, overlaySubnet, overlayHWAddr string
+		result := t.tx.QueryRow(t.tx.Rebind(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet = ? AND %s%s", expired, forUpdate(t.tx.DriverName()))), candidate)
+		err = result.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scan result: %s", err)
+		}
+		return &controller.Lease{
+			UnderlayIP:          underlayIP,
+			OverlaySubnet:       overlaySubnet,
+			OverlayHardwareAddr: overlayHWAddr,
+		}, nil
+	}
+	return nil, nil
+}
+
+func (t *leaseTransaction) expiredSubnets(expired string, reclaimable func(overlaySubnet string) bool) ([]string, error) {
+	rows, err := t.tx.Query(t.tx.Rebind(fmt.Sprintf("SELECT overlay_subnet FROM subnets WHERE %s AND overlay_ip_version = ? AND %s AND overlay_subnet NOT IN (SELECT overlay_subnet FROM reserved_subnets) ORDER BY last_renewed_at ASC", t.poolCondition(), expired)), t.ipVersion)
+	if err != nil {
+		return nil, fmt.Errorf("selecting expired subnets: %s", err)
+	}
+	defer rows.Close() // untested
+
+	var subnets []string
+	for rows.Next() {
+		var overlaySubnet string
+		if err := rows.Scan(&overlaySubnet); err != nil {
+			return nil, fmt.Errorf("selecting expired subnets: parsing result: %s", err)
+		}
+		if reclaimable == nil || reclaimable(overlaySubnet) {
+			subnets = append(subnets, overlaySubnet)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("selecting expired subnets: getting next row: %s", err) // untested
+	}
+	return subnets, nil
+}
+
+// LeaseRecordForOverlaySubnet locks and returns the lease holding the
+// overlay subnet, or nil if it is free.
+func (t *leaseTransaction) LeaseRecordForOverlaySubnet(overlaySubnet string, expirationTime int) (*controller.LeaseRecord, error) {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return nil, err
+	}
+
+	record := controller.LeaseRecord{OverlaySubnet: overlaySubnet}
+	var expired int
+	result := t.tx.QueryRow(t.tx.Rebind(fmt.Sprintf("SELECT underlay_ip, overlay_hwaddr, last_renewed_at, CASE WHEN last_renewed_at + %d <= %s THEN 1 ELSE 0 END FROM subnets WHERE overlay_subnet = ?%s", expirationTime, timestamp, forUpdate(t.tx.DriverName()))), overlaySubnet)
+	err = result.Scan(&record.UnderlayIP, &record.OverlayHardwareAddr, &record.LastRenewedAt, &expired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("scan result: %s", err)
+	}
+	record.Expired = expired == 1
+	return &record, nil
+}
+
+func (t *leaseTransaction) AddEntry(lease controller.Lease) error {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return err
+	}
+
+	labels, err := encodeLabels(lease.Labels)
+	if err != nil {
+		return fmt.Errorf("adding entry: %s", err)
+	}
+
+	_, err = t.tx.Exec(t.tx.Rebind(fmt.Sprintf("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, overlay_ip_version, last_renewed_at, cell_id, az, labels) VALUES (?, ?, ?, ?, %s, ?, ?, ?)", timestamp)), lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr, overlayIPVersionForSubnet(lease.OverlaySubnet), lease.CellID, lease.AZ, labels)
+	if err != nil {
+		return fmt.Errorf("adding entry: %s", err)
+	}
+	return nil
+}
+
+// ReassignEntry hands the row of an expired lease for lease.OverlaySubnet to
+// a new underlay ip, so the subnet is never released while it is reclaimed.
+// The new lease starts out of quarantine.
+func (t *leaseTransaction) ReassignEntry(lease controller.Lease) error {
+	timestamp, err := timestampForDriver(t.tx.DriverName())
+	if err != nil {
+		return err
+	}
+
+	labels, err := encodeLabels(lease.Labels)
+	if err != nil {
+		return fmt.Errorf("reassigning entry: %s", err)
+	}
+
+	result, err := t.tx.Exec(t.tx.Rebind(fmt.Sprintf("UPDATE subnets SET underlay_ip = ?, overlay_hwaddr = ?, last_renewed_at = %s, quarantined_at = 0, cell_id = ?, az = ?, labels = ? WHERE overlay_subnet = ?", timestamp)), lease.UnderlayIP, lease.OverlayHardwareAddr, lease.CellID, lease.AZ, labels, lease.OverlaySubnet)
+	if err != nil {
+		return fmt.Errorf("reassigning entry: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("parse result: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return RecordNotAffectedError
+	}
+
+	return nil
+}
+
+func (t *leaseTransaction) AddLeaseEvent(eventType string, lease controller.Lease) error {
+	return addLeaseEvent(t.tx, eventType, lease)
+}
+
+func (t *leaseTransaction) BumpRevision() error {
+	return bumpRevision(t.tx)
+}
+
+func (t *leaseTransaction) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *leaseTransaction) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *leaseTransaction) lockName() string {
+	if t.singleOverlayIP {
+		return fmt.Sprintf("single-%d", t.ipVersion)
+	}
+	return fmt.Sprintf("block-%d", t.ipVersion)
+}
+
+func (t *leaseTransaction) poolCondition() string {
+	if t.singleOverlayIP {
+		return singleIPSubnetCondition
+	}
+	return "NOT " + singleIPSubnetCondition
+}
+
+// forUpdate returns the row locking clause for the driver. sqlite has no row
+// locks; its connection is limited to one, which serializes the transactions.
+// SKIP LOCKED is left out since MySQL before 8.0 and Postgres before 9.5 do
+// not support it.
+func forUpdate(driverName string) string {
+	if driverName == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
@@ -0,0 +1,515 @@
+package database_test
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/db"
+	"code.cloudfoundry.org/cf-networking-helpers/testsupport"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
+	"code.cloudfoundry.org/silk/controller/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseTransaction", func() {
+	var (
+		databaseHandler *database.DatabaseHandler
+		realDb          *db.ConnWrapper
+		dbConfig        db.Config
+		blockLease      controller.Lease
+		singleIPLease   controller.Lease
+		ipv6Lease       controller.Lease
+	)
+
+	BeforeEach(func() {
+		dbConfig = testsupport.GetDBConfig()
+		dbConfig.DatabaseName = fmt.Sprintf("test_db_%03d_%x", GinkgoParallelNode(), rand.Int())
+		testsupport.CreateDatabase(dbConfig)
+
+		var err error
+		realDb, err = db.NewConnectionPool(
+			dbConfig,
+			200,
+			200,
+			5*time.Minute,
+			"controller",
+			"lease-transaction",
+			lagertest.NewTestLogger("test"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		databaseHandler = database.NewDatabaseHandler(&database.MigrateAdapter{}, realDb)
+		_, err = databaseHandler.Migrate()
+		Expect(err).NotTo(HaveOccurred())
+
+		blockLease = controller.Lease{
+			UnderlayIP:          "10.244.11.22",
+			OverlaySubnet:       "10.255.17.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:11:00",
+		}
+		singleIPLease = controller.Lease{
+			UnderlayIP:          "10.244.11.26",
+			OverlaySubnet:       "10.255.0.12/32",
+			OverlayHardwareAddr: "ee:ee:0a:ff:00:0c",
+		}
+		ipv6Lease = controller.Lease{
+			UnderlayIP:          "10.244.11.22",
+			OverlaySubnet:       "fd00:10:255:11::/64",
+			OverlayHardwareAddr: "ee:e6:1d:2c:3b:4a",
+		}
+		Expect(databaseHandler.AddEntry(blockLease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(singleIPLease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(ipv6Lease)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if realDb != nil {
+			Expect(realDb.Close()).To(Succeed())
+		}
+		testsupport.RemoveDatabase(dbConfig)
+	})
+
+	Describe("TakenSubnets", func() {
+		It("returns the subnets of the pool being allocated from", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			taken, err := tx.TakenSubnets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taken).To(ConsistOf("10.255.17.0/24"))
+		})
+
+		It("returns the single ip subnets", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(true, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			taken, err := tx.TakenSubnets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taken).To(ConsistOf("10.255.0.12/32"))
+		})
+
+		It("includes the reserved subnets", func() {
+			Expect(databaseHandler.AddReservation("10.255.30.0/24")).To(Succeed())
+
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			taken, err := tx.TakenSubnets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taken).To(ConsistOf("10.255.17.0/24", "10.255.30.0/24"))
+		})
+
+		It("does not list a reserved subnet twice when it is also leased", func() {
+			Expect(databaseHandler.AddReservation("10.255.17.0/24")).To(Succeed())
+
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			taken, err := tx.TakenSubnets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taken).To(ConsistOf("10.255.17.0/24"))
+		})
+
+		It("returns the ipv6 subnets", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, true)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			taken, err := tx.TakenSubnets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taken).To(ConsistOf("fd00:10:255:11::/64"))
+		})
+	})
+
+	Describe("AddEntry", func() {
+		It("adds the entry when the transaction is committed", func() {
+			newLease := controller.Lease{
+				UnderlayIP:          "10.244.11.23",
+				OverlaySubnet:       "10.255.18.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:12:00",
+			}
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.AddEntry(newLease)).To(Succeed())
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).NotTo(ContainElement(newLease))
+
+			Expect(tx.Commit()).To(Succeed())
+
+			leases, err = databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(ContainElement(newLease))
+		})
+
+		It("does not add the entry when the transaction is rolled back", func() {
+			newLease := controller.Lease{
+				UnderlayIP:          "10.244.11.23",
+				OverlaySubnet:       "10.255.18.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:12:00",
+			}
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.AddEntry(newLease)).To(Succeed())
+			Expect(tx.Rollback()).To(Succeed())
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).NotTo(ContainElement(newLease))
+		})
+	})
+
+	Describe("AddLeaseEvent", func() {
+		It("records the event when the transaction is committed", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.AddLeaseEvent("reclaim", blockLease)).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+
+			events, err := databaseHandler.LeaseEventsForOverlaySubnet(blockLease.OverlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].EventType).To(Equal("reclaim"))
+			Expect(events[0].UnderlayIP).To(Equal(blockLease.UnderlayIP))
+		})
+
+		It("does not record the event when the transaction is rolled back", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.AddLeaseEvent("reclaim", blockLease)).To(Succeed())
+			Expect(tx.Rollback()).To(Succeed())
+
+			events, err := databaseHandler.LeaseEventsForOverlaySubnet(blockLease.OverlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+		})
+	})
+
+	Describe("BumpRevision", func() {
+		It("bumps the revision when the transaction is committed", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.BumpRevision()).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+
+			revision, err := databaseHandler.Revision()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal(int64(1)))
+		})
+
+		It("does not bump the revision when the transaction is rolled back", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.BumpRevision()).To(Succeed())
+			Expect(tx.Rollback()).To(Succeed())
+
+			revision, err := databaseHandler.Revision()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revision).To(Equal(int64(0)))
+		})
+	})
+
+	Describe("OldestExpired", func() {
+		It("gets the oldest expired lease of the pool", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			expiredLease, err := tx.OldestExpired(0, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiredLease).To(Equal(&blockLease))
+		})
+
+		It("gets the oldest expired single ip lease", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(true, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			expiredLease, err := tx.OldestExpired(0, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiredLease).To(Equal(&singleIPLease))
+		})
+
+		Context("when the expired lease is reserved", func() {
+			It("does not reclaim it", func() {
+				Expect(databaseHandler.AddReservation(blockLease.OverlaySubnet)).To(Succeed())
+
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				expiredLease, err := tx.OldestExpired(0, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expiredLease).To(BeNil())
+			})
+		})
+
+		Context("when the expired lease is excluded", func() {
+			It("does not reclaim it", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				expiredLease, err := tx.OldestExpired(0, func(subnet string) bool {
+					return subnet != blockLease.OverlaySubnet
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expiredLease).To(BeNil())
+			})
+		})
+
+		Context("when no lease is expired", func() {
+			It("returns nil and does not error", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				expiredLease, err := tx.OldestExpired(23, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expiredLease).To(BeNil())
+			})
+		})
+
+		Context("when the expired lease is locked by another transaction", func() {
+			It("waits for it and returns the lease as the other transaction left it", func() {
+				lockingTx, err := databaseHandler.BeginLeaseTransaction(true, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer lockingTx.Rollback()
+				reassignedLease := controller.Lease{
+					UnderlayIP:          "10.244.11.99",
+					OverlaySubnet:       blockLease.OverlaySubnet,
+					OverlayHardwareAddr: blockLease.OverlayHardwareAddr,
+				}
+				Expect(lockingTx.ReassignEntry(reassignedLease)).To(Succeed())
+
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				expiredLeases := make(chan *controller.Lease)
+				go func() {
+					defer GinkgoRecover()
+					expiredLease, err := tx.OldestExpired(0, nil)
+					Expect(err).NotTo(HaveOccurred())
+					expiredLeases <- expiredLease
+				}()
+				Consistently(expiredLeases, "200ms").ShouldNot(Receive())
+
+				Expect(lockingTx.Commit()).To(Succeed())
+				Eventually(expiredLeases).Should(Receive(Equal(&reassignedLease)))
+			})
+		})
+	})
+
+	Describe("QuarantineOldestExpired", func() {
+		It("quarantines the oldest expired lease of the pool", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			quarantinedLease, err := tx.QuarantineOldestExpired(0, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(quarantinedLease).To(Equal(&blockLease))
+			Expect(tx.Commit()).To(Succeed())
+
+			records, err := databaseHandler.AllLeaseRecords(0)
+			Expect(err).NotTo(HaveOccurred())
+			for _, record := range records {
+				Expect(record.Quarantined).To(Equal(record.OverlaySubnet == blockLease.OverlaySubnet))
+			}
+		})
+
+		Context("when the oldest expired lease is already quarantined", func() {
+			It("does not quarantine it again", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				_, err = tx.QuarantineOldestExpired(0, nil)
+				Expect(err).NotTo(HaveOccurred())
+				quarantinedLease, err := tx.QuarantineOldestExpired(0, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(quarantinedLease).To(BeNil())
+			})
+		})
+
+		Context("when no lease is expired", func() {
+			It("returns nil and does not error", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				quarantinedLease, err := tx.QuarantineOldestExpired(23, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(quarantinedLease).To(BeNil())
+			})
+		})
+	})
+
+	Describe("OldestReclaimable", func() {
+		BeforeEach(func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = tx.QuarantineOldestExpired(0, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.Commit()).To(Succeed())
+		})
+
+		It("gets the oldest lease whose quarantine is over", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			reclaimableLease, err := tx.OldestReclaimable(0, 0, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaimableLease).To(Equal(&blockLease))
+		})
+
+		Context("when the lease is still in quarantine", func() {
+			It("returns nil", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				reclaimableLease, err := tx.OldestReclaimable(0, 1000, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reclaimableLease).To(BeNil())
+			})
+		})
+
+		Context("when the lease was renewed", func() {
+			It("returns nil", func() {
+				Expect(databaseHandler.RenewLeaseForUnderlayIP(blockLease.UnderlayIP, false)).To(Succeed())
+
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				reclaimableLease, err := tx.OldestReclaimable(0, 0, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reclaimableLease).To(BeNil())
+			})
+		})
+	})
+
+	Describe("LeaseRecordForOverlaySubnet", func() {
+		It("returns the holder of the overlay subnet", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			record, err := tx.LeaseRecordForOverlaySubnet(blockLease.OverlaySubnet, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.UnderlayIP).To(Equal(blockLease.UnderlayIP))
+			Expect(record.OverlaySubnet).To(Equal(blockLease.OverlaySubnet))
+			Expect(record.OverlayHardwareAddr).To(Equal(blockLease.OverlayHardwareAddr))
+			Expect(record.LastRenewedAt).To(BeNumerically(">", 0))
+			Expect(record.Expired).To(BeFalse())
+		})
+
+		It("flags an expired holder", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			record, err := tx.LeaseRecordForOverlaySubnet(blockLease.OverlaySubnet, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.Expired).To(BeTrue())
+		})
+
+		Context("when the overlay subnet is free", func() {
+			It("returns nil", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				record, err := tx.LeaseRecordForOverlaySubnet("10.255.99.0/24", 1000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(record).To(BeNil())
+			})
+		})
+	})
+
+	Describe("ReassignEntry", func() {
+		It("hands the subnet to the new underlay ip", func() {
+			reclaimedLease := controller.Lease{
+				UnderlayIP:          "10.244.11.23",
+				OverlaySubnet:       blockLease.OverlaySubnet,
+				OverlayHardwareAddr: blockLease.OverlayHardwareAddr,
+			}
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.ReassignEntry(reclaimedLease)).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+
+			leases, err := databaseHandler.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(ContainElement(reclaimedLease))
+			Expect(leases).NotTo(ContainElement(blockLease))
+		})
+
+		Context("when there is no entry for the subnet", func() {
+			It("returns a RecordNotAffectedError", func() {
+				tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				err = tx.ReassignEntry(controller.Lease{
+					UnderlayIP:          "10.244.11.23",
+					OverlaySubnet:       "10.255.99.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:63:00",
+				})
+				Expect(err).To(Equal(database.RecordNotAffectedError))
+			})
+		})
+	})
+
+	Describe("concurrent transactions", func() {
+		It("serializes transactions on the same pool", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			began := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				otherTx, err := databaseHandler.BeginLeaseTransaction(false, false)
+				Expect(err).NotTo(HaveOccurred())
+				close(began)
+				Expect(otherTx.Rollback()).To(Succeed())
+			}()
+
+			Consistently(began, "500ms").ShouldNot(BeClosed())
+			Expect(tx.Rollback()).To(Succeed())
+			Eventually(began).Should(BeClosed())
+		})
+
+		It("does not block transactions on other pools", func() {
+			tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			otherTx, err := databaseHandler.BeginLeaseTransaction(true, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherTx.Rollback()).To(Succeed())
+		})
+	})
+
+	Context("when the database type is not supported", func() {
+		It("returns an error", func() {
+			mockDb := &fakes.Db{}
+			mockDb.DriverNameReturns("foo")
+			handler := database.NewDatabaseHandler(&fakes.MigrateAdapter{}, mockDb)
+
+			_, err := handler.BeginLeaseTransaction(false, false)
+			Expect(err).To(MatchError("database type foo is not supported"))
+		})
+	})
+})
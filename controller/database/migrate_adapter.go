@@ -10,3 +10,11 @@ type MigrateAdapter struct {
 func (ma *MigrateAdapter) Exec(db Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection) (int, error) {
 	return migrate.Exec(db.RawConnection().DB, dialect, m, dir)
 }
+
+func (ma *MigrateAdapter) ExecMax(db Db, dialect string, m migrate.MigrationSource, dir migrate.MigrationDirection, max int) (int, error) {
+	return migrate.ExecMax(db.RawConnection().DB, dialect, m, dir, max)
+}
+
+func (ma *MigrateAdapter) GetMigrationRecords(db Db, dialect string) ([]*migrate.MigrationRecord, error) {
+	return migrate.GetMigrationRecords(db.RawConnection().DB, dialect)
+}
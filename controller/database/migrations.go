@@ -0,0 +1,204 @@
+package database
+
+import (
+	"fmt"
+
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// migrationsForDriver returns the schema migrations for the driver's dialect.
+// Migrations are applied in the order of their numeric ids; new ones are only
+// ever appended, and every one of them needs a Down for `migrate down`.
+func migrationsForDriver(driverName string) []*migrate.Migration {
+	return []*migrate.Migration{
+		{
+			Id:   "1",
+			Up:   []string{createSubnetTable(driverName)},
+			Down: []string{"DROP TABLE subnets"},
+		},
+		{
+			Id:   "2",
+			Up:   addIPv6ToSubnetTable(driverName),
+			Down: removeIPv6FromSubnetTable(driverName),
+		},
+		{
+			Id: "3",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS subnet_allocation_locks (name varchar(16) NOT NULL, PRIMARY KEY (name));",
+				"INSERT INTO subnet_allocation_locks (name) VALUES ('block-4'), ('single-4'), ('block-6'), ('single-6');",
+			},
+			Down: []string{"DROP TABLE subnet_allocation_locks"},
+		},
+		{
+			Id: "4",
+			Up: []string{
+				createLeaseEventsTable(driverName),
+				"CREATE INDEX lease_events_overlay_subnet ON lease_events (overlay_subnet);",
+			},
+			Down: []string{"DROP TABLE lease_events"},
+		},
+		{
+			Id:   "5",
+			Up:   []string{"CREATE TABLE IF NOT EXISTS reserved_subnets (overlay_subnet varchar(49) NOT NULL, created_at bigint NOT NULL, PRIMARY KEY (overlay_subnet));"},
+			Down: []string{"DROP TABLE reserved_subnets"},
+		},
+		{
+			Id: "6",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS lease_revisions (id int NOT NULL, revision bigint NOT NULL, PRIMARY KEY (id));",
+				"INSERT INTO lease_revisions (id, revision) VALUES (1, 0);",
+			},
+			Down: []string{"DROP TABLE lease_revisions"},
+		},
+		{
+			Id:   "7",
+			Up:   []string{"CREATE TABLE IF NOT EXISTS locks (name varchar(64) NOT NULL, owner varchar(255) NOT NULL, expires_at bigint NOT NULL, PRIMARY KEY (name));"},
+			Down: []string{"DROP TABLE locks"},
+		},
+		{
+			Id: "8",
+			Up: []string{
+				"ALTER TABLE subnets ADD COLUMN cell_id varchar(255) NOT NULL DEFAULT '';",
+				"ALTER TABLE subnets ADD COLUMN az varchar(255) NOT NULL DEFAULT '';",
+				"ALTER TABLE subnets ADD COLUMN labels varchar(2048) NOT NULL DEFAULT '';",
+			},
+			Down: []string{
+				"ALTER TABLE subnets DROP COLUMN labels;",
+				"ALTER TABLE subnets DROP COLUMN az;",
+				"ALTER TABLE subnets DROP COLUMN cell_id;",
+			},
+		},
+		{
+			Id:   "9",
+			Up:   []string{"ALTER TABLE subnets ADD COLUMN quarantined_at bigint NOT NULL DEFAULT 0;"},
+			Down: []string{"ALTER TABLE subnets DROP COLUMN quarantined_at;"},
+		},
+	}
+}
+
+func createSubnetTable(dbType string) string {
+	baseCreateTable := "CREATE TABLE IF NOT EXISTS subnets (" +
+		"%s" +
+		", underlay_ip varchar(15) NOT NULL" +
+		", overlay_subnet varchar(18) NOT NULL" +
+		", overlay_hwaddr varchar(17) NOT NULL" +
+		", last_renewed_at bigint NOT NULL" +
+		", UNIQUE (underlay_ip)" +
+		", UNIQUE (overlay_subnet)" +
+		", UNIQUE (overlay_hwaddr)" +
+		");"
+	mysqlId := "id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id)"
+	psqlId := "id SERIAL PRIMARY KEY"
+	sqliteId := "id INTEGER PRIMARY KEY AUTOINCREMENT"
+
+	switch dbType {
+	case Postgres:
+		return fmt.Sprintf(baseCreateTable, psqlId)
+	case MySQL:
+		return fmt.Sprintf(baseCreateTable, mysqlId)
+	case SQLite:
+		return fmt.Sprintf(baseCreateTable, sqliteId)
+	}
+
+	return ""
+}
+
+func createLeaseEventsTable(dbType string) string {
+	baseCreateTable := "CREATE TABLE IF NOT EXISTS lease_events (" +
+		"%s" +
+		", event_type varchar(16) NOT NULL" +
+		", underlay_ip varchar(45) NOT NULL" +
+		", overlay_subnet varchar(49) NOT NULL" +
+		", overlay_hwaddr varchar(17) NOT NULL" +
+		", created_at bigint NOT NULL" +
+		");"
+	mysqlId := "id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id)"
+	psqlId := "id SERIAL PRIMARY KEY"
+	sqliteId := "id INTEGER PRIMARY KEY AUTOINCREMENT"
+
+	switch dbType {
+	case Postgres:
+		return fmt.Sprintf(baseCreateTable, psqlId)
+	case MySQL:
+		return fmt.Sprintf(baseCreateTable, mysqlId)
+	case SQLite:
+		return fmt.Sprintf(baseCreateTable, sqliteId)
+	}
+
+	return ""
+}
+
+func addIPv6ToSubnetTable(dbType string) []string {
+	switch dbType {
+	case Postgres:
+		return []string{
+			"ALTER TABLE subnets ALTER COLUMN underlay_ip TYPE varchar(45);",
+			"ALTER TABLE subnets ALTER COLUMN overlay_subnet TYPE varchar(49);",
+			"ALTER TABLE subnets ADD COLUMN overlay_ip_version smallint NOT NULL DEFAULT 4;",
+			"ALTER TABLE subnets DROP CONSTRAINT subnets_underlay_ip_key;",
+			"ALTER TABLE subnets ADD UNIQUE (underlay_ip, overlay_ip_version);",
+		}
+	case MySQL:
+		return []string{
+			"ALTER TABLE subnets MODIFY underlay_ip varchar(45) NOT NULL;",
+			"ALTER TABLE subnets MODIFY overlay_subnet varchar(49) NOT NULL;",
+			"ALTER TABLE subnets ADD COLUMN overlay_ip_version smallint NOT NULL DEFAULT 4;",
+			"ALTER TABLE subnets DROP INDEX underlay_ip;",
+			"ALTER TABLE subnets ADD UNIQUE (underlay_ip, overlay_ip_version);",
+		}
+	case SQLite:
+		// sqlite cannot drop a unique constraint, so the table is rebuilt
+		return []string{
+			"CREATE TABLE subnets_ipv6 (id INTEGER PRIMARY KEY AUTOINCREMENT" +
+				", underlay_ip varchar(45) NOT NULL" +
+				", overlay_subnet varchar(49) NOT NULL" +
+				", overlay_hwaddr varchar(17) NOT NULL" +
+				", last_renewed_at bigint NOT NULL" +
+				", overlay_ip_version smallint NOT NULL DEFAULT 4" +
+				", UNIQUE (underlay_ip, overlay_ip_version)" +
+				", UNIQUE (overlay_subnet)" +
+				", UNIQUE (overlay_hwaddr)" +
+				");",
+			"INSERT INTO subnets_ipv6 (id, underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) SELECT id, underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at FROM subnets;",
+			"DROP TABLE subnets;",
+			"ALTER TABLE subnets_ipv6 RENAME TO subnets;",
+		}
+	}
+
+	return nil
+}
+
+// removeIPv6FromSubnetTable also deletes the leases of ipv6 underlay ips,
+// which do not fit the narrower underlay_ip column.
+func removeIPv6FromSubnetTable(dbType string) []string {
+	switch dbType {
+	case Postgres:
+		return []string{
+			"DELETE FROM subnets WHERE overlay_ip_version = 6 OR underlay_ip LIKE '%:%';",
+			"ALTER TABLE subnets DROP CONSTRAINT subnets_underlay_ip_overlay_ip_version_key;",
+			"ALTER TABLE subnets ADD UNIQUE (underlay_ip);",
+			"ALTER TABLE subnets DROP COLUMN overlay_ip_version;",
+			"ALTER TABLE subnets ALTER COLUMN overlay_subnet TYPE varchar(18);",
+			"ALTER TABLE subnets ALTER COLUMN underlay_ip TYPE varchar(15);",
+		}
+	case MySQL:
+		return []string{
+			"DELETE FROM subnets WHERE overlay_ip_version = 6 OR underlay_ip LIKE '%:%';",
+			"ALTER TABLE subnets DROP INDEX underlay_ip;",
+			"ALTER TABLE subnets ADD UNIQUE (underlay_ip);",
+			"ALTER TABLE subnets DROP COLUMN overlay_ip_version;",
+			"ALTER TABLE subnets MODIFY overlay_subnet varchar(18) NOT NULL;",
+			"ALTER TABLE subnets MODIFY underlay_ip varchar(15) NOT NULL;",
+		}
+	case SQLite:
+		return []string{
+			"DELETE FROM subnets WHERE overlay_ip_version = 6 OR underlay_ip LIKE '%:%';",
+			"ALTER TABLE subnets RENAME TO subnets_ipv6;",
+			createSubnetTable(SQLite),
+			"INSERT INTO subnets (id, underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) SELECT id, underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at FROM subnets_ipv6;",
+			"DROP TABLE subnets_ipv6;",
+		}
+	}
+
+	return nil
+}
@@ -29,6 +29,9 @@ func (m *Migrator) TryMigrations() error {
 			m.Logger.Info("db-migration-complete", lager.Data{"num-applied": n})
 			return nil
 		}
+		if _, ok := err.(*SchemaTooNewError); ok {
+			return err
+		}
 
 		nErrors++
 		time.Sleep(m.MigrationAttemptSleepDuration)
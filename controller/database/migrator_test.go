@@ -51,6 +51,16 @@ var _ = Describe("Migrator", func() {
 				Expect(databaseMigrator.MigrateCallCount()).To(Equal(5))
 			})
 		})
+
+		Context("when the schema is newer than the controller", func() {
+			It("returns the error without retrying", func() {
+				databaseMigrator.MigrateReturns(0, &database.SchemaTooNewError{UnknownMigrations: []string{"99"}})
+				err := migrator.TryMigrations()
+
+				Expect(err).To(MatchError("database schema is newer than this controller, unknown migrations: 99"))
+				Expect(databaseMigrator.MigrateCallCount()).To(Equal(1))
+			})
+		})
 	})
 
 })
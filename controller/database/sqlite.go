@@ -0,0 +1,29 @@
+//go:build sqlite
+// +build sqlite
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewSQLiteConnection opens the sqlite database at path, creating it if it
+// does not exist. sqlite allows a single writer and has no row locks, so the
+// pool is limited to one connection to serialize the lease transactions.
+func NewSQLiteConnection(path string) (*SQLiteConnection, error) {
+	db, err := sqlx.Open(SQLite, fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on", path))
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening sqlite database: %s", err)
+	}
+
+	return &SQLiteConnection{DB: db}, nil
+}
@@ -0,0 +1,14 @@
+package database
+
+import "github.com/jmoiron/sqlx"
+
+// SQLiteConnection is a Db backed by an embedded sqlite file, for small
+// deployments without a database server. Opening one needs a build with the
+// sqlite tag, as the sqlite driver needs cgo.
+type SQLiteConnection struct {
+	*sqlx.DB
+}
+
+func (c *SQLiteConnection) RawConnection() *sqlx.DB {
+	return c.DB
+}
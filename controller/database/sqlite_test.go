@@ -0,0 +1,290 @@
+//go:build sqlite
+// +build sqlite
+
+package database_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLite", func() {
+	var (
+		dir             string
+		sqliteDb        *database.SQLiteConnection
+		databaseHandler *database.DatabaseHandler
+		lease           controller.Lease
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "silk-sqlite")
+		Expect(err).NotTo(HaveOccurred())
+
+		sqliteDb, err = database.NewSQLiteConnection(filepath.Join(dir, "silk.db"))
+		Expect(err).NotTo(HaveOccurred())
+
+		databaseHandler = database.NewDatabaseHandler(&database.MigrateAdapter{}, sqliteDb)
+		_, err = databaseHandler.Migrate()
+		Expect(err).NotTo(HaveOccurred())
+
+		lease = controller.Lease{
+			UnderlayIP:          "10.244.11.22",
+			OverlaySubnet:       "10.255.17.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:11:00",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(sqliteDb.Close()).To(Succeed())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("adds, renews and deletes leases", func() {
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(controller.Lease{
+			UnderlayIP:          "10.244.11.22",
+			OverlaySubnet:       "fd00::/64",
+			OverlayHardwareAddr: "ee:ee:fd:00:00:00",
+		})).To(Succeed())
+
+		Expect(databaseHandler.RenewLeaseForUnderlayIP("10.244.11.22", false)).To(Succeed())
+		lastRenewedAt, err := databaseHandler.LastRenewedAtForUnderlayIP("10.244.11.22", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lastRenewedAt).To(BeNumerically(">", 0))
+
+		leases, err := databaseHandler.AllActive(60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(ContainElement(lease))
+		Expect(leases).To(HaveLen(2))
+
+		Expect(databaseHandler.DeleteEntry("10.244.11.22")).To(Succeed())
+		leases, err = databaseHandler.All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(BeEmpty())
+	})
+
+	It("queries the leases", func() {
+		lease.LeaseMetadata = controller.LeaseMetadata{AZ: "z1", Labels: map[string]string{"rack": "r3", "pool": "a%_\\\""}}
+		lease2 := controller.Lease{
+			UnderlayIP:          "10.244.11.23",
+			OverlaySubnet:       "10.255.0.9/32",
+			OverlayHardwareAddr: "ee:ee:0a:ff:00:09",
+			LeaseMetadata:       controller.LeaseMetadata{AZ: "z2", Labels: map[string]string{"rack": "r3"}},
+		}
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(lease2)).To(Succeed())
+
+		leases, _, err := databaseHandler.QueryLeases(controller.LeaseQuery{ContainsIP: "10.255.17.200"}, 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(Equal([]controller.Lease{lease}))
+
+		leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{Labels: lease.Labels}, 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(Equal([]controller.Lease{lease}))
+
+		leases, _, err = databaseHandler.QueryLeases(controller.LeaseQuery{Labels: map[string]string{"pool": "a"}}, 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(BeEmpty())
+
+		leases, next, err := databaseHandler.QueryLeases(controller.LeaseQuery{Labels: map[string]string{"rack": "r3"}, Limit: 1}, 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(Equal([]controller.Lease{lease2}))
+		Expect(next).To(Equal(lease2.OverlaySubnet))
+
+		leases, next, err = databaseHandler.QueryLeases(controller.LeaseQuery{Labels: map[string]string{"rack": "r3"}, Limit: 1, After: next}, 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(Equal([]controller.Lease{lease}))
+		Expect(next).To(BeEmpty())
+	})
+
+	It("looks up the lease holding an ip", func() {
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+
+		record, err := databaseHandler.LeaseRecordContainingIP("10.255.17.12", 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.UnderlayIP).To(Equal(lease.UnderlayIP))
+		Expect(record.Expired).To(BeFalse())
+
+		record, err = databaseHandler.LeaseRecordContainingIP("10.255.18.12", 60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record).To(BeNil())
+	})
+
+	It("stores the lease metadata", func() {
+		lease.LeaseMetadata = controller.LeaseMetadata{
+			CellID: "diego-cell/0",
+			AZ:     "z1",
+			Labels: map[string]string{"rack": "r3"},
+		}
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+
+		stored, err := databaseHandler.LeaseForOverlaySubnet(lease.OverlaySubnet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*stored).To(Equal(lease))
+
+		lease.LeaseMetadata = controller.LeaseMetadata{CellID: "diego-cell/1"}
+		Expect(databaseHandler.UpdateLeaseMetadata(lease)).To(Succeed())
+
+		records, err := databaseHandler.AllLeaseRecords(60)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].LeaseMetadata).To(Equal(lease.LeaseMetadata))
+	})
+
+	It("acquires leases in a transaction", func() {
+		tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.AddEntry(lease)).To(Succeed())
+		Expect(tx.AddLeaseEvent(controller.LeaseEventAcquire, lease)).To(Succeed())
+		Expect(tx.BumpRevision()).To(Succeed())
+		Expect(tx.Commit()).To(Succeed())
+
+		tx, err = databaseHandler.BeginLeaseTransaction(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		taken, err := tx.TakenSubnets()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(taken).To(ConsistOf("10.255.17.0/24"))
+
+		expired, err := tx.OldestExpired(-1, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expired).To(Equal(&lease))
+		Expect(tx.Rollback()).To(Succeed())
+
+		revision, err := databaseHandler.Revision()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revision).To(Equal(int64(1)))
+	})
+
+	It("reclaims the oldest expired lease the pool accepts", func() {
+		otherLease := controller.Lease{
+			UnderlayIP:          "10.244.11.23",
+			OverlaySubnet:       "10.255.18.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:12:00",
+		}
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(otherLease)).To(Succeed())
+
+		tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		defer tx.Rollback()
+
+		expired, err := tx.OldestExpired(-1, func(subnet string) bool {
+			return subnet != lease.OverlaySubnet
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expired).To(Equal(&otherLease))
+
+		expired, err = tx.OldestExpired(-1, func(string) bool { return false })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expired).To(BeNil())
+	})
+
+	It("quarantines expired leases until they are renewed or reassigned", func() {
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+
+		tx, err := databaseHandler.BeginLeaseTransaction(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		quarantined, err := tx.QuarantineOldestExpired(-1, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(quarantined).To(Equal(&lease))
+		Expect(tx.Commit()).To(Succeed())
+
+		record, err := databaseHandler.LeaseRecordContainingIP("10.255.17.12", -1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Quarantined).To(BeTrue())
+		Expect(record.QuarantinedAt).To(BeNumerically(">", 0))
+
+		tx, err = databaseHandler.BeginLeaseTransaction(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		reclaimable, err := tx.OldestReclaimable(-1, 1000, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reclaimable).To(BeNil())
+		reclaimable, err = tx.OldestReclaimable(-1, -1, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reclaimable).To(Equal(&lease))
+		Expect(tx.Rollback()).To(Succeed())
+
+		Expect(databaseHandler.RenewLeaseForUnderlayIP(lease.UnderlayIP, false)).To(Succeed())
+		records, err := databaseHandler.AllLeaseRecords(-1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records[0].Quarantined).To(BeFalse())
+		Expect(records[0].QuarantinedAt).To(BeZero())
+	})
+
+	It("only reaps expired leases after their quarantine", func() {
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+
+		err := databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, -1, -1)
+		Expect(err).To(Equal(database.RecordNotAffectedError))
+
+		Expect(databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, 1000)).To(Equal(database.RecordNotAffectedError))
+		Expect(databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, -1)).To(Succeed())
+		Expect(databaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, -1)).To(Equal(database.RecordNotAffectedError))
+
+		err = databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, -1, 1000)
+		Expect(err).To(Equal(database.RecordNotAffectedError))
+		Expect(databaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, -1, -1)).To(Succeed())
+
+		leases, err := databaseHandler.All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leases).To(BeEmpty())
+	})
+
+	It("hands the leader lock to one owner at a time", func() {
+		acquired, err := databaseHandler.AcquireLock("leader", "controller-1", 15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+
+		acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeFalse())
+
+		Expect(databaseHandler.ReleaseLock("leader", "controller-1")).To(Succeed())
+		acquired, err = databaseHandler.AcquireLock("leader", "controller-2", 15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+	})
+	It("deletes the leases that do not fit the schema without ipv6 support when migrating down", func() {
+		Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		Expect(databaseHandler.AddEntry(controller.Lease{
+			UnderlayIP:          "fd00:10:244:11::22",
+			OverlaySubnet:       "10.255.18.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:12:00",
+		})).To(Succeed())
+
+		statuses, err := databaseHandler.MigrationStatus()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = databaseHandler.MigrateDown(len(statuses) - 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		var underlayIPs []string
+		Expect(sqliteDb.RawConnection().Select(&underlayIPs, "SELECT underlay_ip FROM subnets")).To(Succeed())
+		Expect(underlayIPs).To(ConsistOf(lease.UnderlayIP))
+	})
+
+	It("migrates down and reports the migration status", func() {
+		n, err := databaseHandler.MigrateDown(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+
+		statuses, err := databaseHandler.MigrationStatus()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(statuses[0].Applied).To(BeTrue())
+		Expect(statuses[len(statuses)-1].Applied).To(BeFalse())
+
+		n, err = databaseHandler.MigrateDown(len(statuses))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(len(statuses) - 1))
+
+		n, err = databaseHandler.Migrate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(len(statuses)))
+	})
+})
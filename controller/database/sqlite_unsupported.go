@@ -0,0 +1,10 @@
+//go:build !sqlite
+// +build !sqlite
+
+package database
+
+import "errors"
+
+func NewSQLiteConnection(path string) (*SQLiteConnection, error) {
+	return nil, errors.New("opening sqlite database: not supported by this build, rebuild with -tags sqlite")
+}
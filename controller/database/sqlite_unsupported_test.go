@@ -0,0 +1,17 @@
+//go:build !sqlite
+// +build !sqlite
+
+package database_test
+
+import (
+	"code.cloudfoundry.org/silk/controller/database"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLite", func() {
+	It("returns an error when built without the sqlite tag", func() {
+		_, err := database.NewSQLiteConnection("/some/path/silk.db")
+		Expect(err).To(MatchError("opening sqlite database: not supported by this build, rebuild with -tags sqlite"))
+	})
+})
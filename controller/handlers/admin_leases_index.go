@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_record_repository.go --fake-name LeaseRecordRepository . leaseRecordRepository
+type leaseRecordRepository interface {
+	AllLeases() ([]controller.LeaseRecord, error)
+}
+
+type AdminLeasesIndex struct {
+	Marshaler             marshal.Marshaler
+	LeaseRecordRepository leaseRecordRepository
+	ErrorResponse         errorResponse
+}
+
+func (l *AdminLeasesIndex) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("admin-leases-index")
+
+	leases, err := l.LeaseRecordRepository.AllLeases()
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("all-leases: %s", err.Error()))
+		return
+	}
+
+	response := struct {
+		Leases []controller.LeaseRecord `json:"leases"`
+	}{leases}
+	bytes, err := l.Marshaler.Marshal(response)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdminLeasesIndex", func() {
+	var (
+		logger                *lagertest.TestLogger
+		expectedLogger        lager.Logger
+		handler               *handlers.AdminLeasesIndex
+		leaseRecordRepository *fakes.LeaseRecordRepository
+		resp                  *httptest.ResponseRecorder
+		marshaler             *hfakes.Marshaler
+		fakeErrorResponse     *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("admin-leases-index")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		leaseRecordRepository = &fakes.LeaseRecordRepository{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.AdminLeasesIndex{
+			Marshaler:             marshaler,
+			LeaseRecordRepository: leaseRecordRepository,
+			ErrorResponse:         fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+		leaseRecordRepository.AllLeasesReturns([]controller.LeaseRecord{
+			{
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.16.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+				LastRenewedAt:       1500000000,
+				Expired:             false,
+			},
+			{
+				UnderlayIP:          "10.244.22.33",
+				OverlaySubnet:       "10.255.75.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:4b:00",
+				LastRenewedAt:       1400000000,
+				Expired:             true,
+				Quarantined:         true,
+				QuarantinedAt:       1400000100,
+			},
+		}, nil)
+	})
+
+	It("returns all the leases", func() {
+		expectedResponseJSON := `{ "leases": [
+			{ "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.16.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:10:00", "last_renewed_at": 1500000000, "expired": false, "quarantined": false },
+			{ "underlay_ip": "10.244.22.33", "overlay_subnet": "10.255.75.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:4b:00", "last_renewed_at": 1400000000, "expired": true, "quarantined": true, "quarantined_at": 1400000100 }
+		] }`
+		request, err := http.NewRequest("GET", "/leases", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseRecordRepository.AllLeasesCallCount()).To(Equal(1))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
+	Context("when getting the leases fails", func() {
+		BeforeEach(func() {
+			leaseRecordRepository.AllLeasesReturns(nil, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("all-leases: butter"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
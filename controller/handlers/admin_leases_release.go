@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter -o fakes/admin_lease_releaser.go --fake-name AdminLeaseReleaser . adminLeaseReleaser
+type adminLeaseReleaser interface {
+	ReleaseSubnetLease(underlayIP string) error
+	ReleaseOverlaySubnet(overlaySubnet string) error
+}
+
+type AdminLeasesRelease struct {
+	LeaseReleaser adminLeaseReleaser
+	ErrorResponse errorResponse
+}
+
+func (l *AdminLeasesRelease) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("admin-leases-release")
+
+	query := req.URL.Query()
+	underlayIP := query.Get("underlay_ip")
+	overlaySubnet := query.Get("overlay_subnet")
+
+	var err error
+	switch {
+	case underlayIP != "" && overlaySubnet == "":
+		err = l.LeaseReleaser.ReleaseSubnetLease(underlayIP)
+	case overlaySubnet != "" && underlayIP == "":
+		err = l.LeaseReleaser.ReleaseOverlaySubnet(overlaySubnet)
+	default:
+		err := errors.New("exactly one of underlay_ip or overlay_subnet is required")
+		l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+		return
+	}
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, err.Error())
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
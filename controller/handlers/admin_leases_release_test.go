@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdminLeasesRelease", func() {
+	var (
+		logger            *lagertest.TestLogger
+		expectedLogger    lager.Logger
+		handler           *handlers.AdminLeasesRelease
+		resp              *httptest.ResponseRecorder
+		leaseReleaser     *fakes.AdminLeaseReleaser
+		fakeErrorResponse *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("admin-leases-release")
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		leaseReleaser = &fakes.AdminLeaseReleaser{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+
+		handler = &handlers.AdminLeasesRelease{
+			LeaseReleaser: leaseReleaser,
+			ErrorResponse: fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+	})
+
+	It("releases the lease of an underlay ip", func() {
+		request, err := http.NewRequest("DELETE", "/leases?underlay_ip=10.244.16.11", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseReleaser.ReleaseSubnetLeaseCallCount()).To(Equal(1))
+		Expect(leaseReleaser.ReleaseSubnetLeaseArgsForCall(0)).To(Equal("10.244.16.11"))
+		Expect(leaseReleaser.ReleaseOverlaySubnetCallCount()).To(Equal(0))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(MatchJSON(`{}`))
+	})
+
+	It("releases the lease of an overlay subnet", func() {
+		request, err := http.NewRequest("DELETE", "/leases?overlay_subnet=10.255.30.0%2F24", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseReleaser.ReleaseOverlaySubnetCallCount()).To(Equal(1))
+		Expect(leaseReleaser.ReleaseOverlaySubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+		Expect(leaseReleaser.ReleaseSubnetLeaseCallCount()).To(Equal(0))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(MatchJSON(`{}`))
+	})
+
+	DescribeTable("when the query does not name exactly one lease",
+		func(url string) {
+			request, err := http.NewRequest("DELETE", url, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(leaseReleaser.ReleaseSubnetLeaseCallCount()).To(Equal(0))
+			Expect(leaseReleaser.ReleaseOverlaySubnetCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("exactly one of underlay_ip or overlay_subnet is required"))
+			Expect(description).To(Equal("exactly one of underlay_ip or overlay_subnet is required"))
+		},
+		Entry("no parameters", "/leases"),
+		Entry("both parameters", "/leases?underlay_ip=10.244.16.11&overlay_subnet=10.255.30.0%2F24"),
+	)
+
+	Context("when releasing the lease fails", func() {
+		BeforeEach(func() {
+			leaseReleaser.ReleaseOverlaySubnetReturns(errors.New("kiwi"))
+		})
+
+		It("calls the Error Response InternalServerError() handler", func() {
+			request, err := http.NewRequest("DELETE", "/leases?overlay_subnet=10.255.30.0%2F24", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("kiwi"))
+			Expect(description).To(Equal("kiwi"))
+		})
+	})
+})
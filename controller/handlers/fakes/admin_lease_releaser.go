@@ -0,0 +1,155 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type AdminLeaseReleaser struct {
+	ReleaseSubnetLeaseStub        func(underlayIP string) error
+	releaseSubnetLeaseMutex       sync.RWMutex
+	releaseSubnetLeaseArgsForCall []struct {
+		underlayIP string
+	}
+	releaseSubnetLeaseReturns struct {
+		result1 error
+	}
+	releaseSubnetLeaseReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReleaseOverlaySubnetStub        func(overlaySubnet string) error
+	releaseOverlaySubnetMutex       sync.RWMutex
+	releaseOverlaySubnetArgsForCall []struct {
+		overlaySubnet string
+	}
+	releaseOverlaySubnetReturns struct {
+		result1 error
+	}
+	releaseOverlaySubnetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *AdminLeaseReleaser) ReleaseSubnetLease(underlayIP string) error {
+	fake.releaseSubnetLeaseMutex.Lock()
+	ret, specificReturn := fake.releaseSubnetLeaseReturnsOnCall[len(fake.releaseSubnetLeaseArgsForCall)]
+	fake.releaseSubnetLeaseArgsForCall = append(fake.releaseSubnetLeaseArgsForCall, struct {
+		underlayIP string
+	}{underlayIP})
+	fake.recordInvocation("ReleaseSubnetLease", []interface{}{underlayIP})
+	fake.releaseSubnetLeaseMutex.Unlock()
+	if fake.ReleaseSubnetLeaseStub != nil {
+		return fake.ReleaseSubnetLeaseStub(underlayIP)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.releaseSubnetLeaseReturns.result1
+}
+
+func (fake *AdminLeaseReleaser) ReleaseSubnetLeaseCallCount() int {
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	return len(fake.releaseSubnetLeaseArgsForCall)
+}
+
+func (fake *AdminLeaseReleaser) ReleaseSubnetLeaseArgsForCall(i int) string {
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	return fake.releaseSubnetLeaseArgsForCall[i].underlayIP
+}
+
+func (fake *AdminLeaseReleaser) ReleaseSubnetLeaseReturns(result1 error) {
+	fake.ReleaseSubnetLeaseStub = nil
+	fake.releaseSubnetLeaseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AdminLeaseReleaser) ReleaseSubnetLeaseReturnsOnCall(i int, result1 error) {
+	fake.ReleaseSubnetLeaseStub = nil
+	if fake.releaseSubnetLeaseReturnsOnCall == nil {
+		fake.releaseSubnetLeaseReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseSubnetLeaseReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AdminLeaseReleaser) ReleaseOverlaySubnet(overlaySubnet string) error {
+	fake.releaseOverlaySubnetMutex.Lock()
+	ret, specificReturn := fake.releaseOverlaySubnetReturnsOnCall[len(fake.releaseOverlaySubnetArgsForCall)]
+	fake.releaseOverlaySubnetArgsForCall = append(fake.releaseOverlaySubnetArgsForCall, struct {
+		overlaySubnet string
+	}{overlaySubnet})
+	fake.recordInvocation("ReleaseOverlaySubnet", []interface{}{overlaySubnet})
+	fake.releaseOverlaySubnetMutex.Unlock()
+	if fake.ReleaseOverlaySubnetStub != nil {
+		return fake.ReleaseOverlaySubnetStub(overlaySubnet)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.releaseOverlaySubnetReturns.result1
+}
+
+func (fake *AdminLeaseReleaser) ReleaseOverlaySubnetCallCount() int {
+	fake.releaseOverlaySubnetMutex.RLock()
+	defer fake.releaseOverlaySubnetMutex.RUnlock()
+	return len(fake.releaseOverlaySubnetArgsForCall)
+}
+
+func (fake *AdminLeaseReleaser) ReleaseOverlaySubnetArgsForCall(i int) string {
+	fake.releaseOverlaySubnetMutex.RLock()
+	defer fake.releaseOverlaySubnetMutex.RUnlock()
+	return fake.releaseOverlaySubnetArgsForCall[i].overlaySubnet
+}
+
+func (fake *AdminLeaseReleaser) ReleaseOverlaySubnetReturns(result1 error) {
+	fake.ReleaseOverlaySubnetStub = nil
+	fake.releaseOverlaySubnetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AdminLeaseReleaser) ReleaseOverlaySubnetReturnsOnCall(i int, result1 error) {
+	fake.ReleaseOverlaySubnetStub = nil
+	if fake.releaseOverlaySubnetReturnsOnCall == nil {
+		fake.releaseOverlaySubnetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseOverlaySubnetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AdminLeaseReleaser) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.releaseSubnetLeaseMutex.RLock()
+	defer fake.releaseSubnetLeaseMutex.RUnlock()
+	fake.releaseOverlaySubnetMutex.RLock()
+	defer fake.releaseOverlaySubnetMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *AdminLeaseReleaser) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -33,6 +33,14 @@ type ErrorResponse struct {
 		arg3 error
 		arg4 string
 	}
+	NotFoundStub        func(lager.Logger, http.ResponseWriter, error, string)
+	notFoundMutex       sync.RWMutex
+	notFoundArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 http.ResponseWriter
+		arg3 error
+		arg4 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -118,6 +126,33 @@ func (fake *ErrorResponse) ConflictArgsForCall(i int) (lager.Logger, http.Respon
 	return fake.conflictArgsForCall[i].arg1, fake.conflictArgsForCall[i].arg2, fake.conflictArgsForCall[i].arg3, fake.conflictArgsForCall[i].arg4
 }
 
+func (fake *ErrorResponse) NotFound(arg1 lager.Logger, arg2 http.ResponseWriter, arg3 error, arg4 string) {
+	fake.notFoundMutex.Lock()
+	fake.notFoundArgsForCall = append(fake.notFoundArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 http.ResponseWriter
+		arg3 error
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("NotFound", []interface{}{arg1, arg2, arg3, arg4})
+	fake.notFoundMutex.Unlock()
+	if fake.NotFoundStub != nil {
+		fake.NotFoundStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *ErrorResponse) NotFoundCallCount() int {
+	fake.notFoundMutex.RLock()
+	defer fake.notFoundMutex.RUnlock()
+	return len(fake.notFoundArgsForCall)
+}
+
+func (fake *ErrorResponse) NotFoundArgsForCall(i int) (lager.Logger, http.ResponseWriter, error, string) {
+	fake.notFoundMutex.RLock()
+	defer fake.notFoundMutex.RUnlock()
+	return fake.notFoundArgsForCall[i].arg1, fake.notFoundArgsForCall[i].arg2, fake.notFoundArgsForCall[i].arg3, fake.notFoundArgsForCall[i].arg4
+}
+
 func (fake *ErrorResponse) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -127,6 +162,8 @@ func (fake *ErrorResponse) Invocations() map[string][][]interface{} {
 	defer fake.badRequestMutex.RUnlock()
 	fake.conflictMutex.RLock()
 	defer fake.conflictMutex.RUnlock()
+	fake.notFoundMutex.RLock()
+	defer fake.notFoundMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -8,17 +8,19 @@ import (
 )
 
 type LeaseAcquirer struct {
-	AcquireSubnetLeaseStub        func(underlayIP string, singleOverlayIP bool) (*controller.Lease, error)
-	acquireSubnetLeaseMutex       sync.RWMutex
-	acquireSubnetLeaseArgsForCall []struct {
+	AcquireSubnetLeaseWithMetadataStub        func(underlayIP string, singleOverlayIP bool, ipv6Overlay bool, metadata controller.LeaseMetadata) (*controller.Lease, error)
+	acquireSubnetLeaseWithMetadataMutex       sync.RWMutex
+	acquireSubnetLeaseWithMetadataArgsForCall []struct {
 		underlayIP      string
 		singleOverlayIP bool
+		ipv6Overlay     bool
+		metadata        controller.LeaseMetadata
 	}
-	acquireSubnetLeaseReturns struct {
+	acquireSubnetLeaseWithMetadataReturns struct {
 		result1 *controller.Lease
 		result2 error
 	}
-	acquireSubnetLeaseReturnsOnCall map[int]struct {
+	acquireSubnetLeaseWithMetadataReturnsOnCall map[int]struct {
 		result1 *controller.Lease
 		result2 error
 	}
@@ -26,53 +28,55 @@ type LeaseAcquirer struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *LeaseAcquirer) AcquireSubnetLease(underlayIP string, singleOverlayIP bool) (*controller.Lease, error) {
-	fake.acquireSubnetLeaseMutex.Lock()
-	ret, specificReturn := fake.acquireSubnetLeaseReturnsOnCall[len(fake.acquireSubnetLeaseArgsForCall)]
-	fake.acquireSubnetLeaseArgsForCall = append(fake.acquireSubnetLeaseArgsForCall, struct {
+func (fake *LeaseAcquirer) AcquireSubnetLeaseWithMetadata(underlayIP string, singleOverlayIP bool, ipv6Overlay bool, metadata controller.LeaseMetadata) (*controller.Lease, error) {
+	fake.acquireSubnetLeaseWithMetadataMutex.Lock()
+	ret, specificReturn := fake.acquireSubnetLeaseWithMetadataReturnsOnCall[len(fake.acquireSubnetLeaseWithMetadataArgsForCall)]
+	fake.acquireSubnetLeaseWithMetadataArgsForCall = append(fake.acquireSubnetLeaseWithMetadataArgsForCall, struct {
 		underlayIP      string
 		singleOverlayIP bool
-	}{underlayIP, singleOverlayIP})
-	fake.recordInvocation("AcquireSubnetLease", []interface{}{underlayIP, singleOverlayIP})
-	fake.acquireSubnetLeaseMutex.Unlock()
-	if fake.AcquireSubnetLeaseStub != nil {
-		return fake.AcquireSubnetLeaseStub(underlayIP, singleOverlayIP)
+		ipv6Overlay     bool
+		metadata        controller.LeaseMetadata
+	}{underlayIP, singleOverlayIP, ipv6Overlay, metadata})
+	fake.recordInvocation("AcquireSubnetLeaseWithMetadata", []interface{}{underlayIP, singleOverlayIP, ipv6Overlay, metadata})
+	fake.acquireSubnetLeaseWithMetadataMutex.Unlock()
+	if fake.AcquireSubnetLeaseWithMetadataStub != nil {
+		return fake.AcquireSubnetLeaseWithMetadataStub(underlayIP, singleOverlayIP, ipv6Overlay, metadata)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.acquireSubnetLeaseReturns.result1, fake.acquireSubnetLeaseReturns.result2
+	return fake.acquireSubnetLeaseWithMetadataReturns.result1, fake.acquireSubnetLeaseWithMetadataReturns.result2
 }
 
-func (fake *LeaseAcquirer) AcquireSubnetLeaseCallCount() int {
-	fake.acquireSubnetLeaseMutex.RLock()
-	defer fake.acquireSubnetLeaseMutex.RUnlock()
-	return len(fake.acquireSubnetLeaseArgsForCall)
+func (fake *LeaseAcquirer) AcquireSubnetLeaseWithMetadataCallCount() int {
+	fake.acquireSubnetLeaseWithMetadataMutex.RLock()
+	defer fake.acquireSubnetLeaseWithMetadataMutex.RUnlock()
+	return len(fake.acquireSubnetLeaseWithMetadataArgsForCall)
 }
 
-func (fake *LeaseAcquirer) AcquireSubnetLeaseArgsForCall(i int) (string, bool) {
-	fake.acquireSubnetLeaseMutex.RLock()
-	defer fake.acquireSubnetLeaseMutex.RUnlock()
-	return fake.acquireSubnetLeaseArgsForCall[i].underlayIP, fake.acquireSubnetLeaseArgsForCall[i].singleOverlayIP
+func (fake *LeaseAcquirer) AcquireSubnetLeaseWithMetadataArgsForCall(i int) (string, bool, bool, controller.LeaseMetadata) {
+	fake.acquireSubnetLeaseWithMetadataMutex.RLock()
+	defer fake.acquireSubnetLeaseWithMetadataMutex.RUnlock()
+	return fake.acquireSubnetLeaseWithMetadataArgsForCall[i].underlayIP, fake.acquireSubnetLeaseWithMetadataArgsForCall[i].singleOverlayIP, fake.acquireSubnetLeaseWithMetadataArgsForCall[i].ipv6Overlay, fake.acquireSubnetLeaseWithMetadataArgsForCall[i].metadata
 }
 
-func (fake *LeaseAcquirer) AcquireSubnetLeaseReturns(result1 *controller.Lease, result2 error) {
-	fake.AcquireSubnetLeaseStub = nil
-	fake.acquireSubnetLeaseReturns = struct {
+func (fake *LeaseAcquirer) AcquireSubnetLeaseWithMetadataReturns(result1 *controller.Lease, result2 error) {
+	fake.AcquireSubnetLeaseWithMetadataStub = nil
+	fake.acquireSubnetLeaseWithMetadataReturns = struct {
 		result1 *controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *LeaseAcquirer) AcquireSubnetLeaseReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
-	fake.AcquireSubnetLeaseStub = nil
-	if fake.acquireSubnetLeaseReturnsOnCall == nil {
-		fake.acquireSubnetLeaseReturnsOnCall = make(map[int]struct {
+func (fake *LeaseAcquirer) AcquireSubnetLeaseWithMetadataReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
+	fake.AcquireSubnetLeaseWithMetadataStub = nil
+	if fake.acquireSubnetLeaseWithMetadataReturnsOnCall == nil {
+		fake.acquireSubnetLeaseWithMetadataReturnsOnCall = make(map[int]struct {
 			result1 *controller.Lease
 			result2 error
 		})
 	}
-	fake.acquireSubnetLeaseReturnsOnCall[i] = struct {
+	fake.acquireSubnetLeaseWithMetadataReturnsOnCall[i] = struct {
 		result1 *controller.Lease
 		result2 error
 	}{result1, result2}
@@ -81,8 +85,8 @@ func (fake *LeaseAcquirer) AcquireSubnetLeaseReturnsOnCall(i int, result1 *contr
 func (fake *LeaseAcquirer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.acquireSubnetLeaseMutex.RLock()
-	defer fake.acquireSubnetLeaseMutex.RUnlock()
+	fake.acquireSubnetLeaseWithMetadataMutex.RLock()
+	defer fake.acquireSubnetLeaseWithMetadataMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseHistoryRepository struct {
+	LeaseHistoryStub        func(overlaySubnet string) ([]controller.LeaseEvent, error)
+	leaseHistoryMutex       sync.RWMutex
+	leaseHistoryArgsForCall []struct {
+		overlaySubnet string
+	}
+	leaseHistoryReturns struct {
+		result1 []controller.LeaseEvent
+		result2 error
+	}
+	leaseHistoryReturnsOnCall map[int]struct {
+		result1 []controller.LeaseEvent
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseHistoryRepository) LeaseHistory(overlaySubnet string) ([]controller.LeaseEvent, error) {
+	fake.leaseHistoryMutex.Lock()
+	ret, specificReturn := fake.leaseHistoryReturnsOnCall[len(fake.leaseHistoryArgsForCall)]
+	fake.leaseHistoryArgsForCall = append(fake.leaseHistoryArgsForCall, struct {
+		overlaySubnet string
+	}{overlaySubnet})
+	fake.recordInvocation("LeaseHistory", []interface{}{overlaySubnet})
+	fake.leaseHistoryMutex.Unlock()
+	if fake.LeaseHistoryStub != nil {
+		return fake.LeaseHistoryStub(overlaySubnet)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.leaseHistoryReturns.result1, fake.leaseHistoryReturns.result2
+}
+
+func (fake *LeaseHistoryRepository) LeaseHistoryCallCount() int {
+	fake.leaseHistoryMutex.RLock()
+	defer fake.leaseHistoryMutex.RUnlock()
+	return len(fake.leaseHistoryArgsForCall)
+}
+
+func (fake *LeaseHistoryRepository) LeaseHistoryArgsForCall(i int) string {
+	fake.leaseHistoryMutex.RLock()
+	defer fake.leaseHistoryMutex.RUnlock()
+	return fake.leaseHistoryArgsForCall[i].overlaySubnet
+}
+
+func (fake *LeaseHistoryRepository) LeaseHistoryReturns(result1 []controller.LeaseEvent, result2 error) {
+	fake.LeaseHistoryStub = nil
+	fake.leaseHistoryReturns = struct {
+		result1 []controller.LeaseEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseHistoryRepository) LeaseHistoryReturnsOnCall(i int, result1 []controller.LeaseEvent, result2 error) {
+	fake.LeaseHistoryStub = nil
+	if fake.leaseHistoryReturnsOnCall == nil {
+		fake.leaseHistoryReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseEvent
+			result2 error
+		})
+	}
+	fake.leaseHistoryReturnsOnCall[i] = struct {
+		result1 []controller.LeaseEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseHistoryRepository) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.leaseHistoryMutex.RLock()
+	defer fake.leaseHistoryMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseHistoryRepository) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
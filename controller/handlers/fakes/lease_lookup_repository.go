@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseLookupRepository struct {
+	LookupLeaseStub        func(ip string) (*controller.LeaseRecord, error)
+	lookupLeaseMutex       sync.RWMutex
+	lookupLeaseArgsForCall []struct {
+		ip string
+	}
+	lookupLeaseReturns struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	lookupLeaseReturnsOnCall map[int]struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseLookupRepository) LookupLease(ip string) (*controller.LeaseRecord, error) {
+	fake.lookupLeaseMutex.Lock()
+	ret, specificReturn := fake.lookupLeaseReturnsOnCall[len(fake.lookupLeaseArgsForCall)]
+	fake.lookupLeaseArgsForCall = append(fake.lookupLeaseArgsForCall, struct {
+		ip string
+	}{ip})
+	fake.recordInvocation("LookupLease", []interface{}{ip})
+	fake.lookupLeaseMutex.Unlock()
+	if fake.LookupLeaseStub != nil {
+		return fake.LookupLeaseStub(ip)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.lookupLeaseReturns.result1, fake.lookupLeaseReturns.result2
+}
+
+func (fake *LeaseLookupRepository) LookupLeaseCallCount() int {
+	fake.lookupLeaseMutex.RLock()
+	defer fake.lookupLeaseMutex.RUnlock()
+	return len(fake.lookupLeaseArgsForCall)
+}
+
+func (fake *LeaseLookupRepository) LookupLeaseArgsForCall(i int) string {
+	fake.lookupLeaseMutex.RLock()
+	defer fake.lookupLeaseMutex.RUnlock()
+	return fake.lookupLeaseArgsForCall[i].ip
+}
+
+func (fake *LeaseLookupRepository) LookupLeaseReturns(result1 *controller.LeaseRecord, result2 error) {
+	fake.LookupLeaseStub = nil
+	fake.lookupLeaseReturns = struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseLookupRepository) LookupLeaseReturnsOnCall(i int, result1 *controller.LeaseRecord, result2 error) {
+	fake.LookupLeaseStub = nil
+	if fake.lookupLeaseReturnsOnCall == nil {
+		fake.lookupLeaseReturnsOnCall = make(map[int]struct {
+			result1 *controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.lookupLeaseReturnsOnCall[i] = struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseLookupRepository) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.lookupLeaseMutex.RLock()
+	defer fake.lookupLeaseMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseLookupRepository) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,91 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseRecordRepository struct {
+	AllLeasesStub        func() ([]controller.LeaseRecord, error)
+	allLeasesMutex       sync.RWMutex
+	allLeasesArgsForCall []struct{}
+	allLeasesReturns     struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	allLeasesReturnsOnCall map[int]struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseRecordRepository) AllLeases() ([]controller.LeaseRecord, error) {
+	fake.allLeasesMutex.Lock()
+	ret, specificReturn := fake.allLeasesReturnsOnCall[len(fake.allLeasesArgsForCall)]
+	fake.allLeasesArgsForCall = append(fake.allLeasesArgsForCall, struct{}{})
+	fake.recordInvocation("AllLeases", []interface{}{})
+	fake.allLeasesMutex.Unlock()
+	if fake.AllLeasesStub != nil {
+		return fake.AllLeasesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.allLeasesReturns.result1, fake.allLeasesReturns.result2
+}
+
+func (fake *LeaseRecordRepository) AllLeasesCallCount() int {
+	fake.allLeasesMutex.RLock()
+	defer fake.allLeasesMutex.RUnlock()
+	return len(fake.allLeasesArgsForCall)
+}
+
+func (fake *LeaseRecordRepository) AllLeasesReturns(result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeasesStub = nil
+	fake.allLeasesReturns = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseRecordRepository) AllLeasesReturnsOnCall(i int, result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeasesStub = nil
+	if fake.allLeasesReturnsOnCall == nil {
+		fake.allLeasesReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.allLeasesReturnsOnCall[i] = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseRecordRepository) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.allLeasesMutex.RLock()
+	defer fake.allLeasesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseRecordRepository) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
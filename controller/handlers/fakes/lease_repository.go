@@ -19,6 +19,21 @@ type LeaseRepository struct {
 		result1 []controller.Lease
 		result2 error
 	}
+	QueryLeasesStub        func(controller.LeaseQuery) ([]controller.Lease, string, error)
+	queryLeasesMutex       sync.RWMutex
+	queryLeasesArgsForCall []struct {
+		arg1 controller.LeaseQuery
+	}
+	queryLeasesReturns struct {
+		result1 []controller.Lease
+		result2 string
+		result3 error
+	}
+	queryLeasesReturnsOnCall map[int]struct {
+		result1 []controller.Lease
+		result2 string
+		result3 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -66,11 +81,67 @@ func (fake *LeaseRepository) RoutableLeasesReturnsOnCall(i int, result1 []contro
 	}{result1, result2}
 }
 
+func (fake *LeaseRepository) QueryLeases(arg1 controller.LeaseQuery) ([]controller.Lease, string, error) {
+	fake.queryLeasesMutex.Lock()
+	ret, specificReturn := fake.queryLeasesReturnsOnCall[len(fake.queryLeasesArgsForCall)]
+	fake.queryLeasesArgsForCall = append(fake.queryLeasesArgsForCall, struct {
+		arg1 controller.LeaseQuery
+	}{arg1})
+	fake.recordInvocation("QueryLeases", []interface{}{arg1})
+	fake.queryLeasesMutex.Unlock()
+	if fake.QueryLeasesStub != nil {
+		return fake.QueryLeasesStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fake.queryLeasesReturns.result1, fake.queryLeasesReturns.result2, fake.queryLeasesReturns.result3
+}
+
+func (fake *LeaseRepository) QueryLeasesCallCount() int {
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
+	return len(fake.queryLeasesArgsForCall)
+}
+
+func (fake *LeaseRepository) QueryLeasesArgsForCall(i int) controller.LeaseQuery {
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
+	return fake.queryLeasesArgsForCall[i].arg1
+}
+
+func (fake *LeaseRepository) QueryLeasesReturns(result1 []controller.Lease, result2 string, result3 error) {
+	fake.QueryLeasesStub = nil
+	fake.queryLeasesReturns = struct {
+		result1 []controller.Lease
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *LeaseRepository) QueryLeasesReturnsOnCall(i int, result1 []controller.Lease, result2 string, result3 error) {
+	fake.QueryLeasesStub = nil
+	if fake.queryLeasesReturnsOnCall == nil {
+		fake.queryLeasesReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
+			result2 string
+			result3 error
+		})
+	}
+	fake.queryLeasesReturnsOnCall[i] = struct {
+		result1 []controller.Lease
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *LeaseRepository) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.routableLeasesMutex.RLock()
 	defer fake.routableLeasesMutex.RUnlock()
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,89 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type ReservationRepository struct {
+	ReservationsStub        func() ([]string, error)
+	reservationsMutex       sync.RWMutex
+	reservationsArgsForCall []struct{}
+	reservationsReturns     struct {
+		result1 []string
+		result2 error
+	}
+	reservationsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ReservationRepository) Reservations() ([]string, error) {
+	fake.reservationsMutex.Lock()
+	ret, specificReturn := fake.reservationsReturnsOnCall[len(fake.reservationsArgsForCall)]
+	fake.reservationsArgsForCall = append(fake.reservationsArgsForCall, struct{}{})
+	fake.recordInvocation("Reservations", []interface{}{})
+	fake.reservationsMutex.Unlock()
+	if fake.ReservationsStub != nil {
+		return fake.ReservationsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.reservationsReturns.result1, fake.reservationsReturns.result2
+}
+
+func (fake *ReservationRepository) ReservationsCallCount() int {
+	fake.reservationsMutex.RLock()
+	defer fake.reservationsMutex.RUnlock()
+	return len(fake.reservationsArgsForCall)
+}
+
+func (fake *ReservationRepository) ReservationsReturns(result1 []string, result2 error) {
+	fake.ReservationsStub = nil
+	fake.reservationsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ReservationRepository) ReservationsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.ReservationsStub = nil
+	if fake.reservationsReturnsOnCall == nil {
+		fake.reservationsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.reservationsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ReservationRepository) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.reservationsMutex.RLock()
+	defer fake.reservationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ReservationRepository) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,148 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+	"time"
+)
+
+type RevisionWatcher struct {
+	RevisionStub        func() int64
+	revisionMutex       sync.RWMutex
+	revisionArgsForCall []struct{}
+	revisionReturns     struct {
+		result1 int64
+	}
+	revisionReturnsOnCall map[int]struct {
+		result1 int64
+	}
+	WaitStub        func(revision int64, timeout time.Duration) int64
+	waitMutex       sync.RWMutex
+	waitArgsForCall []struct {
+		revision int64
+		timeout  time.Duration
+	}
+	waitReturns struct {
+		result1 int64
+	}
+	waitReturnsOnCall map[int]struct {
+		result1 int64
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *RevisionWatcher) Revision() int64 {
+	fake.revisionMutex.Lock()
+	ret, specificReturn := fake.revisionReturnsOnCall[len(fake.revisionArgsForCall)]
+	fake.revisionArgsForCall = append(fake.revisionArgsForCall, struct{}{})
+	fake.recordInvocation("Revision", []interface{}{})
+	fake.revisionMutex.Unlock()
+	if fake.RevisionStub != nil {
+		return fake.RevisionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.revisionReturns.result1
+}
+
+func (fake *RevisionWatcher) RevisionCallCount() int {
+	fake.revisionMutex.RLock()
+	defer fake.revisionMutex.RUnlock()
+	return len(fake.revisionArgsForCall)
+}
+
+func (fake *RevisionWatcher) RevisionReturns(result1 int64) {
+	fake.RevisionStub = nil
+	fake.revisionReturns = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *RevisionWatcher) RevisionReturnsOnCall(i int, result1 int64) {
+	fake.RevisionStub = nil
+	if fake.revisionReturnsOnCall == nil {
+		fake.revisionReturnsOnCall = make(map[int]struct {
+			result1 int64
+		})
+	}
+	fake.revisionReturnsOnCall[i] = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *RevisionWatcher) Wait(revision int64, timeout time.Duration) int64 {
+	fake.waitMutex.Lock()
+	ret, specificReturn := fake.waitReturnsOnCall[len(fake.waitArgsForCall)]
+	fake.waitArgsForCall = append(fake.waitArgsForCall, struct {
+		revision int64
+		timeout  time.Duration
+	}{revision, timeout})
+	fake.recordInvocation("Wait", []interface{}{revision, timeout})
+	fake.waitMutex.Unlock()
+	if fake.WaitStub != nil {
+		return fake.WaitStub(revision, timeout)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.waitReturns.result1
+}
+
+func (fake *RevisionWatcher) WaitCallCount() int {
+	fake.waitMutex.RLock()
+	defer fake.waitMutex.RUnlock()
+	return len(fake.waitArgsForCall)
+}
+
+func (fake *RevisionWatcher) WaitArgsForCall(i int) (int64, time.Duration) {
+	fake.waitMutex.RLock()
+	defer fake.waitMutex.RUnlock()
+	return fake.waitArgsForCall[i].revision, fake.waitArgsForCall[i].timeout
+}
+
+func (fake *RevisionWatcher) WaitReturns(result1 int64) {
+	fake.WaitStub = nil
+	fake.waitReturns = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *RevisionWatcher) WaitReturnsOnCall(i int, result1 int64) {
+	fake.WaitStub = nil
+	if fake.waitReturnsOnCall == nil {
+		fake.waitReturnsOnCall = make(map[int]struct {
+			result1 int64
+		})
+	}
+	fake.waitReturnsOnCall[i] = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *RevisionWatcher) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.revisionMutex.RLock()
+	defer fake.revisionMutex.RUnlock()
+	fake.waitMutex.RLock()
+	defer fake.waitMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *RevisionWatcher) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,94 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type SubnetReserver struct {
+	ReserveSubnetStub        func(overlaySubnet string) error
+	reserveSubnetMutex       sync.RWMutex
+	reserveSubnetArgsForCall []struct {
+		overlaySubnet string
+	}
+	reserveSubnetReturns struct {
+		result1 error
+	}
+	reserveSubnetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *SubnetReserver) ReserveSubnet(overlaySubnet string) error {
+	fake.reserveSubnetMutex.Lock()
+	ret, specificReturn := fake.reserveSubnetReturnsOnCall[len(fake.reserveSubnetArgsForCall)]
+	fake.reserveSubnetArgsForCall = append(fake.reserveSubnetArgsForCall, struct {
+		overlaySubnet string
+	}{overlaySubnet})
+	fake.recordInvocation("ReserveSubnet", []interface{}{overlaySubnet})
+	fake.reserveSubnetMutex.Unlock()
+	if fake.ReserveSubnetStub != nil {
+		return fake.ReserveSubnetStub(overlaySubnet)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.reserveSubnetReturns.result1
+}
+
+func (fake *SubnetReserver) ReserveSubnetCallCount() int {
+	fake.reserveSubnetMutex.RLock()
+	defer fake.reserveSubnetMutex.RUnlock()
+	return len(fake.reserveSubnetArgsForCall)
+}
+
+func (fake *SubnetReserver) ReserveSubnetArgsForCall(i int) string {
+	fake.reserveSubnetMutex.RLock()
+	defer fake.reserveSubnetMutex.RUnlock()
+	return fake.reserveSubnetArgsForCall[i].overlaySubnet
+}
+
+func (fake *SubnetReserver) ReserveSubnetReturns(result1 error) {
+	fake.ReserveSubnetStub = nil
+	fake.reserveSubnetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SubnetReserver) ReserveSubnetReturnsOnCall(i int, result1 error) {
+	fake.ReserveSubnetStub = nil
+	if fake.reserveSubnetReturnsOnCall == nil {
+		fake.reserveSubnetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.reserveSubnetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SubnetReserver) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.reserveSubnetMutex.RLock()
+	defer fake.reserveSubnetMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *SubnetReserver) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
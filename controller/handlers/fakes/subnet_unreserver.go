@@ -0,0 +1,94 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type SubnetUnreserver struct {
+	UnreserveSubnetStub        func(overlaySubnet string) error
+	unreserveSubnetMutex       sync.RWMutex
+	unreserveSubnetArgsForCall []struct {
+		overlaySubnet string
+	}
+	unreserveSubnetReturns struct {
+		result1 error
+	}
+	unreserveSubnetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *SubnetUnreserver) UnreserveSubnet(overlaySubnet string) error {
+	fake.unreserveSubnetMutex.Lock()
+	ret, specificReturn := fake.unreserveSubnetReturnsOnCall[len(fake.unreserveSubnetArgsForCall)]
+	fake.unreserveSubnetArgsForCall = append(fake.unreserveSubnetArgsForCall, struct {
+		overlaySubnet string
+	}{overlaySubnet})
+	fake.recordInvocation("UnreserveSubnet", []interface{}{overlaySubnet})
+	fake.unreserveSubnetMutex.Unlock()
+	if fake.UnreserveSubnetStub != nil {
+		return fake.UnreserveSubnetStub(overlaySubnet)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.unreserveSubnetReturns.result1
+}
+
+func (fake *SubnetUnreserver) UnreserveSubnetCallCount() int {
+	fake.unreserveSubnetMutex.RLock()
+	defer fake.unreserveSubnetMutex.RUnlock()
+	return len(fake.unreserveSubnetArgsForCall)
+}
+
+func (fake *SubnetUnreserver) UnreserveSubnetArgsForCall(i int) string {
+	fake.unreserveSubnetMutex.RLock()
+	defer fake.unreserveSubnetMutex.RUnlock()
+	return fake.unreserveSubnetArgsForCall[i].overlaySubnet
+}
+
+func (fake *SubnetUnreserver) UnreserveSubnetReturns(result1 error) {
+	fake.UnreserveSubnetStub = nil
+	fake.unreserveSubnetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SubnetUnreserver) UnreserveSubnetReturnsOnCall(i int, result1 error) {
+	fake.UnreserveSubnetStub = nil
+	if fake.unreserveSubnetReturnsOnCall == nil {
+		fake.unreserveSubnetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.unreserveSubnetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SubnetUnreserver) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.unreserveSubnetMutex.RLock()
+	defer fake.unreserveSubnetMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *SubnetUnreserver) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
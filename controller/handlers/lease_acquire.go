@@ -13,7 +13,7 @@ import (
 
 //go:generate counterfeiter -o fakes/lease_acquirer.go --fake-name LeaseAcquirer . leaseAcquirer
 type leaseAcquirer interface {
-	AcquireSubnetLease(underlayIP string, singleOverlayIP bool) (*controller.Lease, error)
+	AcquireSubnetLeaseWithMetadata(underlayIP string, singleOverlayIP, ipv6Overlay bool, metadata controller.LeaseMetadata) (*controller.Lease, error)
 }
 
 type LeasesAcquire struct {
@@ -32,18 +32,19 @@ func (l *LeasesAcquire) ServeHTTP(logger lager.Logger, w http.ResponseWriter, re
 		return
 	}
 
-	var payload struct {
-		UnderlayIP      string `json:"underlay_ip"`
-		SingleOverlayIP bool   `json:"single_overlay_ip"`
-	}
+	var payload controller.AcquireLeaseRequest
 	err = l.Unmarshaler.Unmarshal(bodyBytes, &payload)
 	if err != nil {
 		l.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("unmarshal-request: %s", err.Error()))
 		return
 	}
 
-	lease, err := l.LeaseAcquirer.AcquireSubnetLease(payload.UnderlayIP, payload.SingleOverlayIP)
+	lease, err := l.LeaseAcquirer.AcquireSubnetLeaseWithMetadata(payload.UnderlayIP, payload.SingleOverlayIP, payload.IPv6Overlay, payload.LeaseMetadata)
 	if err != nil {
+		if _, ok := err.(controller.NonRetriableError); ok {
+			l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+			return
+		}
 		l.ErrorResponse.InternalServerError(logger, w, err, err.Error())
 		return
 	}
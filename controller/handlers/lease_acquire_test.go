@@ -60,7 +60,7 @@ var _ = Describe("LeasesAcquire", func() {
 			OverlaySubnet:       "10.255.17.0/24",
 			OverlayHardwareAddr: "ee:ee:0a:ff:11:00",
 		}
-		leaseAcquirer.AcquireSubnetLeaseReturns(lease, nil)
+		leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(lease, nil)
 	})
 
 	It("acquires a lease for subnet", func() {
@@ -72,10 +72,11 @@ var _ = Describe("LeasesAcquire", func() {
 		request.RemoteAddr = "some-host:some-port"
 
 		handler.ServeHTTP(logger, resp, request)
-		Expect(leaseAcquirer.AcquireSubnetLeaseCallCount()).To(Equal(1))
-		underlayIP, singleOverlayIP := leaseAcquirer.AcquireSubnetLeaseArgsForCall(0)
+		Expect(leaseAcquirer.AcquireSubnetLeaseWithMetadataCallCount()).To(Equal(1))
+		underlayIP, singleOverlayIP, ipv6Overlay, _ := leaseAcquirer.AcquireSubnetLeaseWithMetadataArgsForCall(0)
 		Expect(underlayIP).To(Equal("10.244.16.11"))
 		Expect(singleOverlayIP).To(Equal(false))
+		Expect(ipv6Overlay).To(Equal(false))
 
 		Expect(resp.Code).To(Equal(http.StatusOK))
 		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
@@ -87,7 +88,7 @@ var _ = Describe("LeasesAcquire", func() {
 			OverlaySubnet:       "10.255.0.17/32",
 			OverlayHardwareAddr: "ee:ee:0a:fb:00:11",
 		}
-		leaseAcquirer.AcquireSubnetLeaseReturns(lease, nil)
+		leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(lease, nil)
 
 		expectedResponseJSON := `{ "underlay_ip": "10.244.0.12", "overlay_subnet": "10.255.0.17/32", "overlay_hardware_addr": "ee:ee:0a:fb:00:11" }`
 		requestBody := bytes.NewBuffer([]byte(`{ "underlay_ip": "10.244.0.12", "single_overlay_ip": true }`))
@@ -97,8 +98,8 @@ var _ = Describe("LeasesAcquire", func() {
 		request.RemoteAddr = "remote-host:remote-port"
 
 		handler.ServeHTTP(logger, resp, request)
-		Expect(leaseAcquirer.AcquireSubnetLeaseCallCount()).To(Equal(1))
-		underlayIP, singleOverlayIP := leaseAcquirer.AcquireSubnetLeaseArgsForCall(0)
+		Expect(leaseAcquirer.AcquireSubnetLeaseWithMetadataCallCount()).To(Equal(1))
+		underlayIP, singleOverlayIP, _, _ := leaseAcquirer.AcquireSubnetLeaseWithMetadataArgsForCall(0)
 		Expect(underlayIP).To(Equal("10.244.0.12"))
 		Expect(singleOverlayIP).To(Equal(true))
 
@@ -106,6 +107,57 @@ var _ = Describe("LeasesAcquire", func() {
 		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
 	})
 
+	It("acquires a lease for an ipv6 overlay subnet", func() {
+		lease := &controller.Lease{
+			UnderlayIP:          "10.244.16.11",
+			OverlaySubnet:       "fd00:10:255:11::/64",
+			OverlayHardwareAddr: "ee:e6:1d:2c:3b:4a",
+		}
+		leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(lease, nil)
+
+		expectedResponseJSON := `{ "underlay_ip": "10.244.16.11", "overlay_subnet": "fd00:10:255:11::/64", "overlay_hardware_addr": "ee:e6:1d:2c:3b:4a" }`
+		requestBody := bytes.NewBuffer([]byte(`{ "underlay_ip": "10.244.16.11", "ipv6_overlay": true }`))
+		request, err := http.NewRequest("PUT", "/leases/acquire", requestBody)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseAcquirer.AcquireSubnetLeaseWithMetadataCallCount()).To(Equal(1))
+		underlayIP, singleOverlayIP, ipv6Overlay, _ := leaseAcquirer.AcquireSubnetLeaseWithMetadataArgsForCall(0)
+		Expect(underlayIP).To(Equal("10.244.16.11"))
+		Expect(singleOverlayIP).To(Equal(false))
+		Expect(ipv6Overlay).To(Equal(true))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
+	It("passes the lease metadata to the acquirer", func() {
+		lease := &controller.Lease{
+			UnderlayIP:          "10.244.16.11",
+			OverlaySubnet:       "10.255.17.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:11:00",
+			LeaseMetadata: controller.LeaseMetadata{
+				CellID: "diego-cell/0",
+				AZ:     "z1",
+				Labels: map[string]string{"rack": "r3"},
+			},
+		}
+		leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(lease, nil)
+
+		expectedResponseJSON := `{ "underlay_ip": "10.244.16.11", "overlay_subnet": "10.255.17.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:11:00", "cell_id": "diego-cell/0", "az": "z1", "labels": { "rack": "r3" } }`
+		requestBody := bytes.NewBuffer([]byte(`{ "underlay_ip": "10.244.16.11", "cell_id": "diego-cell/0", "az": "z1", "labels": { "rack": "r3" } }`))
+		request, err := http.NewRequest("PUT", "/leases/acquire", requestBody)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseAcquirer.AcquireSubnetLeaseWithMetadataCallCount()).To(Equal(1))
+		_, _, _, metadata := leaseAcquirer.AcquireSubnetLeaseWithMetadataArgsForCall(0)
+		Expect(metadata).To(Equal(lease.LeaseMetadata))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
 	Context("when there are errors reading the body bytes", func() {
 		var request *http.Request
 		BeforeEach(func() {
@@ -149,7 +201,7 @@ var _ = Describe("LeasesAcquire", func() {
 
 	Context("when acquiring a lease fails", func() {
 		BeforeEach(func() {
-			leaseAcquirer.AcquireSubnetLeaseReturns(nil, errors.New("kiwi"))
+			leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(nil, errors.New("kiwi"))
 		})
 
 		It("logs the error and returns a 500", func() {
@@ -168,9 +220,30 @@ var _ = Describe("LeasesAcquire", func() {
 		})
 	})
 
+	Context("when acquiring a lease fails with a non-retriable error", func() {
+		BeforeEach(func() {
+			leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(nil, controller.NonRetriableError("ipv6 overlay network is not configured"))
+		})
+
+		It("logs the error and returns a 400", func() {
+			requestBody := bytes.NewBuffer([]byte(`{ "underlay_ip": "10.244.16.11", "ipv6_overlay": true }`))
+			request, err := http.NewRequest("PUT", "/leases/acquire", requestBody)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("ipv6 overlay network is not configured"))
+			Expect(description).To(Equal("ipv6 overlay network is not configured"))
+		})
+	})
+
 	Context("when no leases are available", func() {
 		BeforeEach(func() {
-			leaseAcquirer.AcquireSubnetLeaseReturns(nil, nil)
+			leaseAcquirer.AcquireSubnetLeaseWithMetadataReturns(nil, nil)
 		})
 
 		It("logs the error and returns a 503", func() {
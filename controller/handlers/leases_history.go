@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_history_repository.go --fake-name LeaseHistoryRepository . leaseHistoryRepository
+type leaseHistoryRepository interface {
+	LeaseHistory(overlaySubnet string) ([]controller.LeaseEvent, error)
+}
+
+type LeasesHistory struct {
+	Marshaler              marshal.Marshaler
+	LeaseHistoryRepository leaseHistoryRepository
+	ErrorResponse          errorResponse
+}
+
+func (l *LeasesHistory) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("leases-history")
+
+	overlaySubnet := req.URL.Query().Get("overlay_subnet")
+	if overlaySubnet == "" {
+		err := errors.New("missing overlay_subnet")
+		l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+		return
+	}
+
+	events, err := l.LeaseHistoryRepository.LeaseHistory(overlaySubnet)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("lease-history: %s", err.Error()))
+		return
+	}
+
+	response := struct {
+		Events []controller.LeaseEvent `json:"events"`
+	}{events}
+	bytes, err := l.Marshaler.Marshal(response)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
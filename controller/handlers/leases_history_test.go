@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeasesHistory", func() {
+	var (
+		logger                 *lagertest.TestLogger
+		expectedLogger         lager.Logger
+		handler                *handlers.LeasesHistory
+		leaseHistoryRepository *fakes.LeaseHistoryRepository
+		resp                   *httptest.ResponseRecorder
+		marshaler              *hfakes.Marshaler
+		fakeErrorResponse      *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("leases-history")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		leaseHistoryRepository = &fakes.LeaseHistoryRepository{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.LeasesHistory{
+			Marshaler:              marshaler,
+			LeaseHistoryRepository: leaseHistoryRepository,
+			ErrorResponse:          fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+		leaseHistoryRepository.LeaseHistoryReturns([]controller.LeaseEvent{
+			{
+				EventType:           "acquire",
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.30.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+				CreatedAt:           1500000000,
+			},
+			{
+				EventType:           "release",
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.30.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+				CreatedAt:           1500000100,
+			},
+		}, nil)
+	})
+
+	It("returns the events for the overlay subnet", func() {
+		expectedResponseJSON := `{ "events": [
+			{ "event_type": "acquire", "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.30.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:1e:00", "created_at": 1500000000 },
+			{ "event_type": "release", "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.30.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:1e:00", "created_at": 1500000100 }
+		] }`
+		request, err := http.NewRequest("GET", "/leases/history?overlay_subnet=10.255.30.0%2F24", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseHistoryRepository.LeaseHistoryCallCount()).To(Equal(1))
+		Expect(leaseHistoryRepository.LeaseHistoryArgsForCall(0)).To(Equal("10.255.30.0/24"))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
+	Context("when the overlay subnet is missing", func() {
+		It("calls the bad request handler", func() {
+			request, err := http.NewRequest("GET", "/leases/history", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(leaseHistoryRepository.LeaseHistoryCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("missing overlay_subnet"))
+			Expect(description).To(Equal("missing overlay_subnet"))
+		})
+	})
+
+	Context("when getting the lease history fails", func() {
+		BeforeEach(func() {
+			leaseHistoryRepository.LeaseHistoryReturns(nil, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/history?overlay_subnet=10.255.30.0%2F24", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("lease-history: butter"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/history?overlay_subnet=10.255.30.0%2F24", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
@@ -1,8 +1,18 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"code.cloudfoundry.org/cf-networking-helpers/marshal"
 	"code.cloudfoundry.org/lager"
@@ -12,26 +22,89 @@ import (
 //go:generate counterfeiter -o fakes/lease_repository.go --fake-name LeaseRepository . leaseRepository
 type leaseRepository interface {
 	RoutableLeases() ([]controller.Lease, error)
+	QueryLeases(controller.LeaseQuery) ([]controller.Lease, string, error)
 }
 
+// LeasesIndex serves the lease revision of the database, the same one the
+// watch serves, together with a hash of the leases as the revision and ETag
+// of the leases. The leases may be newer than the database revision that was
+// read, so only the hash tells the lease sets served under one database
+// revision apart.
 type LeasesIndex struct {
 	Marshaler       marshal.Marshaler
 	LeaseRepository leaseRepository
+	RevisionWatcher revisionWatcher
 	ErrorResponse   errorResponse
+
+	// SnapshotCount is the number of recent lease sets kept to answer
+	// requests for the changes since an earlier revision.
+	SnapshotCount int
+
+	mutex     sync.Mutex
+	snapshots map[string][]controller.Lease
+	keys      []string
 }
 
 func (l *LeasesIndex) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
 	logger = logger.Session("leases-index")
 
-	leases, err := l.LeaseRepository.RoutableLeases()
+	query, err := leaseQueryFromURL(req.URL.Query())
 	if err != nil {
-		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("all-routable-leases: %s", err.Error()))
+		l.ErrorResponse.BadRequest(logger, w, err, err.Error())
 		return
 	}
 
-	response := struct {
-		Leases []controller.Lease `json:"leases"`
-	}{leases}
+	// The database revision is read before the leases, so the leases are
+	// never older than the revision they are served with.
+	databaseRevision := l.RevisionWatcher.Revision()
+
+	var leases []controller.Lease
+	var nextCursor string
+	if isUnfiltered(query) {
+		leases, err = l.LeaseRepository.RoutableLeases()
+		if err != nil {
+			l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("all-routable-leases: %s", err.Error()))
+			return
+		}
+	} else {
+		var next string
+		leases, next, err = l.LeaseRepository.QueryLeases(query)
+		if err != nil {
+			l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("query-leases: %s", err.Error()))
+			return
+		}
+		if next != "" {
+			nextCursor = base64.RawURLEncoding.EncodeToString([]byte(next))
+		}
+	}
+
+	revision := fmt.Sprintf("%d-%s", databaseRevision, leasesHash(leases))
+	etag := fmt.Sprintf("%q", revision)
+	w.Header().Set("ETag", etag)
+
+	since := req.URL.Query().Get("since")
+	if since == revision || req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var response interface{} = struct {
+		Revision   string             `json:"revision"`
+		Leases     []controller.Lease `json:"leases"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}{revision, leases, nextCursor}
+	if previous, ok := l.snapshot(snapshotKey(req.URL.Query(), since)); ok {
+		added, removed := diffLeases(previous, leases)
+		response = struct {
+			Revision   string             `json:"revision"`
+			Delta      bool               `json:"delta"`
+			Added      []controller.Lease `json:"added"`
+			Removed    []controller.Lease `json:"removed"`
+			NextCursor string             `json:"next_cursor,omitempty"`
+		}{revision, true, added, removed, nextCursor}
+	}
+	l.remember(snapshotKey(req.URL.Query(), revision), leases)
+
 	bytes, err := l.Marshaler.Marshal(response)
 	if err != nil {
 		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
@@ -40,3 +113,145 @@ func (l *LeasesIndex) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req
 
 	w.Write(bytes)
 }
+
+func (l *LeasesIndex) snapshot(key string) ([]controller.Lease, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	leases, ok := l.snapshots[key]
+	return leases, ok
+}
+
+func (l *LeasesIndex) remember(key string, leases []controller.Lease) {
+	if l.SnapshotCount < 1 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, ok := l.snapshots[key]; ok {
+		return
+	}
+	if l.snapshots == nil {
+		l.snapshots = map[string][]controller.Lease{}
+	}
+	l.snapshots[key] = leases
+	l.keys = append(l.keys, key)
+	if len(l.keys) > l.SnapshotCount {
+		delete(l.snapshots, l.keys[0])
+		l.keys = l.keys[1:]
+	}
+}
+
+// snapshotKey identifies the leases of a query at a revision. Every query
+// shares the revision, so the other parameters are part of the key.
+func snapshotKey(values url.Values, revision string) string {
+	if revision == "" {
+		return ""
+	}
+
+	parameters := url.Values{}
+	for name, value := range values {
+		if name != "since" {
+			parameters[name] = value
+		}
+	}
+	return revision + "?" + parameters.Encode()
+}
+
+// leasesHash identifies a set of leases by its content, regardless of the
+// order the leases were listed in.
+func leasesHash(leases []controller.Lease) string {
+	keys := make([]string, 0, len(leases))
+	for _, lease := range leases {
+		keys = append(keys, lease.Key())
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintln(hash, key)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+func diffLeases(previous, current []controller.Lease) ([]controller.Lease, []controller.Lease) {
+	previousSet := map[string]bool{}
+	for _, lease := range previous {
+		previousSet[lease.Key()] = true
+	}
+	currentSet := map[string]bool{}
+	for _, lease := range current {
+		currentSet[lease.Key()] = true
+	}
+
+	added := []controller.Lease{}
+	for _, lease := range current {
+		if !previousSet[lease.Key()] {
+			added = append(added, lease)
+		}
+	}
+	removed := []controller.Lease{}
+	for _, lease := range previous {
+		if !currentSet[lease.Key()] {
+			removed = append(removed, lease)
+		}
+	}
+	return added, removed
+}
+
+// leaseQueryFromURL parses the filters and pagination parameters. Deltas of
+// a filtered response work the same as for the unfiltered one.
+func leaseQueryFromURL(values url.Values) (controller.LeaseQuery, error) {
+	query := controller.LeaseQuery{
+		UnderlayIP:    values.Get("underlay_ip"),
+		OverlaySubnet: values.Get("overlay_subnet"),
+		ContainsIP:    values.Get("contains_ip"),
+		CellID:        values.Get("cell_id"),
+		AZ:            values.Get("az"),
+	}
+	if query.ContainsIP != "" && net.ParseIP(query.ContainsIP) == nil {
+		return controller.LeaseQuery{}, errors.New("contains_ip must be an ip address")
+	}
+	for _, label := range values["label"] {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return controller.LeaseQuery{}, errors.New("label must be in the form key=value")
+		}
+		if query.Labels == nil {
+			query.Labels = map[string]string{}
+		}
+		query.Labels[parts[0]] = parts[1]
+	}
+	if includeExpired := values.Get("include_expired"); includeExpired != "" {
+		var err error
+		query.IncludeExpired, err = strconv.ParseBool(includeExpired)
+		if err != nil {
+			return controller.LeaseQuery{}, errors.New("include_expired must be true or false")
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 {
+			return controller.LeaseQuery{}, errors.New("limit must be a positive number")
+		}
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(after) == 0 {
+			return controller.LeaseQuery{}, errors.New("invalid cursor")
+		}
+		query.After = string(after)
+	}
+	return query, nil
+}
+
+// isUnfiltered reports whether the query selects every active lease, which
+// is served from the routable leases rather than from the database.
+func isUnfiltered(query controller.LeaseQuery) bool {
+	return query.UnderlayIP == "" && query.OverlaySubnet == "" && query.ContainsIP == "" &&
+		query.CellID == "" && query.AZ == "" && len(query.Labels) == 0 &&
+		!query.IncludeExpired && query.Limit == 0 && query.After == ""
+}
@@ -14,6 +14,7 @@ import (
 	"code.cloudfoundry.org/silk/controller/handlers/fakes"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -23,6 +24,7 @@ var _ = Describe("LeasesIndex", func() {
 		expectedLogger    lager.Logger
 		handler           *handlers.LeasesIndex
 		leaseRepository   *fakes.LeaseRepository
+		revisionWatcher   *fakes.RevisionWatcher
 		resp              *httptest.ResponseRecorder
 		marshaler         *hfakes.Marshaler
 		fakeErrorResponse *fakes.ErrorResponse
@@ -40,9 +42,12 @@ var _ = Describe("LeasesIndex", func() {
 		marshaler.MarshalStub = json.Marshal
 		leaseRepository = &fakes.LeaseRepository{}
 		fakeErrorResponse = &fakes.ErrorResponse{}
+		revisionWatcher = &fakes.RevisionWatcher{}
+		revisionWatcher.RevisionReturns(7)
 		handler = &handlers.LeasesIndex{
 			Marshaler:       marshaler,
 			LeaseRepository: leaseRepository,
+			RevisionWatcher: revisionWatcher,
 			ErrorResponse:   fakeErrorResponse,
 		}
 		resp = httptest.NewRecorder()
@@ -61,10 +66,10 @@ var _ = Describe("LeasesIndex", func() {
 	})
 
 	It("returns the routable leases", func() {
-		expectedResponseJSON := `{ "leases": [
+		expectedLeasesJSON := `[
 		{ "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.16.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:10:00" },
 		  { "underlay_ip": "10.244.22.33", "overlay_subnet": "10.255.75.0/32", "overlay_hardware_addr": "ee:ee:0a:ff:4b:00" }
-		] }`
+		]`
 		request, err := http.NewRequest("GET", "/leases", nil)
 		Expect(err).NotTo(HaveOccurred())
 
@@ -73,7 +78,298 @@ var _ = Describe("LeasesIndex", func() {
 		handler.ServeHTTP(logger, resp, request)
 		Expect(leaseRepository.RoutableLeasesCallCount()).To(Equal(1))
 		Expect(resp.Code).To(Equal(http.StatusOK))
-		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+
+		var response struct {
+			Revision string          `json:"revision"`
+			Leases   json.RawMessage `json:"leases"`
+		}
+		Expect(json.Unmarshal(resp.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Leases).To(MatchJSON(expectedLeasesJSON))
+		Expect(response.Revision).To(HavePrefix("7-"))
+		Expect(resp.Header().Get("ETag")).To(Equal(`"` + response.Revision + `"`))
+	})
+
+	Describe("revisions", func() {
+		var (
+			leases []controller.Lease
+			serve  func(url string, header http.Header) *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			handler.SnapshotCount = 2
+			leases, _ = leaseRepository.RoutableLeases()
+			serve = func(url string, header http.Header) *httptest.ResponseRecorder {
+				request, err := http.NewRequest("GET", url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				if header != nil {
+					request.Header = header
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(logger, recorder, request)
+				return recorder
+			}
+		})
+
+		revisionOf := func(recorder *httptest.ResponseRecorder) string {
+			var response controller.LeasesResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			return response.Revision
+		}
+
+		It("identifies the leases by the database revision", func() {
+			Expect(revisionOf(serve("/leases", nil))).To(HavePrefix("7-"))
+
+			revisionWatcher.RevisionReturns(8)
+			Expect(revisionOf(serve("/leases", nil))).To(HavePrefix("8-"))
+		})
+
+		It("identifies the leases by their content regardless of their order", func() {
+			revision := revisionOf(serve("/leases", nil))
+
+			leaseRepository.RoutableLeasesReturns([]controller.Lease{leases[1], leases[0]}, nil)
+			Expect(revisionOf(serve("/leases", nil))).To(Equal(revision))
+		})
+
+		Context("when the leases change while the database revision stays the same", func() {
+			var (
+				revision string
+				etag     string
+				newLease controller.Lease
+			)
+
+			BeforeEach(func() {
+				recorder := serve("/leases", nil)
+				revision = revisionOf(recorder)
+				etag = recorder.Header().Get("ETag")
+
+				newLease = controller.Lease{
+					UnderlayIP:          "10.244.1.2",
+					OverlaySubnet:       "10.255.1.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:01:00",
+				}
+				leaseRepository.RoutableLeasesReturns([]controller.Lease{leases[1], newLease}, nil)
+			})
+
+			It("serves them under a new revision and ETag", func() {
+				recorder := serve("/leases", http.Header{"If-None-Match": []string{etag}})
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(revisionOf(recorder)).To(HavePrefix("7-"))
+				Expect(revisionOf(recorder)).NotTo(Equal(revision))
+				Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+			})
+
+			It("returns the changes since the leases the client got", func() {
+				recorder := serve("/leases?since="+revision, nil)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				var response controller.LeasesResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Delta).To(BeTrue())
+				Expect(response.Added).To(Equal([]controller.Lease{newLease}))
+				Expect(response.Removed).To(Equal(leases[:1]))
+
+				next := response.Revision
+				leaseRepository.RoutableLeasesReturns(leases, nil)
+				Expect(json.Unmarshal(serve("/leases?since="+next, nil).Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Delta).To(BeTrue())
+				Expect(response.Added).To(Equal(leases[:1]))
+				Expect(response.Removed).To(Equal([]controller.Lease{newLease}))
+			})
+		})
+
+		Context("when If-None-Match matches the current revision", func() {
+			It("responds not modified", func() {
+				etag := serve("/leases", nil).Header().Get("ETag")
+
+				recorder := serve("/leases", http.Header{"If-None-Match": []string{etag}})
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Header().Get("ETag")).To(Equal(etag))
+				Expect(recorder.Body.Len()).To(Equal(0))
+			})
+		})
+
+		Context("when since is the current revision", func() {
+			It("responds not modified", func() {
+				revision := revisionOf(serve("/leases", nil))
+
+				recorder := serve("/leases?since="+revision, nil)
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+			})
+		})
+
+		Context("when since is a recent revision", func() {
+			It("returns the leases added and removed since then", func() {
+				revision := revisionOf(serve("/leases", nil))
+
+				newLease := controller.Lease{
+					UnderlayIP:          "10.244.1.2",
+					OverlaySubnet:       "10.255.1.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:01:00",
+				}
+				revisionWatcher.RevisionReturns(8)
+				leaseRepository.RoutableLeasesReturns([]controller.Lease{leases[1], newLease}, nil)
+
+				recorder := serve("/leases?since="+revision, nil)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				var response controller.LeasesResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Revision).To(HavePrefix("8-"))
+				Expect(response.Delta).To(BeTrue())
+				Expect(response.Leases).To(BeEmpty())
+				Expect(response.Added).To(Equal([]controller.Lease{newLease}))
+				Expect(response.Removed).To(Equal(leases[:1]))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"` + response.Revision + `"`))
+			})
+		})
+
+		Context("when since is a revision remembered for other filters", func() {
+			It("returns all the leases", func() {
+				leaseRepository.QueryLeasesReturns(leases[:1], "", nil)
+				revision := revisionOf(serve("/leases?az=z1", nil))
+				revisionWatcher.RevisionReturns(8)
+
+				var response controller.LeasesResponse
+				Expect(json.Unmarshal(serve("/leases?since="+revision, nil).Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Delta).To(BeFalse())
+				Expect(response.Leases).To(Equal(leases))
+			})
+		})
+
+		Context("when since is a revision that is no longer remembered", func() {
+			It("returns all the leases", func() {
+				revision := revisionOf(serve("/leases", nil))
+				revisionWatcher.RevisionReturns(8)
+				leaseRepository.RoutableLeasesReturns(leases[:1], nil)
+				serve("/leases", nil)
+				revisionWatcher.RevisionReturns(9)
+				leaseRepository.RoutableLeasesReturns(leases[1:], nil)
+				serve("/leases", nil)
+				revisionWatcher.RevisionReturns(10)
+				leaseRepository.RoutableLeasesReturns(nil, nil)
+
+				var response controller.LeasesResponse
+				Expect(json.Unmarshal(serve("/leases?since="+revision, nil).Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Delta).To(BeFalse())
+				Expect(response.Leases).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("filters", func() {
+		var queriedLeases []controller.Lease
+
+		BeforeEach(func() {
+			queriedLeases = []controller.Lease{
+				{
+					UnderlayIP:          "10.244.5.9",
+					OverlaySubnet:       "10.255.16.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+					LeaseMetadata: controller.LeaseMetadata{
+						CellID: "diego-cell/0",
+						AZ:     "z1",
+						Labels: map[string]string{"rack": "r1", "pool": "blue"},
+					},
+				},
+			}
+			leaseRepository.QueryLeasesReturns(queriedLeases, "", nil)
+		})
+
+		serve := func(url string) *httptest.ResponseRecorder {
+			request, err := http.NewRequest("GET", url, nil)
+			Expect(err).NotTo(HaveOccurred())
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(logger, recorder, request)
+			return recorder
+		}
+
+		It("queries the leases matching the filters", func() {
+			recorder := serve("/leases?underlay_ip=10.244.5.9&overlay_subnet=10.255.16.0/24&contains_ip=10.255.16.4" +
+				"&cell_id=diego-cell/0&az=z1&label=pool=blue&label=rack=r1&include_expired=true")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(leaseRepository.RoutableLeasesCallCount()).To(Equal(0))
+			Expect(leaseRepository.QueryLeasesCallCount()).To(Equal(1))
+			Expect(leaseRepository.QueryLeasesArgsForCall(0)).To(Equal(controller.LeaseQuery{
+				UnderlayIP:     "10.244.5.9",
+				OverlaySubnet:  "10.255.16.0/24",
+				ContainsIP:     "10.255.16.4",
+				CellID:         "diego-cell/0",
+				AZ:             "z1",
+				Labels:         map[string]string{"pool": "blue", "rack": "r1"},
+				IncludeExpired: true,
+			}))
+
+			var response controller.LeasesResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Leases).To(Equal(queriedLeases))
+			Expect(response.Revision).To(HavePrefix("7-"))
+			Expect(response.NextCursor).To(BeEmpty())
+		})
+
+		It("returns the lease metadata", func() {
+			leaseRepository.RoutableLeasesReturns(queriedLeases, nil)
+
+			var response controller.LeasesResponse
+			Expect(json.Unmarshal(serve("/leases").Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Leases[0].CellID).To(Equal("diego-cell/0"))
+			Expect(response.Leases[0].AZ).To(Equal("z1"))
+			Expect(response.Leases[0].Labels).To(Equal(map[string]string{"rack": "r1", "pool": "blue"}))
+		})
+
+		It("serves the unfiltered leases from the routable leases", func() {
+			serve("/leases?since=some-revision")
+			Expect(leaseRepository.RoutableLeasesCallCount()).To(Equal(1))
+			Expect(leaseRepository.QueryLeasesCallCount()).To(Equal(0))
+		})
+
+		Context("when paginating", func() {
+			It("returns a cursor for the next page", func() {
+				leaseRepository.QueryLeasesReturns(queriedLeases, "10.255.16.0/24", nil)
+
+				var response controller.LeasesResponse
+				Expect(json.Unmarshal(serve("/leases?limit=1").Body.Bytes(), &response)).To(Succeed())
+				Expect(leaseRepository.QueryLeasesArgsForCall(0)).To(Equal(controller.LeaseQuery{Limit: 1}))
+				Expect(response.NextCursor).NotTo(BeEmpty())
+
+				serve("/leases?limit=1&cursor=" + response.NextCursor)
+				Expect(leaseRepository.QueryLeasesArgsForCall(1)).To(Equal(controller.LeaseQuery{
+					Limit: 1,
+					After: "10.255.16.0/24",
+				}))
+			})
+		})
+
+		DescribeTable("when a parameter is invalid",
+			func(query, message string) {
+				serve("/leases?" + query)
+
+				Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+				_, _, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+				Expect(err).To(MatchError(message))
+				Expect(description).To(Equal(message))
+				Expect(leaseRepository.RoutableLeasesCallCount()).To(Equal(0))
+				Expect(leaseRepository.QueryLeasesCallCount()).To(Equal(0))
+			},
+			Entry("label", "label=rack", "label must be in the form key=value"),
+			Entry("contains_ip", "contains_ip=10.255.16", "contains_ip must be an ip address"),
+			Entry("include_expired", "include_expired=sometimes", "include_expired must be true or false"),
+			Entry("limit", "limit=0", "limit must be a positive number"),
+			Entry("cursor", "cursor=!!", "invalid cursor"),
+		)
+
+		Context("when querying the leases fails", func() {
+			It("calls the internal server error handler", func() {
+				leaseRepository.QueryLeasesReturns(nil, "", errors.New("toast"))
+
+				serve("/leases?az=z1")
+
+				Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+				_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+				Expect(err).To(MatchError("toast"))
+				Expect(description).To(Equal("query-leases: toast"))
+			})
+		})
 	})
 
 	Context("when getting the routable leases fails", func() {
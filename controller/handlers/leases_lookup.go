@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_lookup_repository.go --fake-name LeaseLookupRepository . leaseLookupRepository
+type leaseLookupRepository interface {
+	LookupLease(ip string) (*controller.LeaseRecord, error)
+}
+
+type LeasesLookup struct {
+	Marshaler             marshal.Marshaler
+	LeaseLookupRepository leaseLookupRepository
+	ErrorResponse         errorResponse
+}
+
+func (l *LeasesLookup) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("leases-lookup")
+
+	ip := req.URL.Query().Get("ip")
+	if net.ParseIP(ip) == nil {
+		err := errors.New("ip must be an ip address")
+		l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+		return
+	}
+
+	record, err := l.LeaseLookupRepository.LookupLease(ip)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("lookup-lease: %s", err.Error()))
+		return
+	}
+	if record == nil {
+		err := fmt.Errorf("no lease holds %s", ip)
+		l.ErrorResponse.NotFound(logger, w, err, err.Error())
+		return
+	}
+
+	response := controller.LeaseLookupResponse{
+		Lease: controller.Lease{
+			UnderlayIP:          record.UnderlayIP,
+			OverlaySubnet:       record.OverlaySubnet,
+			OverlayHardwareAddr: record.OverlayHardwareAddr,
+			LeaseMetadata:       record.LeaseMetadata,
+		},
+		Active:        !record.Expired,
+		Quarantined:   record.Quarantined,
+		LastRenewedAt: record.LastRenewedAt,
+	}
+	bytes, err := l.Marshaler.Marshal(response)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
@@ -0,0 +1,178 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeasesLookup", func() {
+	var (
+		logger                *lagertest.TestLogger
+		expectedLogger        lager.Logger
+		handler               *handlers.LeasesLookup
+		leaseLookupRepository *fakes.LeaseLookupRepository
+		resp                  *httptest.ResponseRecorder
+		marshaler             *hfakes.Marshaler
+		fakeErrorResponse     *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("leases-lookup")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		leaseLookupRepository = &fakes.LeaseLookupRepository{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.LeasesLookup{
+			Marshaler:             marshaler,
+			LeaseLookupRepository: leaseLookupRepository,
+			ErrorResponse:         fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+		leaseLookupRepository.LookupLeaseReturns(&controller.LeaseRecord{
+			UnderlayIP:          "10.244.5.9",
+			OverlaySubnet:       "10.255.77.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:4d:00",
+			LastRenewedAt:       1500000000,
+			Expired:             true,
+			LeaseMetadata:       controller.LeaseMetadata{CellID: "diego-cell/3"},
+		}, nil)
+	})
+
+	It("returns the lease holding the ip", func() {
+		expectedResponseJSON := `{
+			"lease": { "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.77.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:4d:00", "cell_id": "diego-cell/3" },
+			"active": false,
+			"quarantined": false,
+			"last_renewed_at": 1500000000
+		}`
+		request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77.12", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseLookupRepository.LookupLeaseCallCount()).To(Equal(1))
+		Expect(leaseLookupRepository.LookupLeaseArgsForCall(0)).To(Equal("10.255.77.12"))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
+	Context("when the lease is quarantined", func() {
+		BeforeEach(func() {
+			leaseLookupRepository.LookupLeaseReturns(&controller.LeaseRecord{
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.77.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:4d:00",
+				LastRenewedAt:       1500000000,
+				Expired:             true,
+				Quarantined:         true,
+				QuarantinedAt:       1500000100,
+			}, nil)
+		})
+
+		It("says so in the response", func() {
+			request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77.12", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(MatchJSON(`{
+				"lease": { "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.77.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:4d:00" },
+				"active": false,
+				"quarantined": true,
+				"last_renewed_at": 1500000000
+			}`))
+		})
+	})
+
+	Context("when the ip is missing or invalid", func() {
+		It("calls the bad request handler", func() {
+			request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(leaseLookupRepository.LookupLeaseCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("ip must be an ip address"))
+			Expect(description).To(Equal("ip must be an ip address"))
+		})
+	})
+
+	Context("when no lease holds the ip", func() {
+		BeforeEach(func() {
+			leaseLookupRepository.LookupLeaseReturns(nil, nil)
+		})
+
+		It("calls the not found handler", func() {
+			request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77.12", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.NotFoundCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.NotFoundArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("no lease holds 10.255.77.12"))
+			Expect(description).To(Equal("no lease holds 10.255.77.12"))
+		})
+	})
+
+	Context("when looking up the lease fails", func() {
+		BeforeEach(func() {
+			leaseLookupRepository.LookupLeaseReturns(nil, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77.12", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("lookup-lease: butter"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/lookup?ip=10.255.77.12", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/revision_watcher.go --fake-name RevisionWatcher . revisionWatcher
+type revisionWatcher interface {
+	Revision() int64
+	Wait(revision int64, timeout time.Duration) int64
+}
+
+type LeasesWatch struct {
+	Marshaler       marshal.Marshaler
+	RevisionWatcher revisionWatcher
+	LeaseRepository leaseRepository
+	ErrorResponse   errorResponse
+	MaxTimeout      time.Duration
+}
+
+func (l *LeasesWatch) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("leases-watch")
+
+	query := req.URL.Query()
+
+	timeout := l.MaxTimeout
+	if timeoutParam := query.Get("timeout"); timeoutParam != "" {
+		seconds, err := strconv.Atoi(timeoutParam)
+		if err != nil || seconds < 0 {
+			err := errors.New("timeout must be a non-negative number of seconds")
+			l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+			return
+		}
+		if requested := time.Duration(seconds) * time.Second; requested < timeout {
+			timeout = requested
+		}
+	}
+
+	var revision int64
+	if revisionParam := query.Get("revision"); revisionParam != "" {
+		knownRevision, err := strconv.ParseInt(revisionParam, 10, 64)
+		if err != nil {
+			err := errors.New("revision must be a number")
+			l.ErrorResponse.BadRequest(logger, w, err, err.Error())
+			return
+		}
+		revision = l.RevisionWatcher.Wait(knownRevision, timeout)
+	} else {
+		revision = l.RevisionWatcher.Revision()
+	}
+
+	leases, err := l.LeaseRepository.RoutableLeases()
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("all-routable-leases: %s", err.Error()))
+		return
+	}
+
+	response := controller.WatchLeasesResponse{
+		Revision: revision,
+		Leases:   leases,
+	}
+	bytes, err := l.Marshaler.Marshal(response)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
@@ -0,0 +1,169 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeasesWatch", func() {
+	var (
+		logger            *lagertest.TestLogger
+		expectedLogger    lager.Logger
+		handler           *handlers.LeasesWatch
+		revisionWatcher   *fakes.RevisionWatcher
+		leaseRepository   *fakes.LeaseRepository
+		resp              *httptest.ResponseRecorder
+		marshaler         *hfakes.Marshaler
+		fakeErrorResponse *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("leases-watch")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		revisionWatcher = &fakes.RevisionWatcher{}
+		revisionWatcher.RevisionReturns(4)
+		revisionWatcher.WaitReturns(5)
+		leaseRepository = &fakes.LeaseRepository{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.LeasesWatch{
+			Marshaler:       marshaler,
+			RevisionWatcher: revisionWatcher,
+			LeaseRepository: leaseRepository,
+			ErrorResponse:   fakeErrorResponse,
+			MaxTimeout:      30 * time.Second,
+		}
+		resp = httptest.NewRecorder()
+		leaseRepository.RoutableLeasesReturns([]controller.Lease{
+			{
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.16.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+			},
+		}, nil)
+	})
+
+	It("waits for the revision to change and returns the routable leases", func() {
+		request, err := http.NewRequest("GET", "/leases/watch?revision=4&timeout=10", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+
+		Expect(revisionWatcher.WaitCallCount()).To(Equal(1))
+		revision, timeout := revisionWatcher.WaitArgsForCall(0)
+		Expect(revision).To(Equal(int64(4)))
+		Expect(timeout).To(Equal(10 * time.Second))
+
+		Expect(leaseRepository.RoutableLeasesCallCount()).To(Equal(1))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(`{ "revision": 5, "leases": [
+			{ "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.16.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:10:00" }
+		] }`))
+	})
+
+	Context("when no revision is given", func() {
+		It("returns the current revision without waiting", func() {
+			request, err := http.NewRequest("GET", "/leases/watch", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(revisionWatcher.WaitCallCount()).To(Equal(0))
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(ContainSubstring(`"revision":4`))
+		})
+	})
+
+	Context("when the timeout is missing or above the maximum", func() {
+		It("waits for the maximum timeout", func() {
+			for _, url := range []string{"/leases/watch?revision=4", "/leases/watch?revision=4&timeout=600"} {
+				request, err := http.NewRequest("GET", url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				handler.ServeHTTP(logger, httptest.NewRecorder(), request)
+			}
+
+			Expect(revisionWatcher.WaitCallCount()).To(Equal(2))
+			_, timeout := revisionWatcher.WaitArgsForCall(0)
+			Expect(timeout).To(Equal(30 * time.Second))
+			_, timeout = revisionWatcher.WaitArgsForCall(1)
+			Expect(timeout).To(Equal(30 * time.Second))
+		})
+	})
+
+	DescribeTable("when a query parameter is invalid",
+		func(url, description string) {
+			request, err := http.NewRequest("GET", url, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(revisionWatcher.WaitCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, desc := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError(description))
+			Expect(desc).To(Equal(description))
+		},
+		Entry("revision", "/leases/watch?revision=banana", "revision must be a number"),
+		Entry("timeout", "/leases/watch?revision=4&timeout=-1", "timeout must be a non-negative number of seconds"),
+	)
+
+	Context("when getting the routable leases fails", func() {
+		BeforeEach(func() {
+			leaseRepository.RoutableLeasesReturns(nil, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/watch?revision=4", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("all-routable-leases: butter"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			request, err := http.NewRequest("GET", "/leases/watch?revision=4", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			_, _, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
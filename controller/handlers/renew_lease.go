@@ -20,6 +20,7 @@ type errorResponse interface {
 	InternalServerError(lager.Logger, http.ResponseWriter, error, string)
 	BadRequest(lager.Logger, http.ResponseWriter, error, string)
 	Conflict(lager.Logger, http.ResponseWriter, error, string)
+	NotFound(lager.Logger, http.ResponseWriter, error, string)
 }
 
 type RenewLease struct {
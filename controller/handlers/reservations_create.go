@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/subnet_reserver.go --fake-name SubnetReserver . subnetReserver
+type subnetReserver interface {
+	ReserveSubnet(overlaySubnet string) error
+}
+
+type ReservationsCreate struct {
+	Unmarshaler    marshal.Unmarshaler
+	SubnetReserver subnetReserver
+	ErrorResponse  errorResponse
+}
+
+func (r *ReservationsCreate) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("reservations-create")
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		r.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("read-body: %s", err.Error()))
+		return
+	}
+
+	var payload struct {
+		OverlaySubnet string `json:"overlay_subnet"`
+	}
+	err = r.Unmarshaler.Unmarshal(bodyBytes, &payload)
+	if err != nil {
+		r.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("unmarshal-request: %s", err.Error()))
+		return
+	}
+
+	err = r.SubnetReserver.ReserveSubnet(payload.OverlaySubnet)
+	if err != nil {
+		if _, ok := err.(controller.NonRetriableError); ok {
+			r.ErrorResponse.BadRequest(logger, w, err, err.Error())
+			return
+		}
+		r.ErrorResponse.InternalServerError(logger, w, err, err.Error())
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
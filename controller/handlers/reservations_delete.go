@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter -o fakes/subnet_unreserver.go --fake-name SubnetUnreserver . subnetUnreserver
+type subnetUnreserver interface {
+	UnreserveSubnet(overlaySubnet string) error
+}
+
+type ReservationsDelete struct {
+	SubnetUnreserver subnetUnreserver
+	ErrorResponse    errorResponse
+}
+
+func (r *ReservationsDelete) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("reservations-delete")
+
+	overlaySubnet := req.URL.Query().Get("overlay_subnet")
+	if overlaySubnet == "" {
+		err := errors.New("missing overlay_subnet")
+		r.ErrorResponse.BadRequest(logger, w, err, err.Error())
+		return
+	}
+
+	err := r.SubnetUnreserver.UnreserveSubnet(overlaySubnet)
+	if err != nil {
+		r.ErrorResponse.InternalServerError(logger, w, err, err.Error())
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
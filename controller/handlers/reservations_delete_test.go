@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReservationsDelete", func() {
+	var (
+		logger            *lagertest.TestLogger
+		expectedLogger    lager.Logger
+		handler           *handlers.ReservationsDelete
+		resp              *httptest.ResponseRecorder
+		subnetUnreserver  *fakes.SubnetUnreserver
+		fakeErrorResponse *fakes.ErrorResponse
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("reservations-delete")
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		subnetUnreserver = &fakes.SubnetUnreserver{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+
+		handler = &handlers.ReservationsDelete{
+			SubnetUnreserver: subnetUnreserver,
+			ErrorResponse:    fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+	})
+
+	It("unreserves the subnet", func() {
+		request, err := http.NewRequest("DELETE", "/reservations?overlay_subnet=10.255.30.0%2F24", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(subnetUnreserver.UnreserveSubnetCallCount()).To(Equal(1))
+		Expect(subnetUnreserver.UnreserveSubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(MatchJSON(`{}`))
+	})
+
+	Context("when the overlay subnet is missing", func() {
+		It("calls the bad request handler", func() {
+			request, err := http.NewRequest("DELETE", "/reservations", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(subnetUnreserver.UnreserveSubnetCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("missing overlay_subnet"))
+			Expect(description).To(Equal("missing overlay_subnet"))
+		})
+	})
+
+	Context("when unreserving the subnet fails", func() {
+		BeforeEach(func() {
+			subnetUnreserver.UnreserveSubnetReturns(errors.New("kiwi"))
+		})
+
+		It("calls the Error Response InternalServerError() handler", func() {
+			request, err := http.NewRequest("DELETE", "/reservations?overlay_subnet=10.255.30.0%2F24", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("kiwi"))
+			Expect(description).To(Equal("kiwi"))
+		})
+	})
+})
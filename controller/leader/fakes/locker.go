@@ -0,0 +1,166 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type Locker struct {
+	AcquireLockStub        func(name string, owner string, ttlSeconds int) (bool, error)
+	acquireLockMutex       sync.RWMutex
+	acquireLockArgsForCall []struct {
+		name       string
+		owner      string
+		ttlSeconds int
+	}
+	acquireLockReturns struct {
+		result1 bool
+		result2 error
+	}
+	acquireLockReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	ReleaseLockStub        func(name string, owner string) error
+	releaseLockMutex       sync.RWMutex
+	releaseLockArgsForCall []struct {
+		name  string
+		owner string
+	}
+	releaseLockReturns struct {
+		result1 error
+	}
+	releaseLockReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Locker) AcquireLock(name string, owner string, ttlSeconds int) (bool, error) {
+	fake.acquireLockMutex.Lock()
+	ret, specificReturn := fake.acquireLockReturnsOnCall[len(fake.acquireLockArgsForCall)]
+	fake.acquireLockArgsForCall = append(fake.acquireLockArgsForCall, struct {
+		name       string
+		owner      string
+		ttlSeconds int
+	}{name, owner, ttlSeconds})
+	fake.recordInvocation("AcquireLock", []interface{}{name, owner, ttlSeconds})
+	fake.acquireLockMutex.Unlock()
+	if fake.AcquireLockStub != nil {
+		return fake.AcquireLockStub(name, owner, ttlSeconds)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.acquireLockReturns.result1, fake.acquireLockReturns.result2
+}
+
+func (fake *Locker) AcquireLockCallCount() int {
+	fake.acquireLockMutex.RLock()
+	defer fake.acquireLockMutex.RUnlock()
+	return len(fake.acquireLockArgsForCall)
+}
+
+func (fake *Locker) AcquireLockArgsForCall(i int) (string, string, int) {
+	fake.acquireLockMutex.RLock()
+	defer fake.acquireLockMutex.RUnlock()
+	return fake.acquireLockArgsForCall[i].name, fake.acquireLockArgsForCall[i].owner, fake.acquireLockArgsForCall[i].ttlSeconds
+}
+
+func (fake *Locker) AcquireLockReturns(result1 bool, result2 error) {
+	fake.AcquireLockStub = nil
+	fake.acquireLockReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Locker) AcquireLockReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.AcquireLockStub = nil
+	if fake.acquireLockReturnsOnCall == nil {
+		fake.acquireLockReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.acquireLockReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Locker) ReleaseLock(name string, owner string) error {
+	fake.releaseLockMutex.Lock()
+	ret, specificReturn := fake.releaseLockReturnsOnCall[len(fake.releaseLockArgsForCall)]
+	fake.releaseLockArgsForCall = append(fake.releaseLockArgsForCall, struct {
+		name  string
+		owner string
+	}{name, owner})
+	fake.recordInvocation("ReleaseLock", []interface{}{name, owner})
+	fake.releaseLockMutex.Unlock()
+	if fake.ReleaseLockStub != nil {
+		return fake.ReleaseLockStub(name, owner)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.releaseLockReturns.result1
+}
+
+func (fake *Locker) ReleaseLockCallCount() int {
+	fake.releaseLockMutex.RLock()
+	defer fake.releaseLockMutex.RUnlock()
+	return len(fake.releaseLockArgsForCall)
+}
+
+func (fake *Locker) ReleaseLockArgsForCall(i int) (string, string) {
+	fake.releaseLockMutex.RLock()
+	defer fake.releaseLockMutex.RUnlock()
+	return fake.releaseLockArgsForCall[i].name, fake.releaseLockArgsForCall[i].owner
+}
+
+func (fake *Locker) ReleaseLockReturns(result1 error) {
+	fake.ReleaseLockStub = nil
+	fake.releaseLockReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Locker) ReleaseLockReturnsOnCall(i int, result1 error) {
+	fake.ReleaseLockStub = nil
+	if fake.releaseLockReturnsOnCall == nil {
+		fake.releaseLockReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseLockReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Locker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.acquireLockMutex.RLock()
+	defer fake.acquireLockMutex.RUnlock()
+	fake.releaseLockMutex.RLock()
+	defer fake.releaseLockMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Locker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
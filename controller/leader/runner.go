@@ -0,0 +1,95 @@
+package leader
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter -o fakes/locker.go --fake-name Locker . locker
+type locker interface {
+	AcquireLock(name, owner string, ttlSeconds int) (bool, error)
+	ReleaseLock(name, owner string) error
+}
+
+// Job is periodic work that must only run on one controller at a time.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+// Runner competes for the leader lock and runs the jobs while it holds it.
+// Jobs run one after the other between lock renewals, so each of them must
+// finish well within the TTL.
+type Runner struct {
+	Locker        locker
+	LockName      string
+	Owner         string
+	TTL           time.Duration
+	RenewInterval time.Duration
+	Jobs          []Job
+	Logger        lager.Logger
+
+	isLeader bool
+	lastRuns map[string]time.Time
+}
+
+func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	r.lastRuns = map[string]time.Time{}
+	close(ready)
+
+	for {
+		r.cycle()
+
+		select {
+		case <-signals:
+			r.resign()
+			return nil
+		case <-time.After(r.RenewInterval):
+		}
+	}
+}
+
+func (r *Runner) cycle() {
+	acquired, err := r.Locker.AcquireLock(r.LockName, r.Owner, int(r.TTL/time.Second))
+	if err != nil {
+		r.Logger.Error("acquire-lock", err)
+		acquired = false
+	}
+
+	if acquired != r.isLeader {
+		if acquired {
+			r.Logger.Info("became-leader", lager.Data{"owner": r.Owner})
+		} else {
+			r.Logger.Info("lost-leadership", lager.Data{"owner": r.Owner})
+		}
+		r.isLeader = acquired
+	}
+	if !r.isLeader {
+		return
+	}
+
+	now := time.Now()
+	for _, job := range r.Jobs {
+		if now.Sub(r.lastRuns[job.Name]) < job.Interval {
+			continue
+		}
+		r.lastRuns[job.Name] = now
+		if err := job.Run(); err != nil {
+			r.Logger.Error("run-job", err, lager.Data{"job": job.Name})
+		}
+	}
+}
+
+// resign releases the lock so that another controller can take over without
+// waiting for it to expire.
+func (r *Runner) resign() {
+	if !r.isLeader {
+		return
+	}
+	if err := r.Locker.ReleaseLock(r.LockName, r.Owner); err != nil {
+		r.Logger.Error("release-lock", err)
+	}
+}
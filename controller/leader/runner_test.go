@@ -0,0 +1,133 @@
+package leader_test
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller/leader"
+	"code.cloudfoundry.org/silk/controller/leader/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Runner", func() {
+	var (
+		locker   *fakes.Locker
+		logger   *lagertest.TestLogger
+		runner   *leader.Runner
+		process  ifrit.Process
+		jobRuns  int32
+		jobError error
+	)
+
+	BeforeEach(func() {
+		locker = &fakes.Locker{}
+		locker.AcquireLockReturns(true, nil)
+		logger = lagertest.NewTestLogger("test")
+		jobRuns = 0
+		jobError = nil
+		runner = &leader.Runner{
+			Locker:        locker,
+			LockName:      "leader",
+			Owner:         "controller-1",
+			TTL:           3 * time.Second,
+			RenewInterval: 10 * time.Millisecond,
+			Jobs: []leader.Job{{
+				Name:     "some-job",
+				Interval: 50 * time.Millisecond,
+				Run: func() error {
+					atomic.AddInt32(&jobRuns, 1)
+					return jobError
+				},
+			}},
+			Logger: logger,
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(runner)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("acquires the lock with the ttl and runs the jobs at their interval", func() {
+		Eventually(locker.AcquireLockCallCount).Should(BeNumerically(">", 1))
+		name, owner, ttlSeconds := locker.AcquireLockArgsForCall(0)
+		Expect(name).To(Equal("leader"))
+		Expect(owner).To(Equal("controller-1"))
+		Expect(ttlSeconds).To(Equal(3))
+
+		Eventually(logger).Should(gbytes.Say("test.became-leader"))
+		Eventually(func() int32 { return atomic.LoadInt32(&jobRuns) }).Should(BeNumerically(">=", 2))
+		Expect(int(atomic.LoadInt32(&jobRuns))).To(BeNumerically("<", locker.AcquireLockCallCount()))
+	})
+
+	It("releases the lock when signaled", func() {
+		Eventually(logger).Should(gbytes.Say("test.became-leader"))
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+
+		Expect(locker.ReleaseLockCallCount()).To(Equal(1))
+		name, owner := locker.ReleaseLockArgsForCall(0)
+		Expect(name).To(Equal("leader"))
+		Expect(owner).To(Equal("controller-1"))
+	})
+
+	Context("when another controller holds the lock", func() {
+		BeforeEach(func() {
+			locker.AcquireLockReturns(false, nil)
+		})
+
+		It("does not run the jobs or release the lock", func() {
+			Eventually(locker.AcquireLockCallCount).Should(BeNumerically(">", 2))
+			Consistently(func() int32 { return atomic.LoadInt32(&jobRuns) }, 100*time.Millisecond).Should(BeZero())
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(locker.ReleaseLockCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the lock is lost", func() {
+		It("stops running the jobs", func() {
+			Eventually(logger).Should(gbytes.Say("test.became-leader"))
+
+			locker.AcquireLockReturns(false, nil)
+			Eventually(logger).Should(gbytes.Say("test.lost-leadership"))
+
+			runs := atomic.LoadInt32(&jobRuns)
+			Consistently(func() int32 { return atomic.LoadInt32(&jobRuns) }, 100*time.Millisecond).Should(Equal(runs))
+		})
+	})
+
+	Context("when acquiring the lock fails", func() {
+		BeforeEach(func() {
+			locker.AcquireLockReturns(false, errors.New("banana"))
+		})
+
+		It("logs the error and does not run the jobs", func() {
+			Eventually(logger).Should(gbytes.Say("test.acquire-lock.*banana"))
+			Consistently(func() int32 { return atomic.LoadInt32(&jobRuns) }, 100*time.Millisecond).Should(BeZero())
+		})
+	})
+
+	Context("when a job fails", func() {
+		BeforeEach(func() {
+			jobError = errors.New("kiwi")
+		})
+
+		It("logs the error and keeps running it", func() {
+			Eventually(logger).Should(gbytes.Say("test.run-job.*kiwi.*some-job"))
+			Eventually(func() int32 { return atomic.LoadInt32(&jobRuns) }).Should(BeNumerically(">=", 2))
+		})
+	})
+})
@@ -0,0 +1,26 @@
+package leaser
+
+import "fmt"
+
+// AllocationStrategy decides which free subnet is handed out next.
+type AllocationStrategy string
+
+const (
+	RandomAllocation     AllocationStrategy = "random"
+	SequentialAllocation AllocationStrategy = "sequential"
+	// LeastRecentlyUsedAllocation hands out subnets that were never leased
+	// first and otherwise the subnet that was released the longest time ago,
+	// so peers have had time to forget the previous holder.
+	LeastRecentlyUsedAllocation AllocationStrategy = "least-recently-used"
+)
+
+func NewAllocationStrategy(name string) (AllocationStrategy, error) {
+	switch strategy := AllocationStrategy(name); strategy {
+	case "":
+		return RandomAllocation, nil
+	case RandomAllocation, SequentialAllocation, LeastRecentlyUsedAllocation:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown allocation strategy %q", name)
+	}
+}
@@ -0,0 +1,29 @@
+package leaser_test
+
+import (
+	"code.cloudfoundry.org/silk/controller/leaser"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewAllocationStrategy", func() {
+	DescribeTable("returns the named strategy",
+		func(name string, expected leaser.AllocationStrategy) {
+			strategy, err := leaser.NewAllocationStrategy(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strategy).To(Equal(expected))
+		},
+		Entry("defaults to random", "", leaser.RandomAllocation),
+		Entry("random", "random", leaser.RandomAllocation),
+		Entry("sequential", "sequential", leaser.SequentialAllocation),
+		Entry("least-recently-used", "least-recently-used", leaser.LeastRecentlyUsedAllocation),
+	)
+
+	Context("when the strategy is unknown", func() {
+		It("returns an error", func() {
+			_, err := leaser.NewAllocationStrategy("banana")
+			Expect(err).To(MatchError(`unknown allocation strategy "banana"`))
+		})
+	})
+})
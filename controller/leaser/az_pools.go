@@ -0,0 +1,58 @@
+package leaser
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/controller/config"
+)
+
+// AZPools splits the blocks of an overlay pool into the ranges of each
+// availability zone and a shared pool holding the rest, so that the cells of
+// a zone get subnets that can be summarized.
+type AZPools struct {
+	Shared   cidrPool
+	Zones    map[string]cidrPool
+	Fallback bool
+}
+
+// NewAZPools ignores the ranges of the other ip version. With fallback set a
+// cell allocates from the shared pool once the ranges of its zone are
+// exhausted.
+func NewAZPools(pool *CIDRPool, ranges []config.AZSubnetRange, fallback bool) *AZPools {
+	rangesByAZ := map[string][]string{}
+	var all []string
+	for _, r := range ranges {
+		ip, _, err := net.ParseCIDR(r.Network)
+		if err != nil {
+			panic(err)
+		}
+		if (ip.To4() == nil) != pool.isIPv6() {
+			continue
+		}
+		rangesByAZ[r.AZ] = append(rangesByAZ[r.AZ], r.Network)
+		all = append(all, r.Network)
+	}
+
+	zones := map[string]cidrPool{}
+	for az, azRanges := range rangesByAZ {
+		zones[az] = pool.SubPool(azRanges...)
+	}
+	return &AZPools{
+		Shared:   pool.Without(all...),
+		Zones:    zones,
+		Fallback: fallback,
+	}
+}
+
+// PoolsFor returns the pools to allocate a block from, in order, for a cell
+// in the zone. Cells in a zone without ranges use the shared pool.
+func (p *AZPools) PoolsFor(az string) []cidrPool {
+	zone, ok := p.Zones[az]
+	if !ok {
+		return []cidrPool{p.Shared}
+	}
+	if p.Fallback {
+		return []cidrPool{zone, p.Shared}
+	}
+	return []cidrPool{zone}
+}
@@ -0,0 +1,73 @@
+package leaser_test
+
+import (
+	"code.cloudfoundry.org/silk/controller/config"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AZPools", func() {
+	Describe("NewAZPools", func() {
+		var azPools *leaser.AZPools
+
+		BeforeEach(func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/22", 24)
+			cidrPool.SetAllocationStrategy(leaser.SequentialAllocation)
+			azPools = leaser.NewAZPools(cidrPool, []config.AZSubnetRange{
+				{AZ: "z1", Network: "10.255.1.0/24"},
+				{AZ: "z1", Network: "10.255.3.0/24"},
+				{AZ: "z2", Network: "fd00:10:255::/56"},
+			}, true)
+		})
+
+		It("hands out the ranges of a zone to the zone only", func() {
+			z1 := azPools.Zones["z1"]
+			Expect(z1.GetAvailableBlock(nil)).To(Equal("10.255.1.0/24"))
+			Expect(z1.GetAvailableBlock([]string{"10.255.1.0/24"})).To(Equal("10.255.3.0/24"))
+
+			Expect(azPools.Shared.GetAvailableBlock(nil)).To(Equal("10.255.2.0/24"))
+			Expect(azPools.Shared.GetAvailableBlock([]string{"10.255.2.0/24"})).To(Equal(""))
+		})
+
+		It("ignores the ranges of the other ip version", func() {
+			Expect(azPools.Zones).NotTo(HaveKey("z2"))
+		})
+
+		It("sets the fallback", func() {
+			Expect(azPools.Fallback).To(BeTrue())
+		})
+	})
+
+	Describe("PoolsFor", func() {
+		var azPools *leaser.AZPools
+
+		BeforeEach(func() {
+			azPools = leaser.NewAZPools(leaser.NewCIDRPool("10.255.0.0/22", 24), []config.AZSubnetRange{
+				{AZ: "z1", Network: "10.255.1.0/24"},
+			}, false)
+		})
+
+		It("returns the pool of the zone", func() {
+			pools := azPools.PoolsFor("z1")
+			Expect(pools).To(HaveLen(1))
+			Expect(pools[0]).To(BeIdenticalTo(azPools.Zones["z1"]))
+		})
+
+		It("returns the shared pool for a zone without ranges", func() {
+			pools := azPools.PoolsFor("z9")
+			Expect(pools).To(HaveLen(1))
+			Expect(pools[0]).To(BeIdenticalTo(azPools.Shared))
+		})
+
+		Context("when falling back to the shared pool", func() {
+			It("returns the shared pool after the pool of the zone", func() {
+				azPools.Fallback = true
+				pools := azPools.PoolsFor("z1")
+				Expect(pools).To(HaveLen(2))
+				Expect(pools[0]).To(BeIdenticalTo(azPools.Zones["z1"]))
+				Expect(pools[1]).To(BeIdenticalTo(azPools.Shared))
+			})
+		})
+	})
+})
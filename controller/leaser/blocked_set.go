@@ -0,0 +1,104 @@
+package leaser
+
+import mathRand "math/rand"
+
+// blockedSet holds disjoint intervals of blocked subnet indexes, the
+// excluded ranges and the taken subnets of a range, in a treap ordered by
+// the start of the intervals. Every node knows how many indexes its subtree
+// blocks, so adding or removing an interval and finding the n-th free index
+// take O(log n).
+type blockedSet struct {
+	root *blockedNode
+}
+
+type blockedNode struct {
+	interval    indexInterval
+	priority    int64
+	count       int64
+	left, right *blockedNode
+}
+
+func (s *blockedSet) count() int64 {
+	return s.root.subtreeCount()
+}
+
+// add expects an interval that overlaps none of the intervals of the set.
+func (s *blockedSet) add(interval indexInterval) {
+	node := &blockedNode{interval: interval, priority: mathRand.Int63()}
+	node.update()
+	less, greater := split(s.root, interval.lo)
+	s.root = merge(merge(less, node), greater)
+}
+
+// remove removes the interval starting at lo.
+func (s *blockedSet) remove(lo int64) {
+	less, greater := split(s.root, lo)
+	_, greater = split(greater, lo+1)
+	s.root = merge(less, greater)
+}
+
+// nthFree returns the n-th (zero based) index from first on that is in
+// none of the intervals. The number of free indexes below the start of an
+// interval is its distance to first minus the indexes blocked before it,
+// which the counts of the subtrees on the way down add up to.
+func (s *blockedSet) nthFree(first, n int64) int64 {
+	var blockedBefore int64
+	node := s.root
+	for node != nil {
+		leftCount := node.left.subtreeCount()
+		if node.interval.lo-first-blockedBefore-leftCount > n {
+			node = node.left
+			continue
+		}
+		blockedBefore += leftCount + node.interval.hi - node.interval.lo
+		node = node.right
+	}
+	return first + n + blockedBefore
+}
+
+func (n *blockedNode) subtreeCount() int64 {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+func (n *blockedNode) update() {
+	n.count = n.left.subtreeCount() + n.interval.hi - n.interval.lo + n.right.subtreeCount()
+}
+
+// split returns the nodes of the treap starting below lo and the ones
+// starting at or above it.
+func split(node *blockedNode, lo int64) (*blockedNode, *blockedNode) {
+	if node == nil {
+		return nil, nil
+	}
+	if node.interval.lo < lo {
+		less, greater := split(node.right, lo)
+		node.right = less
+		node.update()
+		return node, greater
+	}
+	less, greater := split(node.left, lo)
+	node.left = greater
+	node.update()
+	return less, node
+}
+
+// merge expects every node of less to start below every node of greater.
+func merge(less, greater *blockedNode) *blockedNode {
+	if less == nil {
+		return greater
+	}
+	if greater == nil {
+		return less
+	}
+	if less.priority > greater.priority {
+		less.right = merge(less.right, greater)
+		less.update()
+		return less
+	}
+	greater.left = merge(less, greater.left)
+	greater.update()
+	return greater
+}
@@ -3,104 +3,513 @@ package leaser
 import (
 	cryptoRand "crypto/rand"
 	"fmt"
-	"github.com/ziutek/utils/netaddr"
 	"math"
 	"math/big"
 	mathRand "math/rand"
 	"net"
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller/config"
 )
 
+// Single IP leases are carved out of the first block of the overlay. For IPv6
+// that block is usually a /64, so only the addresses in the lowest
+// maxIPv6SingleIPHostBits bits of it are offered.
+const maxIPv6SingleIPHostBits = 16
+
+// Ranges are indexed with int64, so a range can hold at most 2^maxIndexBits
+// subnets.
+const maxIndexBits = 62
+
+// CIDRPool allocates from its networks in order, so a network is only used
+// once the ones before it are exhausted.
 type CIDRPool struct {
-	blockPool  map[string]struct{}
-	singlePool map[string]struct{}
+	networks       []overlayNetwork
+	excludedRanges []*net.IPNet
+	sequential     bool
+
+	// mutex guards the taken subnets of the ranges
+	mutex sync.Mutex
 }
 
-func NewCIDRPool(subnetRange string, subnetMask int) *CIDRPool {
-	_, ipCIDR, err := net.ParseCIDR(subnetRange)
-	if err != nil {
-		panic(err)
+type overlayNetwork struct {
+	blockRange    subnetRange
+	singleIPRange subnetRange
+}
+
+// A subnetRange describes the subnets base + i*2^(addressBits-prefixLength)
+// for first <= i < end without materializing them. It keeps the taken
+// subnets between calls, so memory use is proportional to the number of
+// leases rather than to the size of the overlay network.
+type subnetRange struct {
+	base         *big.Int
+	ipLen        int
+	prefixLength uint
+	hostBits     uint
+	first        int64
+	end          int64
+	excluded     []indexInterval
+	taken        *takenSubnets
+}
+
+// takenSubnets holds the indexes of the taken subnets of a range, together
+// with its excluded intervals, in blocked. Each call to take only updates
+// blocked for the subnets that were taken or released since the last call.
+type takenSubnets struct {
+	blocked    blockedSet
+	indexes    map[string]takenIndex
+	refs       map[int64]int
+	generation uint64
+}
+
+// takenIndex is the index of a taken subnet, or -1 for a subnet that is not
+// a member of the range, as of the last call to take that saw the subnet.
+type takenIndex struct {
+	index      int64
+	generation uint64
+}
+
+// indexInterval is the half-open interval of subnet indexes [lo, hi).
+type indexInterval struct {
+	lo, hi int64
+}
+
+// NewCIDRPool never hands out a subnet that overlaps one of the
+// excludedRanges. Excluded ranges of the other ip version are ignored.
+func NewCIDRPool(subnetRange string, subnetMask int, excludedRanges ...string) *CIDRPool {
+	return NewCIDRPoolForNetworks([]config.OverlayNetwork{{
+		Network:            subnetRange,
+		SubnetPrefixLength: subnetMask,
+	}}, excludedRanges...)
+}
+
+// NewCIDRPoolForNetworks expects networks of a single ip version.
+func NewCIDRPoolForNetworks(networks []config.OverlayNetwork, excludedRanges ...string) *CIDRPool {
+	var ipCIDRs []*net.IPNet
+	for _, network := range networks {
+		_, ipCIDR, err := net.ParseCIDR(network.Network)
+		if err != nil {
+			panic(err)
+		}
+		ipCIDRs = append(ipCIDRs, ipCIDR)
+	}
+	_, addressBits := ipCIDRs[0].Mask.Size()
+
+	var excluded []*net.IPNet
+	for _, excludedRange := range excludedRanges {
+		_, excludedCIDR, err := net.ParseCIDR(excludedRange)
+		if err != nil {
+			panic(err)
+		}
+		if _, bits := excludedCIDR.Mask.Size(); bits == addressBits {
+			excluded = append(excluded, excludedCIDR)
+		}
 	}
-	cidrMask, _ := ipCIDR.Mask.Size()
 
 	mathRand.Seed(getRandomSeed())
 
-	return &CIDRPool{
-		blockPool:  generateBlockPool(ipCIDR.IP, uint(cidrMask), uint(subnetMask)),
-		singlePool: generateSingleIPPool(ipCIDR.IP, uint(subnetMask)),
+	pool := &CIDRPool{excludedRanges: excluded}
+	for i, ipCIDR := range ipCIDRs {
+		cidrMask, addressBits := ipCIDR.Mask.Size()
+		subnetMask := networks[i].SubnetPrefixLength
+
+		blockRange := newBlockRange(ipCIDR.IP, uint(addressBits), uint(cidrMask), uint(subnetMask))
+		blockRange.exclude(excluded)
+		singleIPRange := newSingleIPRange(ipCIDR.IP, uint(addressBits), uint(subnetMask))
+		singleIPRange.exclude(excluded)
+
+		pool.networks = append(pool.networks, overlayNetwork{
+			blockRange:    blockRange,
+			singleIPRange: singleIPRange,
+		})
+	}
+	return pool
+}
+
+// SetAllocationStrategy makes the pool hand out the lowest free subnet for
+// SequentialAllocation and a random free subnet otherwise.
+func (c *CIDRPool) SetAllocationStrategy(strategy AllocationStrategy) {
+	c.sequential = strategy == SequentialAllocation
+}
+
+// SubPool returns a pool that hands out only the blocks of c that lie within
+// the ranges. It never hands out single IPs.
+func (c *CIDRPool) SubPool(ranges ...string) *CIDRPool {
+	sub := &CIDRPool{excludedRanges: c.excludedRanges, sequential: c.sequential}
+	for _, network := range c.networks {
+		for _, interval := range network.blockRange.intervals(c.parseRanges(ranges)) {
+			blockRange := network.blockRange
+			blockRange.first, blockRange.end = interval.lo, interval.hi
+			blockRange.exclude(c.excludedRanges)
+
+			singleIPRange := network.singleIPRange
+			singleIPRange.end = singleIPRange.first
+			singleIPRange.exclude(nil)
+
+			sub.networks = append(sub.networks, overlayNetwork{
+				blockRange:    blockRange,
+				singleIPRange: singleIPRange,
+			})
+		}
+	}
+	return sub
+}
+
+// Without returns a copy of c whose blocks within the ranges are neither
+// handed out nor members of the pool, e.g. because they belong to a SubPool.
+func (c *CIDRPool) Without(ranges ...string) *CIDRPool {
+	without := &CIDRPool{excludedRanges: c.excludedRanges, sequential: c.sequential}
+	for _, network := range c.networks {
+		blockRange := network.blockRange
+		excluded := append([]indexInterval{}, blockRange.excluded...)
+		blockRange.setExcluded(mergeIntervals(append(excluded, blockRange.intervals(c.parseRanges(ranges))...)))
+
+		singleIPRange := network.singleIPRange
+		singleIPRange.setExcluded(singleIPRange.excluded)
+
+		without.networks = append(without.networks, overlayNetwork{
+			blockRange:    blockRange,
+			singleIPRange: singleIPRange,
+		})
 	}
+	return without
 }
 
-func (c *CIDRPool) GetBlockPool() map[string] struct{} {
-	return c.blockPool
+func (c *CIDRPool) isIPv6() bool {
+	return len(c.networks) > 0 && c.networks[0].blockRange.ipLen == net.IPv6len
 }
 
-func (c *CIDRPool) GetSinglePool() map[string] struct{} {
-	return c.singlePool
+// parseRanges ignores the ranges of the other ip version.
+func (c *CIDRPool) parseRanges(ranges []string) []*net.IPNet {
+	addressBits := 8 * net.IPv4len
+	if c.isIPv6() {
+		addressBits = 8 * net.IPv6len
+	}
+	var networks []*net.IPNet
+	for _, r := range ranges {
+		_, network, err := net.ParseCIDR(r)
+		if err != nil {
+			panic(err)
+		}
+		if _, bits := network.Mask.Size(); bits == addressBits {
+			networks = append(networks, network)
+		}
+	}
+	return networks
 }
 
 func (c *CIDRPool) BlockPoolSize() int {
-	return len(c.blockPool)
+	var size int64
+	for _, network := range c.networks {
+		size += network.blockRange.size()
+	}
+	return int(size)
 }
 
 func (c *CIDRPool) SingleIPPoolSize() int {
-	return len(c.singlePool)
+	var size int64
+	for _, network := range c.networks {
+		size += network.singleIPRange.size()
+	}
+	return int(size)
 }
 
 func (c *CIDRPool) GetAvailableBlock(taken []string) string {
-	return getAvailable(taken, c.blockPool)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, network := range c.networks {
+		if subnet := network.blockRange.getAvailable(taken, c.sequential); subnet != "" {
+			return subnet
+		}
+	}
+	return ""
 }
 
 func (c *CIDRPool) GetAvailableSingleIP(taken []string) string {
-	return getAvailable(taken, c.singlePool)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, network := range c.networks {
+		if subnet := network.singleIPRange.getAvailable(taken, c.sequential); subnet != "" {
+			return subnet
+		}
+	}
+	return ""
 }
 
 func (c *CIDRPool) IsMember(subnet string) bool {
-	_, blockOk := c.blockPool[subnet]
-	_, singleOk := c.singlePool[subnet]
-	return blockOk || singleOk
+	for _, network := range c.networks {
+		if network.blockRange.isMember(subnet) || network.singleIPRange.isMember(subnet) {
+			return true
+		}
+	}
+	return false
 }
 
-func getAvailable(taken []string, pool map[string]struct{}) string {
-	available := make(map[string]struct{})
-	for k, v := range pool {
-		available[k] = v
+// IsExcluded reports whether the subnet overlaps an excluded range.
+func (c *CIDRPool) IsExcluded(subnet string) bool {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return false
 	}
-	for _, subnet := range taken {
-		delete(available, subnet)
+	for _, excluded := range c.excludedRanges {
+		if excluded.Contains(ipNet.IP) || ipNet.Contains(excluded.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func newBlockRange(ipStart net.IP, addressBits, cidrMask, cidrMaskBlock uint) subnetRange {
+	r := subnetRange{
+		base:         ipToInt(ipStart),
+		ipLen:        int(addressBits / 8),
+		prefixLength: cidrMaskBlock,
+		hostBits:     addressBits - cidrMaskBlock,
 	}
-	if len(available) == 0 {
+	if cidrMaskBlock < cidrMask || cidrMaskBlock > addressBits {
+		return r
+	}
+	blockBits := cidrMaskBlock - cidrMask
+	if blockBits > maxIndexBits {
+		blockBits = maxIndexBits
+	}
+	// the first block holds the single IP leases
+	r.first = 1
+	r.end = 1 << blockBits
+	return r
+}
+
+func newSingleIPRange(ipStart net.IP, addressBits, cidrMaskBlock uint) subnetRange {
+	r := subnetRange{
+		base:         ipToInt(ipStart),
+		ipLen:        int(addressBits / 8),
+		prefixLength: addressBits,
+	}
+	if cidrMaskBlock > addressBits {
+		return r
+	}
+	hostBits := addressBits - cidrMaskBlock
+	if addressBits == 8*net.IPv6len && hostBits > maxIPv6SingleIPHostBits {
+		hostBits = maxIPv6SingleIPHostBits
+	}
+	if hostBits > maxIndexBits {
+		hostBits = maxIndexBits
+	}
+	// the network address is never handed out
+	r.first = 1
+	r.end = 1 << hostBits
+	return r
+}
+
+func (r subnetRange) size() int64 {
+	return r.end - r.first - blockedCount(r.excluded)
+}
+
+// exclude marks every subnet of the range that overlaps one of the excluded
+// networks as unavailable.
+func (r *subnetRange) exclude(excluded []*net.IPNet) {
+	r.setExcluded(r.intervals(excluded))
+}
+
+// setExcluded also forgets the taken subnets, so that a copy of a range
+// never shares them with the original.
+func (r *subnetRange) setExcluded(excluded []indexInterval) {
+	r.excluded = excluded
+	r.taken = &takenSubnets{indexes: map[string]takenIndex{}, refs: map[int64]int{}}
+	for _, interval := range excluded {
+		r.taken.blocked.add(interval)
+	}
+}
+
+// intervals returns the merged indexes of the subnets of the range that
+// overlap one of the networks.
+func (r subnetRange) intervals(networks []*net.IPNet) []indexInterval {
+	var intervals []indexInterval
+	for _, network := range networks {
+		start := ipToInt(network.IP)
+		last := new(big.Int).Or(start, lastAddressOffset(network))
+		if last.Cmp(r.base) < 0 {
+			continue
+		}
+		lo := r.indexForAddress(start)
+		hi := r.indexForAddress(last)
+		if lo < r.first {
+			lo = r.first
+		}
+		if hi >= r.end {
+			hi = r.end - 1
+		}
+		if lo > hi {
+			continue
+		}
+		intervals = append(intervals, indexInterval{lo: lo, hi: hi + 1})
+	}
+	return mergeIntervals(intervals)
+}
+
+// indexForAddress returns the index of the subnet holding the address,
+// saturating at the int64 range.
+func (r subnetRange) indexForAddress(address *big.Int) int64 {
+	offset := new(big.Int).Sub(address, r.base)
+	if offset.Sign() < 0 {
+		return -1
+	}
+	index := offset.Rsh(offset, r.hostBits)
+	if !index.IsInt64() {
+		return math.MaxInt64
+	}
+	return index.Int64()
+}
+
+func (r subnetRange) isMember(subnet string) bool {
+	index, ok := r.indexOf(subnet)
+	return ok && !isBlocked(r.excluded, index)
+}
+
+func (r subnetRange) subnet(index int64) string {
+	offset := new(big.Int).Lsh(big.NewInt(index), r.hostBits)
+	ip := intToIP(offset.Add(offset, r.base), r.ipLen)
+	return fmt.Sprintf("%s/%d", ip, r.prefixLength)
+}
+
+func (r subnetRange) indexOf(subnet string) (int64, bool) {
+	ip, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return 0, false
+	}
+	if ones, bits := ipNet.Mask.Size(); uint(ones) != r.prefixLength || bits != 8*r.ipLen {
+		return 0, false
+	}
+	if !ip.Equal(ipNet.IP) {
+		return 0, false
+	}
+	if r.ipLen == net.IPv4len {
+		ip = ip.To4()
+	}
+	offset := new(big.Int).Sub(ipToInt(ip), r.base)
+	if offset.Sign() < 0 {
+		return 0, false
+	}
+	index := new(big.Int).Rsh(offset, r.hostBits)
+	if !index.IsInt64() || index.Int64() < r.first || index.Int64() >= r.end {
+		return 0, false
+	}
+	return index.Int64(), true
+}
+
+// getAvailable returns a subnet that is neither taken nor excluded, or "" if
+// the range is exhausted. The subnet is the lowest one when sequential is set
+// and a random one otherwise.
+func (r subnetRange) getAvailable(taken []string, sequential bool) string {
+	r.take(taken)
+
+	free := r.end - r.first - r.taken.blocked.count()
+	if free <= 0 {
 		return ""
 	}
-	i := mathRand.Intn(len(available))
-	n := 0
-	for subnet := range available {
-		if i == n {
-			return subnet
+	if sequential {
+		return r.subnet(r.taken.blocked.nthFree(r.first, 0))
+	}
+	return r.subnet(r.taken.blocked.nthFree(r.first, mathRand.Int63n(free)))
+}
+
+// take makes the taken subnets of the range the ones of taken. Subnets seen
+// by the last call are neither parsed nor added again, and the ones missing
+// since then are removed, so only acquired and released subnets change the
+// blocked set. Taken subnets in an excluded interval are blocked already,
+// and refs counts the spellings of the same subnet.
+func (r subnetRange) take(taken []string) {
+	r.taken.generation++
+	generation := r.taken.generation
+
+	for _, subnet := range taken {
+		if known, ok := r.taken.indexes[subnet]; ok {
+			known.generation = generation
+			r.taken.indexes[subnet] = known
+			continue
+		}
+
+		index, ok := r.indexOf(subnet)
+		if !ok || isBlocked(r.excluded, index) {
+			index = -1
+		}
+		if index >= 0 {
+			r.taken.refs[index]++
+			if r.taken.refs[index] == 1 {
+				r.taken.blocked.add(indexInterval{lo: index, hi: index + 1})
+			}
 		}
-		n++
+		r.taken.indexes[subnet] = takenIndex{index: index, generation: generation}
+	}
+
+	for subnet, known := range r.taken.indexes {
+		if known.generation == generation {
+			continue
+		}
+		if known.index >= 0 {
+			r.taken.refs[known.index]--
+			if r.taken.refs[known.index] == 0 {
+				delete(r.taken.refs, known.index)
+				r.taken.blocked.remove(known.index)
+			}
+		}
+		delete(r.taken.indexes, subnet)
 	}
-	return ""
 }
 
-func generateBlockPool(ipStart net.IP, cidrMask, cidrMaskBlock uint) map[string]struct{} {
-	pool := make(map[string]struct{})
-	fullRange := 1 << (32 - cidrMask)
-	blockSize := 1 << (32 - cidrMaskBlock)
-	for i := blockSize; i < fullRange; i += blockSize {
-		subnet := fmt.Sprintf("%s/%d", netaddr.IPAdd(ipStart, i), cidrMaskBlock)
-		pool[subnet] = struct{}{}
+// mergeIntervals sorts the intervals and joins the overlapping and adjacent
+// ones.
+func mergeIntervals(intervals []indexInterval) []indexInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].lo < intervals[j].lo })
+
+	var merged []indexInterval
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && interval.lo <= merged[last].hi {
+			if interval.hi > merged[last].hi {
+				merged[last].hi = interval.hi
+			}
+			continue
+		}
+		merged = append(merged, interval)
 	}
-	return pool
+	return merged
 }
 
-func generateSingleIPPool(ipStart net.IP, cidrMaskBlock uint) map[string]struct{} {
-	pool := make(map[string]struct{})
-	blockSize := 1 << (32 - cidrMaskBlock)
-	for i := 1; i < blockSize; i++ {
-		singleCIDR := fmt.Sprintf("%s/32", netaddr.IPAdd(ipStart, i))
-		pool[singleCIDR] = struct{}{}
+func blockedCount(intervals []indexInterval) int64 {
+	var count int64
+	for _, interval := range intervals {
+		count += interval.hi - interval.lo
 	}
-	return pool
+	return count
+}
+
+func isBlocked(intervals []indexInterval, index int64) bool {
+	j := sort.Search(len(intervals), func(j int) bool { return intervals[j].hi > index })
+	return j < len(intervals) && intervals[j].lo <= index
+}
+
+func lastAddressOffset(network *net.IPNet) *big.Int {
+	ones, bits := network.Mask.Size()
+	hostBits := uint(bits - ones)
+	return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), hostBits), big.NewInt(1))
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+func intToIP(i *big.Int, ipLen int) net.IP {
+	ipBytes := i.Bytes()
+	ip := make(net.IP, ipLen)
+	copy(ip[ipLen-len(ipBytes):], ipBytes)
+	return ip
 }
 
 func getRandomSeed() int64 {
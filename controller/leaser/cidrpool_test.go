@@ -1,6 +1,9 @@
 package leaser_test
 
 import (
+	"fmt"
+
+	"code.cloudfoundry.org/silk/controller/config"
 	"code.cloudfoundry.org/silk/controller/leaser"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -18,21 +21,38 @@ var _ = Describe("CIDRPool", func() {
 			Entry("when the range is /16 and mask is /24", "10.255.0.0/16", 24, 255),
 			Entry("when the range is /16 and mask is /20", "10.255.0.0/16", 20, 15),
 			Entry("when the range is /16 and mask is /16", "10.255.0.0/16", 16, 0),
+			Entry("when the range is an ipv6 /56 and mask is /64", "fd00:10:255::/56", 64, 255),
+			Entry("when the range is an ipv6 /48 and mask is /64", "fd00:10:255::/48", 64, 65535),
+			Entry("when the range is an ipv6 /32 and mask is /64", "fd00:10::/32", 64, 1<<32-1),
 		)
 
+		It("does not count subnets overlapping an excluded range", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.16.0/20", "10.255.200.128/25", "10.255.18.0/24")
+			Expect(cidrPool.BlockPoolSize()).To(Equal(255 - 16 - 1))
+		})
+
+		It("ignores excluded ranges outside of the pool or of the other ip version", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.254.0.0/16", "fd00:10:255::/56")
+			Expect(cidrPool.BlockPoolSize()).To(Equal(255))
+		})
+
 		DescribeTable("produces valid subnets within the correct range",
 			func(overlayCIDR string, subnetMask int) {
 				_, overlayNetwork, _ := net.ParseCIDR(overlayCIDR)
 				cidrPool := leaser.NewCIDRPool(overlayCIDR, subnetMask)
 
-				for blockDividedCIDR, _ := range cidrPool.GetBlockPool() {
-					_, blockNetwork, _ := net.ParseCIDR(blockDividedCIDR)
+				var taken []string
+				for i := 0; i < 255; i++ {
+					s := cidrPool.GetAvailableBlock(taken)
+					_, blockNetwork, _ := net.ParseCIDR(s)
 					Expect(overlayNetwork.Contains(blockNetwork.IP)).Should(BeTrue())
+					taken = append(taken, s)
 				}
 			},
 			Entry("when ip is in the start of the cidr range", "10.240.0.0/12", 24),
 			Entry("when ip is in the middle of the cidr range", "10.255.0.0/12", 24),
 			Entry("when ip is in the end of the cidr range", "10.255.255.255/12", 24),
+			Entry("when the range is ipv6", "fd00:10:255::/56", 64),
 		)
 	})
 
@@ -45,6 +65,9 @@ var _ = Describe("CIDRPool", func() {
 			Entry("when the range is /16 and mask is /25", "10.255.0.0/16", 25, 127),
 			Entry("when the range is /16 and mask is /26", "10.255.0.0/16", 26, 63),
 			Entry("when the range is /16 and mask is /27", "10.255.0.0/16", 27, 31),
+			Entry("when the range is ipv6 and mask is /120", "fd00:10:255::/56", 120, 255),
+			Entry("when the range is ipv6 and mask is /64", "fd00:10:255::/56", 64, 65535),
+			Entry("when the range is /8 and mask is /8", "10.0.0.0/8", 8, 1<<24-1),
 		)
 
 		It("produces valid subnet starting with the first IP of the cidr", func(){
@@ -54,10 +77,13 @@ var _ = Describe("CIDRPool", func() {
 
 			cidrPool := leaser.NewCIDRPool(overlayCIDR, subnetMask)
 			_, expectedSingleIPNetwork, _ := net.ParseCIDR(firstSubnet)
-			for singleIPCIDR, _ := range cidrPool.GetSinglePool() {
-				_, singleIPNetwork, _ := net.ParseCIDR(singleIPCIDR)
+			var taken []string
+			for s := cidrPool.GetAvailableSingleIP(taken); s != ""; s = cidrPool.GetAvailableSingleIP(taken) {
+				_, singleIPNetwork, _ := net.ParseCIDR(s)
 				Expect(expectedSingleIPNetwork.Contains(singleIPNetwork.IP)).Should(BeTrue())
+				taken = append(taken, s)
 			}
+			Expect(taken).To(HaveLen(cidrPool.SingleIPPoolSize()))
 		})
 	})
 
@@ -87,6 +113,108 @@ var _ = Describe("CIDRPool", func() {
 			}
 		})
 
+		It("ignores taken subnets that are not in the pool", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24)
+			taken := []string{"10.254.1.0/24", "10.255.1.0/24", "10.255.1.0/24", "10.255.0.7/32", "10.255.2.1/24", "banana"}
+			results := map[string]int{}
+			for s := cidrPool.GetAvailableBlock(taken); s != ""; s = cidrPool.GetAvailableBlock(taken) {
+				results[s]++
+				taken = append(taken, s)
+			}
+			Expect(results).To(HaveLen(254))
+			Expect(results).NotTo(HaveKey("10.255.1.0/24"))
+		})
+
+		It("only returns the remaining subnet when all others are taken", func() {
+			cidrPool := leaser.NewCIDRPool("fd00:10::/32", 64)
+			var taken []string
+			for i := 1; i < 1000; i++ {
+				if i != 577 {
+					taken = append(taken, fmt.Sprintf("fd00:10:0:%x::/64", i))
+				}
+			}
+			for i := 0; i < 10; i++ {
+				s := cidrPool.GetAvailableBlock(taken)
+				Expect(cidrPool.IsMember(s)).To(BeTrue())
+				Expect(taken).NotTo(ContainElement(s))
+			}
+
+			smallPool := leaser.NewCIDRPool("10.255.0.0/22", 24)
+			Expect(smallPool.GetAvailableBlock([]string{"10.255.3.0/24", "10.255.1.0/24"})).To(Equal("10.255.2.0/24"))
+		})
+
+		Context("when the allocation strategy is sequential", func() {
+			It("returns the lowest free subnet", func() {
+				cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.2.0/24")
+				cidrPool.SetAllocationStrategy(leaser.SequentialAllocation)
+
+				Expect(cidrPool.GetAvailableBlock(nil)).To(Equal("10.255.1.0/24"))
+				Expect(cidrPool.GetAvailableBlock([]string{"10.255.1.0/24"})).To(Equal("10.255.3.0/24"))
+				Expect(cidrPool.GetAvailableBlock([]string{"10.255.1.0/24", "10.255.4.0/24"})).To(Equal("10.255.3.0/24"))
+				Expect(cidrPool.GetAvailableSingleIP([]string{"10.255.0.1/32"})).To(Equal("10.255.0.2/32"))
+			})
+		})
+
+		Context("when subnets are released", func() {
+			It("hands them out again", func() {
+				cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24)
+				cidrPool.SetAllocationStrategy(leaser.SequentialAllocation)
+
+				Expect(cidrPool.GetAvailableBlock([]string{"10.255.1.0/24", "10.255.2.0/24", "10.255.3.0/24"})).To(Equal("10.255.4.0/24"))
+				Expect(cidrPool.GetAvailableBlock([]string{"10.255.1.0/24", "10.255.3.0/24"})).To(Equal("10.255.2.0/24"))
+				Expect(cidrPool.GetAvailableBlock([]string{"10.255.2.0/24", "10.255.3.0/24"})).To(Equal("10.255.1.0/24"))
+				Expect(cidrPool.GetAvailableBlock(nil)).To(Equal("10.255.1.0/24"))
+			})
+
+			It("keeps a subnet taken while any spelling of it is taken", func() {
+				cidrPool := leaser.NewCIDRPool("fd00::/48", 64)
+				cidrPool.SetAllocationStrategy(leaser.SequentialAllocation)
+
+				Expect(cidrPool.GetAvailableBlock([]string{"fd00:0:0:1::/64", "fd00:0000:0000:0001::/64"})).To(Equal("fd00:0:0:2::/64"))
+				Expect(cidrPool.GetAvailableBlock([]string{"fd00:0000:0000:0001::/64"})).To(Equal("fd00:0:0:2::/64"))
+			})
+
+			It("only hands out free subnets of a large pool", func() {
+				cidrPool := leaser.NewCIDRPool("fd00::/32", 64)
+
+				var taken []string
+				for i := 1; i <= 1000; i++ {
+					taken = append(taken, fmt.Sprintf("fd00:0:%x::/64", i))
+				}
+				isTaken := map[string]bool{}
+				for _, subnet := range taken {
+					isTaken[subnet] = true
+				}
+				for i := 0; i < 1000; i++ {
+					subnet := cidrPool.GetAvailableBlock(taken)
+					Expect(isTaken).NotTo(HaveKey(subnet))
+					taken = append(taken[1:], subnet)
+					isTaken[subnet] = true
+				}
+			})
+		})
+
+		Context("when there are excluded ranges", func() {
+			It("never returns a subnet overlapping an excluded range", func() {
+				cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.16.0/20", "10.255.200.128/25", "10.255.255.0/24")
+				Expect(cidrPool.BlockPoolSize()).To(Equal(255 - 16 - 1 - 1))
+
+				taken := []string{"10.255.17.0/24", "10.255.3.0/24"}
+				results := map[string]int{}
+				for s := cidrPool.GetAvailableBlock(taken); s != ""; s = cidrPool.GetAvailableBlock(taken) {
+					results[s]++
+					taken = append(taken, s)
+				}
+				Expect(results).To(HaveLen(255 - 16 - 1 - 1 - 1))
+				for i := 16; i < 32; i++ {
+					Expect(results).NotTo(HaveKey(fmt.Sprintf("10.255.%d.0/24", i)))
+				}
+				Expect(results).NotTo(HaveKey("10.255.200.0/24"))
+				Expect(results).NotTo(HaveKey("10.255.255.0/24"))
+				Expect(results).NotTo(HaveKey("10.255.3.0/24"))
+			})
+		})
+
 		Context("when no subnets are available", func() {
 			It("returns an empty string", func() {
 				subnetRange := "10.255.0.0/16"
@@ -180,6 +308,44 @@ var _ = Describe("CIDRPool", func() {
 		})
 	})
 
+	Describe("GetAvailableSingleIP with excluded ranges", func() {
+		It("never returns an excluded ip", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.0.0/26", "10.255.0.200/32")
+			Expect(cidrPool.SingleIPPoolSize()).To(Equal(255 - 63 - 1))
+
+			var taken []string
+			for s := cidrPool.GetAvailableSingleIP(taken); s != ""; s = cidrPool.GetAvailableSingleIP(taken) {
+				ip, _, err := net.ParseCIDR(s)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ip.To4()[3]).To(BeNumerically(">=", 64))
+				Expect(s).NotTo(Equal("10.255.0.200/32"))
+				taken = append(taken, s)
+			}
+			Expect(taken).To(HaveLen(255 - 63 - 1))
+		})
+	})
+
+	Describe("GetAvailableSingleIP for an ipv6 range", func() {
+		It("returns /128 addresses from the first block", func() {
+			cidrPool := leaser.NewCIDRPool("fd00:10:255::/56", 125)
+
+			var taken []string
+			for i := 0; i < 7; i++ {
+				taken = append(taken, cidrPool.GetAvailableSingleIP(taken))
+			}
+			Expect(taken).To(ConsistOf(
+				"fd00:10:255::1/128",
+				"fd00:10:255::2/128",
+				"fd00:10:255::3/128",
+				"fd00:10:255::4/128",
+				"fd00:10:255::5/128",
+				"fd00:10:255::6/128",
+				"fd00:10:255::7/128",
+			))
+			Expect(cidrPool.GetAvailableSingleIP(taken)).To(Equal(""))
+		})
+	})
+
 	Describe("IsMember", func() {
 		var cidrPool *leaser.CIDRPool
 		BeforeEach(func() {
@@ -210,5 +376,143 @@ var _ = Describe("CIDRPool", func() {
 				Expect(cidrPool.IsMember("10.255.30.0/20")).To(BeFalse())
 			})
 		})
+
+		Context("when the subnet overlaps an excluded range", func() {
+			BeforeEach(func() {
+				cidrPool = leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.30.128/25", "10.255.0.4/30")
+			})
+
+			It("returns false", func() {
+				Expect(cidrPool.IsMember("10.255.30.0/24")).To(BeFalse())
+				Expect(cidrPool.IsMember("10.255.0.5/32")).To(BeFalse())
+				Expect(cidrPool.IsMember("10.255.31.0/24")).To(BeTrue())
+				Expect(cidrPool.IsMember("10.255.0.8/32")).To(BeTrue())
+			})
+		})
+
+		Context("when the pool is ipv6", func() {
+			BeforeEach(func() {
+				cidrPool = leaser.NewCIDRPool("fd00:10:255::/56", 64)
+			})
+
+			It("matches ipv6 blocks and single ips", func() {
+				Expect(cidrPool.IsMember("fd00:10:255:1e::/64")).To(BeTrue())
+				Expect(cidrPool.IsMember("fd00:10:255::5/128")).To(BeTrue())
+				Expect(cidrPool.IsMember("fd00:10:254:1e::/64")).To(BeFalse())
+				Expect(cidrPool.IsMember("fd00:10:255:100::/64")).To(BeFalse())
+				Expect(cidrPool.IsMember("fd00:10:255:1e::1/64")).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("IsExcluded", func() {
+		It("returns true when the subnet overlaps an excluded range", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.30.128/25", "fd00:10:255:1e::/64")
+			Expect(cidrPool.IsExcluded("10.255.30.0/24")).To(BeTrue())
+			Expect(cidrPool.IsExcluded("10.255.30.200/32")).To(BeTrue())
+			Expect(cidrPool.IsExcluded("10.255.0.0/16")).To(BeTrue())
+			Expect(cidrPool.IsExcluded("10.255.30.0/32")).To(BeFalse())
+			Expect(cidrPool.IsExcluded("10.255.31.0/24")).To(BeFalse())
+			Expect(cidrPool.IsExcluded("banana")).To(BeFalse())
+		})
+
+		It("ignores excluded ranges of the other ip version", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24, "fd00:10:255:1e::/64")
+			Expect(cidrPool.IsExcluded("fd00:10:255:1e::/64")).To(BeFalse())
+		})
+	})
+
+	Describe("SubPool", func() {
+		var cidrPool *leaser.CIDRPool
+
+		BeforeEach(func() {
+			cidrPool = leaser.NewCIDRPool("10.255.0.0/16", 24, "10.255.2.0/24")
+			cidrPool.SetAllocationStrategy(leaser.SequentialAllocation)
+		})
+
+		It("hands out only the blocks within the ranges", func() {
+			subPool := cidrPool.SubPool("10.255.0.0/22", "10.255.64.0/23", "fd00:10:255::/56")
+			Expect(subPool.BlockPoolSize()).To(Equal(3 - 1 + 2))
+			Expect(subPool.SingleIPPoolSize()).To(Equal(0))
+
+			taken := []string{}
+			for i := 0; i < 4; i++ {
+				taken = append(taken, subPool.GetAvailableBlock(taken))
+			}
+			Expect(taken).To(Equal([]string{"10.255.1.0/24", "10.255.3.0/24", "10.255.64.0/24", "10.255.65.0/24"}))
+			Expect(subPool.GetAvailableBlock(taken)).To(Equal(""))
+			Expect(subPool.GetAvailableSingleIP(nil)).To(Equal(""))
+		})
+
+		It("is only a member of the blocks within the ranges", func() {
+			subPool := cidrPool.SubPool("10.255.0.0/22")
+			Expect(subPool.IsMember("10.255.3.0/24")).To(BeTrue())
+			Expect(subPool.IsMember("10.255.2.0/24")).To(BeFalse())
+			Expect(subPool.IsMember("10.255.4.0/24")).To(BeFalse())
+			Expect(subPool.IsMember("10.255.0.7/32")).To(BeFalse())
+			Expect(subPool.IsExcluded("10.255.2.0/24")).To(BeTrue())
+		})
+	})
+
+	Describe("Without", func() {
+		It("neither hands out nor holds the blocks within the ranges", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/22", 24)
+			without := cidrPool.Without("10.255.1.0/24", "10.255.2.0/24")
+
+			Expect(without.GetAvailableBlock(nil)).To(Equal("10.255.3.0/24"))
+			Expect(without.GetAvailableBlock([]string{"10.255.3.0/24"})).To(Equal(""))
+			Expect(without.IsMember("10.255.1.0/24")).To(BeFalse())
+			Expect(without.IsMember("10.255.0.7/32")).To(BeTrue())
+			Expect(without.GetAvailableSingleIP(nil)).NotTo(BeEmpty())
+
+			Expect(cidrPool.IsMember("10.255.1.0/24")).To(BeTrue())
+		})
+	})
+
+	Describe("a pool with several networks", func() {
+		var cidrPool *leaser.CIDRPool
+
+		BeforeEach(func() {
+			cidrPool = leaser.NewCIDRPoolForNetworks([]config.OverlayNetwork{
+				{Network: "10.255.0.0/22", SubnetPrefixLength: 24},
+				{Network: "10.254.0.0/16", SubnetPrefixLength: 26},
+			}, "10.254.0.128/25")
+		})
+
+		It("adds up the sizes of the networks", func() {
+			Expect(cidrPool.BlockPoolSize()).To(Equal(3 + 1023 - 2))
+			Expect(cidrPool.SingleIPPoolSize()).To(Equal(255 + 63))
+		})
+
+		It("allocates from the next network once the first is exhausted", func() {
+			taken := []string{}
+			for i := 0; i < 3; i++ {
+				s := cidrPool.GetAvailableBlock(taken)
+				Expect(s).To(MatchRegexp(`^10\.255\.[1-3]\.0/24$`))
+				taken = append(taken, s)
+			}
+
+			s := cidrPool.GetAvailableBlock(taken)
+			Expect(s).To(MatchRegexp(`^10\.254\.\d+\.\d+/26$`))
+			Expect(cidrPool.IsMember(s)).To(BeTrue())
+			Expect(cidrPool.IsExcluded(s)).To(BeFalse())
+		})
+
+		It("allocates single ips from the next network once the first is exhausted", func() {
+			taken := []string{}
+			for i := 1; i < 256; i++ {
+				taken = append(taken, fmt.Sprintf("10.255.0.%d/32", i))
+			}
+			Expect(cidrPool.GetAvailableSingleIP(taken)).To(MatchRegexp(`^10\.254\.0\.\d+/32$`))
+		})
+
+		It("is a member of every network", func() {
+			Expect(cidrPool.IsMember("10.255.2.0/24")).To(BeTrue())
+			Expect(cidrPool.IsMember("10.254.2.64/26")).To(BeTrue())
+			Expect(cidrPool.IsMember("10.255.0.7/32")).To(BeTrue())
+			Expect(cidrPool.IsMember("10.254.1.0/24")).To(BeFalse())
+			Expect(cidrPool.IsMember("10.254.0.128/26")).To(BeFalse())
+			Expect(cidrPool.IsMember("10.253.2.0/24")).To(BeFalse())
+		})
 	})
 })
@@ -0,0 +1,64 @@
+package leaser
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/routable_lease_repository.go --fake-name RoutableLeaseRepository . routableLeaseRepository
+type routableLeaseRepository interface {
+	RoutableLeases() ([]controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/revision_bumper.go --fake-name RevisionBumper . revisionBumper
+type revisionBumper interface {
+	BumpRevision() error
+}
+
+// ExpiryNotifier is the background job that bumps the lease revision when
+// the routable leases change without a write, which happens when a lease
+// expires or an expired lease is renewed. The revision then changes with
+// every change of the routable leases.
+type ExpiryNotifier struct {
+	LeaseRepository routableLeaseRepository
+	RevisionBumper  revisionBumper
+
+	routable map[string]bool
+}
+
+// Notify bumps the revision on its first run as well, since the routable
+// leases may have changed while no controller was running the job.
+func (e *ExpiryNotifier) Notify() error {
+	leases, err := e.LeaseRepository.RoutableLeases()
+	if err != nil {
+		return fmt.Errorf("routable leases: %s", err)
+	}
+
+	routable := map[string]bool{}
+	for _, lease := range leases {
+		routable[lease.Key()] = true
+	}
+	if e.routable != nil && sameKeys(e.routable, routable) {
+		return nil
+	}
+
+	err = e.RevisionBumper.BumpRevision()
+	if err != nil {
+		return fmt.Errorf("bump revision: %s", err)
+	}
+	e.routable = routable
+	return nil
+}
+
+func sameKeys(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b[key] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package leaser_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	"code.cloudfoundry.org/silk/controller/leaser/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExpiryNotifier", func() {
+	var (
+		leaseRepository *fakes.RoutableLeaseRepository
+		revisionBumper  *fakes.RevisionBumper
+		notifier        *leaser.ExpiryNotifier
+		leases          []controller.Lease
+	)
+
+	BeforeEach(func() {
+		leases = []controller.Lease{
+			{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24", OverlayHardwareAddr: "ee:ee:0a:ff:10:00"},
+			{UnderlayIP: "10.244.5.10", OverlaySubnet: "10.255.17.0/24", OverlayHardwareAddr: "ee:ee:0a:ff:11:00"},
+		}
+		leaseRepository = &fakes.RoutableLeaseRepository{}
+		leaseRepository.RoutableLeasesReturns(leases, nil)
+		revisionBumper = &fakes.RevisionBumper{}
+		notifier = &leaser.ExpiryNotifier{
+			LeaseRepository: leaseRepository,
+			RevisionBumper:  revisionBumper,
+		}
+	})
+
+	It("bumps the revision on the first run", func() {
+		Expect(notifier.Notify()).To(Succeed())
+		Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(1))
+	})
+
+	It("does not bump the revision while the routable leases stay the same", func() {
+		Expect(notifier.Notify()).To(Succeed())
+		leaseRepository.RoutableLeasesReturns([]controller.Lease{leases[1], leases[0]}, nil)
+		Expect(notifier.Notify()).To(Succeed())
+
+		Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(1))
+	})
+
+	It("bumps the revision when a lease expires", func() {
+		Expect(notifier.Notify()).To(Succeed())
+		leaseRepository.RoutableLeasesReturns(leases[:1], nil)
+		Expect(notifier.Notify()).To(Succeed())
+
+		Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(2))
+	})
+
+	It("bumps the revision when an expired lease is renewed", func() {
+		leaseRepository.RoutableLeasesReturns(leases[:1], nil)
+		Expect(notifier.Notify()).To(Succeed())
+		leaseRepository.RoutableLeasesReturns(leases, nil)
+		Expect(notifier.Notify()).To(Succeed())
+
+		Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(2))
+	})
+
+	Context("when getting the routable leases fails", func() {
+		BeforeEach(func() {
+			leaseRepository.RoutableLeasesReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(notifier.Notify()).To(MatchError("routable leases: banana"))
+			Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when bumping the revision fails", func() {
+		BeforeEach(func() {
+			revisionBumper.BumpRevisionReturns(errors.New("kiwi"))
+		})
+
+		It("returns the error and bumps it again on the next run", func() {
+			Expect(notifier.Notify()).To(MatchError("bump revision: kiwi"))
+
+			revisionBumper.BumpRevisionReturns(nil)
+			Expect(notifier.Notify()).To(Succeed())
+			Expect(revisionBumper.BumpRevisionCallCount()).To(Equal(2))
+		})
+	})
+})
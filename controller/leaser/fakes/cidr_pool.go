@@ -39,6 +39,26 @@ type CIDRPool struct {
 	isMemberReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	IsExcludedStub        func(string) bool
+	isExcludedMutex       sync.RWMutex
+	isExcludedArgsForCall []struct {
+		arg1 string
+	}
+	isExcludedReturns struct {
+		result1 bool
+	}
+	isExcludedReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	BlockPoolSizeStub        func() int
+	blockPoolSizeMutex       sync.RWMutex
+	blockPoolSizeArgsForCall []struct{}
+	blockPoolSizeReturns     struct {
+		result1 int
+	}
+	blockPoolSizeReturnsOnCall map[int]struct {
+		result1 int
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -197,6 +217,94 @@ func (fake *CIDRPool) IsMemberReturnsOnCall(i int, result1 bool) {
 	}{result1}
 }
 
+func (fake *CIDRPool) IsExcluded(arg1 string) bool {
+	fake.isExcludedMutex.Lock()
+	ret, specificReturn := fake.isExcludedReturnsOnCall[len(fake.isExcludedArgsForCall)]
+	fake.isExcludedArgsForCall = append(fake.isExcludedArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("IsExcluded", []interface{}{arg1})
+	fake.isExcludedMutex.Unlock()
+	if fake.IsExcludedStub != nil {
+		return fake.IsExcludedStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.isExcludedReturns.result1
+}
+
+func (fake *CIDRPool) IsExcludedCallCount() int {
+	fake.isExcludedMutex.RLock()
+	defer fake.isExcludedMutex.RUnlock()
+	return len(fake.isExcludedArgsForCall)
+}
+
+func (fake *CIDRPool) IsExcludedArgsForCall(i int) string {
+	fake.isExcludedMutex.RLock()
+	defer fake.isExcludedMutex.RUnlock()
+	return fake.isExcludedArgsForCall[i].arg1
+}
+
+func (fake *CIDRPool) IsExcludedReturns(result1 bool) {
+	fake.IsExcludedStub = nil
+	fake.isExcludedReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *CIDRPool) IsExcludedReturnsOnCall(i int, result1 bool) {
+	fake.IsExcludedStub = nil
+	if fake.isExcludedReturnsOnCall == nil {
+		fake.isExcludedReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.isExcludedReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *CIDRPool) BlockPoolSize() int {
+	fake.blockPoolSizeMutex.Lock()
+	ret, specificReturn := fake.blockPoolSizeReturnsOnCall[len(fake.blockPoolSizeArgsForCall)]
+	fake.blockPoolSizeArgsForCall = append(fake.blockPoolSizeArgsForCall, struct{}{})
+	fake.recordInvocation("BlockPoolSize", []interface{}{})
+	fake.blockPoolSizeMutex.Unlock()
+	if fake.BlockPoolSizeStub != nil {
+		return fake.BlockPoolSizeStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.blockPoolSizeReturns.result1
+}
+
+func (fake *CIDRPool) BlockPoolSizeCallCount() int {
+	fake.blockPoolSizeMutex.RLock()
+	defer fake.blockPoolSizeMutex.RUnlock()
+	return len(fake.blockPoolSizeArgsForCall)
+}
+
+func (fake *CIDRPool) BlockPoolSizeReturns(result1 int) {
+	fake.BlockPoolSizeStub = nil
+	fake.blockPoolSizeReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *CIDRPool) BlockPoolSizeReturnsOnCall(i int, result1 int) {
+	fake.BlockPoolSizeStub = nil
+	if fake.blockPoolSizeReturnsOnCall == nil {
+		fake.blockPoolSizeReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.blockPoolSizeReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
 func (fake *CIDRPool) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -206,6 +314,10 @@ func (fake *CIDRPool) Invocations() map[string][][]interface{} {
 	defer fake.getAvailableSingleIPMutex.RUnlock()
 	fake.isMemberMutex.RLock()
 	defer fake.isMemberMutex.RUnlock()
+	fake.isExcludedMutex.RLock()
+	defer fake.isExcludedMutex.RUnlock()
+	fake.blockPoolSizeMutex.RLock()
+	defer fake.blockPoolSizeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
 )
 
 type DatabaseHandler struct {
@@ -30,10 +31,59 @@ type DatabaseHandler struct {
 	deleteEntryReturnsOnCall map[int]struct {
 		result1 error
 	}
-	LeaseForUnderlayIPStub        func(string) (*controller.Lease, error)
+	DeleteEntryForOverlaySubnetStub        func(string) error
+	deleteEntryForOverlaySubnetMutex       sync.RWMutex
+	deleteEntryForOverlaySubnetArgsForCall []struct {
+		arg1 string
+	}
+	deleteEntryForOverlaySubnetReturns struct {
+		result1 error
+	}
+	deleteEntryForOverlaySubnetReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteExpiredEntryStub        func(string, int) error
+	deleteExpiredEntryMutex       sync.RWMutex
+	deleteExpiredEntryArgsForCall []struct {
+		arg1 string
+		arg2 int
+	}
+	deleteExpiredEntryReturns struct {
+		result1 error
+	}
+	deleteExpiredEntryReturnsOnCall map[int]struct {
+		result1 error
+	}
+	QuarantineExpiredEntryStub        func(string, int) error
+	quarantineExpiredEntryMutex       sync.RWMutex
+	quarantineExpiredEntryArgsForCall []struct {
+		arg1 string
+		arg2 int
+	}
+	quarantineExpiredEntryReturns struct {
+		result1 error
+	}
+	quarantineExpiredEntryReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteReclaimableEntryStub        func(string, int, int) error
+	deleteReclaimableEntryMutex       sync.RWMutex
+	deleteReclaimableEntryArgsForCall []struct {
+		arg1 string
+		arg2 int
+		arg3 int
+	}
+	deleteReclaimableEntryReturns struct {
+		result1 error
+	}
+	deleteReclaimableEntryReturnsOnCall map[int]struct {
+		result1 error
+	}
+	LeaseForUnderlayIPStub        func(string, bool) (*controller.Lease, error)
 	leaseForUnderlayIPMutex       sync.RWMutex
 	leaseForUnderlayIPArgsForCall []struct {
 		arg1 string
+		arg2 bool
 	}
 	leaseForUnderlayIPReturns struct {
 		result1 *controller.Lease
@@ -43,10 +93,11 @@ type DatabaseHandler struct {
 		result1 *controller.Lease
 		result2 error
 	}
-	LastRenewedAtForUnderlayIPStub        func(string) (int64, error)
+	LastRenewedAtForUnderlayIPStub        func(string, bool) (int64, error)
 	lastRenewedAtForUnderlayIPMutex       sync.RWMutex
 	lastRenewedAtForUnderlayIPArgsForCall []struct {
 		arg1 string
+		arg2 bool
 	}
 	lastRenewedAtForUnderlayIPReturns struct {
 		result1 int64
@@ -56,10 +107,11 @@ type DatabaseHandler struct {
 		result1 int64
 		result2 error
 	}
-	RenewLeaseForUnderlayIPStub        func(string) error
+	RenewLeaseForUnderlayIPStub        func(string, bool) error
 	renewLeaseForUnderlayIPMutex       sync.RWMutex
 	renewLeaseForUnderlayIPArgsForCall []struct {
 		arg1 string
+		arg2 bool
 	}
 	renewLeaseForUnderlayIPReturns struct {
 		result1 error
@@ -67,6 +119,17 @@ type DatabaseHandler struct {
 	renewLeaseForUnderlayIPReturnsOnCall map[int]struct {
 		result1 error
 	}
+	UpdateLeaseMetadataStub        func(controller.Lease) error
+	updateLeaseMetadataMutex       sync.RWMutex
+	updateLeaseMetadataArgsForCall []struct {
+		arg1 controller.Lease
+	}
+	updateLeaseMetadataReturns struct {
+		result1 error
+	}
+	updateLeaseMetadataReturnsOnCall map[int]struct {
+		result1 error
+	}
 	AllStub        func() ([]controller.Lease, error)
 	allMutex       sync.RWMutex
 	allArgsForCall []struct{}
@@ -78,67 +141,167 @@ type DatabaseHandler struct {
 		result1 []controller.Lease
 		result2 error
 	}
-	AllBlockSubnetsStub        func() ([]controller.Lease, error)
-	allBlockSubnetsMutex       sync.RWMutex
-	allBlockSubnetsArgsForCall []struct{}
-	allBlockSubnetsReturns     struct {
+	AllActiveStub        func(int) ([]controller.Lease, error)
+	allActiveMutex       sync.RWMutex
+	allActiveArgsForCall []struct {
+		arg1 int
+	}
+	allActiveReturns struct {
 		result1 []controller.Lease
 		result2 error
 	}
-	allBlockSubnetsReturnsOnCall map[int]struct {
+	allActiveReturnsOnCall map[int]struct {
 		result1 []controller.Lease
 		result2 error
 	}
-	AllSingleIPSubnetsStub        func() ([]controller.Lease, error)
-	allSingleIPSubnetsMutex       sync.RWMutex
-	allSingleIPSubnetsArgsForCall []struct{}
-	allSingleIPSubnetsReturns     struct {
+	QueryLeasesStub        func(controller.LeaseQuery, int) ([]controller.Lease, string, error)
+	queryLeasesMutex       sync.RWMutex
+	queryLeasesArgsForCall []struct {
+		arg1 controller.LeaseQuery
+		arg2 int
+	}
+	queryLeasesReturns struct {
 		result1 []controller.Lease
-		result2 error
+		result2 string
+		result3 error
 	}
-	allSingleIPSubnetsReturnsOnCall map[int]struct {
+	queryLeasesReturnsOnCall map[int]struct {
 		result1 []controller.Lease
+		result2 string
+		result3 error
+	}
+	BeginLeaseTransactionStub        func(bool, bool) (database.LeaseTransaction, error)
+	beginLeaseTransactionMutex       sync.RWMutex
+	beginLeaseTransactionArgsForCall []struct {
+		arg1 bool
+		arg2 bool
+	}
+	beginLeaseTransactionReturns struct {
+		result1 database.LeaseTransaction
 		result2 error
 	}
-	AllActiveStub        func(int) ([]controller.Lease, error)
-	allActiveMutex       sync.RWMutex
-	allActiveArgsForCall []struct {
-		arg1 int
+	beginLeaseTransactionReturnsOnCall map[int]struct {
+		result1 database.LeaseTransaction
+		result2 error
 	}
-	allActiveReturns struct {
-		result1 []controller.Lease
+	AddLeaseEventStub        func(string, controller.Lease) error
+	addLeaseEventMutex       sync.RWMutex
+	addLeaseEventArgsForCall []struct {
+		arg1 string
+		arg2 controller.Lease
+	}
+	addLeaseEventReturns struct {
+		result1 error
+	}
+	addLeaseEventReturnsOnCall map[int]struct {
+		result1 error
+	}
+	LeaseEventsForOverlaySubnetStub        func(string) ([]controller.LeaseEvent, error)
+	leaseEventsForOverlaySubnetMutex       sync.RWMutex
+	leaseEventsForOverlaySubnetArgsForCall []struct {
+		arg1 string
+	}
+	leaseEventsForOverlaySubnetReturns struct {
+		result1 []controller.LeaseEvent
 		result2 error
 	}
-	allActiveReturnsOnCall map[int]struct {
-		result1 []controller.Lease
+	leaseEventsForOverlaySubnetReturnsOnCall map[int]struct {
+		result1 []controller.LeaseEvent
 		result2 error
 	}
-	OldestExpiredBlockSubnetStub        func(int) (*controller.Lease, error)
-	oldestExpiredBlockSubnetMutex       sync.RWMutex
-	oldestExpiredBlockSubnetArgsForCall []struct {
-		arg1 int
+	LeaseForOverlaySubnetStub        func(string) (*controller.Lease, error)
+	leaseForOverlaySubnetMutex       sync.RWMutex
+	leaseForOverlaySubnetArgsForCall []struct {
+		arg1 string
 	}
-	oldestExpiredBlockSubnetReturns struct {
+	leaseForOverlaySubnetReturns struct {
 		result1 *controller.Lease
 		result2 error
 	}
-	oldestExpiredBlockSubnetReturnsOnCall map[int]struct {
+	leaseForOverlaySubnetReturnsOnCall map[int]struct {
 		result1 *controller.Lease
 		result2 error
 	}
-	OldestExpiredSingleIPStub        func(int) (*controller.Lease, error)
-	oldestExpiredSingleIPMutex       sync.RWMutex
-	oldestExpiredSingleIPArgsForCall []struct {
+	AllLeaseRecordsStub        func(int) ([]controller.LeaseRecord, error)
+	allLeaseRecordsMutex       sync.RWMutex
+	allLeaseRecordsArgsForCall []struct {
 		arg1 int
 	}
-	oldestExpiredSingleIPReturns struct {
-		result1 *controller.Lease
+	allLeaseRecordsReturns struct {
+		result1 []controller.LeaseRecord
 		result2 error
 	}
-	oldestExpiredSingleIPReturnsOnCall map[int]struct {
-		result1 *controller.Lease
+	allLeaseRecordsReturnsOnCall map[int]struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	LeaseRecordContainingIPStub        func(string, int) (*controller.LeaseRecord, error)
+	leaseRecordContainingIPMutex       sync.RWMutex
+	leaseRecordContainingIPArgsForCall []struct {
+		arg1 string
+		arg2 int
+	}
+	leaseRecordContainingIPReturns struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	leaseRecordContainingIPReturnsOnCall map[int]struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}
+	AddReservationStub        func(string) error
+	addReservationMutex       sync.RWMutex
+	addReservationArgsForCall []struct {
+		arg1 string
+	}
+	addReservationReturns struct {
+		result1 error
+	}
+	addReservationReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteReservationStub        func(string) error
+	deleteReservationMutex       sync.RWMutex
+	deleteReservationArgsForCall []struct {
+		arg1 string
+	}
+	deleteReservationReturns struct {
+		result1 error
+	}
+	deleteReservationReturnsOnCall map[int]struct {
+		result1 error
+	}
+	AllReservationsStub        func() ([]string, error)
+	allReservationsMutex       sync.RWMutex
+	allReservationsArgsForCall []struct{}
+	allReservationsReturns     struct {
+		result1 []string
+		result2 error
+	}
+	allReservationsReturnsOnCall map[int]struct {
+		result1 []string
 		result2 error
 	}
+	ReleasedSubnetsStub        func() ([]string, error)
+	releasedSubnetsMutex       sync.RWMutex
+	releasedSubnetsArgsForCall []struct{}
+	releasedSubnetsReturns     struct {
+		result1 []string
+		result2 error
+	}
+	releasedSubnetsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	BumpRevisionStub        func() error
+	bumpRevisionMutex       sync.RWMutex
+	bumpRevisionArgsForCall []struct{}
+	bumpRevisionReturns     struct {
+		result1 error
+	}
+	bumpRevisionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -239,16 +402,213 @@ func (fake *DatabaseHandler) DeleteEntryReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *DatabaseHandler) LeaseForUnderlayIP(arg1 string) (*controller.Lease, error) {
+func (fake *DatabaseHandler) DeleteEntryForOverlaySubnet(arg1 string) error {
+	fake.deleteEntryForOverlaySubnetMutex.Lock()
+	ret, specificReturn := fake.deleteEntryForOverlaySubnetReturnsOnCall[len(fake.deleteEntryForOverlaySubnetArgsForCall)]
+	fake.deleteEntryForOverlaySubnetArgsForCall = append(fake.deleteEntryForOverlaySubnetArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("DeleteEntryForOverlaySubnet", []interface{}{arg1})
+	fake.deleteEntryForOverlaySubnetMutex.Unlock()
+	if fake.DeleteEntryForOverlaySubnetStub != nil {
+		return fake.DeleteEntryForOverlaySubnetStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteEntryForOverlaySubnetReturns.result1
+}
+
+func (fake *DatabaseHandler) DeleteEntryForOverlaySubnetCallCount() int {
+	fake.deleteEntryForOverlaySubnetMutex.RLock()
+	defer fake.deleteEntryForOverlaySubnetMutex.RUnlock()
+	return len(fake.deleteEntryForOverlaySubnetArgsForCall)
+}
+
+func (fake *DatabaseHandler) DeleteEntryForOverlaySubnetArgsForCall(i int) string {
+	fake.deleteEntryForOverlaySubnetMutex.RLock()
+	defer fake.deleteEntryForOverlaySubnetMutex.RUnlock()
+	return fake.deleteEntryForOverlaySubnetArgsForCall[i].arg1
+}
+
+func (fake *DatabaseHandler) DeleteEntryForOverlaySubnetReturns(result1 error) {
+	fake.DeleteEntryForOverlaySubnetStub = nil
+	fake.deleteEntryForOverlaySubnetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteEntryForOverlaySubnetReturnsOnCall(i int, result1 error) {
+	fake.DeleteEntryForOverlaySubnetStub = nil
+	if fake.deleteEntryForOverlaySubnetReturnsOnCall == nil {
+		fake.deleteEntryForOverlaySubnetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteEntryForOverlaySubnetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteExpiredEntry(arg1 string, arg2 int) error {
+	fake.deleteExpiredEntryMutex.Lock()
+	ret, specificReturn := fake.deleteExpiredEntryReturnsOnCall[len(fake.deleteExpiredEntryArgsForCall)]
+	fake.deleteExpiredEntryArgsForCall = append(fake.deleteExpiredEntryArgsForCall, struct {
+		arg1 string
+		arg2 int
+	}{arg1, arg2})
+	fake.recordInvocation("DeleteExpiredEntry", []interface{}{arg1, arg2})
+	fake.deleteExpiredEntryMutex.Unlock()
+	if fake.DeleteExpiredEntryStub != nil {
+		return fake.DeleteExpiredEntryStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteExpiredEntryReturns.result1
+}
+
+func (fake *DatabaseHandler) DeleteExpiredEntryCallCount() int {
+	fake.deleteExpiredEntryMutex.RLock()
+	defer fake.deleteExpiredEntryMutex.RUnlock()
+	return len(fake.deleteExpiredEntryArgsForCall)
+}
+
+func (fake *DatabaseHandler) DeleteExpiredEntryArgsForCall(i int) (string, int) {
+	fake.deleteExpiredEntryMutex.RLock()
+	defer fake.deleteExpiredEntryMutex.RUnlock()
+	return fake.deleteExpiredEntryArgsForCall[i].arg1, fake.deleteExpiredEntryArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) DeleteExpiredEntryReturns(result1 error) {
+	fake.DeleteExpiredEntryStub = nil
+	fake.deleteExpiredEntryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteExpiredEntryReturnsOnCall(i int, result1 error) {
+	fake.DeleteExpiredEntryStub = nil
+	if fake.deleteExpiredEntryReturnsOnCall == nil {
+		fake.deleteExpiredEntryReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteExpiredEntryReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) QuarantineExpiredEntry(arg1 string, arg2 int) error {
+	fake.quarantineExpiredEntryMutex.Lock()
+	ret, specificReturn := fake.quarantineExpiredEntryReturnsOnCall[len(fake.quarantineExpiredEntryArgsForCall)]
+	fake.quarantineExpiredEntryArgsForCall = append(fake.quarantineExpiredEntryArgsForCall, struct {
+		arg1 string
+		arg2 int
+	}{arg1, arg2})
+	fake.recordInvocation("QuarantineExpiredEntry", []interface{}{arg1, arg2})
+	fake.quarantineExpiredEntryMutex.Unlock()
+	if fake.QuarantineExpiredEntryStub != nil {
+		return fake.QuarantineExpiredEntryStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.quarantineExpiredEntryReturns.result1
+}
+
+func (fake *DatabaseHandler) QuarantineExpiredEntryCallCount() int {
+	fake.quarantineExpiredEntryMutex.RLock()
+	defer fake.quarantineExpiredEntryMutex.RUnlock()
+	return len(fake.quarantineExpiredEntryArgsForCall)
+}
+
+func (fake *DatabaseHandler) QuarantineExpiredEntryArgsForCall(i int) (string, int) {
+	fake.quarantineExpiredEntryMutex.RLock()
+	defer fake.quarantineExpiredEntryMutex.RUnlock()
+	return fake.quarantineExpiredEntryArgsForCall[i].arg1, fake.quarantineExpiredEntryArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) QuarantineExpiredEntryReturns(result1 error) {
+	fake.QuarantineExpiredEntryStub = nil
+	fake.quarantineExpiredEntryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) QuarantineExpiredEntryReturnsOnCall(i int, result1 error) {
+	fake.QuarantineExpiredEntryStub = nil
+	if fake.quarantineExpiredEntryReturnsOnCall == nil {
+		fake.quarantineExpiredEntryReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.quarantineExpiredEntryReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteReclaimableEntry(arg1 string, arg2 int, arg3 int) error {
+	fake.deleteReclaimableEntryMutex.Lock()
+	ret, specificReturn := fake.deleteReclaimableEntryReturnsOnCall[len(fake.deleteReclaimableEntryArgsForCall)]
+	fake.deleteReclaimableEntryArgsForCall = append(fake.deleteReclaimableEntryArgsForCall, struct {
+		arg1 string
+		arg2 int
+		arg3 int
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("DeleteReclaimableEntry", []interface{}{arg1, arg2, arg3})
+	fake.deleteReclaimableEntryMutex.Unlock()
+	if fake.DeleteReclaimableEntryStub != nil {
+		return fake.DeleteReclaimableEntryStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteReclaimableEntryReturns.result1
+}
+
+func (fake *DatabaseHandler) DeleteReclaimableEntryCallCount() int {
+	fake.deleteReclaimableEntryMutex.RLock()
+	defer fake.deleteReclaimableEntryMutex.RUnlock()
+	return len(fake.deleteReclaimableEntryArgsForCall)
+}
+
+func (fake *DatabaseHandler) DeleteReclaimableEntryArgsForCall(i int) (string, int, int) {
+	fake.deleteReclaimableEntryMutex.RLock()
+	defer fake.deleteReclaimableEntryMutex.RUnlock()
+	return fake.deleteReclaimableEntryArgsForCall[i].arg1, fake.deleteReclaimableEntryArgsForCall[i].arg2, fake.deleteReclaimableEntryArgsForCall[i].arg3
+}
+
+func (fake *DatabaseHandler) DeleteReclaimableEntryReturns(result1 error) {
+	fake.DeleteReclaimableEntryStub = nil
+	fake.deleteReclaimableEntryReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteReclaimableEntryReturnsOnCall(i int, result1 error) {
+	fake.DeleteReclaimableEntryStub = nil
+	if fake.deleteReclaimableEntryReturnsOnCall == nil {
+		fake.deleteReclaimableEntryReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteReclaimableEntryReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) LeaseForUnderlayIP(arg1 string, arg2 bool) (*controller.Lease, error) {
 	fake.leaseForUnderlayIPMutex.Lock()
 	ret, specificReturn := fake.leaseForUnderlayIPReturnsOnCall[len(fake.leaseForUnderlayIPArgsForCall)]
 	fake.leaseForUnderlayIPArgsForCall = append(fake.leaseForUnderlayIPArgsForCall, struct {
 		arg1 string
-	}{arg1})
-	fake.recordInvocation("LeaseForUnderlayIP", []interface{}{arg1})
+		arg2 bool
+	}{arg1, arg2})
+	fake.recordInvocation("LeaseForUnderlayIP", []interface{}{arg1, arg2})
 	fake.leaseForUnderlayIPMutex.Unlock()
 	if fake.LeaseForUnderlayIPStub != nil {
-		return fake.LeaseForUnderlayIPStub(arg1)
+		return fake.LeaseForUnderlayIPStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -262,10 +622,10 @@ func (fake *DatabaseHandler) LeaseForUnderlayIPCallCount() int {
 	return len(fake.leaseForUnderlayIPArgsForCall)
 }
 
-func (fake *DatabaseHandler) LeaseForUnderlayIPArgsForCall(i int) string {
+func (fake *DatabaseHandler) LeaseForUnderlayIPArgsForCall(i int) (string, bool) {
 	fake.leaseForUnderlayIPMutex.RLock()
 	defer fake.leaseForUnderlayIPMutex.RUnlock()
-	return fake.leaseForUnderlayIPArgsForCall[i].arg1
+	return fake.leaseForUnderlayIPArgsForCall[i].arg1, fake.leaseForUnderlayIPArgsForCall[i].arg2
 }
 
 func (fake *DatabaseHandler) LeaseForUnderlayIPReturns(result1 *controller.Lease, result2 error) {
@@ -290,16 +650,17 @@ func (fake *DatabaseHandler) LeaseForUnderlayIPReturnsOnCall(i int, result1 *con
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) LastRenewedAtForUnderlayIP(arg1 string) (int64, error) {
+func (fake *DatabaseHandler) LastRenewedAtForUnderlayIP(arg1 string, arg2 bool) (int64, error) {
 	fake.lastRenewedAtForUnderlayIPMutex.Lock()
 	ret, specificReturn := fake.lastRenewedAtForUnderlayIPReturnsOnCall[len(fake.lastRenewedAtForUnderlayIPArgsForCall)]
 	fake.lastRenewedAtForUnderlayIPArgsForCall = append(fake.lastRenewedAtForUnderlayIPArgsForCall, struct {
 		arg1 string
-	}{arg1})
-	fake.recordInvocation("LastRenewedAtForUnderlayIP", []interface{}{arg1})
+		arg2 bool
+	}{arg1, arg2})
+	fake.recordInvocation("LastRenewedAtForUnderlayIP", []interface{}{arg1, arg2})
 	fake.lastRenewedAtForUnderlayIPMutex.Unlock()
 	if fake.LastRenewedAtForUnderlayIPStub != nil {
-		return fake.LastRenewedAtForUnderlayIPStub(arg1)
+		return fake.LastRenewedAtForUnderlayIPStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -313,10 +674,10 @@ func (fake *DatabaseHandler) LastRenewedAtForUnderlayIPCallCount() int {
 	return len(fake.lastRenewedAtForUnderlayIPArgsForCall)
 }
 
-func (fake *DatabaseHandler) LastRenewedAtForUnderlayIPArgsForCall(i int) string {
+func (fake *DatabaseHandler) LastRenewedAtForUnderlayIPArgsForCall(i int) (string, bool) {
 	fake.lastRenewedAtForUnderlayIPMutex.RLock()
 	defer fake.lastRenewedAtForUnderlayIPMutex.RUnlock()
-	return fake.lastRenewedAtForUnderlayIPArgsForCall[i].arg1
+	return fake.lastRenewedAtForUnderlayIPArgsForCall[i].arg1, fake.lastRenewedAtForUnderlayIPArgsForCall[i].arg2
 }
 
 func (fake *DatabaseHandler) LastRenewedAtForUnderlayIPReturns(result1 int64, result2 error) {
@@ -341,16 +702,17 @@ func (fake *DatabaseHandler) LastRenewedAtForUnderlayIPReturnsOnCall(i int, resu
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) RenewLeaseForUnderlayIP(arg1 string) error {
+func (fake *DatabaseHandler) RenewLeaseForUnderlayIP(arg1 string, arg2 bool) error {
 	fake.renewLeaseForUnderlayIPMutex.Lock()
 	ret, specificReturn := fake.renewLeaseForUnderlayIPReturnsOnCall[len(fake.renewLeaseForUnderlayIPArgsForCall)]
 	fake.renewLeaseForUnderlayIPArgsForCall = append(fake.renewLeaseForUnderlayIPArgsForCall, struct {
 		arg1 string
-	}{arg1})
-	fake.recordInvocation("RenewLeaseForUnderlayIP", []interface{}{arg1})
+		arg2 bool
+	}{arg1, arg2})
+	fake.recordInvocation("RenewLeaseForUnderlayIP", []interface{}{arg1, arg2})
 	fake.renewLeaseForUnderlayIPMutex.Unlock()
 	if fake.RenewLeaseForUnderlayIPStub != nil {
-		return fake.RenewLeaseForUnderlayIPStub(arg1)
+		return fake.RenewLeaseForUnderlayIPStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -364,10 +726,10 @@ func (fake *DatabaseHandler) RenewLeaseForUnderlayIPCallCount() int {
 	return len(fake.renewLeaseForUnderlayIPArgsForCall)
 }
 
-func (fake *DatabaseHandler) RenewLeaseForUnderlayIPArgsForCall(i int) string {
+func (fake *DatabaseHandler) RenewLeaseForUnderlayIPArgsForCall(i int) (string, bool) {
 	fake.renewLeaseForUnderlayIPMutex.RLock()
 	defer fake.renewLeaseForUnderlayIPMutex.RUnlock()
-	return fake.renewLeaseForUnderlayIPArgsForCall[i].arg1
+	return fake.renewLeaseForUnderlayIPArgsForCall[i].arg1, fake.renewLeaseForUnderlayIPArgsForCall[i].arg2
 }
 
 func (fake *DatabaseHandler) RenewLeaseForUnderlayIPReturns(result1 error) {
@@ -389,6 +751,54 @@ func (fake *DatabaseHandler) RenewLeaseForUnderlayIPReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *DatabaseHandler) UpdateLeaseMetadata(arg1 controller.Lease) error {
+	fake.updateLeaseMetadataMutex.Lock()
+	ret, specificReturn := fake.updateLeaseMetadataReturnsOnCall[len(fake.updateLeaseMetadataArgsForCall)]
+	fake.updateLeaseMetadataArgsForCall = append(fake.updateLeaseMetadataArgsForCall, struct {
+		arg1 controller.Lease
+	}{arg1})
+	fake.recordInvocation("UpdateLeaseMetadata", []interface{}{arg1})
+	fake.updateLeaseMetadataMutex.Unlock()
+	if fake.UpdateLeaseMetadataStub != nil {
+		return fake.UpdateLeaseMetadataStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.updateLeaseMetadataReturns.result1
+}
+
+func (fake *DatabaseHandler) UpdateLeaseMetadataCallCount() int {
+	fake.updateLeaseMetadataMutex.RLock()
+	defer fake.updateLeaseMetadataMutex.RUnlock()
+	return len(fake.updateLeaseMetadataArgsForCall)
+}
+
+func (fake *DatabaseHandler) UpdateLeaseMetadataArgsForCall(i int) controller.Lease {
+	fake.updateLeaseMetadataMutex.RLock()
+	defer fake.updateLeaseMetadataMutex.RUnlock()
+	return fake.updateLeaseMetadataArgsForCall[i].arg1
+}
+
+func (fake *DatabaseHandler) UpdateLeaseMetadataReturns(result1 error) {
+	fake.UpdateLeaseMetadataStub = nil
+	fake.updateLeaseMetadataReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) UpdateLeaseMetadataReturnsOnCall(i int, result1 error) {
+	fake.UpdateLeaseMetadataStub = nil
+	if fake.updateLeaseMetadataReturnsOnCall == nil {
+		fake.updateLeaseMetadataReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateLeaseMetadataReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *DatabaseHandler) All() ([]controller.Lease, error) {
 	fake.allMutex.Lock()
 	ret, specificReturn := fake.allReturnsOnCall[len(fake.allArgsForCall)]
@@ -432,245 +842,640 @@ func (fake *DatabaseHandler) AllReturnsOnCall(i int, result1 []controller.Lease,
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllBlockSubnets() ([]controller.Lease, error) {
-	fake.allBlockSubnetsMutex.Lock()
-	ret, specificReturn := fake.allBlockSubnetsReturnsOnCall[len(fake.allBlockSubnetsArgsForCall)]
-	fake.allBlockSubnetsArgsForCall = append(fake.allBlockSubnetsArgsForCall, struct{}{})
-	fake.recordInvocation("AllBlockSubnets", []interface{}{})
-	fake.allBlockSubnetsMutex.Unlock()
-	if fake.AllBlockSubnetsStub != nil {
-		return fake.AllBlockSubnetsStub()
+func (fake *DatabaseHandler) AllActive(arg1 int) ([]controller.Lease, error) {
+	fake.allActiveMutex.Lock()
+	ret, specificReturn := fake.allActiveReturnsOnCall[len(fake.allActiveArgsForCall)]
+	fake.allActiveArgsForCall = append(fake.allActiveArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	fake.recordInvocation("AllActive", []interface{}{arg1})
+	fake.allActiveMutex.Unlock()
+	if fake.AllActiveStub != nil {
+		return fake.AllActiveStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.allBlockSubnetsReturns.result1, fake.allBlockSubnetsReturns.result2
+	return fake.allActiveReturns.result1, fake.allActiveReturns.result2
 }
 
-func (fake *DatabaseHandler) AllBlockSubnetsCallCount() int {
-	fake.allBlockSubnetsMutex.RLock()
-	defer fake.allBlockSubnetsMutex.RUnlock()
-	return len(fake.allBlockSubnetsArgsForCall)
+func (fake *DatabaseHandler) AllActiveCallCount() int {
+	fake.allActiveMutex.RLock()
+	defer fake.allActiveMutex.RUnlock()
+	return len(fake.allActiveArgsForCall)
+}
+
+func (fake *DatabaseHandler) AllActiveArgsForCall(i int) int {
+	fake.allActiveMutex.RLock()
+	defer fake.allActiveMutex.RUnlock()
+	return fake.allActiveArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) AllBlockSubnetsReturns(result1 []controller.Lease, result2 error) {
-	fake.AllBlockSubnetsStub = nil
-	fake.allBlockSubnetsReturns = struct {
+func (fake *DatabaseHandler) AllActiveReturns(result1 []controller.Lease, result2 error) {
+	fake.AllActiveStub = nil
+	fake.allActiveReturns = struct {
 		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllBlockSubnetsReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
-	fake.AllBlockSubnetsStub = nil
-	if fake.allBlockSubnetsReturnsOnCall == nil {
-		fake.allBlockSubnetsReturnsOnCall = make(map[int]struct {
+func (fake *DatabaseHandler) AllActiveReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
+	fake.AllActiveStub = nil
+	if fake.allActiveReturnsOnCall == nil {
+		fake.allActiveReturnsOnCall = make(map[int]struct {
 			result1 []controller.Lease
 			result2 error
 		})
 	}
-	fake.allBlockSubnetsReturnsOnCall[i] = struct {
+	fake.allActiveReturnsOnCall[i] = struct {
 		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllSingleIPSubnets() ([]controller.Lease, error) {
-	fake.allSingleIPSubnetsMutex.Lock()
-	ret, specificReturn := fake.allSingleIPSubnetsReturnsOnCall[len(fake.allSingleIPSubnetsArgsForCall)]
-	fake.allSingleIPSubnetsArgsForCall = append(fake.allSingleIPSubnetsArgsForCall, struct{}{})
-	fake.recordInvocation("AllSingleIPSubnets", []interface{}{})
-	fake.allSingleIPSubnetsMutex.Unlock()
-	if fake.AllSingleIPSubnetsStub != nil {
-		return fake.AllSingleIPSubnetsStub()
+func (fake *DatabaseHandler) QueryLeases(arg1 controller.LeaseQuery, arg2 int) ([]controller.Lease, string, error) {
+	fake.queryLeasesMutex.Lock()
+	ret, specificReturn := fake.queryLeasesReturnsOnCall[len(fake.queryLeasesArgsForCall)]
+	fake.queryLeasesArgsForCall = append(fake.queryLeasesArgsForCall, struct {
+		arg1 controller.LeaseQuery
+		arg2 int
+	}{arg1, arg2})
+	fake.recordInvocation("QueryLeases", []interface{}{arg1, arg2})
+	fake.queryLeasesMutex.Unlock()
+	if fake.QueryLeasesStub != nil {
+		return fake.QueryLeasesStub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1, ret.result2, ret.result3
 	}
-	return fake.allSingleIPSubnetsReturns.result1, fake.allSingleIPSubnetsReturns.result2
+	return fake.queryLeasesReturns.result1, fake.queryLeasesReturns.result2, fake.queryLeasesReturns.result3
+}
+
+func (fake *DatabaseHandler) QueryLeasesCallCount() int {
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
+	return len(fake.queryLeasesArgsForCall)
 }
 
-func (fake *DatabaseHandler) AllSingleIPSubnetsCallCount() int {
-	fake.allSingleIPSubnetsMutex.RLock()
-	defer fake.allSingleIPSubnetsMutex.RUnlock()
-	return len(fake.allSingleIPSubnetsArgsForCall)
+func (fake *DatabaseHandler) QueryLeasesArgsForCall(i int) (controller.LeaseQuery, int) {
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
+	return fake.queryLeasesArgsForCall[i].arg1, fake.queryLeasesArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) QueryLeasesReturns(result1 []controller.Lease, result2 string, result3 error) {
+	fake.QueryLeasesStub = nil
+	fake.queryLeasesReturns = struct {
+		result1 []controller.Lease
+		result2 string
+		result3 error
+	}{result1, result2, result3}
 }
 
-func (fake *DatabaseHandler) AllSingleIPSubnetsReturns(result1 []controller.Lease, result2 error) {
-	fake.AllSingleIPSubnetsStub = nil
-	fake.allSingleIPSubnetsReturns = struct {
+func (fake *DatabaseHandler) QueryLeasesReturnsOnCall(i int, result1 []controller.Lease, result2 string, result3 error) {
+	fake.QueryLeasesStub = nil
+	if fake.queryLeasesReturnsOnCall == nil {
+		fake.queryLeasesReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
+			result2 string
+			result3 error
+		})
+	}
+	fake.queryLeasesReturnsOnCall[i] = struct {
 		result1 []controller.Lease
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *DatabaseHandler) BeginLeaseTransaction(arg1 bool, arg2 bool) (database.LeaseTransaction, error) {
+	fake.beginLeaseTransactionMutex.Lock()
+	ret, specificReturn := fake.beginLeaseTransactionReturnsOnCall[len(fake.beginLeaseTransactionArgsForCall)]
+	fake.beginLeaseTransactionArgsForCall = append(fake.beginLeaseTransactionArgsForCall, struct {
+		arg1 bool
+		arg2 bool
+	}{arg1, arg2})
+	fake.recordInvocation("BeginLeaseTransaction", []interface{}{arg1, arg2})
+	fake.beginLeaseTransactionMutex.Unlock()
+	if fake.BeginLeaseTransactionStub != nil {
+		return fake.BeginLeaseTransactionStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.beginLeaseTransactionReturns.result1, fake.beginLeaseTransactionReturns.result2
+}
+
+func (fake *DatabaseHandler) BeginLeaseTransactionCallCount() int {
+	fake.beginLeaseTransactionMutex.RLock()
+	defer fake.beginLeaseTransactionMutex.RUnlock()
+	return len(fake.beginLeaseTransactionArgsForCall)
+}
+
+func (fake *DatabaseHandler) BeginLeaseTransactionArgsForCall(i int) (bool, bool) {
+	fake.beginLeaseTransactionMutex.RLock()
+	defer fake.beginLeaseTransactionMutex.RUnlock()
+	return fake.beginLeaseTransactionArgsForCall[i].arg1, fake.beginLeaseTransactionArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) BeginLeaseTransactionReturns(result1 database.LeaseTransaction, result2 error) {
+	fake.BeginLeaseTransactionStub = nil
+	fake.beginLeaseTransactionReturns = struct {
+		result1 database.LeaseTransaction
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllSingleIPSubnetsReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
-	fake.AllSingleIPSubnetsStub = nil
-	if fake.allSingleIPSubnetsReturnsOnCall == nil {
-		fake.allSingleIPSubnetsReturnsOnCall = make(map[int]struct {
-			result1 []controller.Lease
+func (fake *DatabaseHandler) BeginLeaseTransactionReturnsOnCall(i int, result1 database.LeaseTransaction, result2 error) {
+	fake.BeginLeaseTransactionStub = nil
+	if fake.beginLeaseTransactionReturnsOnCall == nil {
+		fake.beginLeaseTransactionReturnsOnCall = make(map[int]struct {
+			result1 database.LeaseTransaction
 			result2 error
 		})
 	}
-	fake.allSingleIPSubnetsReturnsOnCall[i] = struct {
-		result1 []controller.Lease
+	fake.beginLeaseTransactionReturnsOnCall[i] = struct {
+		result1 database.LeaseTransaction
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllActive(arg1 int) ([]controller.Lease, error) {
-	fake.allActiveMutex.Lock()
-	ret, specificReturn := fake.allActiveReturnsOnCall[len(fake.allActiveArgsForCall)]
-	fake.allActiveArgsForCall = append(fake.allActiveArgsForCall, struct {
-		arg1 int
+func (fake *DatabaseHandler) AddLeaseEvent(arg1 string, arg2 controller.Lease) error {
+	fake.addLeaseEventMutex.Lock()
+	ret, specificReturn := fake.addLeaseEventReturnsOnCall[len(fake.addLeaseEventArgsForCall)]
+	fake.addLeaseEventArgsForCall = append(fake.addLeaseEventArgsForCall, struct {
+		arg1 string
+		arg2 controller.Lease
+	}{arg1, arg2})
+	fake.recordInvocation("AddLeaseEvent", []interface{}{arg1, arg2})
+	fake.addLeaseEventMutex.Unlock()
+	if fake.AddLeaseEventStub != nil {
+		return fake.AddLeaseEventStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.addLeaseEventReturns.result1
+}
+
+func (fake *DatabaseHandler) AddLeaseEventCallCount() int {
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	return len(fake.addLeaseEventArgsForCall)
+}
+
+func (fake *DatabaseHandler) AddLeaseEventArgsForCall(i int) (string, controller.Lease) {
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	return fake.addLeaseEventArgsForCall[i].arg1, fake.addLeaseEventArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) AddLeaseEventReturns(result1 error) {
+	fake.AddLeaseEventStub = nil
+	fake.addLeaseEventReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) AddLeaseEventReturnsOnCall(i int, result1 error) {
+	fake.AddLeaseEventStub = nil
+	if fake.addLeaseEventReturnsOnCall == nil {
+		fake.addLeaseEventReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addLeaseEventReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) LeaseEventsForOverlaySubnet(arg1 string) ([]controller.LeaseEvent, error) {
+	fake.leaseEventsForOverlaySubnetMutex.Lock()
+	ret, specificReturn := fake.leaseEventsForOverlaySubnetReturnsOnCall[len(fake.leaseEventsForOverlaySubnetArgsForCall)]
+	fake.leaseEventsForOverlaySubnetArgsForCall = append(fake.leaseEventsForOverlaySubnetArgsForCall, struct {
+		arg1 string
 	}{arg1})
-	fake.recordInvocation("AllActive", []interface{}{arg1})
-	fake.allActiveMutex.Unlock()
-	if fake.AllActiveStub != nil {
-		return fake.AllActiveStub(arg1)
+	fake.recordInvocation("LeaseEventsForOverlaySubnet", []interface{}{arg1})
+	fake.leaseEventsForOverlaySubnetMutex.Unlock()
+	if fake.LeaseEventsForOverlaySubnetStub != nil {
+		return fake.LeaseEventsForOverlaySubnetStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.allActiveReturns.result1, fake.allActiveReturns.result2
+	return fake.leaseEventsForOverlaySubnetReturns.result1, fake.leaseEventsForOverlaySubnetReturns.result2
 }
 
-func (fake *DatabaseHandler) AllActiveCallCount() int {
-	fake.allActiveMutex.RLock()
-	defer fake.allActiveMutex.RUnlock()
-	return len(fake.allActiveArgsForCall)
+func (fake *DatabaseHandler) LeaseEventsForOverlaySubnetCallCount() int {
+	fake.leaseEventsForOverlaySubnetMutex.RLock()
+	defer fake.leaseEventsForOverlaySubnetMutex.RUnlock()
+	return len(fake.leaseEventsForOverlaySubnetArgsForCall)
 }
 
-func (fake *DatabaseHandler) AllActiveArgsForCall(i int) int {
-	fake.allActiveMutex.RLock()
-	defer fake.allActiveMutex.RUnlock()
-	return fake.allActiveArgsForCall[i].arg1
+func (fake *DatabaseHandler) LeaseEventsForOverlaySubnetArgsForCall(i int) string {
+	fake.leaseEventsForOverlaySubnetMutex.RLock()
+	defer fake.leaseEventsForOverlaySubnetMutex.RUnlock()
+	return fake.leaseEventsForOverlaySubnetArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) AllActiveReturns(result1 []controller.Lease, result2 error) {
-	fake.AllActiveStub = nil
-	fake.allActiveReturns = struct {
-		result1 []controller.Lease
+func (fake *DatabaseHandler) LeaseEventsForOverlaySubnetReturns(result1 []controller.LeaseEvent, result2 error) {
+	fake.LeaseEventsForOverlaySubnetStub = nil
+	fake.leaseEventsForOverlaySubnetReturns = struct {
+		result1 []controller.LeaseEvent
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) AllActiveReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
-	fake.AllActiveStub = nil
-	if fake.allActiveReturnsOnCall == nil {
-		fake.allActiveReturnsOnCall = make(map[int]struct {
-			result1 []controller.Lease
+func (fake *DatabaseHandler) LeaseEventsForOverlaySubnetReturnsOnCall(i int, result1 []controller.LeaseEvent, result2 error) {
+	fake.LeaseEventsForOverlaySubnetStub = nil
+	if fake.leaseEventsForOverlaySubnetReturnsOnCall == nil {
+		fake.leaseEventsForOverlaySubnetReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseEvent
 			result2 error
 		})
 	}
-	fake.allActiveReturnsOnCall[i] = struct {
-		result1 []controller.Lease
+	fake.leaseEventsForOverlaySubnetReturnsOnCall[i] = struct {
+		result1 []controller.LeaseEvent
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnet(arg1 int) (*controller.Lease, error) {
-	fake.oldestExpiredBlockSubnetMutex.Lock()
-	ret, specificReturn := fake.oldestExpiredBlockSubnetReturnsOnCall[len(fake.oldestExpiredBlockSubnetArgsForCall)]
-	fake.oldestExpiredBlockSubnetArgsForCall = append(fake.oldestExpiredBlockSubnetArgsForCall, struct {
-		arg1 int
+func (fake *DatabaseHandler) LeaseForOverlaySubnet(arg1 string) (*controller.Lease, error) {
+	fake.leaseForOverlaySubnetMutex.Lock()
+	ret, specificReturn := fake.leaseForOverlaySubnetReturnsOnCall[len(fake.leaseForOverlaySubnetArgsForCall)]
+	fake.leaseForOverlaySubnetArgsForCall = append(fake.leaseForOverlaySubnetArgsForCall, struct {
+		arg1 string
 	}{arg1})
-	fake.recordInvocation("OldestExpiredBlockSubnet", []interface{}{arg1})
-	fake.oldestExpiredBlockSubnetMutex.Unlock()
-	if fake.OldestExpiredBlockSubnetStub != nil {
-		return fake.OldestExpiredBlockSubnetStub(arg1)
+	fake.recordInvocation("LeaseForOverlaySubnet", []interface{}{arg1})
+	fake.leaseForOverlaySubnetMutex.Unlock()
+	if fake.LeaseForOverlaySubnetStub != nil {
+		return fake.LeaseForOverlaySubnetStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.oldestExpiredBlockSubnetReturns.result1, fake.oldestExpiredBlockSubnetReturns.result2
+	return fake.leaseForOverlaySubnetReturns.result1, fake.leaseForOverlaySubnetReturns.result2
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetCallCount() int {
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	return len(fake.oldestExpiredBlockSubnetArgsForCall)
+func (fake *DatabaseHandler) LeaseForOverlaySubnetCallCount() int {
+	fake.leaseForOverlaySubnetMutex.RLock()
+	defer fake.leaseForOverlaySubnetMutex.RUnlock()
+	return len(fake.leaseForOverlaySubnetArgsForCall)
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetArgsForCall(i int) int {
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	return fake.oldestExpiredBlockSubnetArgsForCall[i].arg1
+func (fake *DatabaseHandler) LeaseForOverlaySubnetArgsForCall(i int) string {
+	fake.leaseForOverlaySubnetMutex.RLock()
+	defer fake.leaseForOverlaySubnetMutex.RUnlock()
+	return fake.leaseForOverlaySubnetArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetReturns(result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredBlockSubnetStub = nil
-	fake.oldestExpiredBlockSubnetReturns = struct {
+func (fake *DatabaseHandler) LeaseForOverlaySubnetReturns(result1 *controller.Lease, result2 error) {
+	fake.LeaseForOverlaySubnetStub = nil
+	fake.leaseForOverlaySubnetReturns = struct {
 		result1 *controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredBlockSubnetStub = nil
-	if fake.oldestExpiredBlockSubnetReturnsOnCall == nil {
-		fake.oldestExpiredBlockSubnetReturnsOnCall = make(map[int]struct {
+func (fake *DatabaseHandler) LeaseForOverlaySubnetReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
+	fake.LeaseForOverlaySubnetStub = nil
+	if fake.leaseForOverlaySubnetReturnsOnCall == nil {
+		fake.leaseForOverlaySubnetReturnsOnCall = make(map[int]struct {
 			result1 *controller.Lease
 			result2 error
 		})
 	}
-	fake.oldestExpiredBlockSubnetReturnsOnCall[i] = struct {
+	fake.leaseForOverlaySubnetReturnsOnCall[i] = struct {
 		result1 *controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIP(arg1 int) (*controller.Lease, error) {
-	fake.oldestExpiredSingleIPMutex.Lock()
-	ret, specificReturn := fake.oldestExpiredSingleIPReturnsOnCall[len(fake.oldestExpiredSingleIPArgsForCall)]
-	fake.oldestExpiredSingleIPArgsForCall = append(fake.oldestExpiredSingleIPArgsForCall, struct {
+func (fake *DatabaseHandler) AllLeaseRecords(arg1 int) ([]controller.LeaseRecord, error) {
+	fake.allLeaseRecordsMutex.Lock()
+	ret, specificReturn := fake.allLeaseRecordsReturnsOnCall[len(fake.allLeaseRecordsArgsForCall)]
+	fake.allLeaseRecordsArgsForCall = append(fake.allLeaseRecordsArgsForCall, struct {
 		arg1 int
 	}{arg1})
-	fake.recordInvocation("OldestExpiredSingleIP", []interface{}{arg1})
-	fake.oldestExpiredSingleIPMutex.Unlock()
-	if fake.OldestExpiredSingleIPStub != nil {
-		return fake.OldestExpiredSingleIPStub(arg1)
+	fake.recordInvocation("AllLeaseRecords", []interface{}{arg1})
+	fake.allLeaseRecordsMutex.Unlock()
+	if fake.AllLeaseRecordsStub != nil {
+		return fake.AllLeaseRecordsStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.oldestExpiredSingleIPReturns.result1, fake.oldestExpiredSingleIPReturns.result2
+	return fake.allLeaseRecordsReturns.result1, fake.allLeaseRecordsReturns.result2
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPCallCount() int {
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
-	return len(fake.oldestExpiredSingleIPArgsForCall)
+func (fake *DatabaseHandler) AllLeaseRecordsCallCount() int {
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	return len(fake.allLeaseRecordsArgsForCall)
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPArgsForCall(i int) int {
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
-	return fake.oldestExpiredSingleIPArgsForCall[i].arg1
+func (fake *DatabaseHandler) AllLeaseRecordsArgsForCall(i int) int {
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	return fake.allLeaseRecordsArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPReturns(result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredSingleIPStub = nil
-	fake.oldestExpiredSingleIPReturns = struct {
-		result1 *controller.Lease
+func (fake *DatabaseHandler) AllLeaseRecordsReturns(result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeaseRecordsStub = nil
+	fake.allLeaseRecordsReturns = struct {
+		result1 []controller.LeaseRecord
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredSingleIPStub = nil
-	if fake.oldestExpiredSingleIPReturnsOnCall == nil {
-		fake.oldestExpiredSingleIPReturnsOnCall = make(map[int]struct {
-			result1 *controller.Lease
+func (fake *DatabaseHandler) AllLeaseRecordsReturnsOnCall(i int, result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeaseRecordsStub = nil
+	if fake.allLeaseRecordsReturnsOnCall == nil {
+		fake.allLeaseRecordsReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseRecord
 			result2 error
 		})
 	}
-	fake.oldestExpiredSingleIPReturnsOnCall[i] = struct {
-		result1 *controller.Lease
+	fake.allLeaseRecordsReturnsOnCall[i] = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) LeaseRecordContainingIP(arg1 string, arg2 int) (*controller.LeaseRecord, error) {
+	fake.leaseRecordContainingIPMutex.Lock()
+	ret, specificReturn := fake.leaseRecordContainingIPReturnsOnCall[len(fake.leaseRecordContainingIPArgsForCall)]
+	fake.leaseRecordContainingIPArgsForCall = append(fake.leaseRecordContainingIPArgsForCall, struct {
+		arg1 string
+		arg2 int
+	}{arg1, arg2})
+	fake.recordInvocation("LeaseRecordContainingIP", []interface{}{arg1, arg2})
+	fake.leaseRecordContainingIPMutex.Unlock()
+	if fake.LeaseRecordContainingIPStub != nil {
+		return fake.LeaseRecordContainingIPStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.leaseRecordContainingIPReturns.result1, fake.leaseRecordContainingIPReturns.result2
+}
+
+func (fake *DatabaseHandler) LeaseRecordContainingIPCallCount() int {
+	fake.leaseRecordContainingIPMutex.RLock()
+	defer fake.leaseRecordContainingIPMutex.RUnlock()
+	return len(fake.leaseRecordContainingIPArgsForCall)
+}
+
+func (fake *DatabaseHandler) LeaseRecordContainingIPArgsForCall(i int) (string, int) {
+	fake.leaseRecordContainingIPMutex.RLock()
+	defer fake.leaseRecordContainingIPMutex.RUnlock()
+	return fake.leaseRecordContainingIPArgsForCall[i].arg1, fake.leaseRecordContainingIPArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) LeaseRecordContainingIPReturns(result1 *controller.LeaseRecord, result2 error) {
+	fake.LeaseRecordContainingIPStub = nil
+	fake.leaseRecordContainingIPReturns = struct {
+		result1 *controller.LeaseRecord
 		result2 error
 	}{result1, result2}
 }
 
+func (fake *DatabaseHandler) LeaseRecordContainingIPReturnsOnCall(i int, result1 *controller.LeaseRecord, result2 error) {
+	fake.LeaseRecordContainingIPStub = nil
+	if fake.leaseRecordContainingIPReturnsOnCall == nil {
+		fake.leaseRecordContainingIPReturnsOnCall = make(map[int]struct {
+			result1 *controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.leaseRecordContainingIPReturnsOnCall[i] = struct {
+		result1 *controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) AddReservation(arg1 string) error {
+	fake.addReservationMutex.Lock()
+	ret, specificReturn := fake.addReservationReturnsOnCall[len(fake.addReservationArgsForCall)]
+	fake.addReservationArgsForCall = append(fake.addReservationArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("AddReservation", []interface{}{arg1})
+	fake.addReservationMutex.Unlock()
+	if fake.AddReservationStub != nil {
+		return fake.AddReservationStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.addReservationReturns.result1
+}
+
+func (fake *DatabaseHandler) AddReservationCallCount() int {
+	fake.addReservationMutex.RLock()
+	defer fake.addReservationMutex.RUnlock()
+	return len(fake.addReservationArgsForCall)
+}
+
+func (fake *DatabaseHandler) AddReservationArgsForCall(i int) string {
+	fake.addReservationMutex.RLock()
+	defer fake.addReservationMutex.RUnlock()
+	return fake.addReservationArgsForCall[i].arg1
+}
+
+func (fake *DatabaseHandler) AddReservationReturns(result1 error) {
+	fake.AddReservationStub = nil
+	fake.addReservationReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) AddReservationReturnsOnCall(i int, result1 error) {
+	fake.AddReservationStub = nil
+	if fake.addReservationReturnsOnCall == nil {
+		fake.addReservationReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addReservationReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteReservation(arg1 string) error {
+	fake.deleteReservationMutex.Lock()
+	ret, specificReturn := fake.deleteReservationReturnsOnCall[len(fake.deleteReservationArgsForCall)]
+	fake.deleteReservationArgsForCall = append(fake.deleteReservationArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("DeleteReservation", []interface{}{arg1})
+	fake.deleteReservationMutex.Unlock()
+	if fake.DeleteReservationStub != nil {
+		return fake.DeleteReservationStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteReservationReturns.result1
+}
+
+func (fake *DatabaseHandler) DeleteReservationCallCount() int {
+	fake.deleteReservationMutex.RLock()
+	defer fake.deleteReservationMutex.RUnlock()
+	return len(fake.deleteReservationArgsForCall)
+}
+
+func (fake *DatabaseHandler) DeleteReservationArgsForCall(i int) string {
+	fake.deleteReservationMutex.RLock()
+	defer fake.deleteReservationMutex.RUnlock()
+	return fake.deleteReservationArgsForCall[i].arg1
+}
+
+func (fake *DatabaseHandler) DeleteReservationReturns(result1 error) {
+	fake.DeleteReservationStub = nil
+	fake.deleteReservationReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) DeleteReservationReturnsOnCall(i int, result1 error) {
+	fake.DeleteReservationStub = nil
+	if fake.deleteReservationReturnsOnCall == nil {
+		fake.deleteReservationReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteReservationReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) AllReservations() ([]string, error) {
+	fake.allReservationsMutex.Lock()
+	ret, specificReturn := fake.allReservationsReturnsOnCall[len(fake.allReservationsArgsForCall)]
+	fake.allReservationsArgsForCall = append(fake.allReservationsArgsForCall, struct{}{})
+	fake.recordInvocation("AllReservations", []interface{}{})
+	fake.allReservationsMutex.Unlock()
+	if fake.AllReservationsStub != nil {
+		return fake.AllReservationsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.allReservationsReturns.result1, fake.allReservationsReturns.result2
+}
+
+func (fake *DatabaseHandler) AllReservationsCallCount() int {
+	fake.allReservationsMutex.RLock()
+	defer fake.allReservationsMutex.RUnlock()
+	return len(fake.allReservationsArgsForCall)
+}
+
+func (fake *DatabaseHandler) AllReservationsReturns(result1 []string, result2 error) {
+	fake.AllReservationsStub = nil
+	fake.allReservationsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) AllReservationsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.AllReservationsStub = nil
+	if fake.allReservationsReturnsOnCall == nil {
+		fake.allReservationsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.allReservationsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) ReleasedSubnets() ([]string, error) {
+	fake.releasedSubnetsMutex.Lock()
+	ret, specificReturn := fake.releasedSubnetsReturnsOnCall[len(fake.releasedSubnetsArgsForCall)]
+	fake.releasedSubnetsArgsForCall = append(fake.releasedSubnetsArgsForCall, struct{}{})
+	fake.recordInvocation("ReleasedSubnets", []interface{}{})
+	fake.releasedSubnetsMutex.Unlock()
+	if fake.ReleasedSubnetsStub != nil {
+		return fake.ReleasedSubnetsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.releasedSubnetsReturns.result1, fake.releasedSubnetsReturns.result2
+}
+
+func (fake *DatabaseHandler) ReleasedSubnetsCallCount() int {
+	fake.releasedSubnetsMutex.RLock()
+	defer fake.releasedSubnetsMutex.RUnlock()
+	return len(fake.releasedSubnetsArgsForCall)
+}
+
+func (fake *DatabaseHandler) ReleasedSubnetsReturns(result1 []string, result2 error) {
+	fake.ReleasedSubnetsStub = nil
+	fake.releasedSubnetsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) ReleasedSubnetsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.ReleasedSubnetsStub = nil
+	if fake.releasedSubnetsReturnsOnCall == nil {
+		fake.releasedSubnetsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.releasedSubnetsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) BumpRevision() error {
+	fake.bumpRevisionMutex.Lock()
+	ret, specificReturn := fake.bumpRevisionReturnsOnCall[len(fake.bumpRevisionArgsForCall)]
+	fake.bumpRevisionArgsForCall = append(fake.bumpRevisionArgsForCall, struct{}{})
+	fake.recordInvocation("BumpRevision", []interface{}{})
+	fake.bumpRevisionMutex.Unlock()
+	if fake.BumpRevisionStub != nil {
+		return fake.BumpRevisionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.bumpRevisionReturns.result1
+}
+
+func (fake *DatabaseHandler) BumpRevisionCallCount() int {
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
+	return len(fake.bumpRevisionArgsForCall)
+}
+
+func (fake *DatabaseHandler) BumpRevisionReturns(result1 error) {
+	fake.BumpRevisionStub = nil
+	fake.bumpRevisionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) BumpRevisionReturnsOnCall(i int, result1 error) {
+	fake.BumpRevisionStub = nil
+	if fake.bumpRevisionReturnsOnCall == nil {
+		fake.bumpRevisionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.bumpRevisionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *DatabaseHandler) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -678,24 +1483,50 @@ func (fake *DatabaseHandler) Invocations() map[string][][]interface{} {
 	defer fake.addEntryMutex.RUnlock()
 	fake.deleteEntryMutex.RLock()
 	defer fake.deleteEntryMutex.RUnlock()
+	fake.deleteEntryForOverlaySubnetMutex.RLock()
+	defer fake.deleteEntryForOverlaySubnetMutex.RUnlock()
+	fake.deleteExpiredEntryMutex.RLock()
+	defer fake.deleteExpiredEntryMutex.RUnlock()
+	fake.quarantineExpiredEntryMutex.RLock()
+	defer fake.quarantineExpiredEntryMutex.RUnlock()
+	fake.deleteReclaimableEntryMutex.RLock()
+	defer fake.deleteReclaimableEntryMutex.RUnlock()
 	fake.leaseForUnderlayIPMutex.RLock()
 	defer fake.leaseForUnderlayIPMutex.RUnlock()
 	fake.lastRenewedAtForUnderlayIPMutex.RLock()
 	defer fake.lastRenewedAtForUnderlayIPMutex.RUnlock()
 	fake.renewLeaseForUnderlayIPMutex.RLock()
 	defer fake.renewLeaseForUnderlayIPMutex.RUnlock()
+	fake.updateLeaseMetadataMutex.RLock()
+	defer fake.updateLeaseMetadataMutex.RUnlock()
 	fake.allMutex.RLock()
 	defer fake.allMutex.RUnlock()
-	fake.allBlockSubnetsMutex.RLock()
-	defer fake.allBlockSubnetsMutex.RUnlock()
-	fake.allSingleIPSubnetsMutex.RLock()
-	defer fake.allSingleIPSubnetsMutex.RUnlock()
 	fake.allActiveMutex.RLock()
 	defer fake.allActiveMutex.RUnlock()
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
+	fake.queryLeasesMutex.RLock()
+	defer fake.queryLeasesMutex.RUnlock()
+	fake.beginLeaseTransactionMutex.RLock()
+	defer fake.beginLeaseTransactionMutex.RUnlock()
+	fake.addLeaseEventMutex.RLock()
+	defer fake.addLeaseEventMutex.RUnlock()
+	fake.leaseEventsForOverlaySubnetMutex.RLock()
+	defer fake.leaseEventsForOverlaySubnetMutex.RUnlock()
+	fake.leaseForOverlaySubnetMutex.RLock()
+	defer fake.leaseForOverlaySubnetMutex.RUnlock()
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	fake.leaseRecordContainingIPMutex.RLock()
+	defer fake.leaseRecordContainingIPMutex.RUnlock()
+	fake.addReservationMutex.RLock()
+	defer fake.addReservationMutex.RUnlock()
+	fake.deleteReservationMutex.RLock()
+	defer fake.deleteReservationMutex.RUnlock()
+	fake.allReservationsMutex.RLock()
+	defer fake.allReservationsMutex.RUnlock()
+	fake.releasedSubnetsMutex.RLock()
+	defer fake.releasedSubnetsMutex.RUnlock()
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,103 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type ExpiredLeaseReaper struct {
+	ReapExpiredLeasesStub        func(gracePeriodSeconds int, dryRun bool) ([]controller.Lease, error)
+	reapExpiredLeasesMutex       sync.RWMutex
+	reapExpiredLeasesArgsForCall []struct {
+		gracePeriodSeconds int
+		dryRun             bool
+	}
+	reapExpiredLeasesReturns struct {
+		result1 []controller.Lease
+		result2 error
+	}
+	reapExpiredLeasesReturnsOnCall map[int]struct {
+		result1 []controller.Lease
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ExpiredLeaseReaper) ReapExpiredLeases(gracePeriodSeconds int, dryRun bool) ([]controller.Lease, error) {
+	fake.reapExpiredLeasesMutex.Lock()
+	ret, specificReturn := fake.reapExpiredLeasesReturnsOnCall[len(fake.reapExpiredLeasesArgsForCall)]
+	fake.reapExpiredLeasesArgsForCall = append(fake.reapExpiredLeasesArgsForCall, struct {
+		gracePeriodSeconds int
+		dryRun             bool
+	}{gracePeriodSeconds, dryRun})
+	fake.recordInvocation("ReapExpiredLeases", []interface{}{gracePeriodSeconds, dryRun})
+	fake.reapExpiredLeasesMutex.Unlock()
+	if fake.ReapExpiredLeasesStub != nil {
+		return fake.ReapExpiredLeasesStub(gracePeriodSeconds, dryRun)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.reapExpiredLeasesReturns.result1, fake.reapExpiredLeasesReturns.result2
+}
+
+func (fake *ExpiredLeaseReaper) ReapExpiredLeasesCallCount() int {
+	fake.reapExpiredLeasesMutex.RLock()
+	defer fake.reapExpiredLeasesMutex.RUnlock()
+	return len(fake.reapExpiredLeasesArgsForCall)
+}
+
+func (fake *ExpiredLeaseReaper) ReapExpiredLeasesArgsForCall(i int) (int, bool) {
+	fake.reapExpiredLeasesMutex.RLock()
+	defer fake.reapExpiredLeasesMutex.RUnlock()
+	return fake.reapExpiredLeasesArgsForCall[i].gracePeriodSeconds, fake.reapExpiredLeasesArgsForCall[i].dryRun
+}
+
+func (fake *ExpiredLeaseReaper) ReapExpiredLeasesReturns(result1 []controller.Lease, result2 error) {
+	fake.ReapExpiredLeasesStub = nil
+	fake.reapExpiredLeasesReturns = struct {
+		result1 []controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ExpiredLeaseReaper) ReapExpiredLeasesReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
+	fake.ReapExpiredLeasesStub = nil
+	if fake.reapExpiredLeasesReturnsOnCall == nil {
+		fake.reapExpiredLeasesReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
+			result2 error
+		})
+	}
+	fake.reapExpiredLeasesReturnsOnCall[i] = struct {
+		result1 []controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ExpiredLeaseReaper) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.reapExpiredLeasesMutex.RLock()
+	defer fake.reapExpiredLeasesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ExpiredLeaseReaper) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
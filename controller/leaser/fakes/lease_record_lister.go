@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type LeaseRecordLister struct {
+	AllLeaseRecordsStub        func(int) ([]controller.LeaseRecord, error)
+	allLeaseRecordsMutex       sync.RWMutex
+	allLeaseRecordsArgsForCall []struct {
+		arg1 int
+	}
+	allLeaseRecordsReturns struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	allLeaseRecordsReturnsOnCall map[int]struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *LeaseRecordLister) AllLeaseRecords(arg1 int) ([]controller.LeaseRecord, error) {
+	fake.allLeaseRecordsMutex.Lock()
+	ret, specificReturn := fake.allLeaseRecordsReturnsOnCall[len(fake.allLeaseRecordsArgsForCall)]
+	fake.allLeaseRecordsArgsForCall = append(fake.allLeaseRecordsArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	fake.recordInvocation("AllLeaseRecords", []interface{}{arg1})
+	fake.allLeaseRecordsMutex.Unlock()
+	if fake.AllLeaseRecordsStub != nil {
+		return fake.AllLeaseRecordsStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.allLeaseRecordsReturns.result1, fake.allLeaseRecordsReturns.result2
+}
+
+func (fake *LeaseRecordLister) AllLeaseRecordsCallCount() int {
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	return len(fake.allLeaseRecordsArgsForCall)
+}
+
+func (fake *LeaseRecordLister) AllLeaseRecordsArgsForCall(i int) int {
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	return fake.allLeaseRecordsArgsForCall[i].arg1
+}
+
+func (fake *LeaseRecordLister) AllLeaseRecordsReturns(result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeaseRecordsStub = nil
+	fake.allLeaseRecordsReturns = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseRecordLister) AllLeaseRecordsReturnsOnCall(i int, result1 []controller.LeaseRecord, result2 error) {
+	fake.AllLeaseRecordsStub = nil
+	if fake.allLeaseRecordsReturnsOnCall == nil {
+		fake.allLeaseRecordsReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.allLeaseRecordsReturnsOnCall[i] = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *LeaseRecordLister) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.allLeaseRecordsMutex.RLock()
+	defer fake.allLeaseRecordsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *LeaseRecordLister) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,84 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type RevisionBumper struct {
+	BumpRevisionStub        func() error
+	bumpRevisionMutex       sync.RWMutex
+	bumpRevisionArgsForCall []struct{}
+	bumpRevisionReturns     struct {
+		result1 error
+	}
+	bumpRevisionReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *RevisionBumper) BumpRevision() error {
+	fake.bumpRevisionMutex.Lock()
+	ret, specificReturn := fake.bumpRevisionReturnsOnCall[len(fake.bumpRevisionArgsForCall)]
+	fake.bumpRevisionArgsForCall = append(fake.bumpRevisionArgsForCall, struct{}{})
+	fake.recordInvocation("BumpRevision", []interface{}{})
+	fake.bumpRevisionMutex.Unlock()
+	if fake.BumpRevisionStub != nil {
+		return fake.BumpRevisionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.bumpRevisionReturns.result1
+}
+
+func (fake *RevisionBumper) BumpRevisionCallCount() int {
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
+	return len(fake.bumpRevisionArgsForCall)
+}
+
+func (fake *RevisionBumper) BumpRevisionReturns(result1 error) {
+	fake.BumpRevisionStub = nil
+	fake.bumpRevisionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *RevisionBumper) BumpRevisionReturnsOnCall(i int, result1 error) {
+	fake.BumpRevisionStub = nil
+	if fake.bumpRevisionReturnsOnCall == nil {
+		fake.bumpRevisionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.bumpRevisionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *RevisionBumper) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.bumpRevisionMutex.RLock()
+	defer fake.bumpRevisionMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *RevisionBumper) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
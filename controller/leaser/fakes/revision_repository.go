@@ -0,0 +1,89 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+)
+
+type RevisionRepository struct {
+	RevisionStub        func() (int64, error)
+	revisionMutex       sync.RWMutex
+	revisionArgsForCall []struct{}
+	revisionReturns     struct {
+		result1 int64
+		result2 error
+	}
+	revisionReturnsOnCall map[int]struct {
+		result1 int64
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *RevisionRepository) Revision() (int64, error) {
+	fake.revisionMutex.Lock()
+	ret, specificReturn := fake.revisionReturnsOnCall[len(fake.revisionArgsForCall)]
+	fake.revisionArgsForCall = append(fake.revisionArgsForCall, struct{}{})
+	fake.recordInvocation("Revision", []interface{}{})
+	fake.revisionMutex.Unlock()
+	if fake.RevisionStub != nil {
+		return fake.RevisionStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.revisionReturns.result1, fake.revisionReturns.result2
+}
+
+func (fake *RevisionRepository) RevisionCallCount() int {
+	fake.revisionMutex.RLock()
+	defer fake.revisionMutex.RUnlock()
+	return len(fake.revisionArgsForCall)
+}
+
+func (fake *RevisionRepository) RevisionReturns(result1 int64, result2 error) {
+	fake.RevisionStub = nil
+	fake.revisionReturns = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *RevisionRepository) RevisionReturnsOnCall(i int, result1 int64, result2 error) {
+	fake.RevisionStub = nil
+	if fake.revisionReturnsOnCall == nil {
+		fake.revisionReturnsOnCall = make(map[int]struct {
+			result1 int64
+			result2 error
+		})
+	}
+	fake.revisionReturnsOnCall[i] = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *RevisionRepository) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.revisionMutex.RLock()
+	defer fake.revisionMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *RevisionRepository) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -1,13 +1,50 @@
 package leaser
 
 import (
+	"fmt"
+	"math/big"
 	"net"
 
 	"github.com/containernetworking/plugins/pkg/utils/hwaddr"
 )
 
-type HardwareAddressGenerator struct{}
+type HardwareAddressGenerator struct {
+	IPv6Network            string
+	IPv6SubnetPrefixLength int
+}
 
 func (g *HardwareAddressGenerator) GenerateForVTEP(containerIP net.IP) (net.HardwareAddr, error) {
+	if containerIP.To4() == nil {
+		return g.generateHardwareAddr6(containerIP)
+	}
 	return hwaddr.GenerateHardwareAddr4(containerIP, []byte{0xee, 0xee})
 }
+
+// An IPv6 address does not fit in a MAC, so the last four bytes are the index
+// of the address within the IPv6 network instead. Blocks are indexed by their
+// subnet bits, which the config limits to 32, and get the prefix ee:e6. The
+// first block is never handed out; it holds the single IP leases, which are
+// indexed by their host bits and get the prefix ee:e7.
+func (g *HardwareAddressGenerator) generateHardwareAddr6(containerIP net.IP) (net.HardwareAddr, error) {
+	_, network, err := net.ParseCIDR(g.IPv6Network)
+	if err != nil {
+		return nil, fmt.Errorf("parse ipv6 network: %s", err)
+	}
+	if g.IPv6SubnetPrefixLength <= 0 || g.IPv6SubnetPrefixLength > 8*net.IPv6len {
+		return nil, fmt.Errorf("invalid ipv6 subnet prefix length %d", g.IPv6SubnetPrefixLength)
+	}
+	if !network.Contains(containerIP) {
+		return nil, fmt.Errorf("%s is not in the ipv6 network %s", containerIP, g.IPv6Network)
+	}
+
+	offset := new(big.Int).Sub(ipToInt(containerIP), ipToInt(network.IP))
+	blockIndex := new(big.Int).Rsh(offset, uint(8*net.IPv6len-g.IPv6SubnetPrefixLength))
+	if blockIndex.Sign() == 0 {
+		return hardwareAddr6(0xe7, uint32(offset.Uint64())), nil
+	}
+	return hardwareAddr6(0xe6, uint32(blockIndex.Uint64())), nil
+}
+
+func hardwareAddr6(prefix byte, index uint32) net.HardwareAddr {
+	return net.HardwareAddr{0xee, prefix, byte(index >> 24), byte(index >> 16), byte(index >> 8), byte(index)}
+}
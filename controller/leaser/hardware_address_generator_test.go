@@ -0,0 +1,72 @@
+package leaser_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/controller/leaser"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HardwareAddressGenerator", func() {
+	var generator *leaser.HardwareAddressGenerator
+
+	BeforeEach(func() {
+		generator = &leaser.HardwareAddressGenerator{
+			IPv6Network:            "fd00:10:255::/48",
+			IPv6SubnetPrefixLength: 64,
+		}
+	})
+
+	It("derives the hardware address of an ipv4 vtep from its ip", func() {
+		hwAddr, err := generator.GenerateForVTEP(net.ParseIP("10.255.17.0"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hwAddr.String()).To(Equal("ee:ee:0a:ff:11:00"))
+	})
+
+	It("derives the hardware address of an ipv6 block from its index in the network", func() {
+		hwAddr, err := generator.GenerateForVTEP(net.ParseIP("fd00:10:255:11::"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hwAddr.String()).To(Equal("ee:e6:00:00:00:11"))
+	})
+
+	It("derives the hardware address of an ipv6 single ip from its host bits", func() {
+		hwAddr, err := generator.GenerateForVTEP(net.ParseIP("fd00:10:255::11"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hwAddr.String()).To(Equal("ee:e7:00:00:00:11"))
+	})
+
+	It("gives every block and single ip of the ipv6 network its own hardware address", func() {
+		generator.IPv6Network = "fd00:10::/32"
+		seen := map[string]bool{}
+		for _, ip := range []string{"fd00:10::1", "fd00:10::2", "fd00:10:0:1::", "fd00:10:0:2::", "fd00:10:1::", "fd00:10:ffff:ffff::"} {
+			hwAddr, err := generator.GenerateForVTEP(net.ParseIP(ip))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(seen).NotTo(HaveKey(hwAddr.String()))
+			seen[hwAddr.String()] = true
+		}
+	})
+
+	Context("when the ip is not in the ipv6 network", func() {
+		It("returns an error", func() {
+			_, err := generator.GenerateForVTEP(net.ParseIP("fd00:11::"))
+			Expect(err).To(MatchError("fd00:11:: is not in the ipv6 network fd00:10:255::/48"))
+		})
+	})
+
+	Context("when the ipv6 network is not set", func() {
+		It("returns an error", func() {
+			generator.IPv6Network = ""
+			_, err := generator.GenerateForVTEP(net.ParseIP("fd00:10:255:11::"))
+			Expect(err).To(MatchError(HavePrefix("parse ipv6 network: ")))
+		})
+	})
+
+	Context("when the ipv6 subnet prefix length is not set", func() {
+		It("returns an error", func() {
+			generator.IPv6SubnetPrefixLength = 0
+			_, err := generator.GenerateForVTEP(net.ParseIP("fd00:10:255:11::"))
+			Expect(err).To(MatchError("invalid ipv6 subnet prefix length 0"))
+		})
+	})
+})
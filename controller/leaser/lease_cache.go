@@ -0,0 +1,124 @@
+package leaser
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_record_lister.go --fake-name LeaseRecordLister . leaseRecordLister
+type leaseRecordLister interface {
+	AllLeaseRecords(int) ([]controller.LeaseRecord, error)
+}
+
+// LeaseCache keeps a snapshot of the subnets table in memory. It is reloaded
+// every RefreshInterval to pick up writes made by other controllers, and on
+// the next read after Invalidate for writes made by this one.
+type LeaseCache struct {
+	DatabaseHandler leaseRecordLister
+	RefreshInterval time.Duration
+	Logger          lager.Logger
+
+	mutex   sync.Mutex
+	records []controller.LeaseRecord
+	loaded  bool
+}
+
+func (l *LeaseCache) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if err := l.refresh(); err != nil {
+		return err
+	}
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-time.After(l.RefreshInterval):
+			if err := l.refresh(); err != nil {
+				l.Logger.Error("refresh-lease-cache", err)
+			}
+		}
+	}
+}
+
+func (l *LeaseCache) Invalidate() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.loaded = false
+}
+
+func (l *LeaseCache) All() ([]controller.Lease, error) {
+	records, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	leases := []controller.Lease{}
+	for _, record := range records {
+		leases = append(leases, leaseFromRecord(record))
+	}
+	return leases, nil
+}
+
+// AllActive uses the local clock, so it is only as accurate as the clock of
+// the controller is in sync with the clock of the database.
+func (l *LeaseCache) AllActive(duration int) ([]controller.Lease, error) {
+	records, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	leases := []controller.Lease{}
+	for _, record := range records {
+		if record.LastRenewedAt+int64(duration) > now {
+			leases = append(leases, leaseFromRecord(record))
+		}
+	}
+	return leases, nil
+}
+
+func (l *LeaseCache) snapshot() ([]controller.LeaseRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.loaded {
+		if err := l.load(); err != nil {
+			return nil, err
+		}
+	}
+	return l.records, nil
+}
+
+func (l *LeaseCache) refresh() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.load()
+}
+
+func (l *LeaseCache) load() error {
+	records, err := l.DatabaseHandler.AllLeaseRecords(0)
+	if err != nil {
+		return fmt.Errorf("loading leases: %s", err)
+	}
+
+	l.records = records
+	l.loaded = true
+	return nil
+}
+
+func leaseFromRecord(record controller.LeaseRecord) controller.Lease {
+	return controller.Lease{
+		UnderlayIP:          record.UnderlayIP,
+		OverlaySubnet:       record.OverlaySubnet,
+		OverlayHardwareAddr: record.OverlayHardwareAddr,
+		LeaseMetadata:       record.LeaseMetadata,
+	}
+}
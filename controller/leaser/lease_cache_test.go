@@ -0,0 +1,149 @@
+package leaser_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	"code.cloudfoundry.org/silk/controller/leaser/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("LeaseCache", func() {
+	var (
+		leaseRecordLister *fakes.LeaseRecordLister
+		logger            *lagertest.TestLogger
+		leaseCache        *leaser.LeaseCache
+		activeLease       controller.Lease
+		expiredLease      controller.Lease
+	)
+
+	BeforeEach(func() {
+		activeLease = controller.Lease{
+			UnderlayIP:          "10.244.5.9",
+			OverlaySubnet:       "10.255.16.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+		}
+		expiredLease = controller.Lease{
+			UnderlayIP:          "10.244.22.33",
+			OverlaySubnet:       "10.255.75.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:4b:00",
+		}
+		leaseRecordLister = &fakes.LeaseRecordLister{}
+		leaseRecordLister.AllLeaseRecordsReturns([]controller.LeaseRecord{
+			{
+				UnderlayIP:          activeLease.UnderlayIP,
+				OverlaySubnet:       activeLease.OverlaySubnet,
+				OverlayHardwareAddr: activeLease.OverlayHardwareAddr,
+				LastRenewedAt:       time.Now().Unix(),
+			},
+			{
+				UnderlayIP:          expiredLease.UnderlayIP,
+				OverlaySubnet:       expiredLease.OverlaySubnet,
+				OverlayHardwareAddr: expiredLease.OverlayHardwareAddr,
+				LastRenewedAt:       time.Now().Add(-time.Hour).Unix(),
+			},
+		}, nil)
+		logger = lagertest.NewTestLogger("test")
+		leaseCache = &leaser.LeaseCache{
+			DatabaseHandler: leaseRecordLister,
+			RefreshInterval: 10 * time.Millisecond,
+			Logger:          logger,
+		}
+	})
+
+	Describe("All", func() {
+		It("loads the leases once and serves them from memory", func() {
+			leases, err := leaseCache.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{activeLease, expiredLease}))
+
+			_, err = leaseCache.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leaseRecordLister.AllLeaseRecordsCallCount()).To(Equal(1))
+		})
+
+		Context("when loading the leases fails", func() {
+			BeforeEach(func() {
+				leaseRecordLister.AllLeaseRecordsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				_, err := leaseCache.All()
+				Expect(err).To(MatchError("loading leases: banana"))
+			})
+		})
+	})
+
+	Describe("AllActive", func() {
+		It("returns the leases renewed within the duration", func() {
+			leases, err := leaseCache.AllActive(60)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{activeLease}))
+
+			leases, err = leaseCache.AllActive(7200)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{activeLease, expiredLease}))
+		})
+	})
+
+	Describe("Invalidate", func() {
+		It("reloads the leases on the next read", func() {
+			_, err := leaseCache.All()
+			Expect(err).NotTo(HaveOccurred())
+
+			leaseCache.Invalidate()
+			leaseRecordLister.AllLeaseRecordsReturns(nil, nil)
+
+			leases, err := leaseCache.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(BeEmpty())
+			Expect(leaseRecordLister.AllLeaseRecordsCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("loads the leases before becoming ready and refreshes them periodically", func() {
+			process = ifrit.Invoke(leaseCache)
+			Expect(leaseRecordLister.AllLeaseRecordsCallCount()).To(BeNumerically(">=", 1))
+
+			leaseRecordLister.AllLeaseRecordsReturns(nil, nil)
+			Eventually(leaseCache.All).Should(BeEmpty())
+		})
+
+		Context("when a refresh fails", func() {
+			It("logs the error and keeps serving the last snapshot", func() {
+				process = ifrit.Invoke(leaseCache)
+
+				leaseRecordLister.AllLeaseRecordsReturns(nil, errors.New("banana"))
+				Eventually(logger).Should(gbytes.Say("test.refresh-lease-cache.*banana"))
+
+				leases, err := leaseCache.All()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leases).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("when the initial load fails", func() {
+		It("exits with the error", func() {
+			leaseRecordLister.AllLeaseRecordsReturns(nil, errors.New("banana"))
+
+			process := ifrit.Background(leaseCache)
+			Eventually(process.Wait()).Should(Receive(MatchError("loading leases: banana")))
+		})
+	})
+})
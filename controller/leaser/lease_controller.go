@@ -1,8 +1,10 @@
 package leaser
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/silk/controller"
@@ -13,15 +15,28 @@ import (
 type databaseHandler interface {
 	AddEntry(controller.Lease) error
 	DeleteEntry(string) error
-	LeaseForUnderlayIP(string) (*controller.Lease, error)
-	LastRenewedAtForUnderlayIP(string) (int64, error)
-	RenewLeaseForUnderlayIP(string) error
+	DeleteEntryForOverlaySubnet(string) error
+	DeleteExpiredEntry(string, int) error
+	QuarantineExpiredEntry(string, int) error
+	DeleteReclaimableEntry(string, int, int) error
+	LeaseForUnderlayIP(string, bool) (*controller.Lease, error)
+	LastRenewedAtForUnderlayIP(string, bool) (int64, error)
+	RenewLeaseForUnderlayIP(string, bool) error
+	UpdateLeaseMetadata(controller.Lease) error
 	All() ([]controller.Lease, error)
-	AllBlockSubnets() ([]controller.Lease, error)
-	AllSingleIPSubnets() ([]controller.Lease, error)
 	AllActive(int) ([]controller.Lease, error)
-	OldestExpiredBlockSubnet(int) (*controller.Lease, error)
-	OldestExpiredSingleIP(int) (*controller.Lease, error)
+	QueryLeases(controller.LeaseQuery, int) ([]controller.Lease, string, error)
+	BeginLeaseTransaction(bool, bool) (database.LeaseTransaction, error)
+	AddLeaseEvent(string, controller.Lease) error
+	LeaseEventsForOverlaySubnet(string) ([]controller.LeaseEvent, error)
+	LeaseForOverlaySubnet(string) (*controller.Lease, error)
+	AllLeaseRecords(int) ([]controller.LeaseRecord, error)
+	LeaseRecordContainingIP(string, int) (*controller.LeaseRecord, error)
+	AddReservation(string) error
+	DeleteReservation(string) error
+	AllReservations() ([]string, error)
+	ReleasedSubnets() ([]string, error)
+	BumpRevision() error
 }
 
 //go:generate counterfeiter -o fakes/lease_validator.go --fake-name LeaseValidator . leaseValidator
@@ -34,6 +49,19 @@ type cidrPool interface {
 	GetAvailableBlock([]string) string
 	GetAvailableSingleIP([]string) string
 	IsMember(string) bool
+	IsExcluded(string) bool
+	BlockPoolSize() int
+}
+
+type leaseLister interface {
+	All() ([]controller.Lease, error)
+	AllActive(int) ([]controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/lease_cache.go --fake-name LeaseCache . leaseCache
+type leaseCache interface {
+	leaseLister
+	Invalidate()
 }
 
 //go:generate counterfeiter -o fakes/hardwareAddressGenerator.go --fake-name HardwareAddressGenerator . hardwareAddressGenerator
@@ -41,17 +69,39 @@ type hardwareAddressGenerator interface {
 	GenerateForVTEP(containerIP net.IP) (net.HardwareAddr, error)
 }
 
+// errLeaseQuarantined reports an acquisition attempt that quarantined an
+// expired lease instead of taking its subnet.
+var errLeaseQuarantined = errors.New("lease quarantined")
+
 type LeaseController struct {
 	DatabaseHandler            databaseHandler
 	HardwareAddressGenerator   hardwareAddressGenerator
 	AcquireSubnetLeaseAttempts int
 	CIDRPool                   cidrPool
+	IPv6CIDRPool               cidrPool
+	AZPools                    *AZPools
+	IPv6AZPools                *AZPools
+	StaticReservations         StaticReservations
 	LeaseValidator             leaseValidator
 	LeaseExpirationSeconds     int
+	ReclaimQuarantineSeconds   int
+	AllocationStrategy         AllocationStrategy
+	LeaseCache                 leaseCache
 	Logger                     lager.Logger
 }
 
 func (c *LeaseController) ReleaseSubnetLease(underlayIP string) error {
+	var leases []controller.Lease
+	for _, ipv6Overlay := range []bool{false, true} {
+		lease, err := c.DatabaseHandler.LeaseForUnderlayIP(underlayIP, ipv6Overlay)
+		if err != nil {
+			return fmt.Errorf("getting lease for underlay ip: %s", err)
+		}
+		if lease != nil {
+			leases = append(leases, *lease)
+		}
+	}
+
 	err := c.DatabaseHandler.DeleteEntry(underlayIP)
 	if err == database.RecordNotAffectedError {
 		c.Logger.Debug("lease-not-found", lager.Data{"underlay_ip": underlayIP})
@@ -61,37 +111,82 @@ func (c *LeaseController) ReleaseSubnetLease(underlayIP string) error {
 		return fmt.Errorf("release lease: %s", err)
 	}
 
+	c.leasesChanged()
+	for _, lease := range leases {
+		c.recordLeaseEvent(controller.LeaseEventRelease, lease)
+	}
+
 	c.Logger.Info("lease-released", lager.Data{"underlay_ip": underlayIP})
 	return err
 }
 
-func (c *LeaseController) AcquireSubnetLease(underlayIP string, singleOverlayIP bool) (*controller.Lease, error) {
+func (c *LeaseController) AcquireSubnetLease(underlayIP string, singleOverlayIP, ipv6Overlay bool) (*controller.Lease, error) {
+	return c.AcquireSubnetLeaseWithMetadata(underlayIP, singleOverlayIP, ipv6Overlay, controller.LeaseMetadata{})
+}
+
+// AcquireSubnetLeaseWithMetadata acquires a lease like AcquireSubnetLease and
+// stores the metadata with it, replacing the metadata of an existing lease.
+func (c *LeaseController) AcquireSubnetLeaseWithMetadata(underlayIP string, singleOverlayIP, ipv6Overlay bool, metadata controller.LeaseMetadata) (*controller.Lease, error) {
 	var err error
 	var lease *controller.Lease
 
-	if net.ParseIP(underlayIP).To4() == nil {
-		return nil, fmt.Errorf("invalid ipv4 address: %s", underlayIP)
+	if net.ParseIP(underlayIP) == nil {
+		return nil, fmt.Errorf("invalid underlay ip: %s", underlayIP)
 	}
 
-	lease, err = c.DatabaseHandler.LeaseForUnderlayIP(underlayIP)
+	pool := c.CIDRPool
+	if ipv6Overlay {
+		if c.IPv6CIDRPool == nil {
+			return nil, controller.NonRetriableError("ipv6 overlay network is not configured")
+		}
+		pool = c.IPv6CIDRPool
+	}
+
+	staticSubnet, hasStaticSubnet := c.StaticReservations.SubnetFor(underlayIP, ipv6Overlay)
+
+	lease, err = c.DatabaseHandler.LeaseForUnderlayIP(underlayIP, ipv6Overlay)
 	if err != nil {
 		return nil, fmt.Errorf("getting lease for underlay ip: %s", err)
 	}
 
 	if lease != nil {
-		if c.CIDRPool.IsMember(lease.OverlaySubnet) {
+		valid := pool.IsMember(lease.OverlaySubnet) && c.StaticReservations.Allows(underlayIP, lease.OverlaySubnet)
+		if hasStaticSubnet {
+			valid = lease.OverlaySubnet == staticSubnet
+		}
+		if valid {
+			if !lease.LeaseMetadata.Equal(metadata) {
+				lease.LeaseMetadata = metadata
+				if err := c.DatabaseHandler.UpdateLeaseMetadata(*lease); err != nil {
+					return nil, fmt.Errorf("updating lease metadata: %s", err)
+				}
+				c.leasesChanged()
+			}
 			c.Logger.Info("lease-renewed", lager.Data{"lease": lease})
 			return lease, nil
 		}
-		err := c.DatabaseHandler.DeleteEntry(underlayIP)
+		err := c.DatabaseHandler.DeleteEntryForOverlaySubnet(lease.OverlaySubnet)
 		if err != nil {
 			return nil, fmt.Errorf("deleting lease for underlay ip %s: %s", underlayIP, err)
 		}
+		c.leasesChanged()
+		c.recordLeaseEvent(controller.LeaseEventRelease, *lease)
 		c.Logger.Info("lease-deleted", lager.Data{"lease": lease})
 	}
 
+	quarantine := c.ReclaimQuarantineSeconds > 0
 	for numErrs := 0; numErrs < c.AcquireSubnetLeaseAttempts; numErrs++ {
-		lease, err = c.tryAcquireLease(underlayIP, singleOverlayIP)
+		if hasStaticSubnet {
+			lease, err = c.tryAcquireStaticLease(underlayIP, staticSubnet, ipv6Overlay, quarantine, metadata)
+		} else {
+			lease, err = c.tryAcquireLeaseFromPools(underlayIP, singleOverlayIP, ipv6Overlay, quarantine, c.allocationPools(pool, metadata.AZ, singleOverlayIP, ipv6Overlay), metadata)
+		}
+		if err == errLeaseQuarantined {
+			// keep looking, but quarantine at most one lease per request
+			quarantine = false
+			err = nil
+			continue
+		}
 		if lease != nil {
 			c.Logger.Info("lease-acquired", lager.Data{"lease": lease})
 			return lease, nil
@@ -107,7 +202,8 @@ func (c *LeaseController) RenewSubnetLease(lease controller.Lease) error {
 		return controller.NonRetriableError(err.Error())
 	}
 
-	existingLease, err := c.DatabaseHandler.LeaseForUnderlayIP(lease.UnderlayIP)
+	ipv6Overlay := isIPv6Subnet(lease.OverlaySubnet)
+	existingLease, err := c.DatabaseHandler.LeaseForUnderlayIP(lease.UnderlayIP, ipv6Overlay)
 	if err != nil {
 		return fmt.Errorf("getting lease for underlay ip: %s", err)
 	}
@@ -116,15 +212,23 @@ func (c *LeaseController) RenewSubnetLease(lease controller.Lease) error {
 		if err != nil {
 			return controller.NonRetriableError(err.Error())
 		}
-	} else if lease != *existingLease {
+		c.leasesChanged()
+	} else if !lease.SameAddresses(*existingLease) {
+		c.recordLeaseEvent(controller.LeaseEventRenewMismatch, lease)
 		return controller.NonRetriableError("lease mismatch")
+	} else if !lease.LeaseMetadata.Equal(existingLease.LeaseMetadata) {
+		err := c.DatabaseHandler.UpdateLeaseMetadata(lease)
+		if err != nil {
+			return fmt.Errorf("updating lease metadata: %s", err)
+		}
+		c.leasesChanged()
 	}
 
-	err = c.DatabaseHandler.RenewLeaseForUnderlayIP(lease.UnderlayIP)
+	err = c.DatabaseHandler.RenewLeaseForUnderlayIP(lease.UnderlayIP, ipv6Overlay)
 	if err != nil {
 		return fmt.Errorf("renewing lease for underlay ip: %s", err)
 	}
-	lastRenewedAt, err := c.DatabaseHandler.LastRenewedAtForUnderlayIP(lease.UnderlayIP)
+	lastRenewedAt, err := c.DatabaseHandler.LastRenewedAtForUnderlayIP(lease.UnderlayIP, ipv6Overlay)
 	if err != nil {
 		return fmt.Errorf("getting last renewed at: %s", err)
 	}
@@ -135,7 +239,7 @@ func (c *LeaseController) RenewSubnetLease(lease controller.Lease) error {
 }
 
 func (c *LeaseController) RoutableLeases() ([]controller.Lease, error) {
-	leases, err := c.DatabaseHandler.AllActive(c.LeaseExpirationSeconds)
+	leases, err := c.leaseLister().AllActive(c.LeaseExpirationSeconds)
 	if err != nil {
 		return nil, fmt.Errorf("getting all leases: %s", err)
 	}
@@ -143,23 +247,341 @@ func (c *LeaseController) RoutableLeases() ([]controller.Lease, error) {
 	return leases, nil
 }
 
-func (c *LeaseController) tryAcquireLease(underlayIP string, singleOverlayIP bool) (*controller.Lease, error) {
-	var subnet string
-	if singleOverlayIP {
+// QueryLeases always reads from the database, since the lease cache only
+// holds the active leases.
+func (c *LeaseController) QueryLeases(query controller.LeaseQuery) ([]controller.Lease, string, error) {
+	leases, next, err := c.DatabaseHandler.QueryLeases(query, c.LeaseExpirationSeconds)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying leases: %s", err)
+	}
+
+	return leases, next, nil
+}
+
+func (c *LeaseController) ReleaseOverlaySubnet(overlaySubnet string) error {
+	lease, err := c.DatabaseHandler.LeaseForOverlaySubnet(overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("getting lease for overlay subnet: %s", err)
+	}
+	if lease == nil {
+		c.Logger.Debug("lease-not-found", lager.Data{"overlay_subnet": overlaySubnet})
+		return nil
+	}
+
+	err = c.DatabaseHandler.DeleteEntryForOverlaySubnet(overlaySubnet)
+	if err == database.RecordNotAffectedError {
+		c.Logger.Debug("lease-not-found", lager.Data{"overlay_subnet": overlaySubnet})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("release lease: %s", err)
+	}
+
+	c.leasesChanged()
+	c.recordLeaseEvent(controller.LeaseEventRelease, *lease)
+	c.Logger.Info("lease-released", lager.Data{"lease": lease})
+	return nil
+}
+
+func (c *LeaseController) AllLeases() ([]controller.LeaseRecord, error) {
+	records, err := c.DatabaseHandler.AllLeaseRecords(c.LeaseExpirationSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("getting all lease records: %s", err)
+	}
+
+	return records, nil
+}
+
+// QuarantinedLeases returns the expired leases waiting out their quarantine
+// or already reclaimable.
+func (c *LeaseController) QuarantinedLeases() ([]controller.LeaseRecord, error) {
+	records, err := c.DatabaseHandler.AllLeaseRecords(c.LeaseExpirationSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("getting all lease records: %s", err)
+	}
+
+	quarantined := []controller.LeaseRecord{}
+	for _, record := range records {
+		if record.Quarantined && record.Expired {
+			quarantined = append(quarantined, record)
+		}
+	}
+	return quarantined, nil
+}
+
+// LookupLease returns the lease whose overlay subnet holds the ip, expired
+// or not, or nil if there is none.
+func (c *LeaseController) LookupLease(ip string) (*controller.LeaseRecord, error) {
+	record, err := c.DatabaseHandler.LeaseRecordContainingIP(ip, c.LeaseExpirationSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("looking up lease: %s", err)
+	}
+
+	return record, nil
+}
+
+// ReapExpiredLeases deletes the leases that expired more than
+// gracePeriodSeconds ago. With a reclaim quarantine, it quarantines them
+// first and only deletes them once their quarantine is over. In dry run mode
+// it only logs and returns them.
+func (c *LeaseController) ReapExpiredLeases(gracePeriodSeconds int, dryRun bool) ([]controller.Lease, error) {
+	expirationTime := c.LeaseExpirationSeconds + gracePeriodSeconds
+	records, err := c.DatabaseHandler.AllLeaseRecords(expirationTime)
+	if err != nil {
+		return nil, fmt.Errorf("getting all lease records: %s", err)
+	}
+
+	var reaped []controller.Lease
+	for _, record := range records {
+		if !record.Expired {
+			continue
+		}
+		lease := controller.Lease{
+			UnderlayIP:          record.UnderlayIP,
+			OverlaySubnet:       record.OverlaySubnet,
+			OverlayHardwareAddr: record.OverlayHardwareAddr,
+			LeaseMetadata:       record.LeaseMetadata,
+		}
+		logData := lager.Data{"lease": lease, "last_renewed_at": record.LastRenewedAt}
+
+		if c.ReclaimQuarantineSeconds > 0 && !record.Quarantined {
+			if !dryRun {
+				err := c.quarantineExpiredLease(lease, expirationTime)
+				if err != nil {
+					return reaped, err
+				}
+			}
+			continue
+		}
+		if c.ReclaimQuarantineSeconds > 0 && record.QuarantinedAt+int64(c.ReclaimQuarantineSeconds) > time.Now().Unix() {
+			continue
+		}
+
+		if dryRun {
+			c.Logger.Info("lease-reapable", logData)
+			reaped = append(reaped, lease)
+			continue
+		}
+
 		var err error
-		subnet, err = c.tryAcquireAvailableSingleIPSubnet(underlayIP)
+		if c.ReclaimQuarantineSeconds > 0 {
+			err = c.DatabaseHandler.DeleteReclaimableEntry(lease.OverlaySubnet, expirationTime, c.ReclaimQuarantineSeconds)
+		} else {
+			err = c.DatabaseHandler.DeleteExpiredEntry(lease.OverlaySubnet, expirationTime)
+		}
+		if err == database.RecordNotAffectedError {
+			// renewed or released since the records were read
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return reaped, fmt.Errorf("reap lease: %s", err)
 		}
-	} else {
+
+		c.leasesChanged()
+		c.recordLeaseEvent(controller.LeaseEventReclaim, lease)
+		c.Logger.Info("lease-reaped", logData)
+		reaped = append(reaped, lease)
+	}
+
+	return reaped, nil
+}
+
+func (c *LeaseController) quarantineExpiredLease(lease controller.Lease, expirationTime int) error {
+	err := c.DatabaseHandler.QuarantineExpiredEntry(lease.OverlaySubnet, expirationTime)
+	if err == database.RecordNotAffectedError {
+		// renewed or released since the records were read
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("quarantine lease: %s", err)
+	}
+
+	c.recordLeaseEvent(controller.LeaseEventQuarantine, lease)
+	c.Logger.Info("lease-quarantined", lager.Data{"lease": lease, "quarantine_seconds": c.ReclaimQuarantineSeconds})
+	return nil
+}
+
+// LeasesInExcludedRanges returns the leases whose subnet overlaps an excluded
+// range, e.g. because the range was excluded after the lease was acquired.
+func (c *LeaseController) LeasesInExcludedRanges() ([]controller.Lease, error) {
+	leases, err := c.leaseLister().All()
+	if err != nil {
+		return nil, fmt.Errorf("getting all leases: %s", err)
+	}
+
+	var excluded []controller.Lease
+	for _, lease := range leases {
+		if c.isExcluded(lease.OverlaySubnet) {
+			excluded = append(excluded, lease)
+		}
+	}
+	return excluded, nil
+}
+
+func (c *LeaseController) ReserveSubnet(overlaySubnet string) error {
+	if !c.isMember(overlaySubnet) {
+		return controller.NonRetriableError(fmt.Sprintf("subnet %s is not in the overlay network", overlaySubnet))
+	}
+
+	err := c.DatabaseHandler.AddReservation(overlaySubnet)
+	if err != nil {
+		return fmt.Errorf("reserve subnet: %s", err)
+	}
+
+	c.Logger.Info("subnet-reserved", lager.Data{"overlay_subnet": overlaySubnet})
+	return nil
+}
+
+func (c *LeaseController) UnreserveSubnet(overlaySubnet string) error {
+	err := c.DatabaseHandler.DeleteReservation(overlaySubnet)
+	if err == database.RecordNotAffectedError {
+		c.Logger.Debug("reservation-not-found", lager.Data{"overlay_subnet": overlaySubnet})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unreserve subnet: %s", err)
+	}
+
+	c.Logger.Info("subnet-unreserved", lager.Data{"overlay_subnet": overlaySubnet})
+	return nil
+}
+
+func (c *LeaseController) Reservations() ([]string, error) {
+	reservations, err := c.DatabaseHandler.AllReservations()
+	if err != nil {
+		return nil, fmt.Errorf("getting all reservations: %s", err)
+	}
+
+	return reservations, nil
+}
+
+func (c *LeaseController) LeaseHistory(overlaySubnet string) ([]controller.LeaseEvent, error) {
+	events, err := c.DatabaseHandler.LeaseEventsForOverlaySubnet(overlaySubnet)
+	if err != nil {
+		return nil, fmt.Errorf("getting lease events: %s", err)
+	}
+
+	return events, nil
+}
+
+// recordLeaseEvent is best effort: the history is for debugging, so failing
+// to write it must not fail the lease operation that already happened.
+func (c *LeaseController) recordLeaseEvent(eventType string, lease controller.Lease) {
+	err := c.DatabaseHandler.AddLeaseEvent(eventType, lease)
+	if err != nil {
+		c.Logger.Error("record-lease-event", err, lager.Data{"event_type": eventType, "lease": lease})
+	}
+}
+
+// leasesChanged is called after a lease is added or removed outside of a
+// lease transaction. Bumping the revision is best effort as well: watchers
+// that miss a change pick it up when their watch times out.
+func (c *LeaseController) leasesChanged() {
+	c.invalidateLeaseCache()
+
+	err := c.DatabaseHandler.BumpRevision()
+	if err != nil {
+		c.Logger.Error("bump-revision", err)
+	}
+}
+
+// allocationPools returns the pools to allocate a new lease from, in order.
+// Single IP leases always come from the whole pool.
+func (c *LeaseController) allocationPools(pool cidrPool, az string, singleOverlayIP, ipv6Overlay bool) []cidrPool {
+	azPools := c.AZPools
+	if ipv6Overlay {
+		azPools = c.IPv6AZPools
+	}
+	if azPools == nil || singleOverlayIP {
+		return []cidrPool{pool}
+	}
+	return azPools.PoolsFor(az)
+}
+
+// tryAcquireLeaseFromPools only moves on to the next pool when a pool is
+// exhausted, which includes a pool that only had a lease to quarantine. It
+// returns errLeaseQuarantined when none of the pools had a subnet but one of
+// them quarantined a lease.
+func (c *LeaseController) tryAcquireLeaseFromPools(underlayIP string, singleOverlayIP, ipv6Overlay, quarantine bool, pools []cidrPool, metadata controller.LeaseMetadata) (*controller.Lease, error) {
+	var quarantined error
+	for i, pool := range pools {
+		if i > 0 {
+			c.Logger.Info("az-pool-exhausted", lager.Data{"underlay_ip": underlayIP, "az": metadata.AZ})
+		}
+		lease, err := c.tryAcquireLease(underlayIP, singleOverlayIP, ipv6Overlay, quarantine && quarantined == nil, pool, metadata)
+		if err == errLeaseQuarantined {
+			quarantined = err
+			continue
+		}
+		if lease != nil || err != nil {
+			return lease, err
+		}
+	}
+	return nil, quarantined
+}
+
+func (c *LeaseController) tryAcquireLease(underlayIP string, singleOverlayIP, ipv6Overlay, quarantine bool, pool cidrPool, metadata controller.LeaseMetadata) (*controller.Lease, error) {
+	var released []string
+	if c.AllocationStrategy == LeastRecentlyUsedAllocation {
 		var err error
-		subnet, err = c.tryAcquireAvailableBlockSubnet(underlayIP)
+		released, err = c.DatabaseHandler.ReleasedSubnets()
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("get released subnets: %s", err)
 		}
 	}
+
+	tx, err := c.DatabaseHandler.BeginLeaseTransaction(singleOverlayIP, ipv6Overlay)
+	if err != nil {
+		return nil, fmt.Errorf("begin lease transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	taken, err := tx.TakenSubnets()
+	if err != nil {
+		return nil, fmt.Errorf("get taken subnets: %s", err)
+	}
+	taken = append(taken, c.StaticReservations.Subnets()...)
+
+	// prefer subnets that were never released, then the least recently
+	// released one
+	neverReleased := append(append([]string{}, taken...), released...)
+	subnet := getAvailable(pool, neverReleased, singleOverlayIP)
+	if subnet == "" && len(released) > 0 {
+		subnet = leastRecentlyReleased(pool, taken, released, singleOverlayIP)
+	}
+
+	var expiredLease *controller.Lease
 	if subnet == "" {
-		return nil, nil
+		// never reclaim a subnet the pool would not hand out, e.g. one in
+		// an excluded range
+		reserved := map[string]bool{}
+		for _, reservedSubnet := range c.StaticReservations.Subnets() {
+			reserved[reservedSubnet] = true
+		}
+		reclaimable := func(subnet string) bool {
+			return pool.IsMember(subnet) && !reserved[subnet]
+		}
+		if c.ReclaimQuarantineSeconds > 0 {
+			var quarantinedLease *controller.Lease
+			expiredLease, quarantinedLease, err = c.reclaimQuarantined(tx, reclaimable, quarantine)
+			if err == nil && quarantinedLease != nil {
+				err = tx.Commit()
+				if err != nil {
+					return nil, fmt.Errorf("commit lease transaction: %s", err)
+				}
+				c.Logger.Info("lease-quarantined", lager.Data{"lease": quarantinedLease, "quarantine_seconds": c.ReclaimQuarantineSeconds})
+				return nil, errLeaseQuarantined
+			}
+		} else {
+			expiredLease, err = tx.OldestExpired(c.LeaseExpirationSeconds, reclaimable)
+			if err != nil {
+				err = fmt.Errorf("get oldest expired: %s", err)
+			}
+		}
+		if err != nil || expiredLease == nil {
+			return nil, err
+		}
+		subnet = expiredLease.OverlaySubnet
 	}
 
 	vtepIP, _, err := net.ParseCIDR(subnet)
@@ -175,71 +597,226 @@ func (c *LeaseController) tryAcquireLease(underlayIP string, singleOverlayIP boo
 		UnderlayIP:          underlayIP,
 		OverlaySubnet:       subnet,
 		OverlayHardwareAddr: hwAddr.String(),
+		LeaseMetadata:       metadata,
 	}
 
-	err = c.DatabaseHandler.AddEntry(lease)
+	if expiredLease != nil {
+		err = tx.AddLeaseEvent(controller.LeaseEventReclaim, *expiredLease)
+		if err != nil {
+			return nil, fmt.Errorf("adding reclaim event: %s", err)
+		}
+		err = tx.ReassignEntry(lease)
+		if err != nil {
+			return nil, fmt.Errorf("reassign expired lease entry: %s", err)
+		}
+	} else {
+		err = tx.AddEntry(lease)
+		if err != nil {
+			return nil, fmt.Errorf("adding lease entry: %s", err)
+		}
+	}
+
+	err = tx.AddLeaseEvent(controller.LeaseEventAcquire, lease)
 	if err != nil {
-		return nil, fmt.Errorf("adding lease entry: %s", err)
+		return nil, fmt.Errorf("adding acquire event: %s", err)
 	}
+
+	err = tx.BumpRevision()
+	if err != nil {
+		return nil, fmt.Errorf("updating lease revision: %s", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("commit lease transaction: %s", err)
+	}
+	c.invalidateLeaseCache()
 	return &lease, nil
 }
 
-func (c *LeaseController) tryAcquireAvailableSingleIPSubnet(underlayIP string) (string, error) {
-	var subnet string
-	leases, err := c.DatabaseHandler.AllSingleIPSubnets()
+// reclaimQuarantined returns the oldest lease that outlived its quarantine.
+// Without one, and when quarantine is set, it quarantines the oldest expired
+// lease in the transaction, so its daemon gets ReclaimQuarantineSeconds to
+// renew it before the subnet goes to another cell, and returns it as the
+// second lease.
+func (c *LeaseController) reclaimQuarantined(tx database.LeaseTransaction, reclaimable func(string) bool, quarantine bool) (*controller.Lease, *controller.Lease, error) {
+	lease, err := tx.OldestReclaimable(c.LeaseExpirationSeconds, c.ReclaimQuarantineSeconds, reclaimable)
 	if err != nil {
-		return "", fmt.Errorf("getting all single ip subnets: %s", err)
+		return nil, nil, fmt.Errorf("get oldest reclaimable: %s", err)
 	}
-	var taken []string
-	for _, lease := range leases {
-		taken = append(taken, lease.OverlaySubnet)
+	if lease != nil || !quarantine {
+		return lease, nil, nil
 	}
 
-	subnet = c.CIDRPool.GetAvailableSingleIP(taken)
-	if subnet == "" {
-		lease, err := c.DatabaseHandler.OldestExpiredSingleIP(c.LeaseExpirationSeconds)
-		if err != nil {
-			return "", fmt.Errorf("get oldest expired single ip: %s", err)
-		} else if lease == nil {
-			return "", nil
-		} else {
-			err := c.DatabaseHandler.DeleteEntry(lease.UnderlayIP)
-			if err != nil {
-				return "", fmt.Errorf("delete expired subnet: %s", err)
-			}
-			subnet = lease.OverlaySubnet
-		}
+	lease, err = tx.QuarantineOldestExpired(c.LeaseExpirationSeconds, reclaimable)
+	if err != nil {
+		return nil, nil, fmt.Errorf("quarantine oldest expired: %s", err)
+	}
+	if lease == nil {
+		return nil, nil, nil
 	}
 
-	return subnet, nil
+	err = tx.AddLeaseEvent(controller.LeaseEventQuarantine, *lease)
+	if err != nil {
+		return nil, nil, fmt.Errorf("adding quarantine event: %s", err)
+	}
+	return nil, lease, nil
 }
 
-func (c *LeaseController) tryAcquireAvailableBlockSubnet(underlayIP string) (string, error) {
-	var subnet string
-	leases, err := c.DatabaseHandler.AllBlockSubnets()
+// tryAcquireStaticLease takes the subnet reserved for the underlay ip. A
+// holder that is not entitled to the reservation, for example one that got
+// the subnet before it was reserved, loses it right away, while a holder
+// matching the same underlay CIDR keeps it until its lease expires and, with
+// a reclaim quarantine, until the quarantine is over as well.
+func (c *LeaseController) tryAcquireStaticLease(underlayIP, subnet string, ipv6Overlay, quarantine bool, metadata controller.LeaseMetadata) (*controller.Lease, error) {
+	vtepIP, vtepNet, err := net.ParseCIDR(subnet)
 	if err != nil {
-		return "", fmt.Errorf("getting all subnets: %s", err)
+		return nil, fmt.Errorf("parse subnet: %s", err)
 	}
-	var taken []string
-	for _, lease := range leases {
-		taken = append(taken, lease.OverlaySubnet)
+	ones, bits := vtepNet.Mask.Size()
+
+	tx, err := c.DatabaseHandler.BeginLeaseTransaction(ones == bits, ipv6Overlay)
+	if err != nil {
+		return nil, fmt.Errorf("begin lease transaction: %s", err)
 	}
+	defer tx.Rollback()
 
-	subnet = c.CIDRPool.GetAvailableBlock(taken)
-	if subnet == "" {
-		lease, err := c.DatabaseHandler.OldestExpiredBlockSubnet(c.LeaseExpirationSeconds)
+	holder, err := tx.LeaseRecordForOverlaySubnet(subnet, c.LeaseExpirationSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("get static subnet holder: %s", err)
+	}
+	if holder != nil && !holder.Expired && c.StaticReservations.Allows(holder.UnderlayIP, subnet) {
+		return nil, fmt.Errorf("static subnet %s is held by %s", subnet, holder.UnderlayIP)
+	}
+	if holder != nil && c.StaticReservations.Allows(holder.UnderlayIP, subnet) && c.ReclaimQuarantineSeconds > 0 {
+		isSubnet := func(overlaySubnet string) bool {
+			return overlaySubnet == subnet
+		}
+		reclaimable, quarantinedLease, err := c.reclaimQuarantined(tx, isSubnet, quarantine)
 		if err != nil {
-			return "", fmt.Errorf("get oldest expired: %s", err)
-		} else if lease == nil {
-			return "", nil
-		} else {
-			err := c.DatabaseHandler.DeleteEntry(lease.UnderlayIP)
+			return nil, err
+		}
+		if quarantinedLease != nil {
+			err = tx.Commit()
 			if err != nil {
-				return "", fmt.Errorf("delete expired subnet: %s", err) // test
+				return nil, fmt.Errorf("commit lease transaction: %s", err)
 			}
-			subnet = lease.OverlaySubnet
+			c.Logger.Info("lease-quarantined", lager.Data{"lease": quarantinedLease, "quarantine_seconds": c.ReclaimQuarantineSeconds})
+			return nil, errLeaseQuarantined
+		}
+		if reclaimable == nil {
+			return nil, fmt.Errorf("static subnet %s is quarantined for %s", subnet, holder.UnderlayIP)
+		}
+	}
+
+	hwAddr, err := c.HardwareAddressGenerator.GenerateForVTEP(vtepIP)
+	if err != nil {
+		return nil, fmt.Errorf("generate hardware address: %s", err)
+	}
+
+	lease := controller.Lease{
+		UnderlayIP:          underlayIP,
+		OverlaySubnet:       subnet,
+		OverlayHardwareAddr: hwAddr.String(),
+		LeaseMetadata:       metadata,
+	}
+
+	if holder != nil {
+		err = tx.AddLeaseEvent(controller.LeaseEventReclaim, controller.Lease{
+			UnderlayIP:          holder.UnderlayIP,
+			OverlaySubnet:       holder.OverlaySubnet,
+			OverlayHardwareAddr: holder.OverlayHardwareAddr,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("adding reclaim event: %s", err)
+		}
+		err = tx.ReassignEntry(lease)
+		if err != nil {
+			return nil, fmt.Errorf("reassign static lease entry: %s", err)
+		}
+	} else {
+		err = tx.AddEntry(lease)
+		if err != nil {
+			return nil, fmt.Errorf("adding lease entry: %s", err)
+		}
+	}
+
+	err = tx.AddLeaseEvent(controller.LeaseEventAcquire, lease)
+	if err != nil {
+		return nil, fmt.Errorf("adding acquire event: %s", err)
+	}
+
+	err = tx.BumpRevision()
+	if err != nil {
+		return nil, fmt.Errorf("updating lease revision: %s", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("commit lease transaction: %s", err)
+	}
+	c.invalidateLeaseCache()
+	return &lease, nil
+}
+
+func (c *LeaseController) invalidateLeaseCache() {
+	if c.LeaseCache != nil {
+		c.LeaseCache.Invalidate()
+	}
+}
+
+// leaseLister serves reads from the lease cache when there is one.
+func (c *LeaseController) leaseLister() leaseLister {
+	if c.LeaseCache != nil {
+		return c.LeaseCache
+	}
+	return c.DatabaseHandler
+}
+
+func (c *LeaseController) isMember(overlaySubnet string) bool {
+	if c.CIDRPool.IsMember(overlaySubnet) {
+		return true
+	}
+	return c.IPv6CIDRPool != nil && c.IPv6CIDRPool.IsMember(overlaySubnet)
+}
+
+func (c *LeaseController) isExcluded(overlaySubnet string) bool {
+	if c.CIDRPool.IsExcluded(overlaySubnet) {
+		return true
+	}
+	return c.IPv6CIDRPool != nil && c.IPv6CIDRPool.IsExcluded(overlaySubnet)
+}
+
+func getAvailable(pool cidrPool, taken []string, singleOverlayIP bool) string {
+	if singleOverlayIP {
+		return pool.GetAvailableSingleIP(taken)
+	}
+	return pool.GetAvailableBlock(taken)
+}
+
+func leastRecentlyReleased(pool cidrPool, taken, released []string, singleOverlayIP bool) string {
+	isTaken := make(map[string]bool, len(taken))
+	for _, subnet := range taken {
+		isTaken[subnet] = true
+	}
+	for _, subnet := range released {
+		if !isTaken[subnet] && isSingleIPSubnet(subnet) == singleOverlayIP && pool.IsMember(subnet) {
+			return subnet
 		}
 	}
+	return ""
+}
+
+func isSingleIPSubnet(subnet string) bool {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return false
+	}
+	ones, bits := ipNet.Mask.Size()
+	return ones == bits
+}
 
-	return subnet, nil
+func isIPv6Subnet(subnet string) bool {
+	ip, _, err := net.ParseCIDR(subnet)
+	return err == nil && ip.To4() == nil
 }
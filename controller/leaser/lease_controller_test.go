@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 
 	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/config"
 	"code.cloudfoundry.org/silk/controller/database"
+	dbfakes "code.cloudfoundry.org/silk/controller/database/fakes"
 	"code.cloudfoundry.org/silk/controller/leaser"
 	"code.cloudfoundry.org/silk/controller/leaser/fakes"
 
@@ -46,38 +49,34 @@ var _ = Describe("LeaseController", func() {
 	})
 
 	Describe("AcquireSubnetLease", func() {
+		var leaseTransaction *dbfakes.LeaseTransaction
+
 		BeforeEach(func() {
 			leaseController.AcquireSubnetLeaseAttempts = 10
 			leaseController.CIDRPool = cidrPool
-			databaseHandler.AllBlockSubnetsReturns([]controller.Lease{
-				{UnderlayIP: "10.244.11.22", OverlaySubnet: "10.255.33.0/24"},
-				{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.44.0/24"},
-			}, nil)
-			databaseHandler.AllSingleIPSubnetsReturns([]controller.Lease{
-				{UnderlayIP: "10.244.33.44", OverlaySubnet: "10.255.0.11/32"},
-				{UnderlayIP: "10.244.44.55", OverlaySubnet: "10.255.0.12/32"},
-			}, nil)
+			leaseTransaction = &dbfakes.LeaseTransaction{}
+			databaseHandler.BeginLeaseTransactionReturns(leaseTransaction, nil)
+			leaseTransaction.TakenSubnetsReturns([]string{"10.255.33.0/24", "10.255.44.0/24"}, nil)
 			cidrPool.GetAvailableBlockReturns("10.255.76.0/24")
 			cidrPool.GetAvailableSingleIPReturns("10.255.0.13/32")
 		})
 
 		Context("when acquiring a single ip lease", func() {
+			BeforeEach(func() {
+				leaseTransaction.TakenSubnetsReturns([]string{"10.255.0.11/32", "10.255.0.12/32"}, nil)
+			})
+
 			It("acquires a lease successfully and logs the result", func() {
-				lease, err := leaseController.AcquireSubnetLease("10.244.55.66", true)
+				lease, err := leaseController.AcquireSubnetLease("10.244.55.66", true, false)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(lease.OverlaySubnet).To(Equal("10.255.0.13/32"))
-			})
-
-			Context("when retrieving all single ip subnets fails", func() {
-				It("returns an error", func() {
-					databaseHandler.AllSingleIPSubnetsReturns(nil, errors.New("guava"))
-
-					_, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
-					Expect(err).To(MatchError("getting all single ip subnets: guava"))
 
-					Expect(databaseHandler.AllSingleIPSubnetsCallCount()).To(Equal(10))
-					Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
-				})
+				singleOverlayIP, ipv6Overlay := databaseHandler.BeginLeaseTransactionArgsForCall(0)
+				Expect(singleOverlayIP).To(BeTrue())
+				Expect(ipv6Overlay).To(BeFalse())
+				Expect(cidrPool.GetAvailableSingleIPArgsForCall(0)).To(Equal([]string{"10.255.0.11/32", "10.255.0.12/32"}))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
 			})
 
 			Context("when no single ip subnets are free", func() {
@@ -87,15 +86,18 @@ var _ = Describe("LeaseController", func() {
 
 				Context("when there are no single ip expired leases", func() {
 					It("eventually returns an error after failing to find a free subnet", func() {
-						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", true, false)
 						Expect(err).NotTo(HaveOccurred())
 						Expect(lease).To(BeNil())
 
-						Expect(databaseHandler.AllSingleIPSubnetsCallCount()).To(Equal(10))
-						Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+						Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(10))
+						Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+						Expect(leaseTransaction.RollbackCallCount()).To(Equal(10))
 
-						Expect(databaseHandler.OldestExpiredSingleIPCallCount()).To(Equal(10))
-						Expect(databaseHandler.OldestExpiredSingleIPArgsForCall(0)).To(Equal(42))
+						Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(10))
+						expirationTime, _ := leaseTransaction.OldestExpiredArgsForCall(0)
+						Expect(expirationTime).To(Equal(42))
 					})
 				})
 
@@ -108,11 +110,11 @@ var _ = Describe("LeaseController", func() {
 							OverlaySubnet:       "10.255.0.6/32",
 							OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
 						}
-						databaseHandler.OldestExpiredSingleIPReturns(expiredLease, nil)
+						leaseTransaction.OldestExpiredReturns(expiredLease, nil)
 					})
 
-					It("deletes the expired lease and assigns that lease's subnet", func() {
-						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
+					It("reassigns the expired lease's subnet in the same transaction", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", true, false)
 						Expect(err).NotTo(HaveOccurred())
 						Expect(lease).To(Equal(&controller.Lease{
 							UnderlayIP:          "10.244.5.6",
@@ -120,34 +122,38 @@ var _ = Describe("LeaseController", func() {
 							OverlayHardwareAddr: expiredLease.OverlayHardwareAddr,
 						}))
 
-						Expect(databaseHandler.AllSingleIPSubnetsCallCount()).To(Equal(1))
-						Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
-						Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
-						Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal(expiredLease.UnderlayIP))
+						Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+						Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(1))
+						Expect(leaseTransaction.ReassignEntryArgsForCall(0)).To(Equal(*lease))
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+						Expect(databaseHandler.DeleteEntryForOverlaySubnetCallCount()).To(Equal(0))
 
-						Expect(databaseHandler.OldestExpiredSingleIPCallCount()).To(Equal(1))
-						Expect(databaseHandler.OldestExpiredSingleIPArgsForCall(0)).To(Equal(42))
+						Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(1))
+						expirationTime, _ := leaseTransaction.OldestExpiredArgsForCall(0)
+						Expect(expirationTime).To(Equal(42))
 					})
 
 					Context("when getting the oldest expired lease returns an error", func() {
 						BeforeEach(func() {
-							databaseHandler.OldestExpiredSingleIPReturns(nil, errors.New("guava"))
+							leaseTransaction.OldestExpiredReturns(nil, errors.New("guava"))
 						})
 
 						It("returns an error", func() {
-							_, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
-							Expect(err).To(MatchError("get oldest expired single ip: guava"))
+							_, err := leaseController.AcquireSubnetLease("10.244.5.6", true, false)
+							Expect(err).To(MatchError("get oldest expired: guava"))
 						})
 					})
 
-					Context("when deleting the entry errors", func() {
+					Context("when reassigning the entry errors", func() {
 						BeforeEach(func() {
-							databaseHandler.DeleteEntryReturns(errors.New("guava"))
+							leaseTransaction.ReassignEntryReturns(errors.New("guava"))
 						})
 
-						It("returns an error", func() {
-							_, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
-							Expect(err).To(MatchError("delete expired subnet: guava"))
+						It("returns an error and does not commit", func() {
+							_, err := leaseController.AcquireSubnetLease("10.244.5.6", true, false)
+							Expect(err).To(MatchError("reassign expired lease entry: guava"))
+							Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+							Expect(leaseTransaction.RollbackCallCount()).To(Equal(10))
 						})
 					})
 				})
@@ -155,7 +161,7 @@ var _ = Describe("LeaseController", func() {
 		})
 
 		It("acquires a lease and logs the success", func() {
-			lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+			lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(lease.UnderlayIP).To(Equal("10.244.5.6"))
 			Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
@@ -166,26 +172,179 @@ var _ = Describe("LeaseController", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(loggedLease).To(MatchJSON(`{"underlay_ip":"10.244.5.6","overlay_subnet":"10.255.76.0/24","overlay_hardware_addr":"ee:ee:0a:ff:4c:00"}`))
 
-			Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(1))
+			Expect(databaseHandler.BeginLeaseTransactionCallCount()).To(Equal(1))
+			singleOverlayIP, ipv6Overlay := databaseHandler.BeginLeaseTransactionArgsForCall(0)
+			Expect(singleOverlayIP).To(BeFalse())
+			Expect(ipv6Overlay).To(BeFalse())
+
+			Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(1))
 			Expect(cidrPool.GetAvailableBlockCallCount()).To(Equal(1))
 			Expect(cidrPool.GetAvailableBlockArgsForCall(0)).To(Equal([]string{"10.255.33.0/24", "10.255.44.0/24"}))
-			Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
+			Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
 
-			savedLease := databaseHandler.AddEntryArgsForCall(0)
+			savedLease := leaseTransaction.AddEntryArgsForCall(0)
 			Expect(savedLease.UnderlayIP).To(Equal("10.244.5.6"))
 			Expect(savedLease.OverlaySubnet).To(Equal("10.255.76.0/24"))
 			Expect(savedLease.OverlayHardwareAddr).To(Equal("ee:ee:0a:ff:4c:00"))
+
+			Expect(leaseTransaction.AddLeaseEventCallCount()).To(Equal(1))
+			eventType, eventLease := leaseTransaction.AddLeaseEventArgsForCall(0)
+			Expect(eventType).To(Equal("acquire"))
+			Expect(eventLease).To(Equal(savedLease))
+
+			Expect(leaseTransaction.BumpRevisionCallCount()).To(Equal(1))
+			Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+			Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+		})
+
+		It("stores the lease metadata with the lease", func() {
+			metadata := controller.LeaseMetadata{
+				CellID: "diego-cell/0",
+				AZ:     "z1",
+				Labels: map[string]string{"rack": "r3"},
+			}
+			lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, metadata)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lease.LeaseMetadata).To(Equal(metadata))
+
+			Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
+			Expect(leaseTransaction.AddEntryArgsForCall(0).LeaseMetadata).To(Equal(metadata))
+		})
+
+		Context("when the az of the cell has a subnet range", func() {
+			var zoneRange []string
+
+			BeforeEach(func() {
+				pool := leaser.NewCIDRPool("10.255.0.0/16", 24)
+				pool.SetAllocationStrategy(leaser.SequentialAllocation)
+				leaseController.AZPools = leaser.NewAZPools(pool, []config.AZSubnetRange{
+					{AZ: "z1", Network: "10.255.64.0/18"},
+				}, false)
+				leaseTransaction.TakenSubnetsReturns(nil, nil)
+
+				zoneRange = nil
+				for i := 64; i < 128; i++ {
+					zoneRange = append(zoneRange, fmt.Sprintf("10.255.%d.0/24", i))
+				}
+			})
+
+			It("allocates from the range of the az", func() {
+				lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlaySubnet).To(Equal("10.255.64.0/24"))
+				Expect(cidrPool.GetAvailableBlockCallCount()).To(Equal(0))
+			})
+
+			It("allocates outside of the az ranges for other cells", func() {
+				lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z2"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlaySubnet).To(Equal("10.255.1.0/24"))
+			})
+
+			It("allocates single ips from the whole pool", func() {
+				lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", true, false, controller.LeaseMetadata{AZ: "z1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlaySubnet).To(Equal("10.255.0.13/32"))
+			})
+
+			Context("when the range is exhausted", func() {
+				BeforeEach(func() {
+					leaseTransaction.TakenSubnetsReturns(zoneRange, nil)
+				})
+
+				It("does not acquire a lease", func() {
+					lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z1"})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease).To(BeNil())
+				})
+
+				It("only reclaims expired leases within the range", func() {
+					leaseTransaction.TakenSubnetsReturns(append(zoneRange, "10.255.1.0/24"), nil)
+
+					_, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z1"})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(leaseTransaction.OldestExpiredCallCount()).To(BeNumerically(">", 0))
+					_, reclaimable := leaseTransaction.OldestExpiredArgsForCall(0)
+					Expect(reclaimable("10.255.1.0/24")).To(BeFalse())
+					Expect(reclaimable(zoneRange[0])).To(BeTrue())
+				})
+
+				Context("when falling back to the shared pool", func() {
+					BeforeEach(func() {
+						leaseController.AZPools.Fallback = true
+					})
+
+					Context("when a reclaim quarantine is configured", func() {
+						BeforeEach(func() {
+							leaseController.ReclaimQuarantineSeconds = 300
+							leaseTransaction.QuarantineOldestExpiredReturns(&controller.Lease{
+								UnderlayIP:          "10.244.5.60",
+								OverlaySubnet:       "10.255.64.0/24",
+								OverlayHardwareAddr: "ee:ee:0a:ff:40:00",
+							}, nil)
+						})
+
+						It("quarantines a lease of the range and still allocates from the shared pool", func() {
+							lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z1"})
+							Expect(err).NotTo(HaveOccurred())
+							Expect(lease.OverlaySubnet).To(Equal("10.255.1.0/24"))
+							Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(1))
+						})
+					})
+
+					It("allocates from the shared pool and logs it", func() {
+						lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, controller.LeaseMetadata{AZ: "z1"})
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.1.0/24"))
+						Expect(logger.Logs()[0].Message).To(Equal("test.az-pool-exhausted"))
+						Expect(logger.Logs()[0].Data["az"]).To(Equal("z1"))
+					})
+				})
+			})
+		})
+
+		Context("when bumping the revision fails", func() {
+			It("returns an error and does not commit", func() {
+				leaseTransaction.BumpRevisionReturns(errors.New("kiwi"))
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("updating lease revision: kiwi"))
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when adding the acquire event fails", func() {
+			It("returns an error and does not commit", func() {
+				leaseTransaction.AddLeaseEventReturns(errors.New("kiwi"))
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("adding acquire event: kiwi"))
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when beginning the transaction fails", func() {
+			It("returns an error", func() {
+				databaseHandler.BeginLeaseTransactionReturns(nil, errors.New("guava"))
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("begin lease transaction: guava"))
+
+				Expect(databaseHandler.BeginLeaseTransactionCallCount()).To(Equal(10))
+			})
 		})
 
 		Context("when getting all taken subnets returns an error", func() {
 			It("returns an error", func() {
-				databaseHandler.AllBlockSubnetsReturns(nil, errors.New("guava"))
+				leaseTransaction.TakenSubnetsReturns(nil, errors.New("guava"))
 
-				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
-				Expect(err).To(MatchError("getting all subnets: guava"))
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("get taken subnets: guava"))
 
-				Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(10))
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+				Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(10))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+				Expect(leaseTransaction.RollbackCallCount()).To(Equal(10))
 			})
 		})
 
@@ -196,15 +355,62 @@ var _ = Describe("LeaseController", func() {
 
 			Context("when there are no expired leases", func() {
 				It("eventually returns an error after failing to find a free subnet", func() {
-					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(lease).To(BeNil())
 
-					Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(10))
-					Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(10))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+
+					Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(10))
+					expirationTime, _ := leaseTransaction.OldestExpiredArgsForCall(0)
+					Expect(expirationTime).To(Equal(42))
+				})
+			})
+
+			It("does not reclaim taken subnets that are not in the pool", func() {
+				cidrPool.IsMemberStub = func(subnet string) bool {
+					return subnet != "10.255.44.0/24"
+				}
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, reclaimable := leaseTransaction.OldestExpiredArgsForCall(0)
+				Expect(reclaimable("10.255.44.0/24")).To(BeFalse())
+				Expect(reclaimable("10.255.33.0/24")).To(BeTrue())
+			})
+
+			Context("when the allocation strategy is least-recently-used", func() {
+				BeforeEach(func() {
+					leaseController.AllocationStrategy = leaser.LeastRecentlyUsedAllocation
+					cidrPool.IsMemberReturns(true)
+					databaseHandler.ReleasedSubnetsReturns([]string{"10.255.44.0/24", "10.255.0.5/32", "10.255.12.0/24", "10.255.13.0/24"}, nil)
+				})
+
+				It("hands out the least recently released subnet that is free", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.12.0/24"))
+
+					Expect(databaseHandler.ReleasedSubnetsCallCount()).To(Equal(1))
+					Expect(cidrPool.GetAvailableBlockArgsForCall(0)).To(Equal([]string{
+						"10.255.33.0/24", "10.255.44.0/24",
+						"10.255.44.0/24", "10.255.0.5/32", "10.255.12.0/24", "10.255.13.0/24",
+					}))
+					Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(0))
+					Expect(leaseTransaction.AddEntryArgsForCall(0).OverlaySubnet).To(Equal("10.255.12.0/24"))
+				})
+
+				Context("when getting the released subnets fails", func() {
+					BeforeEach(func() {
+						databaseHandler.ReleasedSubnetsReturns(nil, errors.New("guava"))
+					})
 
-					Expect(databaseHandler.OldestExpiredBlockSubnetCallCount()).To(Equal(10))
-					Expect(databaseHandler.OldestExpiredBlockSubnetArgsForCall(0)).To(Equal(42))
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("get released subnets: guava"))
+					})
 				})
 			})
 
@@ -217,11 +423,11 @@ var _ = Describe("LeaseController", func() {
 						OverlaySubnet:       "10.255.76.0/24",
 						OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
 					}
-					databaseHandler.OldestExpiredBlockSubnetReturns(expiredLease, nil)
+					leaseTransaction.OldestExpiredReturns(expiredLease, nil)
 				})
 
-				It("Deletes the expired lease and assigns that lease's subnet", func() {
-					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				It("reassigns the expired lease's subnet in the same transaction", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(lease).To(Equal(&controller.Lease{
 						UnderlayIP:          "10.244.5.6",
@@ -229,41 +435,208 @@ var _ = Describe("LeaseController", func() {
 						OverlayHardwareAddr: expiredLease.OverlayHardwareAddr,
 					}))
 
-					Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(1))
-					Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
-					Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
-					Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal(expiredLease.UnderlayIP))
+					Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(1))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(1))
+					Expect(leaseTransaction.ReassignEntryArgsForCall(0)).To(Equal(*lease))
+					Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+
+					Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(1))
+					expirationTime, _ := leaseTransaction.OldestExpiredArgsForCall(0)
+					Expect(expirationTime).To(Equal(42))
+				})
+
+				It("records the reclaim of the expired lease and the new acquisition", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(leaseTransaction.AddLeaseEventCallCount()).To(Equal(2))
+					eventType, eventLease := leaseTransaction.AddLeaseEventArgsForCall(0)
+					Expect(eventType).To(Equal("reclaim"))
+					Expect(eventLease).To(Equal(*expiredLease))
+					eventType, eventLease = leaseTransaction.AddLeaseEventArgsForCall(1)
+					Expect(eventType).To(Equal("acquire"))
+					Expect(eventLease).To(Equal(*lease))
+				})
 
-					Expect(databaseHandler.OldestExpiredBlockSubnetCallCount()).To(Equal(1))
-					Expect(databaseHandler.OldestExpiredBlockSubnetArgsForCall(0)).To(Equal(42))
+				Context("when adding the reclaim event fails", func() {
+					BeforeEach(func() {
+						leaseTransaction.AddLeaseEventReturns(errors.New("guava"))
+					})
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("adding reclaim event: guava"))
+						Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(0))
+					})
 				})
 
 				Context("when getting the oldest expired lease returns an error", func() {
 					BeforeEach(func() {
-						databaseHandler.OldestExpiredBlockSubnetReturns(nil, errors.New("guava"))
+						leaseTransaction.OldestExpiredReturns(nil, errors.New("guava"))
 					})
 					It("returns an error", func() {
-						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 						Expect(err).To(MatchError("get oldest expired: guava"))
 					})
 				})
 
-				Context("when deleting the entry errors", func() {
+				Context("when reassigning the entry errors", func() {
+					BeforeEach(func() {
+						leaseTransaction.ReassignEntryReturns(errors.New("guava"))
+					})
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("reassign expired lease entry: guava"))
+					})
+				})
+			})
+
+			Context("when a reclaim quarantine is configured", func() {
+				var expiredLease *controller.Lease
+
+				BeforeEach(func() {
+					leaseController.ReclaimQuarantineSeconds = 300
+					expiredLease = &controller.Lease{
+						UnderlayIP:          "10.244.5.60",
+						OverlaySubnet:       "10.255.76.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
+					}
+					leaseTransaction.QuarantineOldestExpiredReturns(expiredLease, nil)
+				})
+
+				It("quarantines the oldest expired lease instead of reassigning it", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease).To(BeNil())
+
+					Expect(leaseTransaction.OldestExpiredCallCount()).To(Equal(0))
+					Expect(leaseTransaction.OldestReclaimableCallCount()).To(Equal(10))
+					expirationTime, quarantineSeconds, _ := leaseTransaction.OldestReclaimableArgsForCall(0)
+					Expect(expirationTime).To(Equal(42))
+					Expect(quarantineSeconds).To(Equal(300))
+
+					Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(1))
+					expirationTime, _ = leaseTransaction.QuarantineOldestExpiredArgsForCall(0)
+					Expect(expirationTime).To(Equal(42))
+
+					Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+				})
+
+				It("keeps trying, but quarantines at most one lease per request", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(databaseHandler.BeginLeaseTransactionCallCount()).To(Equal(10))
+					Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(1))
+				})
+
+				Context("when a later attempt finds a free subnet", func() {
+					BeforeEach(func() {
+						cidrPool.GetAvailableBlockReturnsOnCall(1, "10.255.77.0/24")
+					})
+
+					It("acquires it after committing the quarantine", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.77.0/24"))
+
+						Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(1))
+						Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(2))
+					})
+				})
+
+				It("records and logs the quarantine", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(leaseTransaction.AddLeaseEventCallCount()).To(Equal(1))
+					eventType, eventLease := leaseTransaction.AddLeaseEventArgsForCall(0)
+					Expect(eventType).To(Equal("quarantine"))
+					Expect(eventLease).To(Equal(*expiredLease))
+					Expect(logger.Logs()[0].Message).To(Equal("test.lease-quarantined"))
+					Expect(logger.Logs()[0].Data["quarantine_seconds"]).To(BeEquivalentTo(300))
+				})
+
+				Context("when a quarantined lease is reclaimable", func() {
+					BeforeEach(func() {
+						leaseTransaction.OldestReclaimableReturns(expiredLease, nil)
+					})
+
+					It("reassigns its subnet", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+
+						Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(0))
+						Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(1))
+						Expect(leaseTransaction.ReassignEntryArgsForCall(0)).To(Equal(*lease))
+						eventType, _ := leaseTransaction.AddLeaseEventArgsForCall(0)
+						Expect(eventType).To(Equal("reclaim"))
+					})
+				})
+
+				Context("when there are no expired leases", func() {
+					BeforeEach(func() {
+						leaseTransaction.QuarantineOldestExpiredReturns(nil, nil)
+					})
+
+					It("returns no lease without committing", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease).To(BeNil())
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+					})
+				})
+
+				Context("when getting the oldest reclaimable lease fails", func() {
+					BeforeEach(func() {
+						leaseTransaction.OldestReclaimableReturns(nil, errors.New("guava"))
+					})
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("get oldest reclaimable: guava"))
+					})
+				})
+
+				Context("when quarantining the oldest expired lease fails", func() {
+					BeforeEach(func() {
+						leaseTransaction.QuarantineOldestExpiredReturns(nil, errors.New("guava"))
+					})
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("quarantine oldest expired: guava"))
+					})
+				})
+
+				Context("when adding the quarantine event fails", func() {
+					BeforeEach(func() {
+						leaseTransaction.AddLeaseEventReturns(errors.New("guava"))
+					})
+					It("returns an error without committing", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("adding quarantine event: guava"))
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+					})
+				})
+
+				Context("when committing the quarantine fails", func() {
 					BeforeEach(func() {
-						databaseHandler.DeleteEntryReturns(errors.New("guava"))
+						leaseTransaction.CommitReturns(errors.New("guava"))
 					})
 					It("returns an error", func() {
-						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
-						Expect(err).To(MatchError("delete expired subnet: guava"))
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+						Expect(err).To(MatchError("commit lease transaction: guava"))
 					})
 				})
 			})
 		})
 
-		Context("when the underlay ip is not an IPv4 addr", func() {
+		Context("when the underlay ip is not an IP address", func() {
 			It("returns an error", func() {
-				_, err := leaseController.AcquireSubnetLease("banana", false)
-				Expect(err).To(MatchError("invalid ipv4 address: banana"))
+				_, err := leaseController.AcquireSubnetLease("banana", false, false)
+				Expect(err).To(MatchError("invalid underlay ip: banana"))
 			})
 		})
 
@@ -272,11 +645,11 @@ var _ = Describe("LeaseController", func() {
 				cidrPool.GetAvailableBlockReturns("foo")
 			})
 			It("eventually returns an error after failing to find a free subnet", func() {
-				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 				Expect(err).To(MatchError("parse subnet: invalid CIDR address: foo"))
 
-				Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(10))
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+				Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(10))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
 			})
 		})
 
@@ -285,25 +658,36 @@ var _ = Describe("LeaseController", func() {
 				hardwareAddressGenerator.GenerateForVTEPReturns(nil, errors.New("guava"))
 			})
 			It("eventually returns an error after failing to find a free subnet", func() {
-				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 				Expect(err).To(MatchError("generate hardware address: guava"))
 
-				Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(10))
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+				Expect(leaseTransaction.TakenSubnetsCallCount()).To(Equal(10))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
 			})
 		})
 
 		Context("when adding the lease entry fails", func() {
 			It("returns an error", func() {
-				databaseHandler.AddEntryReturns(errors.New("guava"))
+				leaseTransaction.AddEntryReturns(errors.New("guava"))
 
-				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 				Expect(err).To(MatchError("adding lease entry: guava"))
 
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(10))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(10))
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
 			})
 		})
 
+		Context("when committing the transaction fails", func() {
+			It("returns an error", func() {
+				leaseTransaction.CommitReturns(errors.New("guava"))
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("commit lease transaction: guava"))
+
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(10))
+			})
+		})
 		Context("when a lease has already been assigned", func() {
 			var existingLease *controller.Lease
 			BeforeEach(func() {
@@ -317,7 +701,7 @@ var _ = Describe("LeaseController", func() {
 			})
 
 			It("gets the previously assigned lease", func() {
-				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(lease).To(Equal(existingLease))
 
@@ -326,7 +710,38 @@ var _ = Describe("LeaseController", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(loggedLease).To(MatchJSON(`{"underlay_ip":"10.244.5.6","overlay_subnet":"10.255.76.0/24","overlay_hardware_addr":"ee:ee:0a:ff:4c:00"}`))
 
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+				Expect(databaseHandler.UpdateLeaseMetadataCallCount()).To(Equal(0))
+			})
+
+			Context("when the lease metadata changed", func() {
+				var metadata controller.LeaseMetadata
+
+				BeforeEach(func() {
+					metadata = controller.LeaseMetadata{CellID: "diego-cell/0", AZ: "z1"}
+				})
+
+				It("updates the metadata of the lease", func() {
+					lease, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, metadata)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+					Expect(lease.LeaseMetadata).To(Equal(metadata))
+
+					Expect(databaseHandler.UpdateLeaseMetadataCallCount()).To(Equal(1))
+					Expect(databaseHandler.UpdateLeaseMetadataArgsForCall(0)).To(Equal(*lease))
+					Expect(databaseHandler.BumpRevisionCallCount()).To(Equal(1))
+				})
+
+				Context("when updating the metadata fails", func() {
+					BeforeEach(func() {
+						databaseHandler.UpdateLeaseMetadataReturns(errors.New("guava"))
+					})
+
+					It("returns an error", func() {
+						_, err := leaseController.AcquireSubnetLeaseWithMetadata("10.244.5.6", false, false, metadata)
+						Expect(err).To(MatchError("updating lease metadata: guava"))
+					})
+				})
 			})
 		})
 
@@ -343,7 +758,7 @@ var _ = Describe("LeaseController", func() {
 			})
 
 			It("deletes the previously assigned lease and assigns a new one", func() {
-				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(lease).NotTo(Equal(existingLease))
 
@@ -352,68 +767,384 @@ var _ = Describe("LeaseController", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(deletedLease).To(MatchJSON(`{"underlay_ip":"10.244.5.6","overlay_subnet":"10.254.76.0/24","overlay_hardware_addr":"ee:ee:0a:fe:4c:00"}`))
 
-				Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
+				Expect(databaseHandler.DeleteEntryForOverlaySubnetCallCount()).To(Equal(1))
+				Expect(databaseHandler.DeleteEntryForOverlaySubnetArgsForCall(0)).To(Equal("10.254.76.0/24"))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
+
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(1))
+				eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+				Expect(eventType).To(Equal("release"))
+				Expect(eventLease).To(Equal(*existingLease))
+			})
+
+			Context("when recording the release event fails", func() {
+				BeforeEach(func() {
+					databaseHandler.AddLeaseEventReturns(errors.New("walnut"))
+				})
+				It("logs the error and still assigns a new lease", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(logger.Logs()[0].Message).To(Equal("test.record-lease-event"))
+					Expect(logger.Logs()[0].Data).To(HaveKeyWithValue("error", "walnut"))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(1))
+				})
 			})
 
 			Context("when deleting the existing entry fails", func() {
 				BeforeEach(func() {
-					databaseHandler.DeleteEntryReturns(fmt.Errorf("peanut"))
+					databaseHandler.DeleteEntryForOverlaySubnetReturns(fmt.Errorf("peanut"))
 				})
 				It("returns an error", func() {
-					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
 					Expect(err).To(MatchError("deleting lease for underlay ip 10.244.5.6: peanut"))
-					Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
 				})
 			})
 		})
 
-		Context("when checking for an existing lease fails", func() {
+		Context("when the underlay ip has a static reservation", func() {
 			BeforeEach(func() {
-				databaseHandler.LeaseForUnderlayIPReturns(nil, fmt.Errorf("fruit"))
+				cidrPool.IsMemberReturns(true)
+				var err error
+				leaseController.StaticReservations, err = leaser.NewStaticReservations([]config.StaticReservation{
+					{Underlay: "10.244.5.6", OverlaySubnet: "10.255.30.0/24"},
+					{Underlay: "10.244.6.0/28", OverlaySubnet: "10.255.31.0/24"},
+				}, cidrPool)
+				Expect(err).NotTo(HaveOccurred())
+				hardwareAddressGenerator.GenerateForVTEPReturns(
+					net.HardwareAddr{0xee, 0xee, 0x0a, 0xff, 0x1e, 0x00}, nil,
+				)
 			})
-			It("returns an error", func() {
-				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
-				Expect(err).To(MatchError("getting lease for underlay ip: fruit"))
-				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+
+			It("acquires the reserved subnet", func() {
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease).To(Equal(&controller.Lease{
+					UnderlayIP:          "10.244.5.6",
+					OverlaySubnet:       "10.255.30.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+				}))
+
+				singleOverlayIP, ipv6Overlay := databaseHandler.BeginLeaseTransactionArgsForCall(0)
+				Expect(singleOverlayIP).To(BeFalse())
+				Expect(ipv6Overlay).To(BeFalse())
+				subnet, expirationTime := leaseTransaction.LeaseRecordForOverlaySubnetArgsForCall(0)
+				Expect(subnet).To(Equal("10.255.30.0/24"))
+				Expect(expirationTime).To(Equal(42))
+				Expect(leaseTransaction.AddEntryArgsForCall(0)).To(Equal(*lease))
+				Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+				Expect(cidrPool.GetAvailableBlockCallCount()).To(Equal(0))
 			})
-		})
-	})
 
-	Describe("RenewSubnetLease", func() {
-		var leaseToRenew controller.Lease
-		var lastRenewedAt int64
-		BeforeEach(func() {
-			leaseToRenew = controller.Lease{
-				UnderlayIP:          "10.244.11.22",
-				OverlaySubnet:       "10.255.33.0/24",
-				OverlayHardwareAddr: "ee:ee:0a:ff:21:00",
-			}
-			databaseHandler.LeaseForUnderlayIPReturns(&leaseToRenew, nil)
-			lastRenewedAt = 42
-			databaseHandler.LastRenewedAtForUnderlayIPReturns(lastRenewedAt, nil)
-		})
+			It("keeps the reserved subnets out of the pool for other underlay ips", func() {
+				_, err := leaseController.AcquireSubnetLease("10.244.7.7", false, false)
+				Expect(err).NotTo(HaveOccurred())
 
-		It("renews a lease and logs the success", func() {
-			err := leaseController.RenewSubnetLease(leaseToRenew)
-			Expect(err).NotTo(HaveOccurred())
+				Expect(cidrPool.GetAvailableBlockArgsForCall(0)).To(Equal([]string{"10.255.33.0/24", "10.255.44.0/24", "10.255.30.0/24", "10.255.31.0/24"}))
+			})
 
-			Expect(databaseHandler.LeaseForUnderlayIPCallCount()).To(Equal(1))
-			Expect(databaseHandler.LeaseForUnderlayIPArgsForCall(0)).To(Equal("10.244.11.22"))
-			Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
-			Expect(databaseHandler.RenewLeaseForUnderlayIPArgsForCall(0)).To(Equal("10.244.11.22"))
-			Expect(databaseHandler.LastRenewedAtForUnderlayIPCallCount()).To(Equal(1))
+			It("does not reclaim expired reserved subnets for other underlay ips", func() {
+				cidrPool.GetAvailableBlockReturns("")
+				_, err := leaseController.AcquireSubnetLease("10.244.7.7", false, false)
+				Expect(err).NotTo(HaveOccurred())
 
-			Expect(logger.Logs()).To(HaveLen(1))
-			Expect(logger.Logs()[0].Message).To(Equal("test.lease-renewed"))
-			Expect(logger.Logs()[0].LogLevel).To(Equal(lager.DEBUG))
-			loggedLease, err := json.Marshal(logger.Logs()[0].Data["lease"])
-			Expect(err).NotTo(HaveOccurred())
-			Expect(loggedLease).To(MatchJSON(`{"underlay_ip":"10.244.11.22","overlay_subnet":"10.255.33.0/24","overlay_hardware_addr":"ee:ee:0a:ff:21:00"}`))
-			Expect(int64(logger.Logs()[0].Data["last_renewed_at"].(float64))).To(Equal(lastRenewedAt))
-		})
+				_, reclaimable := leaseTransaction.OldestExpiredArgsForCall(0)
+				Expect(reclaimable("10.255.30.0/24")).To(BeFalse())
+				Expect(reclaimable("10.255.31.0/24")).To(BeFalse())
+				Expect(reclaimable("10.255.33.0/24")).To(BeTrue())
+			})
 
-		Context("when the existing lease does not equal the one we are renewing", func() {
+			Context("when the underlay ip holds a different subnet", func() {
+				BeforeEach(func() {
+					databaseHandler.LeaseForUnderlayIPReturns(&controller.Lease{
+						UnderlayIP:          "10.244.5.6",
+						OverlaySubnet:       "10.255.76.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
+					}, nil)
+				})
+
+				It("deletes it and acquires the reserved subnet", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.30.0/24"))
+					Expect(databaseHandler.DeleteEntryForOverlaySubnetArgsForCall(0)).To(Equal("10.255.76.0/24"))
+				})
+			})
+
+			Context("when another underlay ip holds a reserved subnet", func() {
+				BeforeEach(func() {
+					databaseHandler.LeaseForUnderlayIPReturns(&controller.Lease{
+						UnderlayIP:          "10.244.7.7",
+						OverlaySubnet:       "10.255.30.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+					}, nil)
+				})
+
+				It("gives it up", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.7.7", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(databaseHandler.DeleteEntryForOverlaySubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+				})
+			})
+
+			Context("when the reserved subnet is held by an underlay ip that is not entitled to it", func() {
+				BeforeEach(func() {
+					leaseTransaction.LeaseRecordForOverlaySubnetReturns(&controller.LeaseRecord{
+						UnderlayIP:          "10.244.7.7",
+						OverlaySubnet:       "10.255.30.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+					}, nil)
+				})
+
+				It("reassigns the subnet and records the reclaim", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+					Expect(leaseTransaction.ReassignEntryArgsForCall(0)).To(Equal(*lease))
+					eventType, eventLease := leaseTransaction.AddLeaseEventArgsForCall(0)
+					Expect(eventType).To(Equal("reclaim"))
+					Expect(eventLease.UnderlayIP).To(Equal("10.244.7.7"))
+					eventType, _ = leaseTransaction.AddLeaseEventArgsForCall(1)
+					Expect(eventType).To(Equal("acquire"))
+					Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when the reserved subnet is held by another underlay ip in the same cidr", func() {
+				BeforeEach(func() {
+					leaseTransaction.LeaseRecordForOverlaySubnetReturns(&controller.LeaseRecord{
+						UnderlayIP:          "10.244.6.1",
+						OverlaySubnet:       "10.255.31.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:1f:00",
+					}, nil)
+				})
+
+				It("returns an error while the holder's lease is active", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.6.2", false, false)
+					Expect(err).To(MatchError("static subnet 10.255.31.0/24 is held by 10.244.6.1"))
+					Expect(leaseTransaction.CommitCallCount()).To(Equal(0))
+				})
+
+				It("takes over the subnet once the holder's lease expired", func() {
+					leaseTransaction.LeaseRecordForOverlaySubnetReturns(&controller.LeaseRecord{
+						UnderlayIP:          "10.244.6.1",
+						OverlaySubnet:       "10.255.31.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:1f:00",
+						Expired:             true,
+					}, nil)
+
+					lease, err := leaseController.AcquireSubnetLease("10.244.6.2", false, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.31.0/24"))
+					Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(1))
+				})
+
+				Context("when a reclaim quarantine is configured and the holder's lease expired", func() {
+					var holderLease controller.Lease
+
+					BeforeEach(func() {
+						leaseController.ReclaimQuarantineSeconds = 300
+						holderLease = controller.Lease{
+							UnderlayIP:          "10.244.6.1",
+							OverlaySubnet:       "10.255.31.0/24",
+							OverlayHardwareAddr: "ee:ee:0a:ff:1f:00",
+						}
+						leaseTransaction.LeaseRecordForOverlaySubnetReturns(&controller.LeaseRecord{
+							UnderlayIP:          holderLease.UnderlayIP,
+							OverlaySubnet:       holderLease.OverlaySubnet,
+							OverlayHardwareAddr: holderLease.OverlayHardwareAddr,
+							Expired:             true,
+						}, nil)
+						leaseTransaction.QuarantineOldestExpiredReturns(&holderLease, nil)
+					})
+
+					It("quarantines the holder's lease instead of taking over the subnet", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.6.2", false, false)
+						Expect(err).To(MatchError("static subnet 10.255.31.0/24 is quarantined for 10.244.6.1"))
+						Expect(lease).To(BeNil())
+
+						Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(1))
+						expirationTime, reclaimable := leaseTransaction.QuarantineOldestExpiredArgsForCall(0)
+						Expect(expirationTime).To(Equal(42))
+						Expect(reclaimable("10.255.31.0/24")).To(BeTrue())
+						Expect(reclaimable("10.255.30.0/24")).To(BeFalse())
+						eventType, eventLease := leaseTransaction.AddLeaseEventArgsForCall(0)
+						Expect(eventType).To(Equal("quarantine"))
+						Expect(eventLease).To(Equal(holderLease))
+						Expect(leaseTransaction.CommitCallCount()).To(Equal(1))
+						Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(0))
+					})
+
+					It("takes over the subnet once the quarantine is over", func() {
+						leaseTransaction.OldestReclaimableReturns(&holderLease, nil)
+
+						lease, err := leaseController.AcquireSubnetLease("10.244.6.2", false, false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.31.0/24"))
+
+						_, quarantineSeconds, _ := leaseTransaction.OldestReclaimableArgsForCall(0)
+						Expect(quarantineSeconds).To(Equal(300))
+						Expect(leaseTransaction.QuarantineOldestExpiredCallCount()).To(Equal(0))
+						Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(1))
+					})
+
+					Context("when quarantining the holder's lease fails", func() {
+						It("returns an error and does not take over the subnet", func() {
+							leaseTransaction.QuarantineOldestExpiredReturns(nil, errors.New("plum"))
+
+							_, err := leaseController.AcquireSubnetLease("10.244.6.2", false, false)
+							Expect(err).To(MatchError("quarantine oldest expired: plum"))
+							Expect(leaseTransaction.ReassignEntryCallCount()).To(Equal(0))
+						})
+					})
+				})
+			})
+
+			Context("when getting the holder of the reserved subnet fails", func() {
+				It("returns an error", func() {
+					leaseTransaction.LeaseRecordForOverlaySubnetReturns(nil, errors.New("plum"))
+
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+					Expect(err).To(MatchError("get static subnet holder: plum"))
+				})
+			})
+		})
+
+		Context("when acquiring an ipv6 overlay lease", func() {
+			var ipv6CIDRPool *fakes.CIDRPool
+			BeforeEach(func() {
+				ipv6CIDRPool = &fakes.CIDRPool{}
+				ipv6CIDRPool.GetAvailableBlockReturns("fd00:10:255:4c::/64")
+				ipv6CIDRPool.GetAvailableSingleIPReturns("fd00:10:255::d/128")
+				leaseController.IPv6CIDRPool = ipv6CIDRPool
+			})
+
+			It("acquires a block from the ipv6 pool", func() {
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.UnderlayIP).To(Equal("10.244.5.6"))
+				Expect(lease.OverlaySubnet).To(Equal("fd00:10:255:4c::/64"))
+
+				_, ipv6 := databaseHandler.LeaseForUnderlayIPArgsForCall(0)
+				Expect(ipv6).To(BeTrue())
+				Expect(ipv6CIDRPool.GetAvailableBlockCallCount()).To(Equal(1))
+				Expect(cidrPool.GetAvailableBlockCallCount()).To(Equal(0))
+			})
+
+			It("acquires a single ip from the ipv6 pool", func() {
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", true, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlaySubnet).To(Equal("fd00:10:255::d/128"))
+				Expect(cidrPool.GetAvailableSingleIPCallCount()).To(Equal(0))
+			})
+
+			Context("when the hardware addresses come from the ipv6 network", func() {
+				BeforeEach(func() {
+					leaseController.IPv6CIDRPool = leaser.NewCIDRPool("fd00:10:255::/48", 64)
+					leaseController.HardwareAddressGenerator = &leaser.HardwareAddressGenerator{
+						IPv6Network:            "fd00:10:255::/48",
+						IPv6SubnetPrefixLength: 64,
+					}
+					var taken []string
+					leaseTransaction.TakenSubnetsStub = func() ([]string, error) {
+						return taken, nil
+					}
+					leaseTransaction.AddEntryStub = func(lease controller.Lease) error {
+						taken = append(taken, lease.OverlaySubnet)
+						return nil
+					}
+				})
+
+				It("gives every single ip lease its own hardware address", func() {
+					first, err := leaseController.AcquireSubnetLease("10.244.5.6", true, true)
+					Expect(err).NotTo(HaveOccurred())
+					second, err := leaseController.AcquireSubnetLease("10.244.5.7", true, true)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(second.OverlaySubnet).NotTo(Equal(first.OverlaySubnet))
+					Expect(second.OverlayHardwareAddr).NotTo(Equal(first.OverlayHardwareAddr))
+				})
+			})
+
+			Context("when no ipv6 subnets are free", func() {
+				BeforeEach(func() {
+					ipv6CIDRPool.GetAvailableBlockReturns("")
+				})
+
+				It("only reclaims expired ipv6 leases", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false, true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease).To(BeNil())
+
+					_, ipv6 := databaseHandler.BeginLeaseTransactionArgsForCall(0)
+					Expect(ipv6).To(BeTrue())
+					expirationTime, _ := leaseTransaction.OldestExpiredArgsForCall(0)
+					Expect(expirationTime).To(Equal(42))
+				})
+			})
+
+			Context("when the ipv6 overlay network is not configured", func() {
+				BeforeEach(func() {
+					leaseController.IPv6CIDRPool = nil
+				})
+
+				It("returns a non-retriable error", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, true)
+					Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+					Expect(err).To(MatchError("ipv6 overlay network is not configured"))
+					Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when checking for an existing lease fails", func() {
+			BeforeEach(func() {
+				databaseHandler.LeaseForUnderlayIPReturns(nil, fmt.Errorf("fruit"))
+			})
+			It("returns an error", func() {
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).To(MatchError("getting lease for underlay ip: fruit"))
+				Expect(leaseTransaction.AddEntryCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("RenewSubnetLease", func() {
+		var leaseToRenew controller.Lease
+		var lastRenewedAt int64
+		BeforeEach(func() {
+			leaseToRenew = controller.Lease{
+				UnderlayIP:          "10.244.11.22",
+				OverlaySubnet:       "10.255.33.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:21:00",
+			}
+			databaseHandler.LeaseForUnderlayIPReturns(&leaseToRenew, nil)
+			lastRenewedAt = 42
+			databaseHandler.LastRenewedAtForUnderlayIPReturns(lastRenewedAt, nil)
+		})
+
+		It("renews a lease and logs the success", func() {
+			err := leaseController.RenewSubnetLease(leaseToRenew)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(databaseHandler.LeaseForUnderlayIPCallCount()).To(Equal(1))
+			Expect(databaseHandler.LeaseForUnderlayIPArgsForCall(0)).To(Equal("10.244.11.22"))
+			Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+			Expect(databaseHandler.RenewLeaseForUnderlayIPArgsForCall(0)).To(Equal("10.244.11.22"))
+			Expect(databaseHandler.LastRenewedAtForUnderlayIPCallCount()).To(Equal(1))
+
+			Expect(logger.Logs()).To(HaveLen(1))
+			Expect(logger.Logs()[0].Message).To(Equal("test.lease-renewed"))
+			Expect(logger.Logs()[0].LogLevel).To(Equal(lager.DEBUG))
+			loggedLease, err := json.Marshal(logger.Logs()[0].Data["lease"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loggedLease).To(MatchJSON(`{"underlay_ip":"10.244.11.22","overlay_subnet":"10.255.33.0/24","overlay_hardware_addr":"ee:ee:0a:ff:21:00"}`))
+			Expect(int64(logger.Logs()[0].Data["last_renewed_at"].(float64))).To(Equal(lastRenewedAt))
+		})
+
+		Context("when the existing lease does not equal the one we are renewing", func() {
 			BeforeEach(func() {
 				existingLease := &controller.Lease{
 					UnderlayIP:          leaseToRenew.UnderlayIP,
@@ -428,6 +1159,60 @@ var _ = Describe("LeaseController", func() {
 				Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
 				Expect(err).To(MatchError("lease mismatch"))
 			})
+
+			It("records the mismatched renewal", func() {
+				leaseController.RenewSubnetLease(leaseToRenew)
+
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(1))
+				eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+				Expect(eventType).To(Equal("renew-mismatch"))
+				Expect(eventLease).To(Equal(leaseToRenew))
+			})
+		})
+
+		Context("when only the metadata of the existing lease differs", func() {
+			var renewedLease controller.Lease
+
+			BeforeEach(func() {
+				renewedLease = leaseToRenew
+				renewedLease.LeaseMetadata = controller.LeaseMetadata{CellID: "diego-cell/0"}
+			})
+
+			It("renews the lease and updates its metadata", func() {
+				err := leaseController.RenewSubnetLease(renewedLease)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(databaseHandler.UpdateLeaseMetadataCallCount()).To(Equal(1))
+				Expect(databaseHandler.UpdateLeaseMetadataArgsForCall(0)).To(Equal(renewedLease))
+				Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(0))
+			})
+
+			Context("when updating the metadata fails", func() {
+				BeforeEach(func() {
+					databaseHandler.UpdateLeaseMetadataReturns(errors.New("guava"))
+				})
+
+				It("returns an error", func() {
+					err := leaseController.RenewSubnetLease(renewedLease)
+					Expect(err).To(MatchError("updating lease metadata: guava"))
+				})
+			})
+		})
+
+		Context("when renewing an ipv6 overlay lease", func() {
+			BeforeEach(func() {
+				leaseToRenew.OverlaySubnet = "fd00:10:255:21::/64"
+			})
+			It("looks up and renews the ipv6 lease for the underlay ip", func() {
+				err := leaseController.RenewSubnetLease(leaseToRenew)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, ipv6 := databaseHandler.LeaseForUnderlayIPArgsForCall(0)
+				Expect(ipv6).To(BeTrue())
+				_, ipv6 = databaseHandler.RenewLeaseForUnderlayIPArgsForCall(0)
+				Expect(ipv6).To(BeTrue())
+			})
 		})
 
 		Context("when the existing lease does not exist", func() {
@@ -443,6 +1228,7 @@ var _ = Describe("LeaseController", func() {
 
 				Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
 				Expect(databaseHandler.AddEntryArgsForCall(0)).To(Equal(leaseToRenew))
+				Expect(databaseHandler.BumpRevisionCallCount()).To(Equal(1))
 
 				Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
 				Expect(databaseHandler.RenewLeaseForUnderlayIPArgsForCall(0)).To(Equal("10.244.11.22"))
@@ -526,12 +1312,85 @@ var _ = Describe("LeaseController", func() {
 
 			Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
 			Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal(underlayIP))
+			Expect(databaseHandler.BumpRevisionCallCount()).To(Equal(1))
 
 			Expect(logger.Logs()).To(HaveLen(1))
 			Expect(logger.Logs()[0].Data["underlay_ip"]).To(Equal("10.244.5.0"))
 			Expect(logger.Logs()[0].Message).To(Equal("test.lease-released"))
 		})
 
+		Context("when bumping the revision fails", func() {
+			BeforeEach(func() {
+				databaseHandler.BumpRevisionReturns(errors.New("banana"))
+			})
+
+			It("still releases the lease and logs the failure", func() {
+				err := leaseController.ReleaseSubnetLease(underlayIP)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger.Logs()).To(HaveLen(2))
+				Expect(logger.Logs()[0].Message).To(Equal("test.bump-revision"))
+				Expect(logger.Logs()[0].Data["error"]).To(Equal("banana"))
+			})
+		})
+
+		Context("when the underlay ip holds leases", func() {
+			var ipv4Lease, ipv6Lease controller.Lease
+
+			BeforeEach(func() {
+				ipv4Lease = controller.Lease{
+					UnderlayIP:          underlayIP,
+					OverlaySubnet:       "10.255.30.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+				}
+				ipv6Lease = controller.Lease{
+					UnderlayIP:          underlayIP,
+					OverlaySubnet:       "fd00:10:255:30::/64",
+					OverlayHardwareAddr: "ee:e6:1d:2c:3b:4a",
+				}
+				databaseHandler.LeaseForUnderlayIPStub = func(_ string, ipv6 bool) (*controller.Lease, error) {
+					if ipv6 {
+						return &ipv6Lease, nil
+					}
+					return &ipv4Lease, nil
+				}
+			})
+
+			It("records a release event for each lease", func() {
+				err := leaseController.ReleaseSubnetLease(underlayIP)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(2))
+				eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+				Expect(eventType).To(Equal("release"))
+				Expect(eventLease).To(Equal(ipv4Lease))
+				eventType, eventLease = databaseHandler.AddLeaseEventArgsForCall(1)
+				Expect(eventType).To(Equal("release"))
+				Expect(eventLease).To(Equal(ipv6Lease))
+			})
+
+			Context("when deleting the entry fails", func() {
+				BeforeEach(func() {
+					databaseHandler.DeleteEntryReturns(errors.New("banana"))
+				})
+				It("does not record release events", func() {
+					leaseController.ReleaseSubnetLease(underlayIP)
+					Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when looking up the leases fails", func() {
+			BeforeEach(func() {
+				databaseHandler.LeaseForUnderlayIPReturns(nil, errors.New("cherry"))
+			})
+			It("returns an error and does not delete the entry", func() {
+				err := leaseController.ReleaseSubnetLease(underlayIP)
+				Expect(err).To(MatchError("getting lease for underlay ip: cherry"))
+				Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the database returns RecordNotAffectedError", func() {
 			BeforeEach(func() {
 				databaseHandler.DeleteEntryReturns(database.RecordNotAffectedError)
@@ -558,6 +1417,569 @@ var _ = Describe("LeaseController", func() {
 		})
 	})
 
+	Describe("ReleaseOverlaySubnet", func() {
+		var existingLease *controller.Lease
+
+		BeforeEach(func() {
+			existingLease = &controller.Lease{
+				UnderlayIP:          "10.244.5.6",
+				OverlaySubnet:       "10.255.30.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+			}
+			databaseHandler.LeaseForOverlaySubnetReturns(existingLease, nil)
+		})
+
+		It("deletes the lease and records the release", func() {
+			err := leaseController.ReleaseOverlaySubnet("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(databaseHandler.LeaseForOverlaySubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(databaseHandler.DeleteEntryForOverlaySubnetCallCount()).To(Equal(1))
+			Expect(databaseHandler.DeleteEntryForOverlaySubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(databaseHandler.BumpRevisionCallCount()).To(Equal(1))
+
+			Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(1))
+			eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+			Expect(eventType).To(Equal("release"))
+			Expect(eventLease).To(Equal(*existingLease))
+
+			Expect(logger.Logs()).To(HaveLen(1))
+			Expect(logger.Logs()[0].Message).To(Equal("test.lease-released"))
+		})
+
+		Context("when there is no lease for the overlay subnet", func() {
+			BeforeEach(func() {
+				databaseHandler.LeaseForOverlaySubnetReturns(nil, nil)
+			})
+			It("logs it at DEBUG level and does not delete anything", func() {
+				err := leaseController.ReleaseOverlaySubnet("10.255.30.0/24")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(databaseHandler.DeleteEntryForOverlaySubnetCallCount()).To(Equal(0))
+				Expect(logger.Logs()).To(HaveLen(1))
+				Expect(logger.Logs()[0].Message).To(Equal("test.lease-not-found"))
+				Expect(logger.Logs()[0].LogLevel).To(Equal(lager.DEBUG))
+			})
+		})
+
+		Context("when the lease is deleted concurrently", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteEntryForOverlaySubnetReturns(database.RecordNotAffectedError)
+			})
+			It("swallows the error and does not record a release", func() {
+				err := leaseController.ReleaseOverlaySubnet("10.255.30.0/24")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when getting the lease fails", func() {
+			BeforeEach(func() {
+				databaseHandler.LeaseForOverlaySubnetReturns(nil, errors.New("cherry"))
+			})
+			It("returns an error", func() {
+				err := leaseController.ReleaseOverlaySubnet("10.255.30.0/24")
+				Expect(err).To(MatchError("getting lease for overlay subnet: cherry"))
+			})
+		})
+
+		Context("when deleting the lease fails", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteEntryForOverlaySubnetReturns(errors.New("banana"))
+			})
+			It("wraps the error from the database handler", func() {
+				err := leaseController.ReleaseOverlaySubnet("10.255.30.0/24")
+				Expect(err).To(MatchError("release lease: banana"))
+			})
+		})
+	})
+
+	Describe("AllLeases", func() {
+		var records []controller.LeaseRecord
+
+		BeforeEach(func() {
+			records = []controller.LeaseRecord{
+				{
+					UnderlayIP:    "10.244.5.9",
+					OverlaySubnet: "10.255.16.0/24",
+					LastRenewedAt: 1500000000,
+					Expired:       true,
+				},
+			}
+			databaseHandler.AllLeaseRecordsReturns(records, nil)
+		})
+
+		It("returns all the lease records", func() {
+			leases, err := leaseController.AllLeases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AllLeaseRecordsArgsForCall(0)).To(Equal(42))
+			Expect(leases).To(Equal(records))
+		})
+
+		Context("when getting the lease records fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllLeaseRecordsReturns(nil, errors.New("cupcake"))
+			})
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.AllLeases()
+				Expect(err).To(MatchError("getting all lease records: cupcake"))
+			})
+		})
+	})
+
+	Describe("ReapExpiredLeases", func() {
+		var expiredLease, activeLease controller.Lease
+
+		BeforeEach(func() {
+			expiredLease = controller.Lease{
+				UnderlayIP:          "10.244.5.9",
+				OverlaySubnet:       "10.255.16.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+			}
+			activeLease = controller.Lease{
+				UnderlayIP:          "10.244.22.33",
+				OverlaySubnet:       "10.255.75.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:4b:00",
+			}
+			databaseHandler.AllLeaseRecordsReturns([]controller.LeaseRecord{
+				{
+					UnderlayIP:          expiredLease.UnderlayIP,
+					OverlaySubnet:       expiredLease.OverlaySubnet,
+					OverlayHardwareAddr: expiredLease.OverlayHardwareAddr,
+					LastRenewedAt:       1000,
+					Expired:             true,
+				},
+				{
+					UnderlayIP:          activeLease.UnderlayIP,
+					OverlaySubnet:       activeLease.OverlaySubnet,
+					OverlayHardwareAddr: activeLease.OverlayHardwareAddr,
+					LastRenewedAt:       2000,
+				},
+			}, nil)
+		})
+
+		It("deletes the leases expired for longer than the grace period", func() {
+			reaped, err := leaseController.ReapExpiredLeases(100, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reaped).To(Equal([]controller.Lease{expiredLease}))
+
+			Expect(databaseHandler.AllLeaseRecordsArgsForCall(0)).To(Equal(142))
+			Expect(databaseHandler.DeleteExpiredEntryCallCount()).To(Equal(1))
+			overlaySubnet, expirationTime := databaseHandler.DeleteExpiredEntryArgsForCall(0)
+			Expect(overlaySubnet).To(Equal(expiredLease.OverlaySubnet))
+			Expect(expirationTime).To(Equal(142))
+
+			Expect(databaseHandler.BumpRevisionCallCount()).To(Equal(1))
+			Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(1))
+			eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+			Expect(eventType).To(Equal("reclaim"))
+			Expect(eventLease).To(Equal(expiredLease))
+
+			Expect(logger.Logs()).To(HaveLen(1))
+			Expect(logger.Logs()[0].Message).To(Equal("test.lease-reaped"))
+		})
+
+		Context("when in dry run mode", func() {
+			It("only logs the leases that would be reaped", func() {
+				reaped, err := leaseController.ReapExpiredLeases(100, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reaped).To(Equal([]controller.Lease{expiredLease}))
+
+				Expect(databaseHandler.DeleteExpiredEntryCallCount()).To(Equal(0))
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(0))
+				Expect(logger.Logs()).To(HaveLen(1))
+				Expect(logger.Logs()[0].Message).To(Equal("test.lease-reapable"))
+			})
+		})
+
+		Context("when the lease was renewed after the records were read", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteExpiredEntryReturns(database.RecordNotAffectedError)
+			})
+
+			It("skips it", func() {
+				reaped, err := leaseController.ReapExpiredLeases(100, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reaped).To(BeEmpty())
+				Expect(databaseHandler.AddLeaseEventCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a reclaim quarantine is configured", func() {
+			var records []controller.LeaseRecord
+
+			BeforeEach(func() {
+				leaseController.ReclaimQuarantineSeconds = 300
+				records = []controller.LeaseRecord{
+					{
+						UnderlayIP:          expiredLease.UnderlayIP,
+						OverlaySubnet:       expiredLease.OverlaySubnet,
+						OverlayHardwareAddr: expiredLease.OverlayHardwareAddr,
+						LastRenewedAt:       1000,
+						Expired:             true,
+					},
+				}
+				databaseHandler.AllLeaseRecordsReturns(records, nil)
+			})
+
+			It("quarantines the expired leases instead of deleting them", func() {
+				reaped, err := leaseController.ReapExpiredLeases(100, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reaped).To(BeEmpty())
+
+				Expect(databaseHandler.DeleteExpiredEntryCallCount()).To(Equal(0))
+				Expect(databaseHandler.DeleteReclaimableEntryCallCount()).To(Equal(0))
+				Expect(databaseHandler.QuarantineExpiredEntryCallCount()).To(Equal(1))
+				overlaySubnet, expirationTime := databaseHandler.QuarantineExpiredEntryArgsForCall(0)
+				Expect(overlaySubnet).To(Equal(expiredLease.OverlaySubnet))
+				Expect(expirationTime).To(Equal(142))
+
+				eventType, eventLease := databaseHandler.AddLeaseEventArgsForCall(0)
+				Expect(eventType).To(Equal("quarantine"))
+				Expect(eventLease).To(Equal(expiredLease))
+				Expect(logger.Logs()[0].Message).To(Equal("test.lease-quarantined"))
+			})
+
+			Context("when the lease is still in quarantine", func() {
+				BeforeEach(func() {
+					records[0].Quarantined = true
+					records[0].QuarantinedAt = time.Now().Unix() - 10
+				})
+
+				It("keeps it", func() {
+					reaped, err := leaseController.ReapExpiredLeases(100, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reaped).To(BeEmpty())
+
+					Expect(databaseHandler.QuarantineExpiredEntryCallCount()).To(Equal(0))
+					Expect(databaseHandler.DeleteExpiredEntryCallCount()).To(Equal(0))
+					Expect(databaseHandler.DeleteReclaimableEntryCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the quarantine of the lease is over", func() {
+				BeforeEach(func() {
+					records[0].Quarantined = true
+					records[0].QuarantinedAt = time.Now().Unix() - 301
+				})
+
+				It("deletes it only if it is still reclaimable", func() {
+					reaped, err := leaseController.ReapExpiredLeases(100, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reaped).To(Equal([]controller.Lease{expiredLease}))
+
+					Expect(databaseHandler.DeleteExpiredEntryCallCount()).To(Equal(0))
+					Expect(databaseHandler.DeleteReclaimableEntryCallCount()).To(Equal(1))
+					overlaySubnet, expirationTime, quarantineSeconds := databaseHandler.DeleteReclaimableEntryArgsForCall(0)
+					Expect(overlaySubnet).To(Equal(expiredLease.OverlaySubnet))
+					Expect(expirationTime).To(Equal(142))
+					Expect(quarantineSeconds).To(Equal(300))
+				})
+			})
+
+			Context("when in dry run mode", func() {
+				It("does not quarantine anything", func() {
+					reaped, err := leaseController.ReapExpiredLeases(100, true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(reaped).To(BeEmpty())
+					Expect(databaseHandler.QuarantineExpiredEntryCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when quarantining fails", func() {
+				BeforeEach(func() {
+					databaseHandler.QuarantineExpiredEntryReturns(errors.New("kiwi"))
+				})
+
+				It("returns an error", func() {
+					_, err := leaseController.ReapExpiredLeases(100, false)
+					Expect(err).To(MatchError("quarantine lease: kiwi"))
+				})
+			})
+		})
+
+		Context("when getting the lease records fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllLeaseRecordsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := leaseController.ReapExpiredLeases(100, false)
+				Expect(err).To(MatchError("getting all lease records: banana"))
+			})
+		})
+
+		Context("when deleting a lease fails", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteExpiredEntryReturns(errors.New("kiwi"))
+			})
+
+			It("returns an error", func() {
+				_, err := leaseController.ReapExpiredLeases(100, false)
+				Expect(err).To(MatchError("reap lease: kiwi"))
+			})
+		})
+	})
+
+	Describe("LeasesInExcludedRanges", func() {
+		var ipv6CIDRPool *fakes.CIDRPool
+
+		BeforeEach(func() {
+			ipv6CIDRPool = &fakes.CIDRPool{}
+			leaseController.CIDRPool = cidrPool
+			leaseController.IPv6CIDRPool = ipv6CIDRPool
+			databaseHandler.AllReturns([]controller.Lease{
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+				{UnderlayIP: "10.244.5.10", OverlaySubnet: "10.255.17.0/24"},
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "fd00:10:255:30::/64"},
+			}, nil)
+			cidrPool.IsExcludedStub = func(subnet string) bool {
+				return subnet == "10.255.17.0/24"
+			}
+			ipv6CIDRPool.IsExcludedStub = func(subnet string) bool {
+				return subnet == "fd00:10:255:30::/64"
+			}
+		})
+
+		It("returns the leases overlapping an excluded range of either pool", func() {
+			leases, err := leaseController.LeasesInExcludedRanges()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal([]controller.Lease{
+				{UnderlayIP: "10.244.5.10", OverlaySubnet: "10.255.17.0/24"},
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "fd00:10:255:30::/64"},
+			}))
+		})
+
+		Context("when getting the leases fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllReturns(nil, errors.New("cupcake"))
+			})
+
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.LeasesInExcludedRanges()
+				Expect(err).To(MatchError("getting all leases: cupcake"))
+			})
+		})
+	})
+
+	Describe("ReserveSubnet", func() {
+		var ipv6CIDRPool *fakes.CIDRPool
+
+		BeforeEach(func() {
+			leaseController.CIDRPool = cidrPool
+			ipv6CIDRPool = &fakes.CIDRPool{}
+			cidrPool.IsMemberReturns(true)
+		})
+
+		It("reserves the subnet", func() {
+			err := leaseController.ReserveSubnet("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cidrPool.IsMemberArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(databaseHandler.AddReservationCallCount()).To(Equal(1))
+			Expect(databaseHandler.AddReservationArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(logger.Logs()[0].Message).To(Equal("test.subnet-reserved"))
+		})
+
+		Context("when the subnet is in the ipv6 overlay network", func() {
+			BeforeEach(func() {
+				cidrPool.IsMemberReturns(false)
+				ipv6CIDRPool.IsMemberReturns(true)
+				leaseController.IPv6CIDRPool = ipv6CIDRPool
+			})
+			It("reserves the subnet", func() {
+				err := leaseController.ReserveSubnet("fd00:10:255:30::/64")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(databaseHandler.AddReservationArgsForCall(0)).To(Equal("fd00:10:255:30::/64"))
+			})
+		})
+
+		Context("when the subnet is not in the overlay network", func() {
+			BeforeEach(func() {
+				cidrPool.IsMemberReturns(false)
+			})
+			It("returns a non-retriable error", func() {
+				err := leaseController.ReserveSubnet("10.0.0.0/24")
+				Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+				Expect(err).To(MatchError("subnet 10.0.0.0/24 is not in the overlay network"))
+				Expect(databaseHandler.AddReservationCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when adding the reservation fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AddReservationReturns(errors.New("banana"))
+			})
+			It("wraps the error from the database handler", func() {
+				err := leaseController.ReserveSubnet("10.255.30.0/24")
+				Expect(err).To(MatchError("reserve subnet: banana"))
+			})
+		})
+	})
+
+	Describe("UnreserveSubnet", func() {
+		It("deletes the reservation", func() {
+			err := leaseController.UnreserveSubnet("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(databaseHandler.DeleteReservationArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(logger.Logs()[0].Message).To(Equal("test.subnet-unreserved"))
+		})
+
+		Context("when the subnet is not reserved", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteReservationReturns(database.RecordNotAffectedError)
+			})
+			It("swallows the error and logs it at DEBUG level", func() {
+				err := leaseController.UnreserveSubnet("10.255.30.0/24")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logger.Logs()[0].Message).To(Equal("test.reservation-not-found"))
+				Expect(logger.Logs()[0].LogLevel).To(Equal(lager.DEBUG))
+			})
+		})
+
+		Context("when deleting the reservation fails", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteReservationReturns(errors.New("banana"))
+			})
+			It("wraps the error from the database handler", func() {
+				err := leaseController.UnreserveSubnet("10.255.30.0/24")
+				Expect(err).To(MatchError("unreserve subnet: banana"))
+			})
+		})
+	})
+
+	Describe("Reservations", func() {
+		It("returns the reserved subnets", func() {
+			databaseHandler.AllReservationsReturns([]string{"10.255.30.0/24"}, nil)
+
+			reservations, err := leaseController.Reservations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reservations).To(Equal([]string{"10.255.30.0/24"}))
+		})
+
+		Context("when getting the reservations fails", func() {
+			It("wraps the error from the database handler", func() {
+				databaseHandler.AllReservationsReturns(nil, errors.New("cupcake"))
+
+				_, err := leaseController.Reservations()
+				Expect(err).To(MatchError("getting all reservations: cupcake"))
+			})
+		})
+	})
+
+	Describe("LeaseHistory", func() {
+		var events []controller.LeaseEvent
+
+		BeforeEach(func() {
+			events = []controller.LeaseEvent{
+				{
+					EventType:     "acquire",
+					UnderlayIP:    "10.244.5.9",
+					OverlaySubnet: "10.255.30.0/24",
+					CreatedAt:     1500000000,
+				},
+			}
+			databaseHandler.LeaseEventsForOverlaySubnetReturns(events, nil)
+		})
+
+		It("returns the events for the overlay subnet", func() {
+			history, err := leaseController.LeaseHistory("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.LeaseEventsForOverlaySubnetArgsForCall(0)).To(Equal("10.255.30.0/24"))
+			Expect(history).To(Equal(events))
+		})
+
+		Context("when getting the events fails", func() {
+			BeforeEach(func() {
+				databaseHandler.LeaseEventsForOverlaySubnetReturns(nil, errors.New("muffin"))
+			})
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.LeaseHistory("10.255.30.0/24")
+				Expect(err).To(MatchError("getting lease events: muffin"))
+			})
+		})
+	})
+
+	Describe("QuarantinedLeases", func() {
+		BeforeEach(func() {
+			databaseHandler.AllLeaseRecordsReturns([]controller.LeaseRecord{
+				{OverlaySubnet: "10.255.16.0/24", Expired: true, Quarantined: true, QuarantinedAt: 1500000000},
+				{OverlaySubnet: "10.255.17.0/24", Expired: true},
+				{OverlaySubnet: "10.255.18.0/24"},
+			}, nil)
+		})
+
+		It("returns the expired leases that are quarantined", func() {
+			leases, err := leaseController.QuarantinedLeases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AllLeaseRecordsArgsForCall(0)).To(Equal(42))
+			Expect(leases).To(Equal([]controller.LeaseRecord{
+				{OverlaySubnet: "10.255.16.0/24", Expired: true, Quarantined: true, QuarantinedAt: 1500000000},
+			}))
+		})
+
+		Context("when getting the lease records fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllLeaseRecordsReturns(nil, errors.New("cupcake"))
+			})
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.QuarantinedLeases()
+				Expect(err).To(MatchError("getting all lease records: cupcake"))
+			})
+		})
+	})
+
+	Describe("LookupLease", func() {
+		It("returns the lease record containing the ip", func() {
+			record := &controller.LeaseRecord{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.77.0/24"}
+			databaseHandler.LeaseRecordContainingIPReturns(record, nil)
+
+			found, err := leaseController.LookupLease("10.255.77.12")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal(record))
+
+			ip, expirationTime := databaseHandler.LeaseRecordContainingIPArgsForCall(0)
+			Expect(ip).To(Equal("10.255.77.12"))
+			Expect(expirationTime).To(Equal(42))
+		})
+
+		Context("when looking up the lease fails", func() {
+			It("wraps the error from the database handler", func() {
+				databaseHandler.LeaseRecordContainingIPReturns(nil, errors.New("scone"))
+				_, err := leaseController.LookupLease("10.255.77.12")
+				Expect(err).To(MatchError("looking up lease: scone"))
+			})
+		})
+	})
+
+	Describe("QueryLeases", func() {
+		It("queries the leases in the database", func() {
+			leaseController.LeaseCache = &fakes.LeaseCache{}
+			queried := []controller.Lease{{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"}}
+			databaseHandler.QueryLeasesReturns(queried, "10.255.16.0/24", nil)
+
+			query := controller.LeaseQuery{AZ: "z1", Limit: 1}
+			leases, next, err := leaseController.QueryLeases(query)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leases).To(Equal(queried))
+			Expect(next).To(Equal("10.255.16.0/24"))
+
+			Expect(databaseHandler.QueryLeasesCallCount()).To(Equal(1))
+			actualQuery, expirationTime := databaseHandler.QueryLeasesArgsForCall(0)
+			Expect(actualQuery).To(Equal(query))
+			Expect(expirationTime).To(Equal(42))
+		})
+
+		Context("when querying the leases fails", func() {
+			It("wraps the error from the database handler", func() {
+				databaseHandler.QueryLeasesReturns(nil, "", errors.New("muffin"))
+				_, _, err := leaseController.QueryLeases(controller.LeaseQuery{})
+				Expect(err).To(MatchError("querying leases: muffin"))
+			})
+		})
+	})
+
 	Describe("RoutableLeases", func() {
 		activeLeases := []controller.Lease{
 			{
@@ -589,5 +2011,53 @@ var _ = Describe("LeaseController", func() {
 				Expect(err).To(MatchError("getting all leases: cupcake"))
 			})
 		})
+
+		Context("when there is a lease cache", func() {
+			var leaseCache *fakes.LeaseCache
+
+			BeforeEach(func() {
+				leaseCache = &fakes.LeaseCache{}
+				leaseCache.AllActiveReturns(activeLeases[:1], nil)
+				leaseController.LeaseCache = leaseCache
+			})
+
+			It("serves the leases from the cache", func() {
+				leases, err := leaseController.RoutableLeases()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaseCache.AllActiveCallCount()).To(Equal(1))
+				Expect(leaseCache.AllActiveArgsForCall(0)).To(Equal(42))
+				Expect(databaseHandler.AllActiveCallCount()).To(Equal(0))
+				Expect(leases).To(Equal(activeLeases[:1]))
+			})
+
+			It("invalidates the cache when a lease is acquired or released", func() {
+				leaseController.AcquireSubnetLeaseAttempts = 1
+				leaseController.CIDRPool = cidrPool
+				cidrPool.GetAvailableBlockReturns("10.255.76.0/24")
+				databaseHandler.BeginLeaseTransactionReturns(&dbfakes.LeaseTransaction{}, nil)
+
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaseCache.InvalidateCallCount()).To(Equal(1))
+
+				err = leaseController.ReleaseSubnetLease("10.244.5.6")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaseCache.InvalidateCallCount()).To(Equal(2))
+			})
+
+			It("does not invalidate the cache when a lease is renewed", func() {
+				databaseHandler.LeaseForUnderlayIPReturns(&controller.Lease{
+					UnderlayIP:    "10.244.5.9",
+					OverlaySubnet: "10.255.16.0/24",
+				}, nil)
+
+				err := leaseController.RenewSubnetLease(controller.Lease{
+					UnderlayIP:    "10.244.5.9",
+					OverlaySubnet: "10.255.16.0/24",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaseCache.InvalidateCallCount()).To(Equal(0))
+			})
+		})
 	})
 })
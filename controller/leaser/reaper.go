@@ -0,0 +1,39 @@
+package leaser
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/expired_lease_reaper.go --fake-name ExpiredLeaseReaper . expiredLeaseReaper
+type expiredLeaseReaper interface {
+	ReapExpiredLeases(gracePeriodSeconds int, dryRun bool) ([]controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/metric_sender.go --fake-name MetricSender . metricSender
+type metricSender interface {
+	IncrementCounter(name string)
+}
+
+// Reaper is the background job that removes leases of cells that stopped
+// renewing them, instead of waiting for the pool to run out.
+type Reaper struct {
+	LeaseReaper        expiredLeaseReaper
+	GracePeriodSeconds int
+	DryRun             bool
+	MetricSender       metricSender
+}
+
+func (r *Reaper) Reap() error {
+	reaped, err := r.LeaseReaper.ReapExpiredLeases(r.GracePeriodSeconds, r.DryRun)
+	if !r.DryRun {
+		for range reaped {
+			r.MetricSender.IncrementCounter("reapedLeases")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reap expired leases: %s", err)
+	}
+	return nil
+}
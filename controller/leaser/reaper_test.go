@@ -0,0 +1,72 @@
+package leaser_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	"code.cloudfoundry.org/silk/controller/leaser/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reaper", func() {
+	var (
+		leaseReaper  *fakes.ExpiredLeaseReaper
+		metricSender *fakes.MetricSender
+		reaper       *leaser.Reaper
+	)
+
+	BeforeEach(func() {
+		leaseReaper = &fakes.ExpiredLeaseReaper{}
+		leaseReaper.ReapExpiredLeasesReturns([]controller.Lease{
+			{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+			{UnderlayIP: "10.244.5.10", OverlaySubnet: "10.255.17.0/24"},
+		}, nil)
+		metricSender = &fakes.MetricSender{}
+		reaper = &leaser.Reaper{
+			LeaseReaper:        leaseReaper,
+			GracePeriodSeconds: 300,
+			MetricSender:       metricSender,
+		}
+	})
+
+	It("reaps the expired leases and counts them", func() {
+		Expect(reaper.Reap()).To(Succeed())
+
+		Expect(leaseReaper.ReapExpiredLeasesCallCount()).To(Equal(1))
+		gracePeriodSeconds, dryRun := leaseReaper.ReapExpiredLeasesArgsForCall(0)
+		Expect(gracePeriodSeconds).To(Equal(300))
+		Expect(dryRun).To(BeFalse())
+
+		Expect(metricSender.IncrementCounterCallCount()).To(Equal(2))
+		Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("reapedLeases"))
+	})
+
+	Context("when in dry run mode", func() {
+		BeforeEach(func() {
+			reaper.DryRun = true
+		})
+
+		It("does not count the leases that would be reaped", func() {
+			Expect(reaper.Reap()).To(Succeed())
+
+			_, dryRun := leaseReaper.ReapExpiredLeasesArgsForCall(0)
+			Expect(dryRun).To(BeTrue())
+			Expect(metricSender.IncrementCounterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when reaping fails part way", func() {
+		BeforeEach(func() {
+			leaseReaper.ReapExpiredLeasesReturns([]controller.Lease{
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+			}, errors.New("banana"))
+		})
+
+		It("counts the leases reaped so far and returns the error", func() {
+			Expect(reaper.Reap()).To(MatchError("reap expired leases: banana"))
+			Expect(metricSender.IncrementCounterCallCount()).To(Equal(1))
+		})
+	})
+})
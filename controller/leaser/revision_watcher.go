@@ -0,0 +1,103 @@
+package leaser
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter -o fakes/revision_repository.go --fake-name RevisionRepository . revisionRepository
+type revisionRepository interface {
+	Revision() (int64, error)
+}
+
+// RevisionWatcher polls the lease revision so that any number of watch
+// requests share a single database query per poll interval. When there is a
+// LeaseCache, it is invalidated before watchers see a new revision, so they
+// never read leases older than the revision.
+type RevisionWatcher struct {
+	RevisionRepository revisionRepository
+	LeaseCache         leaseCache
+	PollInterval       time.Duration
+	Logger             lager.Logger
+
+	mutex    sync.Mutex
+	revision int64
+	changed  chan struct{}
+}
+
+func (w *RevisionWatcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	w.poll()
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-time.After(w.PollInterval):
+			w.poll()
+		}
+	}
+}
+
+// Revision returns the latest known revision.
+func (w *RevisionWatcher) Revision() int64 {
+	revision, _ := w.current()
+	return revision
+}
+
+// Wait blocks until the revision differs from the given revision or the
+// timeout elapses, and returns the latest known revision.
+func (w *RevisionWatcher) Wait(revision int64, timeout time.Duration) int64 {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		current, changed := w.current()
+		if current != revision {
+			return current
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			current, _ = w.current()
+			return current
+		}
+	}
+}
+
+func (w *RevisionWatcher) current() (int64, chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.changed == nil {
+		w.changed = make(chan struct{})
+	}
+	return w.revision, w.changed
+}
+
+func (w *RevisionWatcher) poll() {
+	revision, err := w.RevisionRepository.Revision()
+	if err != nil {
+		w.Logger.Error("poll-revision", err)
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if revision == w.revision {
+		return
+	}
+	if w.LeaseCache != nil {
+		w.LeaseCache.Invalidate()
+	}
+	w.revision = revision
+	if w.changed != nil {
+		close(w.changed)
+	}
+	w.changed = make(chan struct{})
+}
@@ -0,0 +1,121 @@
+package leaser_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	"code.cloudfoundry.org/silk/controller/leaser/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("RevisionWatcher", func() {
+	var (
+		revisionRepository *fakes.RevisionRepository
+		logger             *lagertest.TestLogger
+		watcher            *leaser.RevisionWatcher
+		process            ifrit.Process
+	)
+
+	BeforeEach(func() {
+		revisionRepository = &fakes.RevisionRepository{}
+		revisionRepository.RevisionReturns(5, nil)
+		logger = lagertest.NewTestLogger("test")
+		watcher = &leaser.RevisionWatcher{
+			RevisionRepository: revisionRepository,
+			PollInterval:       10 * time.Millisecond,
+			Logger:             logger,
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(watcher)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("polls the revision until signaled", func() {
+		Eventually(revisionRepository.RevisionCallCount).Should(BeNumerically(">", 2))
+	})
+
+	Describe("Revision", func() {
+		It("returns the latest polled revision", func() {
+			Expect(watcher.Revision()).To(Equal(int64(5)))
+
+			revisionRepository.RevisionReturns(7, nil)
+			Eventually(watcher.Revision).Should(Equal(int64(7)))
+		})
+	})
+
+	Describe("Wait", func() {
+		It("returns immediately when the revision differs", func() {
+			Expect(watcher.Wait(3, time.Minute)).To(Equal(int64(5)))
+		})
+
+		It("returns the new revision once it changes", func() {
+			revisions := make(chan int64)
+			go func() {
+				defer GinkgoRecover()
+				revisions <- watcher.Wait(5, time.Minute)
+			}()
+			Consistently(revisions, 50*time.Millisecond).ShouldNot(Receive())
+
+			revisionRepository.RevisionReturns(6, nil)
+			Eventually(revisions).Should(Receive(Equal(int64(6))))
+		})
+
+		It("returns the current revision when the timeout elapses", func() {
+			Expect(watcher.Wait(5, 50*time.Millisecond)).To(Equal(int64(5)))
+		})
+	})
+
+	Context("when there is a lease cache", func() {
+		var leaseCache *fakes.LeaseCache
+
+		BeforeEach(func() {
+			leaseCache = &fakes.LeaseCache{}
+			watcher.LeaseCache = leaseCache
+		})
+
+		It("invalidates it before waiters see a new revision", func() {
+			Eventually(revisionRepository.RevisionCallCount).Should(BeNumerically(">", 2))
+			invalidated := leaseCache.InvalidateCallCount()
+
+			revisions := make(chan int64)
+			go func() {
+				defer GinkgoRecover()
+				revision := watcher.Wait(5, time.Minute)
+				Expect(leaseCache.InvalidateCallCount()).To(Equal(invalidated + 1))
+				revisions <- revision
+			}()
+			revisionRepository.RevisionReturns(6, nil)
+			Eventually(revisions).Should(Receive(Equal(int64(6))))
+		})
+
+		It("does not invalidate it while the revision stays the same", func() {
+			Eventually(revisionRepository.RevisionCallCount).Should(BeNumerically(">", 2))
+			invalidated := leaseCache.InvalidateCallCount()
+
+			Consistently(leaseCache.InvalidateCallCount, 50*time.Millisecond).Should(Equal(invalidated))
+		})
+	})
+
+	Context("when polling the revision fails", func() {
+		BeforeEach(func() {
+			revisionRepository.RevisionReturns(0, errors.New("banana"))
+		})
+
+		It("logs the error and keeps polling", func() {
+			Eventually(logger).Should(gbytes.Say("test.poll-revision.*banana"))
+			Eventually(revisionRepository.RevisionCallCount).Should(BeNumerically(">", 2))
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package leaser
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"code.cloudfoundry.org/silk/controller/config"
+)
+
+type staticReservation struct {
+	underlay      *net.IPNet
+	overlaySubnet string
+}
+
+// StaticReservations pins overlay subnets to underlay IPs or to every
+// underlay IP in a CIDR. Reserved subnets are never handed out by the pools.
+type StaticReservations []staticReservation
+
+func NewStaticReservations(reservations []config.StaticReservation, pools ...cidrPool) (StaticReservations, error) {
+	var s StaticReservations
+	for _, reservation := range reservations {
+		underlay, err := parseUnderlay(reservation.Underlay)
+		if err != nil {
+			return nil, err
+		}
+		if !isMemberOfAny(reservation.OverlaySubnet, pools) {
+			return nil, fmt.Errorf("static reservation %s: not in the overlay network", reservation.OverlaySubnet)
+		}
+		s = append(s, staticReservation{
+			underlay:      underlay,
+			overlaySubnet: reservation.OverlaySubnet,
+		})
+	}
+
+	// the most specific underlay wins
+	sort.SliceStable(s, func(i, j int) bool {
+		iOnes, _ := s[i].underlay.Mask.Size()
+		jOnes, _ := s[j].underlay.Mask.Size()
+		return iOnes > jOnes
+	})
+	return s, nil
+}
+
+// SubnetFor returns the subnet reserved for the underlay IP, if any.
+func (s StaticReservations) SubnetFor(underlayIP string, ipv6Overlay bool) (string, bool) {
+	ip := net.ParseIP(underlayIP)
+	for _, reservation := range s {
+		if reservation.underlay.Contains(ip) && isIPv6Subnet(reservation.overlaySubnet) == ipv6Overlay {
+			return reservation.overlaySubnet, true
+		}
+	}
+	return "", false
+}
+
+// Allows reports whether the underlay IP may hold the overlay subnet, which is
+// true for every subnet that is not reserved.
+func (s StaticReservations) Allows(underlayIP, overlaySubnet string) bool {
+	ip := net.ParseIP(underlayIP)
+	reserved := false
+	for _, reservation := range s {
+		if reservation.overlaySubnet != overlaySubnet {
+			continue
+		}
+		if reservation.underlay.Contains(ip) {
+			return true
+		}
+		reserved = true
+	}
+	return !reserved
+}
+
+func (s StaticReservations) Subnets() []string {
+	subnets := make([]string, 0, len(s))
+	for _, reservation := range s {
+		subnets = append(subnets, reservation.overlaySubnet)
+	}
+	return subnets
+}
+
+func parseUnderlay(underlay string) (*net.IPNet, error) {
+	if ip := net.ParseIP(underlay); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 8 * net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, underlayNetwork, err := net.ParseCIDR(underlay)
+	if err != nil {
+		return nil, fmt.Errorf("static reservation: invalid underlay %q", underlay)
+	}
+	return underlayNetwork, nil
+}
+
+func isMemberOfAny(subnet string, pools []cidrPool) bool {
+	for _, pool := range pools {
+		if pool != nil && pool.IsMember(subnet) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package leaser_test
+
+import (
+	"code.cloudfoundry.org/silk/controller/config"
+	"code.cloudfoundry.org/silk/controller/leaser"
+	"code.cloudfoundry.org/silk/controller/leaser/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StaticReservations", func() {
+	var (
+		pool         *fakes.CIDRPool
+		reservations leaser.StaticReservations
+	)
+
+	BeforeEach(func() {
+		pool = &fakes.CIDRPool{}
+		pool.IsMemberReturns(true)
+
+		var err error
+		reservations, err = leaser.NewStaticReservations([]config.StaticReservation{
+			{Underlay: "10.244.5.0/28", OverlaySubnet: "10.255.80.0/24"},
+			{Underlay: "10.244.5.6", OverlaySubnet: "10.255.30.0/24"},
+			{Underlay: "10.244.5.6", OverlaySubnet: "fd00:10:255:30::/64"},
+		}, pool)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("SubnetFor", func() {
+		It("prefers the most specific underlay", func() {
+			subnet, ok := reservations.SubnetFor("10.244.5.6", false)
+			Expect(ok).To(BeTrue())
+			Expect(subnet).To(Equal("10.255.30.0/24"))
+		})
+
+		It("matches underlay ips inside a cidr", func() {
+			subnet, ok := reservations.SubnetFor("10.244.5.7", false)
+			Expect(ok).To(BeTrue())
+			Expect(subnet).To(Equal("10.255.80.0/24"))
+		})
+
+		It("only returns subnets of the requested ip version", func() {
+			subnet, ok := reservations.SubnetFor("10.244.5.6", true)
+			Expect(ok).To(BeTrue())
+			Expect(subnet).To(Equal("fd00:10:255:30::/64"))
+
+			_, ok = reservations.SubnetFor("10.244.5.7", true)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns false for underlay ips without a reservation", func() {
+			_, ok := reservations.SubnetFor("10.244.6.1", false)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Allows", func() {
+		It("allows unreserved subnets for everyone", func() {
+			Expect(reservations.Allows("10.244.6.1", "10.255.31.0/24")).To(BeTrue())
+		})
+
+		It("allows reserved subnets only for matching underlay ips", func() {
+			Expect(reservations.Allows("10.244.5.6", "10.255.30.0/24")).To(BeTrue())
+			Expect(reservations.Allows("10.244.5.7", "10.255.80.0/24")).To(BeTrue())
+			Expect(reservations.Allows("10.244.6.1", "10.255.30.0/24")).To(BeFalse())
+			Expect(reservations.Allows("10.244.6.1", "10.255.80.0/24")).To(BeFalse())
+		})
+	})
+
+	Describe("Subnets", func() {
+		It("returns every reserved subnet", func() {
+			Expect(reservations.Subnets()).To(ConsistOf("10.255.80.0/24", "10.255.30.0/24", "fd00:10:255:30::/64"))
+		})
+
+		It("is empty without reservations", func() {
+			var none leaser.StaticReservations
+			Expect(none.Subnets()).To(BeEmpty())
+			_, ok := none.SubnetFor("10.244.5.6", false)
+			Expect(ok).To(BeFalse())
+			Expect(none.Allows("10.244.5.6", "10.255.30.0/24")).To(BeTrue())
+		})
+	})
+
+	Context("when a reserved subnet is not in any pool", func() {
+		It("returns an error", func() {
+			pool.IsMemberReturns(false)
+			_, err := leaser.NewStaticReservations([]config.StaticReservation{
+				{Underlay: "10.244.5.6", OverlaySubnet: "10.0.0.0/24"},
+			}, pool, nil)
+			Expect(err).To(MatchError("static reservation 10.0.0.0/24: not in the overlay network"))
+		})
+	})
+
+	Context("when an underlay is invalid", func() {
+		It("returns an error", func() {
+			_, err := leaser.NewStaticReservations([]config.StaticReservation{
+				{Underlay: "potato", OverlaySubnet: "10.255.30.0/24"},
+			}, pool)
+			Expect(err).To(MatchError(`static reservation: invalid underlay "potato"`))
+		})
+	})
+})
@@ -14,11 +14,15 @@ func (v *LeaseValidator) Validate(lease controller.Lease) error {
 		return fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
 	}
 
-	_, _, err := net.ParseCIDR(lease.OverlaySubnet)
+	_, overlaySubnet, err := net.ParseCIDR(lease.OverlaySubnet)
 	if err != nil {
 		return err
 	}
 
+	if _, bits := overlaySubnet.Mask.Size(); bits == 8*net.IPv6len && overlaySubnet.IP.To4() != nil {
+		return fmt.Errorf("invalid overlay subnet: %s is an ipv4-mapped ipv6 subnet", lease.OverlaySubnet)
+	}
+
 	_, err = net.ParseMAC(lease.OverlayHardwareAddr)
 	if err != nil {
 		return err
@@ -48,6 +48,26 @@ var _ = Describe("Validator", func() {
 		})
 	})
 
+	Context("when the overlay subnet is ipv6", func() {
+		BeforeEach(func() {
+			lease.OverlaySubnet = "fd00:10:255:4c::/64"
+		})
+		It("checks that the lease is valid", func() {
+			err := validator.Validate(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the overlay subnet is an ipv4-mapped ipv6 subnet", func() {
+		BeforeEach(func() {
+			lease.OverlaySubnet = "::ffff:10.255.76.0/120"
+		})
+		It("returns an error", func() {
+			err := validator.Validate(lease)
+			Expect(err).To(MatchError("invalid overlay subnet: ::ffff:10.255.76.0/120 is an ipv4-mapped ipv6 subnet"))
+		})
+	})
+
 	Context("when the hardware addr is invalid is invalid", func() {
 		BeforeEach(func() {
 			lease.OverlayHardwareAddr = "not-a-mac"
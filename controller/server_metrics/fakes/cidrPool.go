@@ -15,6 +15,17 @@ type CIDRPool struct {
 	blockPoolSizeReturnsOnCall map[int]struct {
 		result1 int
 	}
+	IsMemberStub        func(string) bool
+	isMemberMutex       sync.RWMutex
+	isMemberArgsForCall []struct {
+		arg1 string
+	}
+	isMemberReturns struct {
+		result1 bool
+	}
+	isMemberReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -59,11 +70,61 @@ func (fake *CIDRPool) BlockPoolSizeReturnsOnCall(i int, result1 int) {
 	}{result1}
 }
 
+func (fake *CIDRPool) IsMember(arg1 string) bool {
+	fake.isMemberMutex.Lock()
+	ret, specificReturn := fake.isMemberReturnsOnCall[len(fake.isMemberArgsForCall)]
+	fake.isMemberArgsForCall = append(fake.isMemberArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("IsMember", []interface{}{arg1})
+	fake.isMemberMutex.Unlock()
+	if fake.IsMemberStub != nil {
+		return fake.IsMemberStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.isMemberReturns.result1
+}
+
+func (fake *CIDRPool) IsMemberCallCount() int {
+	fake.isMemberMutex.RLock()
+	defer fake.isMemberMutex.RUnlock()
+	return len(fake.isMemberArgsForCall)
+}
+
+func (fake *CIDRPool) IsMemberArgsForCall(i int) string {
+	fake.isMemberMutex.RLock()
+	defer fake.isMemberMutex.RUnlock()
+	return fake.isMemberArgsForCall[i].arg1
+}
+
+func (fake *CIDRPool) IsMemberReturns(result1 bool) {
+	fake.IsMemberStub = nil
+	fake.isMemberReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *CIDRPool) IsMemberReturnsOnCall(i int, result1 bool) {
+	fake.IsMemberStub = nil
+	if fake.isMemberReturnsOnCall == nil {
+		fake.isMemberReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.isMemberReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *CIDRPool) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.blockPoolSizeMutex.RLock()
 	defer fake.blockPoolSizeMutex.RUnlock()
+	fake.isMemberMutex.RLock()
+	defer fake.isMemberMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
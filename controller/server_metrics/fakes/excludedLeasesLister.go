@@ -0,0 +1,91 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type ExcludedLeasesLister struct {
+	LeasesInExcludedRangesStub        func() ([]controller.Lease, error)
+	leasesInExcludedRangesMutex       sync.RWMutex
+	leasesInExcludedRangesArgsForCall []struct{}
+	leasesInExcludedRangesReturns     struct {
+		result1 []controller.Lease
+		result2 error
+	}
+	leasesInExcludedRangesReturnsOnCall map[int]struct {
+		result1 []controller.Lease
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ExcludedLeasesLister) LeasesInExcludedRanges() ([]controller.Lease, error) {
+	fake.leasesInExcludedRangesMutex.Lock()
+	ret, specificReturn := fake.leasesInExcludedRangesReturnsOnCall[len(fake.leasesInExcludedRangesArgsForCall)]
+	fake.leasesInExcludedRangesArgsForCall = append(fake.leasesInExcludedRangesArgsForCall, struct{}{})
+	fake.recordInvocation("LeasesInExcludedRanges", []interface{}{})
+	fake.leasesInExcludedRangesMutex.Unlock()
+	if fake.LeasesInExcludedRangesStub != nil {
+		return fake.LeasesInExcludedRangesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.leasesInExcludedRangesReturns.result1, fake.leasesInExcludedRangesReturns.result2
+}
+
+func (fake *ExcludedLeasesLister) LeasesInExcludedRangesCallCount() int {
+	fake.leasesInExcludedRangesMutex.RLock()
+	defer fake.leasesInExcludedRangesMutex.RUnlock()
+	return len(fake.leasesInExcludedRangesArgsForCall)
+}
+
+func (fake *ExcludedLeasesLister) LeasesInExcludedRangesReturns(result1 []controller.Lease, result2 error) {
+	fake.LeasesInExcludedRangesStub = nil
+	fake.leasesInExcludedRangesReturns = struct {
+		result1 []controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ExcludedLeasesLister) LeasesInExcludedRangesReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
+	fake.LeasesInExcludedRangesStub = nil
+	if fake.leasesInExcludedRangesReturnsOnCall == nil {
+		fake.leasesInExcludedRangesReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
+			result2 error
+		})
+	}
+	fake.leasesInExcludedRangesReturnsOnCall[i] = struct {
+		result1 []controller.Lease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ExcludedLeasesLister) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.leasesInExcludedRangesMutex.RLock()
+	defer fake.leasesInExcludedRangesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ExcludedLeasesLister) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
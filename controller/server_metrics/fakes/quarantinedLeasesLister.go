@@ -0,0 +1,91 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type QuarantinedLeasesLister struct {
+	QuarantinedLeasesStub        func() ([]controller.LeaseRecord, error)
+	quarantinedLeasesMutex       sync.RWMutex
+	quarantinedLeasesArgsForCall []struct{}
+	quarantinedLeasesReturns     struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	quarantinedLeasesReturnsOnCall map[int]struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *QuarantinedLeasesLister) QuarantinedLeases() ([]controller.LeaseRecord, error) {
+	fake.quarantinedLeasesMutex.Lock()
+	ret, specificReturn := fake.quarantinedLeasesReturnsOnCall[len(fake.quarantinedLeasesArgsForCall)]
+	fake.quarantinedLeasesArgsForCall = append(fake.quarantinedLeasesArgsForCall, struct{}{})
+	fake.recordInvocation("QuarantinedLeases", []interface{}{})
+	fake.quarantinedLeasesMutex.Unlock()
+	if fake.QuarantinedLeasesStub != nil {
+		return fake.QuarantinedLeasesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.quarantinedLeasesReturns.result1, fake.quarantinedLeasesReturns.result2
+}
+
+func (fake *QuarantinedLeasesLister) QuarantinedLeasesCallCount() int {
+	fake.quarantinedLeasesMutex.RLock()
+	defer fake.quarantinedLeasesMutex.RUnlock()
+	return len(fake.quarantinedLeasesArgsForCall)
+}
+
+func (fake *QuarantinedLeasesLister) QuarantinedLeasesReturns(result1 []controller.LeaseRecord, result2 error) {
+	fake.QuarantinedLeasesStub = nil
+	fake.quarantinedLeasesReturns = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *QuarantinedLeasesLister) QuarantinedLeasesReturnsOnCall(i int, result1 []controller.LeaseRecord, result2 error) {
+	fake.QuarantinedLeasesStub = nil
+	if fake.quarantinedLeasesReturnsOnCall == nil {
+		fake.quarantinedLeasesReturnsOnCall = make(map[int]struct {
+			result1 []controller.LeaseRecord
+			result2 error
+		})
+	}
+	fake.quarantinedLeasesReturnsOnCall[i] = struct {
+		result1 []controller.LeaseRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *QuarantinedLeasesLister) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.quarantinedLeasesMutex.RLock()
+	defer fake.quarantinedLeasesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *QuarantinedLeasesLister) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
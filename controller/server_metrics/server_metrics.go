@@ -1,6 +1,8 @@
 package server_metrics
 
 import (
+	"fmt"
+
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
 	"code.cloudfoundry.org/silk/controller"
 )
@@ -14,6 +16,17 @@ type databaseHandler interface {
 //go:generate counterfeiter -o fakes/cidrPool.go --fake-name CIDRPool . cidrPool
 type cidrPool interface {
 	BlockPoolSize() int
+	IsMember(string) bool
+}
+
+//go:generate counterfeiter -o fakes/excludedLeasesLister.go --fake-name ExcludedLeasesLister . excludedLeasesLister
+type excludedLeasesLister interface {
+	LeasesInExcludedRanges() ([]controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/quarantinedLeasesLister.go --fake-name QuarantinedLeasesLister . quarantinedLeasesLister
+type quarantinedLeasesLister interface {
+	QuarantinedLeases() ([]controller.LeaseRecord, error)
 }
 
 func NewTotalLeasesSource(lister databaseHandler) metrics.MetricSource {
@@ -27,14 +40,37 @@ func NewTotalLeasesSource(lister databaseHandler) metrics.MetricSource {
 	}
 }
 
-func NewFreeLeasesSource(lister databaseHandler, pool cidrPool) metrics.MetricSource {
+// NewFreeLeasesSource counts the blocks of the pools that no lease holds,
+// e.g. of the IPv4 and the IPv6 overlay.
+func NewFreeLeasesSource(lister databaseHandler, pools ...cidrPool) metrics.MetricSource {
+	return newFreeLeasesSource("freeLeases", lister, pools)
+}
+
+// NewAZFreeLeasesSource counts the free blocks in the ranges of an
+// availability zone.
+func NewAZFreeLeasesSource(lister databaseHandler, az string, pools ...cidrPool) metrics.MetricSource {
+	return newFreeLeasesSource(fmt.Sprintf("freeLeases.%s", az), lister, pools)
+}
+
+func newFreeLeasesSource(name string, lister databaseHandler, pools []cidrPool) metrics.MetricSource {
 	return metrics.MetricSource{
-		Name: "freeLeases",
+		Name: name,
 		Unit: "",
 		Getter: func() (float64, error) {
 			allLeases, err := lister.All()
-			size := pool.BlockPoolSize()
-			return float64(size - len(allLeases)), err
+			free := 0
+			for _, pool := range pools {
+				free += pool.BlockPoolSize()
+			}
+			for _, lease := range allLeases {
+				for _, pool := range pools {
+					if pool.IsMember(lease.OverlaySubnet) {
+						free--
+						break
+					}
+				}
+			}
+			return float64(free), err
 		},
 	}
 }
@@ -51,3 +87,25 @@ func NewStaleLeasesSource(lister databaseHandler, seconds int) metrics.MetricSou
 		},
 	}
 }
+
+func NewExcludedLeasesSource(lister excludedLeasesLister) metrics.MetricSource {
+	return metrics.MetricSource{
+		Name: "excludedLeases",
+		Unit: "",
+		Getter: func() (float64, error) {
+			excludedLeases, err := lister.LeasesInExcludedRanges()
+			return float64(len(excludedLeases)), err
+		},
+	}
+}
+
+func NewQuarantinedLeasesSource(lister quarantinedLeasesLister) metrics.MetricSource {
+	return metrics.MetricSource{
+		Name: "quarantinedLeases",
+		Unit: "",
+		Getter: func() (float64, error) {
+			quarantinedLeases, err := lister.QuarantinedLeases()
+			return float64(len(quarantinedLeases)), err
+		},
+	}
+}
@@ -33,6 +33,7 @@ var _ = Describe("ServerMetrics", func() {
 
 		fakeCIDRPool = &fakes.CIDRPool{}
 		fakeCIDRPool.BlockPoolSizeReturns(100)
+		fakeCIDRPool.IsMemberReturns(true)
 	})
 
 	Describe("totalLeases", func() {
@@ -64,6 +65,42 @@ var _ = Describe("ServerMetrics", func() {
 			Expect(fakeCIDRPool.BlockPoolSizeCallCount()).To(Equal(1))
 			Expect(value).To(Equal(98.0))
 		})
+
+		Context("when there are several pools", func() {
+			It("counts the free blocks of every pool", func() {
+				fakeCIDRPool.IsMemberStub = func(subnet string) bool {
+					return subnet == "10.255.16.0/24"
+				}
+				ipv6Pool := &fakes.CIDRPool{}
+				ipv6Pool.BlockPoolSizeReturns(50)
+				ipv6Pool.IsMemberStub = func(subnet string) bool {
+					return subnet == "fd00::/64"
+				}
+				fakeDatabaseHandler.AllReturns(append(allLeases, controller.Lease{
+					UnderlayIP:    "10.244.5.9",
+					OverlaySubnet: "fd00::/64",
+				}), nil)
+				source := server_metrics.NewFreeLeasesSource(fakeDatabaseHandler, fakeCIDRPool, ipv6Pool)
+
+				value, err := source.Getter()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(value).To(Equal(148.0))
+			})
+		})
+
+		Context("for an availability zone", func() {
+			It("counts the free blocks of the pools of the zone", func() {
+				fakeCIDRPool.IsMemberStub = func(subnet string) bool {
+					return subnet == "10.255.16.0/24"
+				}
+				source := server_metrics.NewAZFreeLeasesSource(fakeDatabaseHandler, "z1", fakeCIDRPool)
+
+				Expect(source.Name).To(Equal("freeLeases.z1"))
+				value, err := source.Getter()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(value).To(Equal(99.0))
+			})
+		})
 	})
 
 	Describe("staleLeases", func() {
@@ -83,4 +120,41 @@ var _ = Describe("ServerMetrics", func() {
 		})
 	})
 
+	Describe("excludedLeases", func() {
+		It("returns the number of leases overlapping an excluded range", func() {
+			fakeExcludedLeasesLister := &fakes.ExcludedLeasesLister{}
+			fakeExcludedLeasesLister.LeasesInExcludedRangesReturns([]controller.Lease{allLeases[1]}, nil)
+			source := server_metrics.NewExcludedLeasesSource(fakeExcludedLeasesLister)
+
+			Expect(source.Name).To(Equal("excludedLeases"))
+			Expect(source.Unit).To(Equal(""))
+
+			value, err := source.Getter()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeExcludedLeasesLister.LeasesInExcludedRangesCallCount()).To(Equal(1))
+			Expect(value).To(Equal(1.0))
+		})
+	})
+
+	Describe("quarantinedLeases", func() {
+		It("returns the number of quarantined leases", func() {
+			fakeQuarantinedLeasesLister := &fakes.QuarantinedLeasesLister{}
+			fakeQuarantinedLeasesLister.QuarantinedLeasesReturns([]controller.LeaseRecord{
+				{OverlaySubnet: "10.255.2.0/24", Expired: true, Quarantined: true},
+				{OverlaySubnet: "10.255.3.0/24", Expired: true, Quarantined: true},
+			}, nil)
+			source := server_metrics.NewQuarantinedLeasesSource(fakeQuarantinedLeasesLister)
+
+			Expect(source.Name).To(Equal("quarantinedLeases"))
+			Expect(source.Unit).To(Equal(""))
+
+			value, err := source.Getter()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeQuarantinedLeasesLister.QuarantinedLeasesCallCount()).To(Equal(1))
+			Expect(value).To(Equal(2.0))
+		})
+	})
+
 })
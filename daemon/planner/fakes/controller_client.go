@@ -8,15 +8,17 @@ import (
 )
 
 type ControllerClient struct {
-	GetActiveLeasesStub          func() ([]controller.Lease, error)
-	getRoutableLeasesMutex       sync.RWMutex
-	getRoutableLeasesArgsForCall []struct{}
-	getRoutableLeasesReturns     struct {
-		result1 []controller.Lease
+	GetLeasesSinceStub        func(revision string) (controller.LeasesResponse, error)
+	getLeasesSinceMutex       sync.RWMutex
+	getLeasesSinceArgsForCall []struct {
+		revision string
+	}
+	getLeasesSinceReturns struct {
+		result1 controller.LeasesResponse
 		result2 error
 	}
-	getRoutableLeasesReturnsOnCall map[int]struct {
-		result1 []controller.Lease
+	getLeasesSinceReturnsOnCall map[int]struct {
+		result1 controller.LeasesResponse
 		result2 error
 	}
 	RenewSubnetLeaseStub        func(controller.Lease) error
@@ -30,49 +32,71 @@ type ControllerClient struct {
 	renewSubnetLeaseReturnsOnCall map[int]struct {
 		result1 error
 	}
+	WatchLeasesStub        func(revision int64, timeoutSeconds int) (controller.WatchLeasesResponse, error)
+	watchLeasesMutex       sync.RWMutex
+	watchLeasesArgsForCall []struct {
+		revision       int64
+		timeoutSeconds int
+	}
+	watchLeasesReturns struct {
+		result1 controller.WatchLeasesResponse
+		result2 error
+	}
+	watchLeasesReturnsOnCall map[int]struct {
+		result1 controller.WatchLeasesResponse
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *ControllerClient) GetActiveLeases() ([]controller.Lease, error) {
-	fake.getRoutableLeasesMutex.Lock()
-	ret, specificReturn := fake.getRoutableLeasesReturnsOnCall[len(fake.getRoutableLeasesArgsForCall)]
-	fake.getRoutableLeasesArgsForCall = append(fake.getRoutableLeasesArgsForCall, struct{}{})
-	fake.recordInvocation("GetActiveLeases", []interface{}{})
-	fake.getRoutableLeasesMutex.Unlock()
-	if fake.GetActiveLeasesStub != nil {
-		return fake.GetActiveLeasesStub()
+func (fake *ControllerClient) GetLeasesSince(revision string) (controller.LeasesResponse, error) {
+	fake.getLeasesSinceMutex.Lock()
+	ret, specificReturn := fake.getLeasesSinceReturnsOnCall[len(fake.getLeasesSinceArgsForCall)]
+	fake.getLeasesSinceArgsForCall = append(fake.getLeasesSinceArgsForCall, struct {
+		revision string
+	}{revision})
+	fake.recordInvocation("GetLeasesSince", []interface{}{revision})
+	fake.getLeasesSinceMutex.Unlock()
+	if fake.GetLeasesSinceStub != nil {
+		return fake.GetLeasesSinceStub(revision)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.getRoutableLeasesReturns.result1, fake.getRoutableLeasesReturns.result2
+	return fake.getLeasesSinceReturns.result1, fake.getLeasesSinceReturns.result2
+}
+
+func (fake *ControllerClient) GetLeasesSinceCallCount() int {
+	fake.getLeasesSinceMutex.RLock()
+	defer fake.getLeasesSinceMutex.RUnlock()
+	return len(fake.getLeasesSinceArgsForCall)
 }
 
-func (fake *ControllerClient) GetActiveLeasesCallCount() int {
-	fake.getRoutableLeasesMutex.RLock()
-	defer fake.getRoutableLeasesMutex.RUnlock()
-	return len(fake.getRoutableLeasesArgsForCall)
+func (fake *ControllerClient) GetLeasesSinceArgsForCall(i int) string {
+	fake.getLeasesSinceMutex.RLock()
+	defer fake.getLeasesSinceMutex.RUnlock()
+	return fake.getLeasesSinceArgsForCall[i].revision
 }
 
-func (fake *ControllerClient) GetActiveLeasesReturns(result1 []controller.Lease, result2 error) {
-	fake.GetActiveLeasesStub = nil
-	fake.getRoutableLeasesReturns = struct {
-		result1 []controller.Lease
+func (fake *ControllerClient) GetLeasesSinceReturns(result1 controller.LeasesResponse, result2 error) {
+	fake.GetLeasesSinceStub = nil
+	fake.getLeasesSinceReturns = struct {
+		result1 controller.LeasesResponse
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *ControllerClient) GetActiveLeasesReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
-	fake.GetActiveLeasesStub = nil
-	if fake.getRoutableLeasesReturnsOnCall == nil {
-		fake.getRoutableLeasesReturnsOnCall = make(map[int]struct {
-			result1 []controller.Lease
+func (fake *ControllerClient) GetLeasesSinceReturnsOnCall(i int, result1 controller.LeasesResponse, result2 error) {
+	fake.GetLeasesSinceStub = nil
+	if fake.getLeasesSinceReturnsOnCall == nil {
+		fake.getLeasesSinceReturnsOnCall = make(map[int]struct {
+			result1 controller.LeasesResponse
 			result2 error
 		})
 	}
-	fake.getRoutableLeasesReturnsOnCall[i] = struct {
-		result1 []controller.Lease
+	fake.getLeasesSinceReturnsOnCall[i] = struct {
+		result1 controller.LeasesResponse
 		result2 error
 	}{result1, result2}
 }
@@ -125,14 +149,72 @@ func (fake *ControllerClient) RenewSubnetLeaseReturnsOnCall(i int, result1 error
 	}{result1}
 }
 
+func (fake *ControllerClient) WatchLeases(revision int64, timeoutSeconds int) (controller.WatchLeasesResponse, error) {
+	fake.watchLeasesMutex.Lock()
+	ret, specificReturn := fake.watchLeasesReturnsOnCall[len(fake.watchLeasesArgsForCall)]
+	fake.watchLeasesArgsForCall = append(fake.watchLeasesArgsForCall, struct {
+		revision       int64
+		timeoutSeconds int
+	}{revision, timeoutSeconds})
+	fake.recordInvocation("WatchLeases", []interface{}{revision, timeoutSeconds})
+	fake.watchLeasesMutex.Unlock()
+	if fake.WatchLeasesStub != nil {
+		return fake.WatchLeasesStub(revision, timeoutSeconds)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.watchLeasesReturns.result1, fake.watchLeasesReturns.result2
+}
+
+func (fake *ControllerClient) WatchLeasesCallCount() int {
+	fake.watchLeasesMutex.RLock()
+	defer fake.watchLeasesMutex.RUnlock()
+	return len(fake.watchLeasesArgsForCall)
+}
+
+func (fake *ControllerClient) WatchLeasesArgsForCall(i int) (int64, int) {
+	fake.watchLeasesMutex.RLock()
+	defer fake.watchLeasesMutex.RUnlock()
+	return fake.watchLeasesArgsForCall[i].revision, fake.watchLeasesArgsForCall[i].timeoutSeconds
+}
+
+func (fake *ControllerClient) WatchLeasesReturns(result1 controller.WatchLeasesResponse, result2 error) {
+	fake.WatchLeasesStub = nil
+	fake.watchLeasesReturns = struct {
+		result1 controller.WatchLeasesResponse
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ControllerClient) WatchLeasesReturnsOnCall(i int, result1 controller.WatchLeasesResponse, result2 error) {
+	fake.WatchLeasesStub = nil
+	if fake.watchLeasesReturnsOnCall == nil {
+		fake.watchLeasesReturnsOnCall = make(map[int]struct {
+			result1 controller.WatchLeasesResponse
+			result2 error
+		})
+	}
+	fake.watchLeasesReturnsOnCall[i] = struct {
+		result1 controller.WatchLeasesResponse
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *ControllerClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.getRoutableLeasesMutex.RLock()
-	defer fake.getRoutableLeasesMutex.RUnlock()
+	fake.getLeasesSinceMutex.RLock()
+	defer fake.getLeasesSinceMutex.RUnlock()
 	fake.renewSubnetLeaseMutex.RLock()
 	defer fake.renewSubnetLeaseMutex.RUnlock()
-	return fake.invocations
+	fake.watchLeasesMutex.RLock()
+	defer fake.watchLeasesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
 }
 
 func (fake *ControllerClient) recordInvocation(key string, args []interface{}) {
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
 )
 
 type Converger struct {
@@ -19,6 +20,30 @@ type Converger struct {
 	convergeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	PlanStub        func([]controller.Lease) (vtep.Plan, error)
+	planMutex       sync.RWMutex
+	planArgsForCall []struct {
+		arg1 []controller.Lease
+	}
+	planReturns struct {
+		result1 vtep.Plan
+		result2 error
+	}
+	planReturnsOnCall map[int]struct {
+		result1 vtep.Plan
+		result2 error
+	}
+	ApplyStub        func(vtep.Plan) error
+	applyMutex       sync.RWMutex
+	applyArgsForCall []struct {
+		arg1 vtep.Plan
+	}
+	applyReturns struct {
+		result1 error
+	}
+	applyReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -76,12 +101,124 @@ func (fake *Converger) ConvergeReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Converger) Plan(arg1 []controller.Lease) (vtep.Plan, error) {
+	var arg1Copy []controller.Lease
+	if arg1 != nil {
+		arg1Copy = make([]controller.Lease, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.planMutex.Lock()
+	ret, specificReturn := fake.planReturnsOnCall[len(fake.planArgsForCall)]
+	fake.planArgsForCall = append(fake.planArgsForCall, struct {
+		arg1 []controller.Lease
+	}{arg1Copy})
+	fake.recordInvocation("Plan", []interface{}{arg1Copy})
+	fake.planMutex.Unlock()
+	if fake.PlanStub != nil {
+		return fake.PlanStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.planReturns.result1, fake.planReturns.result2
+}
+
+func (fake *Converger) PlanCallCount() int {
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	return len(fake.planArgsForCall)
+}
+
+func (fake *Converger) PlanArgsForCall(i int) []controller.Lease {
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	return fake.planArgsForCall[i].arg1
+}
+
+func (fake *Converger) PlanReturns(result1 vtep.Plan, result2 error) {
+	fake.PlanStub = nil
+	fake.planReturns = struct {
+		result1 vtep.Plan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Converger) PlanReturnsOnCall(i int, result1 vtep.Plan, result2 error) {
+	fake.PlanStub = nil
+	if fake.planReturnsOnCall == nil {
+		fake.planReturnsOnCall = make(map[int]struct {
+			result1 vtep.Plan
+			result2 error
+		})
+	}
+	fake.planReturnsOnCall[i] = struct {
+		result1 vtep.Plan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Converger) Apply(arg1 vtep.Plan) error {
+	fake.applyMutex.Lock()
+	ret, specificReturn := fake.applyReturnsOnCall[len(fake.applyArgsForCall)]
+	fake.applyArgsForCall = append(fake.applyArgsForCall, struct {
+		arg1 vtep.Plan
+	}{arg1})
+	fake.recordInvocation("Apply", []interface{}{arg1})
+	fake.applyMutex.Unlock()
+	if fake.ApplyStub != nil {
+		return fake.ApplyStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.applyReturns.result1
+}
+
+func (fake *Converger) ApplyCallCount() int {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return len(fake.applyArgsForCall)
+}
+
+func (fake *Converger) ApplyArgsForCall(i int) vtep.Plan {
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	return fake.applyArgsForCall[i].arg1
+}
+
+func (fake *Converger) ApplyReturns(result1 error) {
+	fake.ApplyStub = nil
+	fake.applyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Converger) ApplyReturnsOnCall(i int, result1 error) {
+	fake.ApplyStub = nil
+	if fake.applyReturnsOnCall == nil {
+		fake.applyReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.applyReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Converger) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.convergeMutex.RLock()
 	defer fake.convergeMutex.RUnlock()
-	return fake.invocations
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	fake.applyMutex.RLock()
+	defer fake.applyMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
 }
 
 func (fake *Converger) recordInvocation(key string, args []interface{}) {
@@ -0,0 +1,91 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+type PlanSource struct {
+	PlanStub        func() (vtep.Plan, error)
+	planMutex       sync.RWMutex
+	planArgsForCall []struct{}
+	planReturns     struct {
+		result1 vtep.Plan
+		result2 error
+	}
+	planReturnsOnCall map[int]struct {
+		result1 vtep.Plan
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *PlanSource) Plan() (vtep.Plan, error) {
+	fake.planMutex.Lock()
+	ret, specificReturn := fake.planReturnsOnCall[len(fake.planArgsForCall)]
+	fake.planArgsForCall = append(fake.planArgsForCall, struct{}{})
+	fake.recordInvocation("Plan", []interface{}{})
+	fake.planMutex.Unlock()
+	if fake.PlanStub != nil {
+		return fake.PlanStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.planReturns.result1, fake.planReturns.result2
+}
+
+func (fake *PlanSource) PlanCallCount() int {
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	return len(fake.planArgsForCall)
+}
+
+func (fake *PlanSource) PlanReturns(result1 vtep.Plan, result2 error) {
+	fake.PlanStub = nil
+	fake.planReturns = struct {
+		result1 vtep.Plan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *PlanSource) PlanReturnsOnCall(i int, result1 vtep.Plan, result2 error) {
+	fake.PlanStub = nil
+	if fake.planReturnsOnCall == nil {
+		fake.planReturnsOnCall = make(map[int]struct {
+			result1 vtep.Plan
+			result2 error
+		})
+	}
+	fake.planReturnsOnCall[i] = struct {
+		result1 vtep.Plan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *PlanSource) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.planMutex.RLock()
+	defer fake.planMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *PlanSource) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,48 @@
+package planner
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+//go:generate counterfeiter -o fakes/planSource.go --fake-name PlanSource . planSource
+type planSource interface {
+	Plan() (vtep.Plan, error)
+}
+
+// PlanHandler serves the changes the next converge would make.
+type PlanHandler struct {
+	Logger     lager.Logger
+	PlanSource planSource
+}
+
+func (h *PlanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("plan")
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, err := h.PlanSource.Plan()
+	if err != nil {
+		logger.Error("plan", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "plan"}`))
+		return
+	}
+
+	bytes, err := json.Marshal(plan)
+	if err != nil {
+		logger.Error("marshal-plan", err) // not tested
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "marshal plan"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+}
@@ -0,0 +1,79 @@
+package planner_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/daemon/planner"
+	"code.cloudfoundry.org/silk/daemon/planner/fakes"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("PlanHandler", func() {
+	var (
+		logger     *lagertest.TestLogger
+		planSource *fakes.PlanSource
+		handler    *planner.PlanHandler
+		resp       *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		planSource = &fakes.PlanSource{}
+		planSource.PlanReturns(vtep.Plan{NonRoutableLeases: 3}, nil)
+		handler = &planner.PlanHandler{
+			Logger:     logger,
+			PlanSource: planSource,
+		}
+		resp = httptest.NewRecorder()
+		var err error
+		request, err = http.NewRequest("GET", "/plan", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("serves the plan as json", func() {
+		handler.ServeHTTP(resp, request)
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp.Body.String()).To(MatchJSON(`{
+			"routes": {"add": [], "update": [], "delete": []},
+			"arp": {"add": [], "update": [], "delete": []},
+			"fdb": {"add": [], "update": [], "delete": []},
+			"non_routable_leases": 3
+		}`))
+	})
+
+	Context("when the method is not GET", func() {
+		BeforeEach(func() {
+			request.Method = "POST"
+		})
+
+		It("does not plan", func() {
+			handler.ServeHTTP(resp, request)
+
+			Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(planSource.PlanCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when planning fails", func() {
+		BeforeEach(func() {
+			planSource.PlanReturns(vtep.Plan{}, errors.New("potato"))
+		})
+
+		It("logs the error and returns a 500", func() {
+			handler.ServeHTTP(resp, request)
+
+			Expect(resp.Code).To(Equal(http.StatusInternalServerError))
+			Expect(resp.Body.String()).To(MatchJSON(`{"error": "plan"}`))
+			Expect(logger).To(gbytes.Say("test.plan.plan.*potato"))
+		})
+	})
+})
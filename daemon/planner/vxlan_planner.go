@@ -2,21 +2,26 @@ package planner
 
 import (
 	"fmt"
+	"sync"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/silk/daemon/vtep"
 )
 
 //go:generate counterfeiter -o fakes/controller_client.go --fake-name ControllerClient . controllerClient
 type controllerClient interface {
-	GetActiveLeases() ([]controller.Lease, error)
+	GetLeasesSince(revision string) (controller.LeasesResponse, error)
 	RenewSubnetLease(controller.Lease) error
+	WatchLeases(revision int64, timeoutSeconds int) (controller.WatchLeasesResponse, error)
 }
 
 //go:generate counterfeiter -o fakes/converger.go --fake-name Converger . converger
 type converger interface {
 	Converge([]controller.Lease) error
+	Plan([]controller.Lease) (vtep.Plan, error)
+	Apply(vtep.Plan) error
 }
 
 //go:generate counterfeiter -o fakes/metricSender.go --fake-name MetricSender . metricSender
@@ -32,6 +37,17 @@ type VXLANPlanner struct {
 	Lease            controller.Lease
 	ErrorDetector    FatalErrorDetector
 	MetricSender     metricSender
+
+	WatchTimeoutSeconds int
+
+	convergeMutex sync.Mutex
+	revision      int64
+	// desiredLeases are the leases of the last converge, set before the
+	// converger runs so removals it makes itself are not seen as drift.
+	desiredLeases []controller.Lease
+
+	leases         []controller.Lease
+	leasesRevision string
 }
 
 func (v *VXLANPlanner) DoCycle() error {
@@ -48,14 +64,90 @@ func (v *VXLANPlanner) DoCycle() error {
 
 	v.MetricSender.IncrementCounter("renewSuccess")
 
-	leases, err := v.ControllerClient.GetActiveLeases()
+	response, err := v.ControllerClient.GetLeasesSince(v.leasesRevision)
 	if err != nil {
 		return fmt.Errorf("get routable leases: %s", err)
 	}
+	v.leases = response.Apply(v.leases)
+	v.leasesRevision = response.Revision
+
+	// Converging on unchanged leases still restores the routes and neighbor
+	// entries that something else removed or changed.
+	return v.converge(v.leases)
+}
+
+// WatchCycle waits for the controller to report a new lease revision and
+// converges on the leases it returns.
+func (v *VXLANPlanner) WatchCycle() error {
+	response, err := v.ControllerClient.WatchLeases(v.revision, v.WatchTimeoutSeconds)
+	if err != nil {
+		return fmt.Errorf("watch leases: %s", err)
+	}
+	if response.Revision == v.revision {
+		return nil
+	}
+
+	err = v.converge(response.Leases)
+	if err != nil {
+		return err
+	}
+	v.revision = response.Revision
+	return nil
+}
+
+// RepairDrift converges only the drifted routes and neighbor entries on the
+// leases of the last converge. The entries are compared with the leases, so
+// drift that the converger made itself, or that already went away, needs no
+// repair.
+func (v *VXLANPlanner) RepairDrift(drift vtep.Drift) (bool, error) {
+	v.convergeMutex.Lock()
+	defer v.convergeMutex.Unlock()
+
+	if v.desiredLeases == nil {
+		return false, nil
+	}
+
+	plan, err := v.Converger.Plan(v.desiredLeases)
+	if err != nil {
+		return false, fmt.Errorf("plan leases: %s", err)
+	}
+	plan = plan.Only(drift)
+	if adds, updates, deletes := plan.Counts(); adds+updates+deletes == 0 {
+		return false, nil
+	}
+
+	err = v.Converger.Apply(plan)
+	if err != nil {
+		v.MetricSender.IncrementCounter("convergeFailure")
+		return false, fmt.Errorf("apply plan: %s", err)
+	}
+	v.MetricSender.IncrementCounter("driftRepaired")
+	return true, nil
+}
+
+// Plan returns what converging on the current leases of the controller would
+// change on the VTEP, without changing it.
+func (v *VXLANPlanner) Plan() (vtep.Plan, error) {
+	response, err := v.ControllerClient.GetLeasesSince("")
+	if err != nil {
+		return vtep.Plan{}, fmt.Errorf("get routable leases: %s", err)
+	}
+
+	plan, err := v.Converger.Plan(response.Apply(nil))
+	if err != nil {
+		return vtep.Plan{}, fmt.Errorf("plan leases: %s", err)
+	}
+	return plan, nil
+}
+
+func (v *VXLANPlanner) converge(leases []controller.Lease) error {
+	v.convergeMutex.Lock()
+	defer v.convergeMutex.Unlock()
 
+	v.desiredLeases = leases
 	v.MetricSender.SendValue("numberLeases", float64(len(leases)), "")
 
-	err = v.Converger.Converge(leases)
+	err := v.Converger.Converge(leases)
 	if err != nil {
 		v.MetricSender.IncrementCounter("convergeFailure")
 		return fmt.Errorf("converge leases: %s", err)
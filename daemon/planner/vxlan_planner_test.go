@@ -2,6 +2,7 @@ package planner_test
 
 import (
 	"errors"
+	"net"
 
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -9,10 +10,12 @@ import (
 	"code.cloudfoundry.org/silk/daemon"
 	"code.cloudfoundry.org/silk/daemon/planner"
 	"code.cloudfoundry.org/silk/daemon/planner/fakes"
+	"code.cloudfoundry.org/silk/daemon/vtep"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/types"
+	"github.com/vishvananda/netlink"
 )
 
 var LogsWith = func(level lager.LogLevel, msg string) types.GomegaMatcher {
@@ -75,7 +78,10 @@ var _ = Describe("VxlanPlanner", func() {
 				OverlaySubnet:       "10.244.16.0/24",
 				OverlayHardwareAddr: "ee:ee:0a:f4:10:00",
 			}}
-			controllerClient.GetActiveLeasesReturns(leases, nil)
+			controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{
+				Revision: "some-revision",
+				Leases:   leases,
+			}, nil)
 		})
 
 		It("calls the controller to renew its lease", func() {
@@ -124,7 +130,10 @@ var _ = Describe("VxlanPlanner", func() {
 				OverlaySubnet:       "10.244.17.0/24",
 				OverlayHardwareAddr: "ee:ee:0a:f6:10:00",
 			})
-			controllerClient.GetActiveLeasesReturns(leases, nil)
+			controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{
+				Revision: "some-other-revision",
+				Leases:   leases,
+			}, nil)
 
 			err = vxlanPlanner.DoCycle()
 			name, value, unit = metricSender.SendValueArgsForCall(1)
@@ -163,6 +172,79 @@ var _ = Describe("VxlanPlanner", func() {
 			Expect(metricSender.IncrementCounterArgsForCall(1)).To(Equal("convergeSuccess"))
 		})
 
+		It("applies the changes since the last revision to its leases", func() {
+			err := vxlanPlanner.DoCycle()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(controllerClient.GetLeasesSinceArgsForCall(0)).To(Equal(""))
+
+			newLease := controller.Lease{
+				UnderlayIP:          "172.244.18.0",
+				OverlaySubnet:       "10.244.18.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:f4:12:00",
+			}
+			controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{
+				Revision: "some-other-revision",
+				Delta:    true,
+				Added:    []controller.Lease{newLease},
+				Removed:  leases[:1],
+			}, nil)
+
+			err = vxlanPlanner.DoCycle()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(controllerClient.GetLeasesSinceArgsForCall(1)).To(Equal("some-revision"))
+
+			Expect(converger.ConvergeCallCount()).To(Equal(2))
+			Expect(converger.ConvergeArgsForCall(1)).To(Equal([]controller.Lease{leases[1], newLease}))
+		})
+
+		Context("when the leases have not changed since the last converge", func() {
+			BeforeEach(func() {
+				Expect(vxlanPlanner.DoCycle()).To(Succeed())
+
+				controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{
+					Revision: "some-revision",
+					Delta:    true,
+				}, nil)
+			})
+
+			It("converges again on the leases it already has", func() {
+				Expect(vxlanPlanner.DoCycle()).To(Succeed())
+
+				Expect(controllerClient.GetLeasesSinceArgsForCall(1)).To(Equal("some-revision"))
+				Expect(converger.ConvergeCallCount()).To(Equal(2))
+				Expect(converger.ConvergeArgsForCall(1)).To(Equal(leases))
+			})
+
+			Context("when a route was lost since the last converge", func() {
+				It("restores it", func() {
+					routes := map[string]bool{}
+					converger.ConvergeStub = func(leases []controller.Lease) error {
+						for _, lease := range leases {
+							routes[lease.OverlaySubnet] = true
+						}
+						return nil
+					}
+					Expect(vxlanPlanner.DoCycle()).To(Succeed())
+					delete(routes, "10.244.16.0/24")
+
+					Expect(vxlanPlanner.DoCycle()).To(Succeed())
+					Expect(routes).To(HaveKey("10.244.16.0/24"))
+				})
+			})
+
+			Context("when the last converge failed", func() {
+				It("converges again", func() {
+					converger.ConvergeReturnsOnCall(1, errors.New("banana"))
+					Expect(vxlanPlanner.DoCycle()).NotTo(Succeed())
+
+					Expect(vxlanPlanner.DoCycle()).To(Succeed())
+
+					Expect(converger.ConvergeCallCount()).To(Equal(3))
+					Expect(converger.ConvergeArgsForCall(2)).To(Equal(leases))
+				})
+			})
+		})
+
 		Context("when renewing the subnet lease fails", func() {
 			Context("when the error is detected as non-fatal", func() {
 				BeforeEach(func() {
@@ -207,7 +289,7 @@ var _ = Describe("VxlanPlanner", func() {
 
 		Context("when getting the routable releases fails", func() {
 			BeforeEach(func() {
-				controllerClient.GetActiveLeasesReturns(nil, errors.New("guava"))
+				controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{}, errors.New("guava"))
 			})
 			It("returns the error", func() {
 				err := vxlanPlanner.DoCycle()
@@ -229,4 +311,232 @@ var _ = Describe("VxlanPlanner", func() {
 			})
 		})
 	})
+
+	Describe("WatchCycle", func() {
+		var leases []controller.Lease
+
+		BeforeEach(func() {
+			vxlanPlanner.WatchTimeoutSeconds = 2
+			leases = []controller.Lease{{
+				UnderlayIP:          "172.244.15.0",
+				OverlaySubnet:       "10.244.15.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:f4:0f:00",
+			}}
+			controllerClient.WatchLeasesReturns(controller.WatchLeasesResponse{
+				Revision: 3,
+				Leases:   leases,
+			}, nil)
+		})
+
+		It("watches for the next revision and converges on the returned leases", func() {
+			err := vxlanPlanner.WatchCycle()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(controllerClient.WatchLeasesCallCount()).To(Equal(1))
+			revision, timeoutSeconds := controllerClient.WatchLeasesArgsForCall(0)
+			Expect(revision).To(Equal(int64(0)))
+			Expect(timeoutSeconds).To(Equal(2))
+
+			Expect(converger.ConvergeCallCount()).To(Equal(1))
+			Expect(converger.ConvergeArgsForCall(0)).To(Equal(leases))
+			Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("convergeSuccess"))
+
+			By("watching from the returned revision on the next cycle")
+			err = vxlanPlanner.WatchCycle()
+			Expect(err).NotTo(HaveOccurred())
+
+			revision, _ = controllerClient.WatchLeasesArgsForCall(1)
+			Expect(revision).To(Equal(int64(3)))
+		})
+
+		Context("when the watch times out without a new revision", func() {
+			It("does not converge again", func() {
+				Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+				Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+
+				Expect(converger.ConvergeCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when watching the leases fails", func() {
+			BeforeEach(func() {
+				controllerClient.WatchLeasesReturns(controller.WatchLeasesResponse{}, errors.New("guava"))
+			})
+			It("returns the error", func() {
+				err := vxlanPlanner.WatchCycle()
+				Expect(err).To(MatchError("watch leases: guava"))
+				Expect(converger.ConvergeCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the converger fails", func() {
+			BeforeEach(func() {
+				converger.ConvergeReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := vxlanPlanner.WatchCycle()
+				Expect(err).To(MatchError("converge leases: banana"))
+				Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("convergeFailure"))
+			})
+		})
+	})
+
+	Describe("RepairDrift", func() {
+		var leases []controller.Lease
+
+		BeforeEach(func() {
+			leases = []controller.Lease{{
+				UnderlayIP:          "172.244.15.0",
+				OverlaySubnet:       "10.244.15.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:f4:0f:00",
+			}}
+			controllerClient.WatchLeasesReturns(controller.WatchLeasesResponse{
+				Revision: 3,
+				Leases:   leases,
+			}, nil)
+		})
+
+		var plan vtep.Plan
+
+		BeforeEach(func() {
+			_, dst, _ := net.ParseCIDR("10.244.15.0/24")
+			_, otherDst, _ := net.ParseCIDR("10.244.16.0/24")
+			plan = vtep.Plan{
+				Routes: vtep.RouteChanges{
+					Add:    []netlink.Route{{Dst: dst, Gw: net.ParseIP("10.244.15.0")}},
+					Delete: []netlink.Route{{Dst: otherDst, Gw: net.ParseIP("10.244.16.0")}},
+				},
+			}
+			converger.PlanReturns(plan, nil)
+		})
+
+		It("applies the changes to the drifted entries planned from the last leases", func() {
+			Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+
+			repaired, err := vxlanPlanner.RepairDrift(vtep.Drift{IPs: []net.IP{net.ParseIP("10.244.15.0")}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repaired).To(BeTrue())
+
+			Expect(converger.PlanCallCount()).To(Equal(1))
+			Expect(converger.PlanArgsForCall(0)).To(Equal(leases))
+			Expect(converger.ApplyCallCount()).To(Equal(1))
+			Expect(converger.ApplyArgsForCall(0)).To(Equal(vtep.Plan{
+				Routes: vtep.RouteChanges{Add: plan.Routes.Add},
+			}))
+			Expect(converger.ConvergeCallCount()).To(Equal(1))
+			Expect(controllerClient.WatchLeasesCallCount()).To(Equal(1))
+			Expect(metricSender.IncrementCounterArgsForCall(1)).To(Equal("driftRepaired"))
+		})
+
+		It("applies every change when everything drifted", func() {
+			Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+
+			repaired, err := vxlanPlanner.RepairDrift(vtep.Drift{All: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repaired).To(BeTrue())
+			Expect(converger.ApplyArgsForCall(0)).To(Equal(plan))
+		})
+
+		Context("when the drifted entries match the leases", func() {
+			It("does not apply anything", func() {
+				Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+
+				repaired, err := vxlanPlanner.RepairDrift(vtep.Drift{IPs: []net.IP{net.ParseIP("10.244.99.0")}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(BeFalse())
+				Expect(converger.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when nothing has been converged yet", func() {
+			It("does not plan", func() {
+				repaired, err := vxlanPlanner.RepairDrift(vtep.Drift{All: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(BeFalse())
+				Expect(converger.PlanCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when planning fails", func() {
+			It("returns an error", func() {
+				Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+				converger.PlanReturns(vtep.Plan{}, errors.New("banana"))
+
+				_, err := vxlanPlanner.RepairDrift(vtep.Drift{All: true})
+				Expect(err).To(MatchError("plan leases: banana"))
+				Expect(converger.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when applying fails", func() {
+			It("returns an error", func() {
+				Expect(vxlanPlanner.WatchCycle()).To(Succeed())
+				converger.ApplyReturns(errors.New("banana"))
+
+				_, err := vxlanPlanner.RepairDrift(vtep.Drift{All: true})
+				Expect(err).To(MatchError("apply plan: banana"))
+				Expect(metricSender.IncrementCounterArgsForCall(1)).To(Equal("convergeFailure"))
+			})
+		})
+	})
+
+	Describe("Plan", func() {
+		var (
+			leases []controller.Lease
+			plan   vtep.Plan
+		)
+
+		BeforeEach(func() {
+			leases = []controller.Lease{{
+				UnderlayIP:          "172.244.15.0",
+				OverlaySubnet:       "10.244.15.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:f4:0f:00",
+			}}
+			controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{
+				Revision: "some-revision",
+				Leases:   leases,
+			}, nil)
+			plan = vtep.Plan{NonRoutableLeases: 1}
+			converger.PlanReturns(plan, nil)
+		})
+
+		It("plans the current leases of the controller without converging", func() {
+			Expect(vxlanPlanner.Plan()).To(Equal(plan))
+
+			Expect(controllerClient.GetLeasesSinceArgsForCall(0)).To(Equal(""))
+			Expect(converger.PlanArgsForCall(0)).To(Equal(leases))
+			Expect(converger.ConvergeCallCount()).To(Equal(0))
+			Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(0))
+		})
+
+		It("does not change the revision of the next cycle", func() {
+			_, err := vxlanPlanner.Plan()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(vxlanPlanner.DoCycle()).To(Succeed())
+			Expect(controllerClient.GetLeasesSinceArgsForCall(1)).To(Equal(""))
+		})
+
+		Context("when getting the leases fails", func() {
+			BeforeEach(func() {
+				controllerClient.GetLeasesSinceReturns(controller.LeasesResponse{}, errors.New("guava"))
+			})
+
+			It("returns an error", func() {
+				_, err := vxlanPlanner.Plan()
+				Expect(err).To(MatchError("get routable leases: guava"))
+			})
+		})
+
+		Context("when planning fails", func() {
+			BeforeEach(func() {
+				converger.PlanReturns(vtep.Plan{}, errors.New("guava"))
+			})
+
+			It("returns an error", func() {
+				_, err := vxlanPlanner.Plan()
+				Expect(err).To(MatchError("plan leases: guava"))
+			})
+		})
+	})
 })
@@ -70,16 +70,21 @@ func (c *ConfigCreator) Create(clientConf clientConfig.Config, lease controller.
 		return nil, fmt.Errorf("parsing hardware address: %s", err)
 	}
 
-	_, overlayNetwork, err := net.ParseCIDR(clientConf.OverlayNetwork)
+	leaseNetwork, ok := clientConf.OverlayNetworkFor(overlayIP)
+	if !ok {
+		leaseNetwork = clientConf.OverlayNetworks()[0]
+	}
+
+	_, overlayNetwork, err := net.ParseCIDR(leaseNetwork.Network)
 	if err != nil {
 		return nil, fmt.Errorf("determine overlay network: %s", err)
 	}
 
 	overlayNetworkPrefixLength, _ := overlayNetwork.Mask.Size()
 
-	if overlayNetworkPrefixLength >= clientConf.SubnetPrefixLength {
+	if overlayNetworkPrefixLength >= leaseNetwork.SubnetPrefixLength {
 		return nil, fmt.Errorf("overlay prefix %d must be smaller than subnet prefix %d",
-			overlayNetworkPrefixLength, clientConf.SubnetPrefixLength)
+			overlayNetworkPrefixLength, leaseNetwork.SubnetPrefixLength)
 	}
 
 	return &Config{
@@ -110,7 +115,7 @@ func (c *ConfigCreator) locateInterface(toFind net.IP) (net.Interface, error) {
 			if err != nil {
 				return net.Interface{}, fmt.Errorf("parse address: %s", err)
 			}
-			if ip.String() == toFind.String() {
+			if ip.Equal(toFind) {
 				return iface, nil
 			}
 		}
@@ -68,6 +68,28 @@ var _ = Describe("ConfigCreator", func() {
 			Expect(fakeNetAdapter.InterfaceByNameCallCount()).To(Equal(0))
 		})
 
+		Context("when the underlay ip is IPv6", func() {
+			BeforeEach(func() {
+				clientConf.UnderlayIP = "fd00:172:255:30::2"
+				fakeNetAdapter.InterfacesReturns([]net.Interface{{Index: 41}, {Index: 42}}, nil)
+				fakeNetAdapter.InterfaceAddrsStub = func(iface net.Interface) ([]net.Addr, error) {
+					if iface.Index == 41 {
+						return []net.Addr{&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}}, nil
+					}
+					return []net.Addr{&net.IPNet{IP: net.ParseIP("fd00:172:255:30:0:0:0:2"), Mask: net.CIDRMask(64, 128)}}, nil
+				}
+			})
+
+			It("finds the interface with that address and keeps the IPv4 overlay", func() {
+				conf, err := creator.Create(clientConf, lease)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conf.UnderlayInterface).To(Equal(net.Interface{Index: 42}))
+				Expect(conf.UnderlayIP.String()).To(Equal("fd00:172:255:30::2"))
+				Expect(conf.OverlayIP.String()).To(Equal("10.255.30.0"))
+				Expect(conf.OverlayNetworkPrefixLength).To(Equal(16))
+			})
+		})
+
 		Context("when VxlanInterfaceName is set", func() {
 			BeforeEach(func() {
 				clientConf.VxlanInterfaceName = "eth1"
@@ -95,6 +117,22 @@ var _ = Describe("ConfigCreator", func() {
 			})
 		})
 
+		Context("when the lease is in an additional overlay network", func() {
+			BeforeEach(func() {
+				clientConf.AdditionalOverlayNetworks = []clientConfig.OverlayNetwork{
+					{Network: "10.254.0.0/15", SubnetPrefixLength: 26},
+				}
+				lease.OverlaySubnet = "10.254.30.64/26"
+			})
+
+			It("uses the prefix length of that network", func() {
+				conf, err := creator.Create(clientConf, lease)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conf.OverlayIP.String()).To(Equal("10.254.30.64"))
+				Expect(conf.OverlayNetworkPrefixLength).To(Equal(15))
+			})
+		})
+
 		Context("when the overlay network prefix length is greater than or equal to the subnet prefix length", func() {
 			BeforeEach(func() {
 				clientConf.OverlayNetwork = "10.255.0.0/30"
@@ -10,85 +10,138 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
+//go:generate counterfeiter -o fakes/metricSender.go --fake-name MetricSender . metricSender
+type metricSender interface {
+	SendValue(name string, value float64, units string)
+}
+
 type Converger struct {
-	OverlayNetwork *net.IPNet
-	LocalSubnet    *net.IPNet
-	LocalVTEP      net.Interface
+	OverlayNetworks []*net.IPNet
+	LocalSubnet     *net.IPNet
+	LocalVTEP       net.Interface
+	// UnderlayIP is the source address of the VTEP. Leases with an underlay
+	// ip of the other family are not routable, as the kernel rejects FDB
+	// entries that do not match it.
+	UnderlayIP     net.IP
 	NetlinkAdapter netlinkAdapter
+	MetricSender   metricSender
 	Logger         lager.Logger
 }
 
+// Converge only issues netlink calls for the routes and neighbor entries
+// that differ from the leases.
 func (c *Converger) Converge(leases []controller.Lease) error {
-	previousRoutes, previousNeighs, err := c.getPreviousState(c.LocalVTEP.Index)
+	plan, err := c.Plan(leases)
+	if err != nil {
+		return err
+	}
+
+	err = c.Apply(plan)
 	if err != nil {
 		return err
 	}
 
-	nonRoutableLeaseCount := 0
+	if plan.NonRoutableLeases > 0 {
+		c.Logger.Info("converger", lager.Data{"non-routable-lease-count": plan.NonRoutableLeases})
+	}
+
+	return nil
+}
+
+// Plan compares the routes and neighbor entries of the VTEP with the ones
+// the leases need, without changing them.
+func (c *Converger) Plan(leases []controller.Lease) (Plan, error) {
+	previousRoutes, previousARPNeighs, previousFDBNeighs, err := c.getPreviousState(c.LocalVTEP.Index)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{}
 	var currentRoutes []netlink.Route
-	var currentNeighs []netlink.Neigh
+	var currentARPNeighs, currentFDBNeighs []netlink.Neigh
 	for _, lease := range leases {
 		destAddr, destNet, err := net.ParseCIDR(lease.OverlaySubnet)
 		if err != nil {
-			return fmt.Errorf("parse lease: %s", err)
+			return Plan{}, fmt.Errorf("parse lease: %s", err)
 		}
 
 		if c.isLocal(destNet) {
 			continue
 		}
 
-		if !c.OverlayNetwork.Contains(destNet.IP) {
-			nonRoutableLeaseCount++
+		if c.overlayNetworkFor(destNet.IP) == nil {
+			plan.NonRoutableLeases++
 			continue
 		}
 
-		route, err := c.addRoute(destNet, destAddr)
-		if err != nil {
-			return err
-		}
-		currentRoutes = append(currentRoutes, route)
-
 		underlayIP := net.ParseIP(lease.UnderlayIP)
 		if underlayIP == nil {
-			return fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
+			return Plan{}, fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
+		}
+
+		if !c.sameUnderlayFamily(underlayIP) {
+			plan.NonRoutableLeases++
+			continue
 		}
 
+		currentRoutes = append(currentRoutes, c.route(destNet, destAddr))
+
 		remoteMac, err := net.ParseMAC(lease.OverlayHardwareAddr)
 		if err != nil {
-			return fmt.Errorf("invalid hardware addr: %s", lease.OverlayHardwareAddr)
+			return Plan{}, fmt.Errorf("invalid hardware addr: %s", lease.OverlayHardwareAddr)
 		}
 
-		neighs, err := c.addNeighs(underlayIP, destAddr, remoteMac)
+		arpNeigh, fdbNeigh := c.neighs(underlayIP, destAddr, remoteMac)
+		currentARPNeighs = append(currentARPNeighs, arpNeigh)
+		currentFDBNeighs = append(currentFDBNeighs, fdbNeigh)
+	}
+
+	plan.Routes.Add, plan.Routes.Update, plan.Routes.Delete = diffRoutes(previousRoutes, currentRoutes, c.isOwnedRoute)
+	plan.ARP.Add, plan.ARP.Update, plan.ARP.Delete = diffNeighs(previousARPNeighs, currentARPNeighs, arpKey, c.isOwnedNeigh)
+	plan.FDB.Add, plan.FDB.Update, plan.FDB.Delete = diffNeighs(previousFDBNeighs, currentFDBNeighs, fdbKey, c.isOwnedNeigh)
+	return plan, nil
+}
+
+// Apply makes the changes of the plan and sends how many there were.
+func (c *Converger) Apply(plan Plan) error {
+	for _, route := range append(plan.Routes.Add, plan.Routes.Update...) {
+		route := route
+		err := c.NetlinkAdapter.RouteReplace(&route)
 		if err != nil {
-			return err
+			return fmt.Errorf("add route: %s", err)
 		}
-		currentNeighs = append(currentNeighs, neighs...)
 	}
 
-	routesForDeletion := getDeletedRoutes(previousRoutes, currentRoutes)
-	for _, route := range routesForDeletion {
-		if route.LinkIndex == c.LocalVTEP.Index && c.OverlayNetwork.Contains(route.Gw) {
-			err = c.NetlinkAdapter.RouteDel(&route)
+	for _, changes := range []NeighChanges{plan.ARP, plan.FDB} {
+		for _, neigh := range append(changes.Add, changes.Update...) {
+			neigh := neigh
+			err := c.NetlinkAdapter.NeighSet(&neigh)
 			if err != nil {
-				return fmt.Errorf("del route: %s", err)
+				return fmt.Errorf("set neigh: %s", err)
 			}
 		}
 	}
 
-	neighsForDeletion := getDeletedNeighs(previousNeighs, currentNeighs)
-	for _, neigh := range neighsForDeletion {
-		if neigh.LinkIndex == c.LocalVTEP.Index {
-			err = c.NetlinkAdapter.NeighDel(&neigh)
-			if err != nil {
-				return fmt.Errorf("del neigh with ip/hwaddr %s: %s", &neigh, err)
-			}
+	for _, route := range plan.Routes.Delete {
+		route := route
+		err := c.NetlinkAdapter.RouteDel(&route)
+		if err != nil {
+			return fmt.Errorf("del route: %s", err)
 		}
 	}
 
-	if nonRoutableLeaseCount > 0 {
-		c.Logger.Info("converger", lager.Data{"non-routable-lease-count": nonRoutableLeaseCount})
+	for _, neigh := range append(plan.ARP.Delete, plan.FDB.Delete...) {
+		neigh := neigh
+		err := c.NetlinkAdapter.NeighDel(&neigh)
+		if err != nil {
+			return fmt.Errorf("del neigh with ip/hwaddr %s: %s", &neigh, err)
+		}
 	}
 
+	adds, updates, deletes := plan.Counts()
+	c.MetricSender.SendValue("convergeAdds", float64(adds), "")
+	c.MetricSender.SendValue("convergeUpdates", float64(updates), "")
+	c.MetricSender.SendValue("convergeDeletes", float64(deletes), "")
 	return nil
 }
 
@@ -96,66 +149,140 @@ func (c *Converger) isLocal(destNet *net.IPNet) bool {
 	return destNet.String() == c.LocalSubnet.String()
 }
 
-func getDeletedRoutes(previous, current []netlink.Route) []netlink.Route {
-	var deletedRoutes []netlink.Route
-	for _, previousRoute := range previous {
-		isRemoved := true
-		for _, currentRoute := range current {
-			isRemoved = isRemoved && !routeEqual(previousRoute, currentRoute)
+func (c *Converger) overlayNetworkFor(ip net.IP) *net.IPNet {
+	for _, overlayNetwork := range c.OverlayNetworks {
+		if overlayNetwork.Contains(ip) {
+			return overlayNetwork
 		}
+	}
+	return nil
+}
+
+func (c *Converger) isOwnedRoute(route netlink.Route) bool {
+	return route.LinkIndex == c.LocalVTEP.Index && c.overlayNetworkFor(route.Gw) != nil
+}
 
-		if isRemoved {
-			deletedRoutes = append(deletedRoutes, previousRoute)
+func (c *Converger) isOwnedNeigh(neigh netlink.Neigh) bool {
+	return neigh.LinkIndex == c.LocalVTEP.Index
+}
+
+// diffRoutes keys the routes by destination. Previous routes that are not
+// owned by the converger are left alone.
+func diffRoutes(previous, current []netlink.Route, owned func(netlink.Route) bool) (adds, updates, deletes []netlink.Route) {
+	previousByDst := make(map[string]netlink.Route, len(previous))
+	for _, route := range previous {
+		if owned(route) {
+			previousByDst[route.Dst.String()] = route
 		}
 	}
 
-	return deletedRoutes
+	currentDsts := make(map[string]bool, len(current))
+	for _, route := range current {
+		dst := route.Dst.String()
+		currentDsts[dst] = true
+		previousRoute, ok := previousByDst[dst]
+		switch {
+		case !ok:
+			adds = append(adds, route)
+		case !routeEqual(previousRoute, route):
+			updates = append(updates, route)
+		}
+	}
 
+	for _, route := range previous {
+		if owned(route) && !currentDsts[route.Dst.String()] {
+			deletes = append(deletes, route)
+		}
+	}
+	return adds, updates, deletes
 }
 
-func getDeletedNeighs(previous, current []netlink.Neigh) []netlink.Neigh {
-	var deletedNeighs []netlink.Neigh
-	for _, previousNeigh := range previous {
-		isRemoved := true
-		for _, currentNeigh := range current {
-			isRemoved = isRemoved && !neighEqual(previousNeigh, currentNeigh)
+// diffNeighs keys the neighbor entries with key. A previous entry is deleted
+// when no current entry has its key, or when another previous entry with
+// the same key already matches.
+func diffNeighs(previous, current []netlink.Neigh, key func(netlink.Neigh) string, owned func(netlink.Neigh) bool) (adds, updates, deletes []netlink.Neigh) {
+	currentByKey := make(map[string]netlink.Neigh, len(current))
+	for _, neigh := range current {
+		currentByKey[key(neigh)] = neigh
+	}
+
+	matched := map[string]bool{}
+	mismatched := map[string]bool{}
+	var stale []netlink.Neigh
+	for _, neigh := range previous {
+		if !owned(neigh) {
+			continue
+		}
+		currentNeigh, ok := currentByKey[key(neigh)]
+		switch {
+		case !ok:
+			deletes = append(deletes, neigh)
+		case neighEqual(neigh, currentNeigh):
+			matched[key(neigh)] = true
+		default:
+			mismatched[key(neigh)] = true
+			stale = append(stale, neigh)
 		}
+	}
 
-		if isRemoved {
-			deletedNeighs = append(deletedNeighs, previousNeigh)
+	for _, neigh := range stale {
+		if matched[key(neigh)] {
+			deletes = append(deletes, neigh)
 		}
 	}
 
-	return deletedNeighs
+	for _, neigh := range current {
+		switch {
+		case matched[key(neigh)]:
+		case mismatched[key(neigh)]:
+			updates = append(updates, neigh)
+		default:
+			adds = append(adds, neigh)
+		}
+	}
+	return adds, updates, deletes
+}
+
+func arpKey(neigh netlink.Neigh) string {
+	return neigh.IP.String()
+}
+
+func fdbKey(neigh netlink.Neigh) string {
+	return neigh.HardwareAddr.String()
 }
 
-func (c *Converger) getPreviousState(index int) ([]netlink.Route, []netlink.Neigh, error) {
+func (c *Converger) getPreviousState(index int) ([]netlink.Route, []netlink.Neigh, []netlink.Neigh, error) {
 	link, err := c.NetlinkAdapter.LinkByIndex(c.LocalVTEP.Index)
 	if err != nil {
-		return nil, nil, fmt.Errorf("link by index: %s", err)
+		return nil, nil, nil, fmt.Errorf("link by index: %s", err)
 	}
 
 	previousRoutes, err := c.NetlinkAdapter.RouteList(link, netlink.FAMILY_V4)
 	if err != nil {
-		return nil, nil, fmt.Errorf("list routes: %s", err)
+		return nil, nil, nil, fmt.Errorf("list routes: %s", err)
 	}
 
 	previousFDBNeighs, err := c.NetlinkAdapter.FDBList(c.LocalVTEP.Index)
 	if err != nil {
-		return nil, nil, fmt.Errorf("list fdb: %s", err)
+		return nil, nil, nil, fmt.Errorf("list fdb: %s", err)
 	}
 
 	previousARPNeighs, err := c.NetlinkAdapter.ARPList(c.LocalVTEP.Index)
 	if err != nil {
-		return nil, nil, fmt.Errorf("list arp: %s", err)
+		return nil, nil, nil, fmt.Errorf("list arp: %s", err)
 	}
 
-	previousNeighs := append(previousARPNeighs, previousFDBNeighs...)
+	return previousRoutes, previousARPNeighs, previousFDBNeighs, nil
+}
 
-	return previousRoutes, previousNeighs, nil
+func (c *Converger) sameUnderlayFamily(underlayIP net.IP) bool {
+	if c.UnderlayIP == nil {
+		return true
+	}
+	return (underlayIP.To4() == nil) == (c.UnderlayIP.To4() == nil)
 }
 
-func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route, error) {
+func (c *Converger) route(destNet *net.IPNet, destAddr net.IP) netlink.Route {
 	route := netlink.Route{
 		LinkIndex: c.LocalVTEP.Index,
 		Scope:     netlink.SCOPE_UNIVERSE,
@@ -163,45 +290,31 @@ func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route
 		Gw:        destAddr,
 		Src:       c.LocalSubnet.IP,
 	}
-
-	err := c.NetlinkAdapter.RouteReplace(&route)
-	if err != nil {
-		return netlink.Route{}, fmt.Errorf("add route: %s", err)
-	}
-
-	return route, nil
-}
-
-func (c *Converger) addNeighs(underlayIP, destAddr net.IP, remoteMac net.HardwareAddr) ([]netlink.Neigh, error) {
-	neighs := []*netlink.Neigh{
-		{ // ARP
-			LinkIndex:    c.LocalVTEP.Index,
-			State:        netlink.NUD_PERMANENT,
-			Type:         syscall.RTN_UNICAST,
-			IP:           destAddr,
-			HardwareAddr: remoteMac,
-		},
-		{ // FDB
-			LinkIndex:    c.LocalVTEP.Index,
-			State:        netlink.NUD_PERMANENT,
-			Family:       syscall.AF_BRIDGE,
-			Flags:        netlink.NTF_SELF,
-			IP:           underlayIP,
-			HardwareAddr: remoteMac,
-		},
-	}
-
-	var currentNeighs []netlink.Neigh
-	for _, neigh := range neighs {
-		err := c.NetlinkAdapter.NeighSet(neigh)
-		if err != nil {
-			return nil, fmt.Errorf("set neigh: %s", err)
-		}
-
-		currentNeighs = append(currentNeighs, *neigh)
+	// the vtep address only covers the overlay network of the local subnet,
+	// so gateways in the other overlay networks are not directly reachable
+	if c.overlayNetworkFor(destAddr) != c.overlayNetworkFor(c.LocalSubnet.IP) {
+		route.Flags = int(netlink.FLAG_ONLINK)
 	}
+	return route
+}
 
-	return currentNeighs, nil
+func (c *Converger) neighs(underlayIP, destAddr net.IP, remoteMac net.HardwareAddr) (netlink.Neigh, netlink.Neigh) {
+	arp := netlink.Neigh{
+		LinkIndex:    c.LocalVTEP.Index,
+		State:        netlink.NUD_PERMANENT,
+		Type:         syscall.RTN_UNICAST,
+		IP:           destAddr,
+		HardwareAddr: remoteMac,
+	}
+	fdb := netlink.Neigh{
+		LinkIndex:    c.LocalVTEP.Index,
+		State:        netlink.NUD_PERMANENT,
+		Family:       syscall.AF_BRIDGE,
+		Flags:        netlink.NTF_SELF,
+		IP:           underlayIP,
+		HardwareAddr: remoteMac,
+	}
+	return arp, fdb
 }
 
 func routeEqual(r1, r2 netlink.Route) bool {
@@ -209,7 +322,8 @@ func routeEqual(r1, r2 netlink.Route) bool {
 		r1.Scope == r2.Scope &&
 		r1.Dst.String() == r2.Dst.String() &&
 		r1.Gw.String() == r2.Gw.String() &&
-		r1.Src.String() == r2.Src.String()
+		r1.Src.String() == r2.Src.String() &&
+		r1.Flags&int(netlink.FLAG_ONLINK) == r2.Flags&int(netlink.FLAG_ONLINK)
 }
 
 func neighEqual(n1, n2 netlink.Neigh) bool {
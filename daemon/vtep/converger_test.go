@@ -17,18 +17,20 @@ import (
 
 var _ = Describe("Converger", func() {
 	var (
-		fakeNetlink *fakes.NetlinkAdapter
-		converger   *vtep.Converger
-		leases      []controller.Lease
-		overlayNet  *net.IPNet
-		logger      *lagertest.TestLogger
-		localMac    net.HardwareAddr
-		remoteMac   net.HardwareAddr
+		fakeNetlink  *fakes.NetlinkAdapter
+		metricSender *fakes.MetricSender
+		converger    *vtep.Converger
+		leases       []controller.Lease
+		overlayNet   *net.IPNet
+		logger       *lagertest.TestLogger
+		localMac     net.HardwareAddr
+		remoteMac    net.HardwareAddr
 	)
 
 	Describe("Converge", func() {
 		BeforeEach(func() {
 			fakeNetlink = &fakes.NetlinkAdapter{}
+			metricSender = &fakes.MetricSender{}
 			_, localSubnet, _ := net.ParseCIDR("10.255.32.0/24")
 			_, overlayNet, _ = net.ParseCIDR("10.255.0.0/16")
 			logger = lagertest.NewTestLogger("test")
@@ -37,11 +39,12 @@ var _ = Describe("Converger", func() {
 				Name:  "silk-vtep",
 			}
 			converger = &vtep.Converger{
-				OverlayNetwork: overlayNet,
-				LocalSubnet:    localSubnet,
-				LocalVTEP:      localVTEP,
-				NetlinkAdapter: fakeNetlink,
-				Logger:         logger,
+				OverlayNetworks: []*net.IPNet{overlayNet},
+				LocalSubnet:     localSubnet,
+				LocalVTEP:       localVTEP,
+				NetlinkAdapter:  fakeNetlink,
+				MetricSender:    metricSender,
+				Logger:          logger,
 			}
 			localMac, _ = net.ParseMAC("ee:ee:aa:bb:cc:dd")
 			remoteMac, _ = net.ParseMAC("ee:ee:aa:aa:aa:ff")
@@ -110,6 +113,133 @@ var _ = Describe("Converger", func() {
 			Expect(logger.Logs()).To(HaveLen(0))
 		})
 
+		It("sends the number of changes it applied", func() {
+			err := converger.Converge(leases)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(metricSender.SendValueCallCount()).To(Equal(3))
+			sentValues := map[string]float64{}
+			for i := 0; i < metricSender.SendValueCallCount(); i++ {
+				name, value, _ := metricSender.SendValueArgsForCall(i)
+				sentValues[name] = value
+			}
+			Expect(sentValues).To(Equal(map[string]float64{
+				"convergeAdds":    3,
+				"convergeUpdates": 0,
+				"convergeDeletes": 0,
+			}))
+		})
+
+		Context("when the routes and neighbor entries are already in place", func() {
+			BeforeEach(func() {
+				destGW, destNet, _ := net.ParseCIDR("10.255.19.0/24")
+				fakeNetlink.RouteListReturns([]netlink.Route{{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_UNIVERSE,
+					Dst:       destNet,
+					Gw:        destGW,
+					Src:       net.ParseIP("10.255.32.0").To4(),
+				}}, nil)
+				fakeNetlink.ARPListReturns([]netlink.Neigh{{
+					LinkIndex:    42,
+					State:        netlink.NUD_PERMANENT,
+					Type:         syscall.RTN_UNICAST,
+					IP:           net.ParseIP("10.255.19.0"),
+					HardwareAddr: remoteMac,
+				}}, nil)
+				fakeNetlink.FDBListReturns([]netlink.Neigh{{
+					LinkIndex:    42,
+					State:        netlink.NUD_PERMANENT,
+					Family:       syscall.AF_BRIDGE,
+					Flags:        netlink.NTF_SELF,
+					IP:           net.ParseIP("10.10.0.5"),
+					HardwareAddr: remoteMac,
+				}}, nil)
+			})
+
+			It("does not make any netlink changes", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+				Expect(fakeNetlink.RouteDelCallCount()).To(Equal(0))
+				Expect(fakeNetlink.NeighDelCallCount()).To(Equal(0))
+			})
+
+			Context("when the lease moved to another underlay ip", func() {
+				BeforeEach(func() {
+					leases[1].UnderlayIP = "10.10.0.9"
+				})
+
+				It("only updates the FDB entry in place", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+					Expect(fakeNetlink.NeighSetCallCount()).To(Equal(1))
+					Expect(fakeNetlink.NeighSetArgsForCall(0).IP).To(Equal(net.ParseIP("10.10.0.9")))
+					Expect(fakeNetlink.NeighDelCallCount()).To(Equal(0))
+
+					name, value, _ := metricSender.SendValueArgsForCall(1)
+					Expect(name).To(Equal("convergeUpdates"))
+					Expect(value).To(Equal(1.0))
+				})
+			})
+
+			Context("when the route has a different gateway", func() {
+				BeforeEach(func() {
+					_, destNet, _ := net.ParseCIDR("10.255.19.0/24")
+					fakeNetlink.RouteListReturns([]netlink.Route{{
+						LinkIndex: 42,
+						Scope:     netlink.SCOPE_UNIVERSE,
+						Dst:       destNet,
+						Gw:        net.ParseIP("10.255.19.1"),
+						Src:       net.ParseIP("10.255.32.0").To4(),
+					}}, nil)
+				})
+
+				It("replaces the route without deleting it", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(1))
+					Expect(fakeNetlink.RouteReplaceArgsForCall(0).Gw.String()).To(Equal("10.255.19.0"))
+					Expect(fakeNetlink.RouteDelCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when a stale ARP entry duplicates a matching one", func() {
+				BeforeEach(func() {
+					fakeNetlink.ARPListReturns([]netlink.Neigh{
+						{
+							LinkIndex:    42,
+							State:        netlink.NUD_PERMANENT,
+							Type:         syscall.RTN_UNICAST,
+							IP:           net.ParseIP("10.255.19.0"),
+							HardwareAddr: remoteMac,
+						},
+						{
+							LinkIndex:    42,
+							State:        netlink.NUD_STALE,
+							Type:         syscall.RTN_UNICAST,
+							IP:           net.ParseIP("10.255.19.0"),
+							HardwareAddr: localMac,
+						},
+					}, nil)
+				})
+
+				It("deletes the stale entry", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+					Expect(fakeNetlink.NeighDelCallCount()).To(Equal(1))
+					Expect(fakeNetlink.NeighDelArgsForCall(0).HardwareAddr).To(Equal(localMac))
+				})
+			})
+		})
+
 		Context("when the a remote lease is removed", func() {
 			var (
 				deletedNeighs []netlink.Neigh
@@ -228,6 +358,38 @@ var _ = Describe("Converger", func() {
 				))
 			})
 
+			It("plans the deletes without making netlink changes", func() {
+				plan, err := converger.Plan(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(plan.Routes.Delete).To(HaveLen(1))
+				Expect(plan.Routes.Delete[0].Dst.String()).To(Equal("10.255.20.0/24"))
+				Expect(plan.ARP.Delete).To(HaveLen(1))
+				Expect(plan.ARP.Delete[0].IP.String()).To(Equal("10.255.20.0"))
+				Expect(plan.FDB.Delete).To(HaveLen(1))
+				Expect(plan.FDB.Delete[0].HardwareAddr).To(Equal(oldDestMac))
+				adds, updates, deletes := plan.Counts()
+				Expect([]int{adds, updates, deletes}).To(Equal([]int{0, 0, 3}))
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+				Expect(fakeNetlink.RouteDelCallCount()).To(Equal(0))
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+				Expect(fakeNetlink.NeighDelCallCount()).To(Equal(0))
+				Expect(metricSender.SendValueCallCount()).To(Equal(0))
+			})
+
+			It("applies a plan it made before", func() {
+				plan, err := converger.Plan(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = converger.Apply(plan)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+				Expect(fakeNetlink.RouteDelCallCount()).To(Equal(1))
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+				Expect(deletedNeighs).To(ConsistOf(plan.ARP.Delete[0], plan.FDB.Delete[0]))
+			})
 		})
 
 		Context("when there are other routing rules", func() {
@@ -420,6 +582,38 @@ var _ = Describe("Converger", func() {
 			})
 		})
 
+		Context("when there are additional overlay networks", func() {
+			BeforeEach(func() {
+				_, additionalNet, _ := net.ParseCIDR("10.254.0.0/16")
+				converger.OverlayNetworks = append(converger.OverlayNetworks, additionalNet)
+				leases = append(leases, controller.Lease{
+					UnderlayIP:          "10.10.0.6",
+					OverlaySubnet:       "10.254.11.0/26",
+					OverlayHardwareAddr: "aa:aa:00:00:00:01",
+				})
+			})
+
+			It("adds routes for the leases in every overlay network", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(2))
+				Expect(fakeNetlink.RouteReplaceArgsForCall(0).Flags).To(Equal(0))
+
+				addedRoute := fakeNetlink.RouteReplaceArgsForCall(1)
+				destGW, destNet, _ := net.ParseCIDR("10.254.11.0/26")
+				Expect(addedRoute).To(Equal(&netlink.Route{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_UNIVERSE,
+					Dst:       destNet,
+					Gw:        destGW,
+					Src:       net.ParseIP("10.255.32.0").To4(),
+					Flags:     int(netlink.FLAG_ONLINK),
+				}))
+				Expect(logger.Logs()).To(HaveLen(0))
+			})
+		})
+
 		Context("when a lease has an invalid MAC", func() {
 			BeforeEach(func() {
 				leases = []controller.Lease{
@@ -435,5 +629,70 @@ var _ = Describe("Converger", func() {
 				Expect(err).To(MatchError("invalid hardware addr: banana"))
 			})
 		})
+
+		Context("when the underlay is IPv6", func() {
+			BeforeEach(func() {
+				converger.UnderlayIP = net.ParseIP("fd00:10:10::4")
+				leases = []controller.Lease{
+					{
+						UnderlayIP:          "fd00:10:10::5",
+						OverlaySubnet:       "10.255.19.0/24",
+						OverlayHardwareAddr: remoteMac.String(),
+					},
+					{
+						UnderlayIP:          "10.10.0.6",
+						OverlaySubnet:       "10.255.20.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:14:00",
+					},
+				}
+			})
+
+			It("adds FDB entries with the IPv6 underlay ips and IPv4 routes and ARP entries", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(1))
+				Expect(fakeNetlink.RouteReplaceArgsForCall(0).Dst.String()).To(Equal("10.255.19.0/24"))
+
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(2))
+				Expect(fakeNetlink.NeighSetArgsForCall(0).IP.String()).To(Equal("10.255.19.0"))
+				Expect(fakeNetlink.NeighSetArgsForCall(1)).To(Equal(&netlink.Neigh{
+					LinkIndex:    42,
+					State:        netlink.NUD_PERMANENT,
+					Family:       syscall.AF_BRIDGE,
+					Flags:        netlink.NTF_SELF,
+					IP:           net.ParseIP("fd00:10:10::5"),
+					HardwareAddr: remoteMac,
+				}))
+			})
+
+			It("does not route leases with an IPv4 underlay ip", func() {
+				plan, err := converger.Plan(leases)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.NonRoutableLeases).To(Equal(1))
+				Expect(plan.FDB.Add).To(HaveLen(1))
+			})
+
+			Context("when the FDB entry is already in place", func() {
+				BeforeEach(func() {
+					fakeNetlink.FDBListReturns([]netlink.Neigh{{
+						LinkIndex:    42,
+						State:        netlink.NUD_PERMANENT,
+						Family:       syscall.AF_BRIDGE,
+						Flags:        netlink.NTF_SELF,
+						IP:           net.ParseIP("fd00:10:10:0:0:0:0:5"),
+						HardwareAddr: remoteMac,
+					}}, nil)
+				})
+
+				It("leaves it alone", func() {
+					plan, err := converger.Plan(leases)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(plan.FDB.Add).To(BeEmpty())
+					Expect(plan.FDB.Update).To(BeEmpty())
+					Expect(plan.FDB.Delete).To(BeEmpty())
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,276 @@
+package vtep
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/silk/lib/adapter"
+	"github.com/vishvananda/netlink"
+)
+
+//go:generate counterfeiter -o fakes/netlinkSubscriber.go --fake-name NetlinkSubscriber . netlinkSubscriber
+type netlinkSubscriber interface {
+	RouteSubscribe(chan<- netlink.RouteUpdate, <-chan struct{}) error
+	NeighSubscribe(chan<- adapter.NeighUpdate, <-chan struct{}) error
+	LinkSubscribe(chan<- netlink.LinkUpdate, <-chan struct{}) error
+}
+
+//go:generate counterfeiter -o fakes/driftRepairer.go --fake-name DriftRepairer . driftRepairer
+type driftRepairer interface {
+	// RepairDrift converges the drifted entries on the leases of the last
+	// converge and reports whether anything needed repairing.
+	RepairDrift(Drift) (bool, error)
+}
+
+// Drift names the entries of the VTEP that were changed: routes by the ips
+// of their destination, ARP entries by ip and FDB entries by hardware
+// address. All stands for every entry, e.g. after the VTEP was down.
+type Drift struct {
+	All           bool
+	IPs           []net.IP
+	HardwareAddrs []net.HardwareAddr
+}
+
+// DriftWatcher repairs the routes and neighbor entries of the VTEP as soon as
+// something other than the converger removes or changes them, instead of
+// waiting for the next poll cycle. Updates arriving within SettleTime of
+// each other are repaired together. A subscription that closes, e.g. on a
+// netlink socket error, is renewed after ResubscribeInterval, which doubles
+// while resubscribing fails.
+type DriftWatcher struct {
+	Logger              lager.Logger
+	Subscriber          netlinkSubscriber
+	Repairer            driftRepairer
+	LocalVTEP           net.Interface
+	OverlayNetworks     []*net.IPNet
+	SettleTime          time.Duration
+	ResubscribeInterval time.Duration
+}
+
+const maxResubscribeInterval = time.Minute
+
+func (w *DriftWatcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	routes := make(chan netlink.RouteUpdate)
+	if err := w.Subscriber.RouteSubscribe(routes, done); err != nil {
+		return fmt.Errorf("subscribe to routes: %s", err)
+	}
+	neighs := make(chan adapter.NeighUpdate)
+	if err := w.Subscriber.NeighSubscribe(neighs, done); err != nil {
+		return fmt.Errorf("subscribe to neighbors: %s", err)
+	}
+	links := make(chan netlink.LinkUpdate)
+	if err := w.Subscriber.LinkSubscribe(links, done); err != nil {
+		return fmt.Errorf("subscribe to links: %s", err)
+	}
+	close(ready)
+
+	// the subscriptions only notice done once their current send completes
+	defer func() {
+		drainRoutes(routes)
+		drainNeighs(neighs)
+		drainLinks(links)
+	}()
+
+	var drift Drift
+	var settled <-chan time.Time
+	vtepUp := true
+	routeRetry := &resubscription{name: "route"}
+	neighRetry := &resubscription{name: "neigh"}
+	linkRetry := &resubscription{name: "link"}
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case update, ok := <-routes:
+			if !ok {
+				routes = nil
+				w.scheduleResubscribe(routeRetry, "subscription-closed", errors.New("route subscription closed"))
+				continue
+			}
+			if ip := w.driftedRoute(update); ip != nil {
+				drift.IPs = append(drift.IPs, ip)
+			}
+		case update, ok := <-neighs:
+			if !ok {
+				neighs = nil
+				w.scheduleResubscribe(neighRetry, "subscription-closed", errors.New("neigh subscription closed"))
+				continue
+			}
+			w.addDriftedNeigh(&drift, update)
+		case update, ok := <-links:
+			if !ok {
+				links = nil
+				w.scheduleResubscribe(linkRetry, "subscription-closed", errors.New("link subscription closed"))
+				continue
+			}
+			if int(update.Index) != w.LocalVTEP.Index {
+				continue
+			}
+			if update.Header.Type == syscall.RTM_DELLINK {
+				w.Logger.Error("vtep-deleted", fmt.Errorf("%s was deleted", w.LocalVTEP.Name))
+				continue
+			}
+			// the kernel drops the routes of a link that goes down
+			up := update.Flags&syscall.IFF_UP != 0
+			drift.All = drift.All || (up && !vtepUp)
+			vtepUp = up
+		case <-routeRetry.retry:
+			routes = make(chan netlink.RouteUpdate)
+			if err := w.Subscriber.RouteSubscribe(routes, done); err != nil {
+				routes = nil
+				w.scheduleResubscribe(routeRetry, "resubscribe", err)
+				continue
+			}
+			drift.All = w.resubscribed(routeRetry) || drift.All
+		case <-neighRetry.retry:
+			neighs = make(chan adapter.NeighUpdate)
+			if err := w.Subscriber.NeighSubscribe(neighs, done); err != nil {
+				neighs = nil
+				w.scheduleResubscribe(neighRetry, "resubscribe", err)
+				continue
+			}
+			drift.All = w.resubscribed(neighRetry) || drift.All
+		case <-linkRetry.retry:
+			links = make(chan netlink.LinkUpdate)
+			if err := w.Subscriber.LinkSubscribe(links, done); err != nil {
+				links = nil
+				w.scheduleResubscribe(linkRetry, "resubscribe", err)
+				continue
+			}
+			drift.All = w.resubscribed(linkRetry) || drift.All
+		case <-settled:
+			w.repair(drift)
+			drift, settled = Drift{}, nil
+			continue
+		}
+
+		if settled == nil && (drift.All || len(drift.IPs) > 0 || len(drift.HardwareAddrs) > 0) {
+			settled = time.After(w.SettleTime)
+		}
+	}
+}
+
+// resubscription tracks the renewal of a closed subscription.
+type resubscription struct {
+	name     string
+	interval time.Duration
+	retry    <-chan time.Time
+}
+
+func (w *DriftWatcher) scheduleResubscribe(r *resubscription, action string, err error) {
+	r.interval *= 2
+	if r.interval == 0 {
+		r.interval = w.ResubscribeInterval
+	}
+	if r.interval <= 0 || r.interval > maxResubscribeInterval {
+		r.interval = maxResubscribeInterval
+	}
+	w.Logger.Error(action, err, lager.Data{"subscription": r.name, "resubscribe_in": r.interval.String()})
+	r.retry = time.After(r.interval)
+}
+
+// resubscribed reports that every entry needs repairing, since the updates
+// sent while the subscription was closed are lost.
+func (w *DriftWatcher) resubscribed(r *resubscription) bool {
+	w.Logger.Info("resubscribed", lager.Data{"subscription": r.name})
+	r.interval, r.retry = 0, nil
+	return true
+}
+
+// driftedRoute returns the destination of an added, changed or removed
+// overlay route of the VTEP. Whether it differs from the converged route is
+// up to the repairer.
+func (w *DriftWatcher) driftedRoute(update netlink.RouteUpdate) net.IP {
+	if !isRouteChange(update.Type) || update.LinkIndex != w.LocalVTEP.Index || update.Dst == nil {
+		return nil
+	}
+	for _, overlayNetwork := range w.OverlayNetworks {
+		if overlayNetwork.Contains(update.Dst.IP) {
+			return update.Dst.IP
+		}
+	}
+	return nil
+}
+
+// addDriftedNeigh adds the key of an added, changed or removed neighbor
+// entry of the VTEP.
+func (w *DriftWatcher) addDriftedNeigh(drift *Drift, update adapter.NeighUpdate) {
+	if !isNeighChange(update.Type) || update.LinkIndex != w.LocalVTEP.Index {
+		return
+	}
+	if update.Family == syscall.AF_BRIDGE {
+		if update.HardwareAddr != nil {
+			drift.HardwareAddrs = append(drift.HardwareAddrs, update.HardwareAddr)
+		}
+		return
+	}
+	if update.IP != nil {
+		drift.IPs = append(drift.IPs, update.IP)
+	}
+}
+
+func isRouteChange(updateType uint16) bool {
+	return updateType == syscall.RTM_NEWROUTE || updateType == syscall.RTM_DELROUTE
+}
+
+func isNeighChange(updateType uint16) bool {
+	return updateType == syscall.RTM_NEWNEIGH || updateType == syscall.RTM_DELNEIGH
+}
+
+func (w *DriftWatcher) repair(drift Drift) {
+	repaired, err := w.Repairer.RepairDrift(drift)
+	if err != nil {
+		w.Logger.Error("repair-drift", err)
+		return
+	}
+	if repaired {
+		w.Logger.Info("drift-repaired", lager.Data{"all": drift.All, "ips": drift.IPs, "hardware_addrs": hardwareAddrStrings(drift.HardwareAddrs)})
+	}
+}
+
+func hardwareAddrStrings(hardwareAddrs []net.HardwareAddr) []string {
+	var strs []string
+	for _, hardwareAddr := range hardwareAddrs {
+		strs = append(strs, hardwareAddr.String())
+	}
+	return strs
+}
+
+func drainRoutes(ch <-chan netlink.RouteUpdate) {
+	if ch == nil {
+		return
+	}
+	go func() {
+		for range ch {
+		}
+	}()
+}
+
+func drainNeighs(ch <-chan adapter.NeighUpdate) {
+	if ch == nil {
+		return
+	}
+	go func() {
+		for range ch {
+		}
+	}()
+}
+
+func drainLinks(ch <-chan netlink.LinkUpdate) {
+	if ch == nil {
+		return
+	}
+	go func() {
+		for range ch {
+		}
+	}()
+}
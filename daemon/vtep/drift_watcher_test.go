@@ -0,0 +1,234 @@
+package vtep_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/daemon/vtep/fakes"
+	"code.cloudfoundry.org/silk/lib/adapter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+var _ = Describe("DriftWatcher", func() {
+	var (
+		logger       *lagertest.TestLogger
+		subscriber   *fakes.NetlinkSubscriber
+		repairer     *fakes.DriftRepairer
+		driftWatcher *vtep.DriftWatcher
+		routes       chan<- netlink.RouteUpdate
+		neighs       chan<- adapter.NeighUpdate
+		links        chan<- netlink.LinkUpdate
+		process      ifrit.Process
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		subscriber = &fakes.NetlinkSubscriber{}
+		subscriber.RouteSubscribeStub = func(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error {
+			routes = ch
+			return nil
+		}
+		subscriber.NeighSubscribeStub = func(ch chan<- adapter.NeighUpdate, done <-chan struct{}) error {
+			neighs = ch
+			return nil
+		}
+		subscriber.LinkSubscribeStub = func(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+			links = ch
+			return nil
+		}
+		repairer = &fakes.DriftRepairer{}
+		repairer.RepairDriftReturns(true, nil)
+		_, overlayNet, _ := net.ParseCIDR("10.255.0.0/16")
+		driftWatcher = &vtep.DriftWatcher{
+			Logger:              logger,
+			Subscriber:          subscriber,
+			Repairer:            repairer,
+			LocalVTEP:           net.Interface{Index: 42, Name: "silk-vtep"},
+			OverlayNetworks:     []*net.IPNet{overlayNet},
+			SettleTime:          10 * time.Millisecond,
+			ResubscribeInterval: 10 * time.Millisecond,
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(driftWatcher)
+	})
+
+	AfterEach(func() {
+		if process != nil {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		}
+	})
+
+	routeUpdate := func(updateType uint16, linkIndex int, dst string) netlink.RouteUpdate {
+		_, dstNet, _ := net.ParseCIDR(dst)
+		return netlink.RouteUpdate{Type: updateType, Route: netlink.Route{LinkIndex: linkIndex, Dst: dstNet}}
+	}
+
+	linkUpdate := func(updateType uint16, index int32, flags uint32) netlink.LinkUpdate {
+		update := netlink.LinkUpdate{IfInfomsg: nl.IfInfomsg{IfInfomsg: syscall.IfInfomsg{Index: index, Flags: flags}}}
+		update.Header.Type = updateType
+		return update
+	}
+
+	It("repairs a removed overlay route of the vtep", func() {
+		routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+
+		Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+		Expect(repairer.RepairDriftArgsForCall(0)).To(Equal(vtep.Drift{IPs: []net.IP{net.ParseIP("10.255.19.0").To4()}}))
+		Eventually(logger).Should(gbytes.Say("drift-repaired"))
+	})
+
+	It("repairs an added or changed overlay route of the vtep", func() {
+		routes <- routeUpdate(syscall.RTM_NEWROUTE, 42, "10.255.19.0/24")
+
+		Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+		Expect(repairer.RepairDriftArgsForCall(0)).To(Equal(vtep.Drift{IPs: []net.IP{net.ParseIP("10.255.19.0").To4()}}))
+	})
+
+	It("repairs added, changed or removed ARP entries by ip and FDB entries by hardware address", func() {
+		mac, _ := net.ParseMAC("ee:ee:0a:ff:13:00")
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_NEWNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, IP: net.ParseIP("10.255.19.0"), HardwareAddr: mac}}
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, Family: syscall.AF_BRIDGE, IP: net.ParseIP("10.10.0.5"), HardwareAddr: mac}}
+
+		Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+		Expect(repairer.RepairDriftArgsForCall(0)).To(Equal(vtep.Drift{
+			IPs:           []net.IP{net.ParseIP("10.255.19.0")},
+			HardwareAddrs: []net.HardwareAddr{mac},
+		}))
+	})
+
+	It("repairs the updates that arrive together at once", func() {
+		routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, IP: net.ParseIP("10.255.19.0")}}
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, IP: net.ParseIP("10.10.0.5")}}
+
+		Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+		Consistently(repairer.RepairDriftCallCount, "50ms").Should(Equal(1))
+		Expect(repairer.RepairDriftArgsForCall(0).IPs).To(HaveLen(3))
+	})
+
+	It("ignores other links, routes outside of the overlay and other updates", func() {
+		routes <- routeUpdate(syscall.RTM_DELROUTE, 7, "10.255.19.0/24")
+		routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "192.168.0.0/24")
+		routes <- routeUpdate(syscall.RTM_GETROUTE, 42, "10.255.19.0/24")
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH, Neigh: netlink.Neigh{LinkIndex: 7, IP: net.ParseIP("10.255.19.0")}}
+		neighs <- adapter.NeighUpdate{Type: syscall.RTM_GETNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, IP: net.ParseIP("10.255.19.0")}}
+
+		Consistently(repairer.RepairDriftCallCount, "50ms").Should(Equal(0))
+	})
+
+	It("repairs all leases when the vtep comes back up", func() {
+		links <- linkUpdate(syscall.RTM_NEWLINK, 42, 0)
+		Consistently(repairer.RepairDriftCallCount, "50ms").Should(Equal(0))
+
+		links <- linkUpdate(syscall.RTM_NEWLINK, 42, syscall.IFF_UP)
+		Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+		Expect(repairer.RepairDriftArgsForCall(0)).To(Equal(vtep.Drift{All: true}))
+	})
+
+	It("logs when the vtep is deleted", func() {
+		links <- linkUpdate(syscall.RTM_DELLINK, 42, 0)
+		Eventually(logger).Should(gbytes.Say("vtep-deleted"))
+		Expect(repairer.RepairDriftCallCount()).To(Equal(0))
+	})
+
+	Context("when a subscription closes", func() {
+		It("keeps watching the other subscriptions", func() {
+			close(links)
+			Eventually(logger).Should(gbytes.Say("subscription-closed.*link subscription closed"))
+
+			routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+			Eventually(repairer.RepairDriftCallCount).Should(BeNumerically(">=", 1))
+		})
+
+		It("resubscribes and repairs everything it may have missed", func() {
+			closed := routes
+			close(closed)
+
+			Eventually(subscriber.RouteSubscribeCallCount).Should(Equal(2))
+			Eventually(logger).Should(gbytes.Say("resubscribed"))
+			Eventually(repairer.RepairDriftCallCount).Should(Equal(1))
+			Expect(repairer.RepairDriftArgsForCall(0).All).To(BeTrue())
+
+			Expect(routes).NotTo(Equal(closed))
+			routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+			Eventually(repairer.RepairDriftCallCount).Should(Equal(2))
+		})
+
+		Context("when resubscribing fails", func() {
+			BeforeEach(func() {
+				subscribe := subscriber.NeighSubscribeStub
+				subscriber.NeighSubscribeStub = func(ch chan<- adapter.NeighUpdate, done <-chan struct{}) error {
+					switch subscriber.NeighSubscribeCallCount() {
+					case 2, 3:
+						return errors.New("banana")
+					}
+					return subscribe(ch, done)
+				}
+			})
+
+			It("logs the error and retries", func() {
+				close(neighs)
+
+				Eventually(logger).Should(gbytes.Say("resubscribe.*banana"))
+				Eventually(subscriber.NeighSubscribeCallCount).Should(Equal(4))
+				Eventually(logger).Should(gbytes.Say("resubscribed"))
+
+				neighs <- adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH, Neigh: netlink.Neigh{LinkIndex: 42, IP: net.ParseIP("10.255.19.0")}}
+				Eventually(func() bool {
+					count := repairer.RepairDriftCallCount()
+					return count > 0 && len(repairer.RepairDriftArgsForCall(count-1).IPs) > 0
+				}).Should(BeTrue())
+			})
+		})
+	})
+
+	It("keeps reading the subscriptions after it exits, so they can notice done", func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		process = nil
+
+		Eventually(routes).Should(BeSent(routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")))
+		Eventually(neighs).Should(BeSent(adapter.NeighUpdate{Type: syscall.RTM_DELNEIGH}))
+		Eventually(links).Should(BeSent(linkUpdate(syscall.RTM_NEWLINK, 42, 0)))
+		Expect(repairer.RepairDriftCallCount()).To(Equal(0))
+	})
+
+	Context("when repairing fails", func() {
+		BeforeEach(func() {
+			repairer.RepairDriftReturns(false, errors.New("banana"))
+		})
+
+		It("logs the error and keeps watching", func() {
+			routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+			Eventually(logger).Should(gbytes.Say("repair-drift.*banana"))
+
+			routes <- routeUpdate(syscall.RTM_DELROUTE, 42, "10.255.19.0/24")
+			Eventually(repairer.RepairDriftCallCount).Should(Equal(2))
+		})
+	})
+
+	Context("when subscribing fails", func() {
+		BeforeEach(func() {
+			subscriber.NeighSubscribeReturns(errors.New("banana"))
+			subscriber.NeighSubscribeStub = nil
+		})
+
+		It("returns an error", func() {
+			Eventually(process.Wait()).Should(Receive(MatchError("subscribe to neighbors: banana")))
+			process = nil
+		})
+	})
+})
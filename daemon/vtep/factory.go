@@ -38,7 +38,7 @@ func (f *Factory) CreateVTEP(cfg *Config) error {
 			Name: cfg.VTEPName,
 		},
 		VxlanId:      cfg.VNI,
-		SrcAddr:      cfg.UnderlayIP,
+		SrcAddr:      underlaySrcAddr(cfg.UnderlayIP),
 		Port:         cfg.VTEPPort,
 		VtepDevIndex: cfg.UnderlayInterface.Index,
 		GBP:          true,
@@ -72,6 +72,15 @@ func (f *Factory) CreateVTEP(cfg *Config) error {
 	return nil
 }
 
+// underlaySrcAddr returns IPv4 addresses in their 4 byte form, which netlink
+// sends as IFLA_VXLAN_LOCAL, and IPv6 addresses as IFLA_VXLAN_LOCAL6.
+func underlaySrcAddr(underlayIP net.IP) net.IP {
+	if ip := underlayIP.To4(); ip != nil {
+		return ip
+	}
+	return underlayIP.To16()
+}
+
 func (f *Factory) DeleteVTEP(deviceName string) error {
 	link, err := f.NetlinkAdapter.LinkByName(deviceName)
 	if err != nil {
@@ -89,6 +98,8 @@ func (f *Factory) GetVTEPState(vtepName string) (net.HardwareAddr, net.IP, int,
 	if err != nil {
 		return nil, nil, 0, fmt.Errorf("find link: %s", err)
 	}
+	// the overlay is IPv4 whatever the underlay is, and on an IPv6 underlay
+	// the kernel also gives the VTEP a link-local IPv6 address
 	addresses, err := f.NetlinkAdapter.AddrList(link, netlink.FAMILY_V4)
 	if err != nil {
 		return nil, nil, 0, fmt.Errorf("list addresses: %s", err)
@@ -98,3 +109,16 @@ func (f *Factory) GetVTEPState(vtepName string) (net.HardwareAddr, net.IP, int,
 	}
 	return link.Attrs().HardwareAddr, addresses[0].IP, link.Attrs().MTU, nil
 }
+
+// GetVTEPUnderlayIP returns the source address the VTEP encapsulates from.
+func (f *Factory) GetVTEPUnderlayIP(vtepName string) (net.IP, error) {
+	link, err := f.NetlinkAdapter.LinkByName(vtepName)
+	if err != nil {
+		return nil, fmt.Errorf("find link: %s", err)
+	}
+	vxlan, ok := link.(*netlink.Vxlan)
+	if !ok {
+		return nil, fmt.Errorf("not a vxlan device: %s", vtepName)
+	}
+	return vxlan.SrcAddr, nil
+}
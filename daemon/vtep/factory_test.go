@@ -82,6 +82,36 @@ var _ = Describe("Factory", func() {
 			}))
 		})
 
+		Context("when the underlay ip is IPv6", func() {
+			BeforeEach(func() {
+				vtepConfig.UnderlayIP = net.ParseIP("fd00:172:255::2")
+			})
+			It("creates the link with an IPv6 source address and an IPv4 overlay address", func() {
+				err := factory.CreateVTEP(vtepConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				vxlan := fakeNetlinkAdapter.LinkAddArgsForCall(0).(*netlink.Vxlan)
+				Expect(vxlan.SrcAddr).To(Equal(net.ParseIP("fd00:172:255::2")))
+				Expect(vxlan.SrcAddr).To(HaveLen(net.IPv6len))
+
+				_, addr := fakeNetlinkAdapter.AddrAddScopeLinkArgsForCall(0)
+				Expect(addr.IPNet.String()).To(Equal("10.255.32.0/10"))
+			})
+		})
+
+		Context("when the IPv4 underlay ip is in its 16 byte form", func() {
+			BeforeEach(func() {
+				vtepConfig.UnderlayIP = net.ParseIP("172.255.0.0")
+			})
+			It("creates the link with a 4 byte source address", func() {
+				err := factory.CreateVTEP(vtepConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				vxlan := fakeNetlinkAdapter.LinkAddArgsForCall(0).(*netlink.Vxlan)
+				Expect(vxlan.SrcAddr).To(Equal(net.IP{172, 255, 0, 0}))
+			})
+		})
+
 		Context("when adding the link fails", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.LinkAddReturns(errors.New("potato"))
@@ -193,6 +223,41 @@ var _ = Describe("Factory", func() {
 			})
 		})
 	})
+	Describe("GetVTEPUnderlayIP", func() {
+		BeforeEach(func() {
+			fakeNetlinkAdapter.LinkByNameReturns(&netlink.Vxlan{
+				LinkAttrs: netlink.LinkAttrs{Name: "some-device"},
+				SrcAddr:   net.ParseIP("fd00:172:255::2"),
+			}, nil)
+		})
+
+		It("returns the source address of the vxlan device", func() {
+			ip, err := factory.GetVTEPUnderlayIP(vtepConfig.VTEPName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(Equal(net.ParseIP("fd00:172:255::2")))
+			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("some-device"))
+		})
+
+		Context("when finding the link errors", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("potato"))
+			})
+			It("returns an error", func() {
+				_, err := factory.GetVTEPUnderlayIP(vtepConfig.VTEPName)
+				Expect(err).To(MatchError("find link: potato"))
+			})
+		})
+
+		Context("when the link is not a vxlan device", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(&netlink.Dummy{}, nil)
+			})
+			It("returns an error", func() {
+				_, err := factory.GetVTEPUnderlayIP(vtepConfig.VTEPName)
+				Expect(err).To(MatchError("not a vxlan device: some-device"))
+			})
+		})
+	})
 
 	Describe("DeleteVTEP", func() {
 		BeforeEach(func() {
@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/daemon/vtep"
+)
+
+type DriftRepairer struct {
+	RepairDriftStub        func(vtep.Drift) (bool, error)
+	repairDriftMutex       sync.RWMutex
+	repairDriftArgsForCall []struct {
+		arg1 vtep.Drift
+	}
+	repairDriftReturns struct {
+		result1 bool
+		result2 error
+	}
+	repairDriftReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *DriftRepairer) RepairDrift(arg1 vtep.Drift) (bool, error) {
+	fake.repairDriftMutex.Lock()
+	ret, specificReturn := fake.repairDriftReturnsOnCall[len(fake.repairDriftArgsForCall)]
+	fake.repairDriftArgsForCall = append(fake.repairDriftArgsForCall, struct {
+		arg1 vtep.Drift
+	}{arg1})
+	fake.recordInvocation("RepairDrift", []interface{}{arg1})
+	fake.repairDriftMutex.Unlock()
+	if fake.RepairDriftStub != nil {
+		return fake.RepairDriftStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.repairDriftReturns.result1, fake.repairDriftReturns.result2
+}
+
+func (fake *DriftRepairer) RepairDriftCallCount() int {
+	fake.repairDriftMutex.RLock()
+	defer fake.repairDriftMutex.RUnlock()
+	return len(fake.repairDriftArgsForCall)
+}
+
+func (fake *DriftRepairer) RepairDriftArgsForCall(i int) vtep.Drift {
+	fake.repairDriftMutex.RLock()
+	defer fake.repairDriftMutex.RUnlock()
+	return fake.repairDriftArgsForCall[i].arg1
+}
+
+func (fake *DriftRepairer) RepairDriftReturns(result1 bool, result2 error) {
+	fake.RepairDriftStub = nil
+	fake.repairDriftReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DriftRepairer) RepairDriftReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.RepairDriftStub = nil
+	if fake.repairDriftReturnsOnCall == nil {
+		fake.repairDriftReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.repairDriftReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DriftRepairer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.repairDriftMutex.RLock()
+	defer fake.repairDriftMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *DriftRepairer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
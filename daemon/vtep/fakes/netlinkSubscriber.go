@@ -0,0 +1,225 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/silk/lib/adapter"
+	"github.com/vishvananda/netlink"
+)
+
+type NetlinkSubscriber struct {
+	RouteSubscribeStub        func(chan<- netlink.RouteUpdate, <-chan struct{}) error
+	routeSubscribeMutex       sync.RWMutex
+	routeSubscribeArgsForCall []struct {
+		arg1 chan<- netlink.RouteUpdate
+		arg2 <-chan struct{}
+	}
+	routeSubscribeReturns struct {
+		result1 error
+	}
+	routeSubscribeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	NeighSubscribeStub        func(chan<- adapter.NeighUpdate, <-chan struct{}) error
+	neighSubscribeMutex       sync.RWMutex
+	neighSubscribeArgsForCall []struct {
+		arg1 chan<- adapter.NeighUpdate
+		arg2 <-chan struct{}
+	}
+	neighSubscribeReturns struct {
+		result1 error
+	}
+	neighSubscribeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	LinkSubscribeStub        func(chan<- netlink.LinkUpdate, <-chan struct{}) error
+	linkSubscribeMutex       sync.RWMutex
+	linkSubscribeArgsForCall []struct {
+		arg1 chan<- netlink.LinkUpdate
+		arg2 <-chan struct{}
+	}
+	linkSubscribeReturns struct {
+		result1 error
+	}
+	linkSubscribeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *NetlinkSubscriber) RouteSubscribe(arg1 chan<- netlink.RouteUpdate, arg2 <-chan struct{}) error {
+	fake.routeSubscribeMutex.Lock()
+	ret, specificReturn := fake.routeSubscribeReturnsOnCall[len(fake.routeSubscribeArgsForCall)]
+	fake.routeSubscribeArgsForCall = append(fake.routeSubscribeArgsForCall, struct {
+		arg1 chan<- netlink.RouteUpdate
+		arg2 <-chan struct{}
+	}{arg1, arg2})
+	fake.recordInvocation("RouteSubscribe", []interface{}{arg1, arg2})
+	fake.routeSubscribeMutex.Unlock()
+	if fake.RouteSubscribeStub != nil {
+		return fake.RouteSubscribeStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.routeSubscribeReturns.result1
+}
+
+func (fake *NetlinkSubscriber) RouteSubscribeCallCount() int {
+	fake.routeSubscribeMutex.RLock()
+	defer fake.routeSubscribeMutex.RUnlock()
+	return len(fake.routeSubscribeArgsForCall)
+}
+
+func (fake *NetlinkSubscriber) RouteSubscribeArgsForCall(i int) (chan<- netlink.RouteUpdate, <-chan struct{}) {
+	fake.routeSubscribeMutex.RLock()
+	defer fake.routeSubscribeMutex.RUnlock()
+	return fake.routeSubscribeArgsForCall[i].arg1, fake.routeSubscribeArgsForCall[i].arg2
+}
+
+func (fake *NetlinkSubscriber) RouteSubscribeReturns(result1 error) {
+	fake.RouteSubscribeStub = nil
+	fake.routeSubscribeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) RouteSubscribeReturnsOnCall(i int, result1 error) {
+	fake.RouteSubscribeStub = nil
+	if fake.routeSubscribeReturnsOnCall == nil {
+		fake.routeSubscribeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.routeSubscribeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) NeighSubscribe(arg1 chan<- adapter.NeighUpdate, arg2 <-chan struct{}) error {
+	fake.neighSubscribeMutex.Lock()
+	ret, specificReturn := fake.neighSubscribeReturnsOnCall[len(fake.neighSubscribeArgsForCall)]
+	fake.neighSubscribeArgsForCall = append(fake.neighSubscribeArgsForCall, struct {
+		arg1 chan<- adapter.NeighUpdate
+		arg2 <-chan struct{}
+	}{arg1, arg2})
+	fake.recordInvocation("NeighSubscribe", []interface{}{arg1, arg2})
+	fake.neighSubscribeMutex.Unlock()
+	if fake.NeighSubscribeStub != nil {
+		return fake.NeighSubscribeStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.neighSubscribeReturns.result1
+}
+
+func (fake *NetlinkSubscriber) NeighSubscribeCallCount() int {
+	fake.neighSubscribeMutex.RLock()
+	defer fake.neighSubscribeMutex.RUnlock()
+	return len(fake.neighSubscribeArgsForCall)
+}
+
+func (fake *NetlinkSubscriber) NeighSubscribeArgsForCall(i int) (chan<- adapter.NeighUpdate, <-chan struct{}) {
+	fake.neighSubscribeMutex.RLock()
+	defer fake.neighSubscribeMutex.RUnlock()
+	return fake.neighSubscribeArgsForCall[i].arg1, fake.neighSubscribeArgsForCall[i].arg2
+}
+
+func (fake *NetlinkSubscriber) NeighSubscribeReturns(result1 error) {
+	fake.NeighSubscribeStub = nil
+	fake.neighSubscribeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) NeighSubscribeReturnsOnCall(i int, result1 error) {
+	fake.NeighSubscribeStub = nil
+	if fake.neighSubscribeReturnsOnCall == nil {
+		fake.neighSubscribeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.neighSubscribeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) LinkSubscribe(arg1 chan<- netlink.LinkUpdate, arg2 <-chan struct{}) error {
+	fake.linkSubscribeMutex.Lock()
+	ret, specificReturn := fake.linkSubscribeReturnsOnCall[len(fake.linkSubscribeArgsForCall)]
+	fake.linkSubscribeArgsForCall = append(fake.linkSubscribeArgsForCall, struct {
+		arg1 chan<- netlink.LinkUpdate
+		arg2 <-chan struct{}
+	}{arg1, arg2})
+	fake.recordInvocation("LinkSubscribe", []interface{}{arg1, arg2})
+	fake.linkSubscribeMutex.Unlock()
+	if fake.LinkSubscribeStub != nil {
+		return fake.LinkSubscribeStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.linkSubscribeReturns.result1
+}
+
+func (fake *NetlinkSubscriber) LinkSubscribeCallCount() int {
+	fake.linkSubscribeMutex.RLock()
+	defer fake.linkSubscribeMutex.RUnlock()
+	return len(fake.linkSubscribeArgsForCall)
+}
+
+func (fake *NetlinkSubscriber) LinkSubscribeArgsForCall(i int) (chan<- netlink.LinkUpdate, <-chan struct{}) {
+	fake.linkSubscribeMutex.RLock()
+	defer fake.linkSubscribeMutex.RUnlock()
+	return fake.linkSubscribeArgsForCall[i].arg1, fake.linkSubscribeArgsForCall[i].arg2
+}
+
+func (fake *NetlinkSubscriber) LinkSubscribeReturns(result1 error) {
+	fake.LinkSubscribeStub = nil
+	fake.linkSubscribeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) LinkSubscribeReturnsOnCall(i int, result1 error) {
+	fake.LinkSubscribeStub = nil
+	if fake.linkSubscribeReturnsOnCall == nil {
+		fake.linkSubscribeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.linkSubscribeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkSubscriber) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.routeSubscribeMutex.RLock()
+	defer fake.routeSubscribeMutex.RUnlock()
+	fake.neighSubscribeMutex.RLock()
+	defer fake.neighSubscribeMutex.RUnlock()
+	fake.linkSubscribeMutex.RLock()
+	defer fake.linkSubscribeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *NetlinkSubscriber) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,174 @@
+package vtep
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Plan holds the netlink changes that bring the VTEP in line with a set of
+// leases. An update replaces the entry with the same key, the destination
+// of a route, the ip of an ARP entry or the hardware address of an FDB
+// entry, when its other attributes differ.
+type Plan struct {
+	Routes            RouteChanges `json:"routes"`
+	ARP               NeighChanges `json:"arp"`
+	FDB               NeighChanges `json:"fdb"`
+	NonRoutableLeases int          `json:"non_routable_leases"`
+}
+
+type RouteChanges struct {
+	Add    []netlink.Route
+	Update []netlink.Route
+	Delete []netlink.Route
+}
+
+type NeighChanges struct {
+	Add    []netlink.Neigh
+	Update []netlink.Neigh
+	Delete []netlink.Neigh
+}
+
+// Counts returns the number of adds, updates and deletes of the plan.
+func (p Plan) Counts() (int, int, int) {
+	adds := len(p.Routes.Add) + len(p.ARP.Add) + len(p.FDB.Add)
+	updates := len(p.Routes.Update) + len(p.ARP.Update) + len(p.FDB.Update)
+	deletes := len(p.Routes.Delete) + len(p.ARP.Delete) + len(p.FDB.Delete)
+	return adds, updates, deletes
+}
+
+// Only keeps the changes to the entries named by the drift.
+func (p Plan) Only(drift Drift) Plan {
+	if drift.All {
+		return p
+	}
+
+	routeDrifted := func(route netlink.Route) bool {
+		return route.Dst != nil && containsAny(route.Dst, drift.IPs)
+	}
+	arpDrifted := func(neigh netlink.Neigh) bool {
+		return equalsAny(neigh.IP, drift.IPs)
+	}
+	fdbDrifted := func(neigh netlink.Neigh) bool {
+		for _, hardwareAddr := range drift.HardwareAddrs {
+			if bytes.Equal(neigh.HardwareAddr, hardwareAddr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return Plan{
+		Routes: RouteChanges{
+			Add:    filterRoutes(p.Routes.Add, routeDrifted),
+			Update: filterRoutes(p.Routes.Update, routeDrifted),
+			Delete: filterRoutes(p.Routes.Delete, routeDrifted),
+		},
+		ARP: NeighChanges{
+			Add:    filterNeighs(p.ARP.Add, arpDrifted),
+			Update: filterNeighs(p.ARP.Update, arpDrifted),
+			Delete: filterNeighs(p.ARP.Delete, arpDrifted),
+		},
+		FDB: NeighChanges{
+			Add:    filterNeighs(p.FDB.Add, fdbDrifted),
+			Update: filterNeighs(p.FDB.Update, fdbDrifted),
+			Delete: filterNeighs(p.FDB.Delete, fdbDrifted),
+		},
+		NonRoutableLeases: p.NonRoutableLeases,
+	}
+}
+
+func filterRoutes(routes []netlink.Route, keep func(netlink.Route) bool) []netlink.Route {
+	var kept []netlink.Route
+	for _, route := range routes {
+		if keep(route) {
+			kept = append(kept, route)
+		}
+	}
+	return kept
+}
+
+func filterNeighs(neighs []netlink.Neigh, keep func(netlink.Neigh) bool) []netlink.Neigh {
+	var kept []netlink.Neigh
+	for _, neigh := range neighs {
+		if keep(neigh) {
+			kept = append(kept, neigh)
+		}
+	}
+	return kept
+}
+
+func containsAny(ipNet *net.IPNet, ips []net.IP) bool {
+	for _, ip := range ips {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalsAny(ip net.IP, ips []net.IP) bool {
+	for _, other := range ips {
+		if ip.Equal(other) {
+			return true
+		}
+	}
+	return false
+}
+
+type routeEntry struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+	Source      string `json:"source"`
+	Onlink      bool   `json:"onlink,omitempty"`
+}
+
+type neighEntry struct {
+	IP           string `json:"ip"`
+	HardwareAddr string `json:"hardware_addr"`
+}
+
+func (r RouteChanges) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Add    []routeEntry `json:"add"`
+		Update []routeEntry `json:"update"`
+		Delete []routeEntry `json:"delete"`
+	}{routeEntries(r.Add), routeEntries(r.Update), routeEntries(r.Delete)})
+}
+
+func (n NeighChanges) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Add    []neighEntry `json:"add"`
+		Update []neighEntry `json:"update"`
+		Delete []neighEntry `json:"delete"`
+	}{neighEntries(n.Add), neighEntries(n.Update), neighEntries(n.Delete)})
+}
+
+func routeEntries(routes []netlink.Route) []routeEntry {
+	entries := []routeEntry{}
+	for _, route := range routes {
+		entry := routeEntry{
+			Gateway: route.Gw.String(),
+			Source:  route.Src.String(),
+			Onlink:  route.Flags&int(netlink.FLAG_ONLINK) != 0,
+		}
+		if route.Dst != nil {
+			entry.Destination = route.Dst.String()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func neighEntries(neighs []netlink.Neigh) []neighEntry {
+	entries := []neighEntry{}
+	for _, neigh := range neighs {
+		entries = append(entries, neighEntry{
+			IP:           neigh.IP.String(),
+			HardwareAddr: neigh.HardwareAddr.String(),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,93 @@
+package vtep_test
+
+import (
+	"encoding/json"
+	"net"
+
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Plan", func() {
+	var plan vtep.Plan
+
+	BeforeEach(func() {
+		destGW, destNet, _ := net.ParseCIDR("10.255.19.0/24")
+		remoteMac, _ := net.ParseMAC("ee:ee:aa:aa:aa:ff")
+		plan = vtep.Plan{
+			Routes: vtep.RouteChanges{
+				Add: []netlink.Route{{
+					LinkIndex: 42,
+					Dst:       destNet,
+					Gw:        destGW,
+					Src:       net.ParseIP("10.255.32.0").To4(),
+					Flags:     int(netlink.FLAG_ONLINK),
+				}},
+			},
+			ARP: vtep.NeighChanges{
+				Update: []netlink.Neigh{{LinkIndex: 42, IP: net.ParseIP("10.255.19.0"), HardwareAddr: remoteMac}},
+			},
+			FDB: vtep.NeighChanges{
+				Delete: []netlink.Neigh{{LinkIndex: 42, IP: net.ParseIP("10.10.0.5"), HardwareAddr: remoteMac}},
+			},
+			NonRoutableLeases: 2,
+		}
+	})
+
+	It("counts the adds, updates and deletes", func() {
+		adds, updates, deletes := plan.Counts()
+		Expect(adds).To(Equal(1))
+		Expect(updates).To(Equal(1))
+		Expect(deletes).To(Equal(1))
+	})
+
+	Describe("Only", func() {
+		It("keeps the changes to routes containing an ip and ARP entries with an ip", func() {
+			only := plan.Only(vtep.Drift{IPs: []net.IP{net.ParseIP("10.255.19.7")}})
+			Expect(only.Routes).To(Equal(plan.Routes))
+			Expect(only.ARP.Update).To(BeEmpty())
+			Expect(only.FDB.Delete).To(BeEmpty())
+
+			only = plan.Only(vtep.Drift{IPs: []net.IP{net.ParseIP("10.255.19.0")}})
+			Expect(only.ARP).To(Equal(plan.ARP))
+		})
+
+		It("keeps the changes to FDB entries with a hardware address", func() {
+			remoteMac, _ := net.ParseMAC("ee:ee:aa:aa:aa:ff")
+			only := plan.Only(vtep.Drift{HardwareAddrs: []net.HardwareAddr{remoteMac}})
+			Expect(only.FDB).To(Equal(plan.FDB))
+			Expect(only.Routes.Add).To(BeEmpty())
+			Expect(only.ARP.Update).To(BeEmpty())
+			Expect(only.NonRoutableLeases).To(Equal(2))
+		})
+
+		It("keeps every change when everything drifted", func() {
+			Expect(plan.Only(vtep.Drift{All: true})).To(Equal(plan))
+		})
+	})
+
+	It("marshals to readable entries", func() {
+		bytes, err := json.Marshal(plan)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bytes).To(MatchJSON(`{
+			"routes": {
+				"add": [{"destination": "10.255.19.0/24", "gateway": "10.255.19.0", "source": "10.255.32.0", "onlink": true}],
+				"update": [],
+				"delete": []
+			},
+			"arp": {
+				"add": [],
+				"update": [{"ip": "10.255.19.0", "hardware_addr": "ee:ee:aa:aa:aa:ff"}],
+				"delete": []
+			},
+			"fdb": {
+				"add": [],
+				"update": [],
+				"delete": [{"ip": "10.10.0.5", "hardware_addr": "ee:ee:aa:aa:aa:ff"}]
+			},
+			"non_routable_leases": 2
+		}`))
+	})
+})
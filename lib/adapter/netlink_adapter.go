@@ -5,10 +5,18 @@ import (
 	"syscall"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
 )
 
 type NetlinkAdapter struct{}
 
+// NeighUpdate is sent down the NeighSubscribe channel; Type is
+// RTM_NEWNEIGH or RTM_DELNEIGH.
+type NeighUpdate struct {
+	Type uint16
+	netlink.Neigh
+}
+
 func (*NetlinkAdapter) LinkByName(name string) (netlink.Link, error) {
 	return netlink.LinkByName(name)
 }
@@ -115,3 +123,49 @@ func (*NetlinkAdapter) FilterAdd(filter netlink.Filter) error {
 func (*NetlinkAdapter) TickInUsec() float64 {
 	return netlink.TickInUsec()
 }
+
+func (*NetlinkAdapter) RouteSubscribe(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error {
+	return netlink.RouteSubscribe(ch, done)
+}
+
+func (*NetlinkAdapter) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	return netlink.LinkSubscribe(ch, done)
+}
+
+// NeighSubscribe works like RouteSubscribe for the ARP and FDB entries. The
+// vendored netlink has no NeighSubscribe, so it reads the multicast group
+// itself. The channel is closed when the subscription fails or done is
+// closed, also while a send is blocked.
+func (*NetlinkAdapter) NeighSubscribe(ch chan<- NeighUpdate, done <-chan struct{}) error {
+	s, err := nl.Subscribe(syscall.NETLINK_ROUTE, syscall.RTNLGRP_NEIGH)
+	if err != nil {
+		return err
+	}
+	if done != nil {
+		go func() {
+			<-done
+			s.Close()
+		}()
+	}
+	go func() {
+		defer close(ch)
+		for {
+			msgs, err := s.Receive()
+			if err != nil {
+				return
+			}
+			for _, m := range msgs {
+				neigh, err := netlink.NeighDeserialize(m.Data)
+				if err != nil {
+					return
+				}
+				select {
+				case ch <- NeighUpdate{Type: m.Header.Type, Neigh: *neigh}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}